@@ -4,6 +4,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	defaultBackupRepository = ".backups/repository"
+)
+
 // backupCmd represents the backup command
 var backupCmd = &cobra.Command{
 	Use:   "backup",
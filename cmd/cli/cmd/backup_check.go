@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/spf13/cobra"
+)
+
+// backupCheckCmd represents the backup check command
+var backupCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check backup repository consistency",
+	Long: `Check backup repository consistency, verifying that every snapshot data is stored.
+Encrypted repositories are unlocked with the 'backup.encryption' config keys, or the informed identity or passphrase file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackupCheck(context.Background(), backupCheckOpts.repository, encryption.Config{
+			IdentityFile:   backupCheckOpts.identityFile,
+			PassphraseFile: backupCheckOpts.passphraseFile,
+		}, backupCheckOpts.readData)
+	},
+}
+
+var (
+	backupCheckOpts struct {
+		repository     string
+		readData       bool
+		identityFile   string
+		passphraseFile string
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupCheckCmd)
+
+	backupCheckCmd.Flags().StringVar(&backupCheckOpts.repository, "repository", defaultBackupRepository, "Backup repository folder")
+	backupCheckCmd.Flags().BoolVar(&backupCheckOpts.readData, "read-data", false, "Also read stored data to verify its integrity")
+	backupCheckCmd.Flags().StringVar(&backupCheckOpts.identityFile, "identity-file", "", "Unlock an encrypted repository with the age private keys on this file")
+	backupCheckCmd.Flags().StringVar(&backupCheckOpts.passphraseFile, "passphrase-file", "", "Unlock an encrypted repository with the passphrase on this file")
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+// backupDiffCmd represents the backup diff command
+var backupDiffCmd = &cobra.Command{
+	Use:   "diff <backup-file> [<other-backup-file>]",
+	Short: "Compare backups",
+	Long: `Compare the files of two backup files, or of a backup file and the instance folder (--live).
+Files are compared through the backup files checksums, added, removed and changed files are
+grouped by world dimension, region and config. Use --text-diff to show what changed on text
+config files (like server.properties, ops.json or whitelist.json).`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if backupDiffOpts.live == (len(args) == 2) {
+			return errors.New("inform another backup file or --live")
+		}
+		to := backupDiffOpts.instanceFolder
+		if len(args) == 2 {
+			to = args[1]
+		}
+		return runBackupDiff(context.Background(), args[0], to, backupDiffOpts)
+	},
+}
+
+var (
+	backupDiffOpts struct {
+		live           bool
+		instanceFolder string
+		textDiff       bool
+		identityFile   string
+		passphraseFile string
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupDiffCmd)
+
+	backupDiffCmd.Flags().BoolVar(&backupDiffOpts.live, "live", false, "Compare the backup file with the instance folder")
+	backupDiffCmd.Flags().StringVar(&backupDiffOpts.instanceFolder, "instance-folder", ".", "Installation root directory compared with --live (defaults to current directory)")
+	backupDiffCmd.Flags().BoolVar(&backupDiffOpts.textDiff, "text-diff", false, "Show unified diffs of the changed text config files")
+	backupDiffCmd.Flags().StringVar(&backupDiffOpts.identityFile, "identity-file", "", "Decrypt the backup files with the age private keys on this file")
+	backupDiffCmd.Flags().StringVar(&backupDiffOpts.passphraseFile, "passphrase-file", "", "Decrypt the backup files with the passphrase on this file")
+}
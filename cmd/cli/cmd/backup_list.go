@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// backupListCmd represents the backup list command
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups",
+	Long: `List the backups catalog (or the backup files on --backup-folder), older first.
+Backups can be filtered by instance (name or ID) and creation date ('2006-01-02' or RFC 3339 times).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackupList(context.Background(), backupListOpts)
+	},
+}
+
+var (
+	backupListOpts struct {
+		destFolder string
+		instance   string
+		since      string
+		until      string
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupListCmd)
+
+	backupListCmd.Flags().StringVar(&backupListOpts.destFolder, "backup-folder", "", "Backup files folder or remote destination URL (defaults to the backups catalog)")
+	backupListCmd.Flags().StringVarP(&backupListOpts.instance, "instance", "i", "", "Only list this instance backups (name or ID)")
+	backupListCmd.Flags().StringVar(&backupListOpts.since, "since", "", "Only list backups created on or after this date")
+	backupListCmd.Flags().StringVar(&backupListOpts.until, "until", "", "Only list backups created on or before this date")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+// backupPruneCmd represents the backup prune command
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old backups",
+	Long: `Remove the backups not kept by the retention rules (applied to each instance apart).
+A backup is kept when any rule keeps it, use --dry-run to see which rule kept each backup.
+Encrypted repositories are unlocked with the 'backup.encryption' config keys, or the informed identity or passphrase file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy, err := backupPruneOpts.Policy()
+		if err != nil {
+			return err
+		}
+		if policy.Empty() {
+			return errors.New("inform at least one retention rule")
+		}
+		return runBackupPrune(context.Background(), backupPruneOpts.destFolder, backupPruneOpts.incremental, backupPruneOpts.repository, encryption.Config{
+			IdentityFile:   backupPruneOpts.identityFile,
+			PassphraseFile: backupPruneOpts.passphraseFile,
+		}, policy, backupPruneOpts.dryRun)
+	},
+}
+
+type backupPruneCmdOpts struct {
+	destFolder     string
+	incremental    bool
+	repository     string
+	identityFile   string
+	passphraseFile string
+	keepLast       int
+	keepHourly     int
+	keepDaily      int
+	keepWeekly     int
+	keepMonthly    int
+	keepYearly     int
+	keepWithin     time.Duration
+	maxTotalSize   string
+	dryRun         bool
+}
+
+func (o backupPruneCmdOpts) Policy() (retention.Policy, error) {
+	return retentionPolicy(cfg.Retention{
+		KeepLast:     o.keepLast,
+		KeepHourly:   o.keepHourly,
+		KeepDaily:    o.keepDaily,
+		KeepWeekly:   o.keepWeekly,
+		KeepMonthly:  o.keepMonthly,
+		KeepYearly:   o.keepYearly,
+		KeepWithin:   o.keepWithin,
+		MaxTotalSize: o.maxTotalSize,
+	})
+}
+
+var (
+	backupPruneOpts backupPruneCmdOpts
+)
+
+func init() {
+	backupCmd.AddCommand(backupPruneCmd)
+
+	backupPruneCmd.Flags().StringVar(&backupPruneOpts.destFolder, "backup-folder", ".backups", "Backup files folder or remote destination URL (defaults to .backups on current directory)")
+	backupPruneCmd.Flags().BoolVar(&backupPruneOpts.incremental, "incremental", false, "Prune backup repository snapshots instead of backup files")
+	backupPruneCmd.Flags().StringVar(&backupPruneOpts.repository, "repository", "", "Backup repository folder used with --incremental (defaults to 'repository' inside backup folder)")
+	backupPruneCmd.Flags().StringVar(&backupPruneOpts.identityFile, "identity-file", "", "Unlock an encrypted repository with the age private keys on this file")
+	backupPruneCmd.Flags().StringVar(&backupPruneOpts.passphraseFile, "passphrase-file", "", "Unlock an encrypted repository with the passphrase on this file")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepLast, "keep-last", 0, "Keep the n most recent backups")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepHourly, "keep-hourly", 0, "Keep the most recent backup of each of the last n hours")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepDaily, "keep-daily", 0, "Keep the most recent backup of each of the last n days")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepWeekly, "keep-weekly", 0, "Keep the most recent backup of each of the last n weeks")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepMonthly, "keep-monthly", 0, "Keep the most recent backup of each of the last n months")
+	backupPruneCmd.Flags().IntVar(&backupPruneOpts.keepYearly, "keep-yearly", 0, "Keep the most recent backup of each of the last n years")
+	backupPruneCmd.Flags().DurationVar(&backupPruneOpts.keepWithin, "keep-within", 0, "Keep every backup newer than this duration (e.g. 48h)")
+	backupPruneCmd.Flags().StringVar(&backupPruneOpts.maxTotalSize, "max-total-size", "", "Remove older backups while each instance backup files are bigger than this size (e.g. 20GiB)")
+	backupPruneCmd.Flags().BoolVar(&backupPruneOpts.dryRun, "dry-run", false, "Only show which backups would be kept or removed and why")
+}
@@ -11,8 +11,21 @@ import (
 var backupRestoreCmd = &cobra.Command{
 	Use:   "restore",
 	Short: "Restore instance backup",
-	Long:  `Restore instance backup.`,
+	Long: `Restore instance backup.
+Backup files on remote destinations (e.g. 'sftp://user@host/backups/my-server_2024-12-31_12-00-00_backup.zip')
+are downloaded to a temporary folder first. Encrypted backup files are detected and
+decrypted with the 'backup.encryption' config keys, or the informed identity or passphrase file.
+Backup files are extracted to a staging folder first, then each restored path replaces the
+instance one, the replaced files are kept on the '<instance-folder>.rollback' folder. Restore
+only some paths with --only (e.g. '--only world/ --only server.properties'), and check what
+would be restored with --dry-run. Instances with a running server can't be restored.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if backupRestoreOpts.from != "" {
+			backupRestoreOpts.fromFile = backupRestoreOpts.from
+		}
+		if backupRestoreOpts.snapshot != "" {
+			return runBackupRestoreSnapshot(context.Background(), backupRestoreOpts)
+		}
 		if backupRestoreOpts.fromFile == "" {
 			return errors.New("invalid input file")
 		}
@@ -22,8 +35,15 @@ var backupRestoreCmd = &cobra.Command{
 
 var (
 	backupRestoreOpts struct {
-		fromFile string
-		toFolder string
+		fromFile       string
+		toFolder       string
+		snapshot       string
+		repository     string
+		from           string
+		identityFile   string
+		passphraseFile string
+		only           []string
+		dryRun         bool
 	}
 )
 
@@ -31,5 +51,12 @@ func init() {
 	backupCmd.AddCommand(backupRestoreCmd)
 
 	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.fromFile, "backup-file", "", "Backup file to be restored")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.from, "from", "", "Backup file to be restored, a local path or a remote destination URL")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.identityFile, "identity-file", "", "Decrypt the backup file with the age private keys on this file")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.passphraseFile, "passphrase-file", "", "Decrypt the backup file with the passphrase on this file")
 	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.toFolder, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+	backupRestoreCmd.Flags().StringSliceVar(&backupRestoreOpts.only, "only", nil, "Only restore this file or folder, relative to the instance folder (can be repeated)")
+	backupRestoreCmd.Flags().BoolVar(&backupRestoreOpts.dryRun, "dry-run", false, "Only show what would be restored")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.snapshot, "snapshot", "", "Backup repository snapshot to be restored (an ID prefix or 'latest')")
+	backupRestoreCmd.Flags().StringVar(&backupRestoreOpts.repository, "repository", defaultBackupRepository, "Backup repository folder")
 }
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+// backupRestoreRegionCmd represents the backup restore-region command
+var backupRestoreRegionCmd = &cobra.Command{
+	Use:   "restore-region",
+	Short: "Restore a world area from a backup",
+	Long: `Restore a world area from a backup, leaving the rest of the world untouched.
+The area blocks (from --from-block to --to-block, as 'x,z') are mapped to the dimension
+region files (blocks, entities and points of interest), which replace the instance ones.
+Use --chunks to restore only the area chunks instead of the whole region files (chunks
+missing from the backup are removed, so they're generated again). Replaced region files
+are kept on the '<instance-folder>.rollback' folder. Instances with a running server can't
+be restored.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if backupRestoreRegionOpts.fromFile == "" {
+			return errors.New("invalid input file")
+		}
+		return runBackupRestoreRegion(context.Background(), backupRestoreRegionOpts)
+	},
+}
+
+var (
+	backupRestoreRegionOpts struct {
+		fromFile       string
+		toFolder       string
+		identityFile   string
+		passphraseFile string
+		dimension      string
+		fromBlock      string
+		toBlock        string
+		chunks         bool
+		dryRun         bool
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupRestoreRegionCmd)
+
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.fromFile, "backup-file", "", "Backup file to be restored, a local path or a remote destination URL")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.toFolder, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.identityFile, "identity-file", "", "Decrypt the backup file with the age private keys on this file")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.passphraseFile, "passphrase-file", "", "Decrypt the backup file with the passphrase on this file")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.dimension, "dimension", "overworld", "World dimension: overworld, nether or end")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.fromBlock, "from-block", "", "Area corner block coordinates, as 'x,z'")
+	backupRestoreRegionCmd.Flags().StringVar(&backupRestoreRegionOpts.toBlock, "to-block", "", "Area opposite corner block coordinates, as 'x,z' (defaults to --from-block)")
+	backupRestoreRegionCmd.Flags().BoolVar(&backupRestoreRegionOpts.chunks, "chunks", false, "Only restore the area chunks instead of the whole region files")
+	backupRestoreRegionCmd.Flags().BoolVar(&backupRestoreRegionOpts.dryRun, "dry-run", false, "Only show what would be restored")
+	_ = backupRestoreRegionCmd.MarkFlagRequired("from-block")
+}
@@ -2,16 +2,84 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/logger"
 	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/eldius/mineserver-manager/internal/snapshot"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func runBackupSave(ctx context.Context, opts struct {
-	instance       string
-	destFolder     string
-	maxBackupFiles int
+	instance         string
+	destFolder       string
+	maxBackupFiles   int
+	incremental      bool
+	repository       string
+	to               string
+	encrypt          bool
+	recipients       []string
+	recipientsFile   string
+	passphraseFile   string
+	format           string
+	compressionLevel int
+	excludes         []string
+	includes         []string
+	worldOnly        bool
 }) error {
-	s := minecraft.NewBackupService()
+	svcOpts, err := backupFilesOpts(opts.format, opts.compressionLevel, opts.excludes, opts.includes, opts.worldOnly)
+	if err != nil {
+		return err
+	}
+	if opts.encrypt {
+		keys, err := backupKeys(encryption.Config{
+			Recipients:     opts.recipients,
+			RecipientsFile: opts.recipientsFile,
+			PassphraseFile: opts.passphraseFile,
+		})
+		if err != nil {
+			return err
+		}
+		if !keys.CanEncrypt() {
+			return encryption.ErrNoEncryptionKeys
+		}
+		svcOpts = append(svcOpts, minecraft.WithEncryption(keys))
+	}
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	var packed utils.PackProgress
+	svcOpts = append(svcOpts, minecraft.WithBackupHooks(runner), minecraft.WithPackProgress(func(p utils.PackProgress) {
+		packed = p
+	}))
+	if repo, closeRepo := backupCatalog(ctx); repo != nil {
+		defer closeRepo()
+		svcOpts = append(svcOpts, minecraft.WithBackupRepository(repo))
+	}
+	s := minecraft.NewBackupService(svcOpts...)
+	if opts.incremental {
+		repository, err := backupRepository(opts.destFolder, opts.repository)
+		if err != nil {
+			return err
+		}
+		snap, err := s.Snapshot(ctx, opts.instance, repository)
+		if err != nil {
+			return fmt.Errorf("failed to make a backup: %w", err)
+		}
+		fmt.Printf("Snapshot %s saved to '%s' (%s, %s added)!\n", snap.ShortID(), repository, retention.FormatSize(snap.Size), retention.FormatSize(snap.Added))
+		return nil
+	}
+
 	backupFile, err := s.Backup(ctx, opts.instance, opts.destFolder)
 	if err != nil {
 		return fmt.Errorf("failed to make a backup: %w", err)
@@ -22,13 +90,550 @@ func runBackupSave(ctx context.Context, opts struct {
 			return fmt.Errorf("failed to make a backup rollover: %w", err)
 		}
 	}
-	fmt.Printf("Backup completed to '%s'!\n", backupFile.Path)
+	fmt.Printf("Backup completed to '%s' (%d files, %s in %s, %s/s)!\n",
+		backupFile.Path,
+		packed.Files,
+		retention.FormatSize(packed.Bytes),
+		packed.Elapsed.Round(time.Millisecond),
+		retention.FormatSize(int64(packed.Throughput())),
+	)
 	return nil
 }
 
+func runBackupList(ctx context.Context, opts struct {
+	destFolder string
+	instance   string
+	since      string
+	until      string
+}) error {
+	filter := minecraft.BackupFilter{Instance: opts.instance}
+	var err error
+	if filter.Since, err = parseBackupDate(opts.since, false); err != nil {
+		return fmt.Errorf("parsing --since: %w", err)
+	}
+	if filter.Until, err = parseBackupDate(opts.until, true); err != nil {
+		return fmt.Errorf("parsing --until: %w", err)
+	}
+
+	var svcOpts []minecraft.BackupServiceOpt
+	if opts.destFolder == "" {
+		repo, closeRepo := backupCatalog(ctx)
+		if repo == nil {
+			return fmt.Errorf("listing backups: %w", minecraft.ErrNoBackupCatalog)
+		}
+		defer closeRepo()
+		svcOpts = append(svcOpts, minecraft.WithBackupRepository(repo))
+	}
+	backups, err := minecraft.NewBackupService(svcOpts...).ListBackups(ctx, opts.destFolder, filter)
+	if err != nil {
+		return fmt.Errorf("listing backups: %w", err)
+	}
+	fmt.Printf("Backups (%d):\n", len(backups))
+	for _, b := range backups {
+		if b.Metadata == nil {
+			fmt.Printf("- %s %s %s %s\n", b.Timestamp.Format(bkpDisplayTimeFormat), b.Name, retention.FormatSize(b.Size), b.Path)
+			continue
+		}
+		m := b.Metadata
+		fmt.Printf("- %s %s %s %d files %s %s\n", b.Timestamp.Format(bkpDisplayTimeFormat), b.Name, m.Trigger, m.Files, retention.FormatSize(b.Size), b.Path)
+		if m.MineVersion != "" {
+			fmt.Printf("  server: %s %s, took %s\n", m.MineFlavour, m.MineVersion, m.Duration.Round(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// parseBackupDate parses a date ('2006-01-02') or a time (RFC 3339)
+// filter, dates are the whole day (so 'until' dates end at midnight)
+func parseBackupDate(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s' (use '2006-01-02' or RFC 3339 times)", s)
+	}
+	return t, nil
+}
+
 func runBackupRestore(ctx context.Context, opts struct {
-	fromFile string
-	toFolder string
+	fromFile       string
+	toFolder       string
+	snapshot       string
+	repository     string
+	from           string
+	identityFile   string
+	passphraseFile string
+	only           []string
+	dryRun         bool
+}) error {
+	keys, err := backupKeys(encryption.Config{IdentityFile: opts.identityFile, PassphraseFile: opts.passphraseFile})
+	if err != nil {
+		return err
+	}
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	result, err := minecraft.NewBackupService(minecraft.WithEncryption(keys), minecraft.WithBackupHooks(runner)).Restore(ctx, opts.toFolder, opts.fromFile, minecraft.RestoreOpts{
+		Only:   opts.only,
+		DryRun: opts.dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	if opts.dryRun {
+		fmt.Printf("Would restore %d files to '%s':\n", len(result.Files), opts.toFolder)
+		for _, f := range result.Files {
+			fmt.Printf("  %s\n", f)
+		}
+		for _, r := range result.Replaced {
+			fmt.Printf("Would replace '%s' (kept on '%s')\n", r, result.Rollback)
+		}
+		return nil
+	}
+	fmt.Printf("Restored %d files to '%s'!\n", len(result.Files), opts.toFolder)
+	if result.Rollback != "" {
+		fmt.Printf("Replaced files are kept on '%s'\n", result.Rollback)
+	}
+	return nil
+}
+
+func runBackupRestoreRegion(ctx context.Context, opts struct {
+	fromFile       string
+	toFolder       string
+	identityFile   string
+	passphraseFile string
+	dimension      string
+	fromBlock      string
+	toBlock        string
+	chunks         bool
+	dryRun         bool
+}) error {
+	dim, err := minecraft.ParseDimension(opts.dimension)
+	if err != nil {
+		return err
+	}
+	if opts.toBlock == "" {
+		opts.toBlock = opts.fromBlock
+	}
+	var area minecraft.BlockArea
+	if area.FromX, area.FromZ, err = parseBlockPos(opts.fromBlock); err != nil {
+		return fmt.Errorf("parsing --from-block: %w", err)
+	}
+	if area.ToX, area.ToZ, err = parseBlockPos(opts.toBlock); err != nil {
+		return fmt.Errorf("parsing --to-block: %w", err)
+	}
+	keys, err := backupKeys(encryption.Config{IdentityFile: opts.identityFile, PassphraseFile: opts.passphraseFile})
+	if err != nil {
+		return err
+	}
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	result, err := minecraft.NewBackupService(minecraft.WithEncryption(keys), minecraft.WithBackupHooks(runner)).RestoreRegion(ctx, opts.toFolder, opts.fromFile, minecraft.RegionRestoreOpts{
+		Dimension: dim,
+		Area:      area,
+		Chunks:    opts.chunks,
+		DryRun:    opts.dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore region: %w", err)
+	}
+
+	action := "Restored"
+	if opts.dryRun {
+		action = "Would restore"
+	}
+	if opts.chunks {
+		fmt.Printf("%s %d chunks on %d region files to '%s':\n", action, result.Chunks, len(result.Files), opts.toFolder)
+	} else {
+		fmt.Printf("%s %d region files to '%s':\n", action, len(result.Files), opts.toFolder)
+	}
+	for _, f := range result.Files {
+		fmt.Printf("  %s\n", f)
+	}
+	if result.Rollback != "" {
+		fmt.Printf("Replaced files are kept on '%s'\n", result.Rollback)
+	}
+	return nil
+}
+
+// parseBlockPos parses block coordinates like '-120,64'
+func parseBlockPos(s string) (int, int, error) {
+	xs, zs, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid block coordinates '%s' (use 'x,z')", s)
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(xs))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid block x coordinate '%s'", xs)
+	}
+	z, err := strconv.Atoi(strings.TrimSpace(zs))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid block z coordinate '%s'", zs)
+	}
+	return x, z, nil
+}
+
+func runBackupRestoreSnapshot(ctx context.Context, opts struct {
+	fromFile       string
+	toFolder       string
+	snapshot       string
+	repository     string
+	from           string
+	identityFile   string
+	passphraseFile string
+	only           []string
+	dryRun         bool
+}) error {
+	if len(opts.only) > 0 || opts.dryRun {
+		return errors.New("--only and --dry-run are only supported by backup files")
+	}
+	keys, err := backupKeys(encryption.Config{IdentityFile: opts.identityFile, PassphraseFile: opts.passphraseFile})
+	if err != nil {
+		return err
+	}
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	snap, err := minecraft.NewBackupService(minecraft.WithEncryption(keys), minecraft.WithBackupHooks(runner)).RestoreSnapshot(ctx, opts.toFolder, opts.repository, opts.snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	fmt.Printf("Snapshot %s (%s from %s) restored to '%s'!\n", snap.ShortID(), snap.Name, snap.Time.Format(bkpDisplayTimeFormat), opts.toFolder)
+	return nil
+}
+
+func runBackupVerify(ctx context.Context, files []string, opts struct {
+	all            bool
+	destFolder     string
+	testRestore    bool
+	identityFile   string
+	passphraseFile string
+}) error {
+	keys, err := backupKeys(encryption.Config{IdentityFile: opts.identityFile, PassphraseFile: opts.passphraseFile})
+	if err != nil {
+		return err
+	}
+	s := minecraft.NewBackupService(minecraft.WithEncryption(keys))
+	if opts.all {
+		backups, err := s.ListBackups(ctx, opts.destFolder, minecraft.BackupFilter{})
+		if err != nil {
+			return fmt.Errorf("listing backup files: %w", err)
+		}
+		for _, b := range backups {
+			files = append(files, b.Path)
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("no backup files found")
+	}
+
+	var failed int
+	for _, f := range files {
+		report, err := s.Verify(ctx, f, opts.testRestore)
+		if err != nil {
+			return err
+		}
+		if report.OK() {
+			fmt.Printf("- %s: OK (%d files)\n", f, report.Entries)
+			continue
+		}
+		failed++
+		fmt.Printf("- %s: FAILED\n", f)
+		printProblems("missing", report.Missing)
+		printProblems("extra", report.Extra)
+		printProblems("corrupt", report.Corrupt)
+		printProblems("error", report.Errors)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d backup files failed verification", failed, len(files))
+	}
+	return nil
+}
+
+func runBackupDiff(ctx context.Context, from, to string, opts struct {
+	live           bool
+	instanceFolder string
+	textDiff       bool
+	identityFile   string
+	passphraseFile string
 }) error {
-	return minecraft.NewBackupService().Restore(ctx, opts.toFolder, opts.fromFile)
+	keys, err := backupKeys(encryption.Config{IdentityFile: opts.identityFile, PassphraseFile: opts.passphraseFile})
+	if err != nil {
+		return err
+	}
+	diff, err := minecraft.NewBackupService(minecraft.WithEncryption(keys)).Diff(ctx, from, to, minecraft.DiffOpts{
+		Live:      opts.live,
+		TextDiffs: opts.textDiff,
+	})
+	if err != nil {
+		return fmt.Errorf("comparing backups: %w", err)
+	}
+
+	fmt.Printf("Comparing '%s' to '%s': %d added, %d removed, %d changed (%d unchanged)\n",
+		diff.From,
+		diff.To,
+		diff.Count(minecraft.ChangeAdded),
+		diff.Count(minecraft.ChangeRemoved),
+		diff.Count(minecraft.ChangeChanged),
+		diff.Unchanged,
+	)
+	symbols := map[minecraft.Change]string{
+		minecraft.ChangeAdded:   "+",
+		minecraft.ChangeRemoved: "-",
+		minecraft.ChangeChanged: "~",
+	}
+	var group string
+	for _, f := range diff.Files {
+		if f.Group != group {
+			group = f.Group
+			fmt.Printf("%s:\n", group)
+		}
+		fmt.Printf("  %s %s\n", symbols[f.Change], f.Path)
+		for _, l := range strings.Split(strings.TrimSuffix(f.Diff, "\n"), "\n") {
+			if l != "" {
+				fmt.Printf("    %s\n", l)
+			}
+		}
+	}
+	return nil
+}
+
+func printProblems(kind string, problems []string) {
+	for _, p := range problems {
+		fmt.Printf("  %s: %s\n", kind, p)
+	}
+}
+
+func runBackupSnapshots(_ context.Context, repository string, keys encryption.Config) error {
+	repo, err := openSnapshotRepository(repository, keys)
+	if err != nil {
+		return err
+	}
+	snaps, err := repo.Snapshots()
+	if err != nil {
+		return fmt.Errorf("listing snapshots: %w", err)
+	}
+	fmt.Printf("Snapshots (%d):\n", len(snaps))
+	for _, s := range snaps {
+		fmt.Printf("- %s %s %s (%s, %s added)\n", s.ShortID(), s.Time.Format(bkpDisplayTimeFormat), s.Name, retention.FormatSize(s.Size), retention.FormatSize(s.Added))
+	}
+	return nil
+}
+
+func runBackupPrune(ctx context.Context, destFolder string, incremental bool, repository string, keys encryption.Config, policy retention.Policy, dryRun bool) error {
+	if !incremental {
+		var svcOpts []minecraft.BackupServiceOpt
+		if repo, closeRepo := backupCatalog(ctx); repo != nil {
+			defer closeRepo()
+			svcOpts = append(svcOpts, minecraft.WithBackupRepository(repo))
+		}
+		decisions, err := minecraft.NewBackupService(svcOpts...).ApplyRetention(ctx, destFolder, policy, dryRun)
+		if err != nil {
+			return fmt.Errorf("pruning backup files: %w", err)
+		}
+		printRetention(decisions, dryRun)
+		return nil
+	}
+
+	repository, err := backupRepository(destFolder, repository)
+	if err != nil {
+		return err
+	}
+	repo, err := openSnapshotRepository(repository, keys)
+	if err != nil {
+		return err
+	}
+	result, err := repo.Prune(ctx, policy, dryRun)
+	if err != nil {
+		return fmt.Errorf("pruning backup repository: %w", err)
+	}
+	for i, d := range result.Decisions {
+		// snapshots are shown by their short ID
+		result.Decisions[i].Item.ID = d.Item.ID[:min(len(d.Item.ID), snapshot.ShortIDLength)]
+	}
+	printRetention(result.Decisions, dryRun)
+	if !dryRun {
+		fmt.Printf("Removed %d unused chunks (%s)\n", result.Chunks, retention.FormatSize(result.Bytes))
+	}
+	return nil
+}
+
+// openSnapshotRepository opens a backup repository, unlocking encrypted
+// ones with the config keys or the informed identity or passphrase file.
+func openSnapshotRepository(repository string, informed encryption.Config) (*snapshot.Repository, error) {
+	keys, err := backupKeys(informed)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := snapshot.Open(repository, snapshot.WithEncryption(keys))
+	if err != nil {
+		return nil, fmt.Errorf("opening backup repository: %w", err)
+	}
+	return repo, nil
+}
+
+// backupKeys loads the backup encryption keys from config. Informed
+// recipients or passphrase replace the configured ones.
+func backupKeys(informed encryption.Config) (*encryption.Keys, error) {
+	c, err := cfg.GetBackupEncryption()
+	if err != nil {
+		return nil, err
+	}
+	keys := encryption.Config{
+		Recipients:     c.Recipients,
+		RecipientsFile: c.RecipientsFile,
+		IdentityFile:   c.IdentityFile,
+		Passphrase:     c.Passphrase,
+		PassphraseFile: c.PassphraseFile,
+	}
+	if len(informed.Recipients) > 0 || informed.RecipientsFile != "" || informed.PassphraseFile != "" {
+		keys.Recipients = informed.Recipients
+		keys.RecipientsFile = informed.RecipientsFile
+		keys.Passphrase = ""
+		keys.PassphraseFile = informed.PassphraseFile
+	}
+	if informed.IdentityFile != "" {
+		keys.IdentityFile = informed.IdentityFile
+	}
+	k, err := encryption.NewKeys(keys)
+	if err != nil {
+		return nil, fmt.Errorf("loading backup encryption keys: %w", err)
+	}
+	return k, nil
+}
+
+// backupFilesOpts returns the options telling how instance files are
+// packed, merging the informed values with the 'backup' config ones
+func backupFilesOpts(format string, level int, excludes, includes []string, worldOnly bool) ([]minecraft.BackupServiceOpt, error) {
+	if format == "" {
+		format = cfg.GetBackupFormat()
+	}
+	if level == 0 {
+		level = cfg.GetBackupCompressionLevel()
+	}
+	f := utils.FormatZip
+	if format != "" {
+		var err error
+		f, err = utils.ParseArchiveFormat(format)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []minecraft.BackupServiceOpt{
+		minecraft.WithArchiveFormat(f, level),
+		minecraft.WithExcludes(append(cfg.GetBackupExcludes(), excludes...)...),
+		minecraft.WithIncludes(append(cfg.GetBackupIncludes(), includes...)...),
+		minecraft.WithWorldOnly(worldOnly),
+	}, nil
+}
+
+// backupCatalog opens the instances database keeping the backups
+// catalog. It's optional for making backups (backup metadata files
+// are enough), so failing to open it returns a nil repository.
+func backupCatalog(ctx context.Context) (repository.Repository, func()) {
+	log := logger.GetLogger()
+	repo, err := openInstancesDatabase()
+	if err != nil {
+		log.With("error", err).WarnContext(ctx, "opening backups catalog")
+		return nil, func() {}
+	}
+	return repo, func() {
+		_ = repo.Close()
+	}
+}
+
+// backupRepository returns the backup repository folder, by
+// default the 'repository' folder inside the backup folder
+func backupRepository(destFolder, repository string) (string, error) {
+	if repository != "" {
+		return repository, nil
+	}
+	if destination.IsRemote(destFolder) {
+		return "", minecraft.ErrRemoteRepository
+	}
+	return filepath.Join(destFolder, "repository"), nil
+}
+
+func printRetention(decisions []retention.Decision, dryRun bool) {
+	var removed int
+	for _, d := range decisions {
+		action := "keep"
+		if !d.Keep {
+			action = "remove"
+			removed++
+		}
+		fmt.Printf("- %-6s %s %s %s", action, d.Item.Time.Format(bkpDisplayTimeFormat), d.Item.Group, d.Item.ID)
+		if len(d.Reasons) > 0 {
+			fmt.Printf(" (%s)", strings.Join(d.Reasons, ", "))
+		}
+		fmt.Println()
+	}
+	if dryRun {
+		fmt.Printf("%d of %d backups would be removed (dry run)\n", removed, len(decisions))
+		return
+	}
+	fmt.Printf("Removed %d of %d backups\n", removed, len(decisions))
+}
+
+func runBackupCheck(ctx context.Context, repository string, keys encryption.Config, readData bool) error {
+	repo, err := openSnapshotRepository(repository, keys)
+	if err != nil {
+		return err
+	}
+	result, err := repo.Check(ctx, readData)
+	if err != nil {
+		return fmt.Errorf("checking backup repository: %w", err)
+	}
+	fmt.Printf("Checked %d snapshots and %d chunks (%d unused)\n", result.Snapshots, result.Chunks, result.Unreferenced)
+	for _, e := range result.Errors {
+		fmt.Printf("- %s\n", e)
+	}
+	if !result.OK() {
+		return errors.New("backup repository has errors")
+	}
+	fmt.Println("No errors found")
+	return nil
+}
+
+func retentionPolicy(r cfg.Retention) (retention.Policy, error) {
+	p := retention.Policy{
+		KeepLast:    r.KeepLast,
+		KeepHourly:  r.KeepHourly,
+		KeepDaily:   r.KeepDaily,
+		KeepWeekly:  r.KeepWeekly,
+		KeepMonthly: r.KeepMonthly,
+		KeepYearly:  r.KeepYearly,
+		KeepWithin:  r.KeepWithin,
+	}
+	if r.MaxTotalSize != "" {
+		size, err := retention.ParseSize(r.MaxTotalSize)
+		if err != nil {
+			return p, err
+		}
+		p.MaxTotalSize = size
+	}
+	return p, nil
+}
+
+const (
+	bkpDisplayTimeFormat = "2006-01-02 15:04:05"
+)
+
+func displayTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format(bkpDisplayTimeFormat)
 }
@@ -9,17 +9,45 @@ import (
 var backupSaveCmd = &cobra.Command{
 	Use:   "save",
 	Short: "Save a backup from instance",
-	Long:  `Save a backup from instance.`,
+	Long: `Save a backup from instance.
+Backups can be sent to a remote destination with --to, like 'sftp://user@host/backups'
+or 's3://bucket/prefix' (see the 'endpoint', 'region' and 'path-style' URL parameters
+for other S3 compatible services).
+Backups are encrypted with --encrypt using the 'backup.encryption' config keys, or the
+informed recipients or passphrase file. With --incremental a new repository is encrypted
+and its snapshots stay encrypted (saving them needs the identity or passphrase).
+Files are left out with gitignore-style --exclude patterns (added to the 'backup.exclude'
+config ones and to the instance '.mineserverignore' file ones) and included again with
+--include. Logs, crash reports and the folders restored by the installer are left out
+by default, --world-only only keeps the world folders.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if backupSaveOpts.to != "" {
+			backupSaveOpts.destFolder = backupSaveOpts.to
+		}
+		if len(backupSaveOpts.recipients) > 0 || backupSaveOpts.recipientsFile != "" || backupSaveOpts.passphraseFile != "" {
+			backupSaveOpts.encrypt = true
+		}
 		return runBackupSave(context.Background(), backupSaveOpts)
 	},
 }
 
 var (
 	backupSaveOpts struct {
-		instance       string
-		destFolder     string
-		maxBackupFiles int
+		instance         string
+		destFolder       string
+		maxBackupFiles   int
+		incremental      bool
+		repository       string
+		to               string
+		encrypt          bool
+		recipients       []string
+		recipientsFile   string
+		passphraseFile   string
+		format           string
+		compressionLevel int
+		excludes         []string
+		includes         []string
+		worldOnly        bool
 	}
 )
 
@@ -28,5 +56,17 @@ func init() {
 
 	backupSaveCmd.Flags().StringVar(&backupSaveOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
 	backupSaveCmd.Flags().StringVar(&backupSaveOpts.destFolder, "backup-folder", ".backups", "Backup file destination folder (defaults to .backups on current directory)")
+	backupSaveCmd.Flags().StringVar(&backupSaveOpts.to, "to", "", "Backup file destination, a folder or a remote destination URL (sftp://user@host/path or s3://bucket/prefix)")
 	backupSaveCmd.Flags().IntVar(&backupSaveOpts.maxBackupFiles, "max-backup-files", 0, "Max number of backup files to be stored (defaults to 0 - disabled)")
+	backupSaveCmd.Flags().BoolVar(&backupSaveOpts.incremental, "incremental", false, "Save an incremental snapshot to a deduplicated backup repository instead of a zip file")
+	backupSaveCmd.Flags().BoolVar(&backupSaveOpts.encrypt, "encrypt", false, "Encrypt the backup file with the 'backup.encryption' config keys")
+	backupSaveCmd.Flags().StringSliceVar(&backupSaveOpts.recipients, "recipient", nil, "Encrypt the backup file to this age public key (can be repeated)")
+	backupSaveCmd.Flags().StringVar(&backupSaveOpts.recipientsFile, "recipients-file", "", "Encrypt the backup file to the age public keys listed on this file")
+	backupSaveCmd.Flags().StringVar(&backupSaveOpts.passphraseFile, "passphrase-file", "", "Encrypt the backup file with the passphrase on this file")
+	backupSaveCmd.Flags().StringVar(&backupSaveOpts.format, "format", "", "Backup file format: zip, tar.gz or tar.zst (defaults to 'backup.format' config or zip)")
+	backupSaveCmd.Flags().IntVar(&backupSaveOpts.compressionLevel, "compression-level", 0, "Compression level, 1 to 9 (zip and tar.gz) or 1 to 22 (tar.zst) (defaults to the format default)")
+	backupSaveCmd.Flags().StringSliceVar(&backupSaveOpts.excludes, "exclude", nil, "Leave out files matching this gitignore-style pattern (can be repeated)")
+	backupSaveCmd.Flags().StringSliceVar(&backupSaveOpts.includes, "include", nil, "Include files matching this pattern, even when left out by other rules (can be repeated)")
+	backupSaveCmd.Flags().BoolVar(&backupSaveOpts.worldOnly, "world-only", false, "Only back up the world folders")
+	backupSaveCmd.Flags().StringVar(&backupSaveOpts.repository, "repository", "", "Backup repository folder used with --incremental (defaults to 'repository' inside backup folder)")
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/spf13/cobra"
+)
+
+// backupSnapshotsCmd represents the backup snapshots command
+var backupSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "List backup repository snapshots",
+	Long: `List backup repository snapshots.
+Encrypted repositories are unlocked with the 'backup.encryption' config keys, or the informed identity or passphrase file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackupSnapshots(context.Background(), backupSnapshotsOpts.repository, encryption.Config{
+			IdentityFile:   backupSnapshotsOpts.identityFile,
+			PassphraseFile: backupSnapshotsOpts.passphraseFile,
+		})
+	},
+}
+
+var (
+	backupSnapshotsOpts struct {
+		repository     string
+		identityFile   string
+		passphraseFile string
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupSnapshotsCmd)
+
+	backupSnapshotsCmd.Flags().StringVar(&backupSnapshotsOpts.repository, "repository", defaultBackupRepository, "Backup repository folder")
+	backupSnapshotsCmd.Flags().StringVar(&backupSnapshotsOpts.identityFile, "identity-file", "", "Unlock an encrypted repository with the age private keys on this file")
+	backupSnapshotsCmd.Flags().StringVar(&backupSnapshotsOpts.passphraseFile, "passphrase-file", "", "Unlock an encrypted repository with the passphrase on this file")
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/cobra"
+)
+
+// backupVerifyCmd represents the backup verify command
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify [backup-file...]",
+	Short: "Verify backup files integrity",
+	Long: `Verify backup files integrity, checking every packed file against the backup checksums. Exits with an error when any backup is damaged.
+Encrypted backup files are decrypted with the 'backup.encryption' config keys, or the informed identity or passphrase file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !backupVerifyOpts.all && len(args) == 0 {
+			return errors.New("inform the backup files to verify or use --all")
+		}
+		return runBackupVerify(context.Background(), args, backupVerifyOpts)
+	},
+}
+
+var (
+	backupVerifyOpts struct {
+		all            bool
+		destFolder     string
+		testRestore    bool
+		identityFile   string
+		passphraseFile string
+	}
+)
+
+func init() {
+	backupCmd.AddCommand(backupVerifyCmd)
+
+	backupVerifyCmd.Flags().BoolVar(&backupVerifyOpts.all, "all", false, "Verify every backup file on backup folder")
+	backupVerifyCmd.Flags().StringVar(&backupVerifyOpts.destFolder, "backup-folder", ".backups", "Backup files folder or remote destination URL used with --all (defaults to .backups on current directory)")
+	backupVerifyCmd.Flags().StringVar(&backupVerifyOpts.identityFile, "identity-file", "", "Decrypt backup files with the age private keys on this file")
+	backupVerifyCmd.Flags().StringVar(&backupVerifyOpts.passphraseFile, "passphrase-file", "", "Decrypt backup files with the passphrase on this file")
+	backupVerifyCmd.Flags().BoolVar(&backupVerifyOpts.testRestore, "test-restore", false, "Also restore backups to a temporary folder and validate the world level data")
+}
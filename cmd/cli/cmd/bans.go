@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// bansCmd represents the bans command
+var bansCmd = &cobra.Command{
+	Use:   "bans",
+	Short: "Instance ban lists management",
+	Long:  `Instance ban lists management (banned players and IP addresses).`,
+}
+
+var (
+	bansOpts struct {
+		instance string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(bansCmd)
+
+	bansCmd.PersistentFlags().StringVar(&bansOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+// bansAddCmd represents the bans add command
+var bansAddCmd = &cobra.Command{
+	Use:   "add <user|ip>...",
+	Short: "Ban users or IP addresses",
+	Long:  `Ban users or IP addresses (arguments that are valid IP addresses are banned as IPs).`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBansAdd(context.Background(), bansOpts.instance, args, bansAddOpts.reason, bansAddOpts.source, bansAddOpts.expiresIn)
+	},
+}
+
+var (
+	bansAddOpts struct {
+		reason    string
+		source    string
+		expiresIn time.Duration
+	}
+)
+
+func init() {
+	bansCmd.AddCommand(bansAddCmd)
+
+	bansAddCmd.Flags().StringVar(&bansAddOpts.reason, "reason", "", "Ban reason (defaults to 'Banned by an operator.')")
+	bansAddCmd.Flags().StringVar(&bansAddOpts.source, "source", "", "Who is banning (defaults to 'Server')")
+	bansAddCmd.Flags().DurationVar(&bansAddOpts.expiresIn, "expires-in", 0, "Ban duration, like 72h (defaults to forever, running servers apply timed bans after a restart)")
+}
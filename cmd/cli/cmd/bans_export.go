@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// bansExportCmd represents the bans export command
+var bansExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export instance ban lists",
+	Long:  `Export instance ban lists (to stdout when file isn't informed).`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var file string
+		if len(args) > 0 {
+			file = args[0]
+		}
+		return runBansExport(context.Background(), bansOpts.instance, file)
+	},
+}
+
+func init() {
+	bansCmd.AddCommand(bansExportCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// bansImportCmd represents the bans import command
+var bansImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import bans from an exported ban list",
+	Long:  `Import bans from an exported ban list, keeping the existing ones.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBansImport(context.Background(), bansOpts.instance, args[0])
+	},
+}
+
+func init() {
+	bansCmd.AddCommand(bansImportCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// bansListCmd represents the bans list command
+var bansListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List instance banned users and IP addresses",
+	Long:  `List instance banned users and IP addresses.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBansList(context.Background(), bansOpts.instance)
+	},
+}
+
+func init() {
+	bansCmd.AddCommand(bansListCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// bansRemoveCmd represents the bans remove command
+var bansRemoveCmd = &cobra.Command{
+	Use:   "remove <user|ip>...",
+	Short: "Pardon users or IP addresses",
+	Long:  `Pardon users or IP addresses (arguments that are valid IP addresses are pardoned as IPs).`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBansRemove(context.Background(), bansOpts.instance, args)
+	},
+}
+
+func init() {
+	bansCmd.AddCommand(bansRemoveCmd)
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"net"
+	"os"
+	"time"
+)
+
+func newBansService() minecraft.BansService {
+	return minecraft.NewBansService(
+		minecraft.WithMojangClient(newMojangClient()),
+	)
+}
+
+func runBansAdd(ctx context.Context, instance string, targets []string, reason, source string, expiresIn time.Duration) error {
+	opts := minecraft.BanOpts{Reason: reason, Source: source}
+	if expiresIn > 0 {
+		opts.Expires = time.Now().Add(expiresIn)
+	}
+
+	users, ips := splitBanTargets(targets)
+	s := newBansService()
+	if len(users) > 0 {
+		if _, err := s.Ban(ctx, instance, opts, users...); err != nil {
+			return fmt.Errorf("banning users: %w", err)
+		}
+	}
+	if len(ips) > 0 {
+		if _, err := s.BanIP(ctx, instance, opts, ips...); err != nil {
+			return fmt.Errorf("banning ips: %w", err)
+		}
+	}
+	return runBansList(ctx, instance)
+}
+
+func runBansRemove(ctx context.Context, instance string, targets []string) error {
+	users, ips := splitBanTargets(targets)
+	s := newBansService()
+	if len(users) > 0 {
+		if _, err := s.Pardon(ctx, instance, users...); err != nil {
+			return fmt.Errorf("pardoning users: %w", err)
+		}
+	}
+	if len(ips) > 0 {
+		if _, err := s.PardonIP(ctx, instance, ips...); err != nil {
+			return fmt.Errorf("pardoning ips: %w", err)
+		}
+	}
+	return runBansList(ctx, instance)
+}
+
+func runBansList(ctx context.Context, instance string) error {
+	list, err := newBansService().List(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("listing bans: %w", err)
+	}
+	printBans(list)
+	return nil
+}
+
+func runBansImport(ctx context.Context, instance, file string) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading ban list file: %w", err)
+	}
+	var list model.BanList
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("decoding ban list file: %w", err)
+	}
+
+	added, err := newBansService().Import(ctx, instance, &list)
+	if err != nil {
+		return fmt.Errorf("importing bans: %w", err)
+	}
+	fmt.Printf("Imported bans:\n")
+	printBans(added)
+	return nil
+}
+
+func runBansExport(ctx context.Context, instance, file string) error {
+	list, err := newBansService().List(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("listing bans: %w", err)
+	}
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding ban list: %w", err)
+	}
+	b = append(b, '\n')
+	if file == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("writing ban list file: %w", err)
+	}
+	return nil
+}
+
+func runBansSync(ctx context.Context, from string, to []string) error {
+	repo, err := openInstancesDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = repo.Close()
+	}()
+
+	fromPath, err := instancePath(ctx, repo, from)
+	if err != nil {
+		return err
+	}
+	toPaths := make([]string, 0, len(to))
+	for _, t := range to {
+		p, err := instancePath(ctx, repo, t)
+		if err != nil {
+			return err
+		}
+		toPaths = append(toPaths, p)
+	}
+
+	result, err := newBansService().Sync(ctx, fromPath, toPaths...)
+	if err != nil {
+		return fmt.Errorf("syncing bans: %w", err)
+	}
+	for _, p := range toPaths {
+		added, ok := result[p]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%s: %d player(s) and %d ip(s) banned\n", p, len(added.Players), len(added.IPs))
+	}
+	return nil
+}
+
+// instancePath returns the folder of a registered instance, informed
+// by name, or the informed folder when it isn't a registered name
+func instancePath(ctx context.Context, repo repository.Repository, instance string) (string, error) {
+	inst, err := repo.GetInstanceByName(ctx, instance)
+	if err == nil {
+		return inst.Path, nil
+	}
+	if !errors.Is(err, repository.ErrInstanceNotFound) {
+		return "", err
+	}
+	p, err := utils.AbsolutePath(instance)
+	if err != nil {
+		return "", fmt.Errorf("parsing instance path: %w", err)
+	}
+	if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("instance '%s' is not registered: %w", instance, repository.ErrInstanceNotFound)
+	}
+	return p, nil
+}
+
+// splitBanTargets splits ban targets in users and IP addresses
+func splitBanTargets(targets []string) ([]string, []string) {
+	var users, ips []string
+	for _, t := range targets {
+		if net.ParseIP(t) != nil {
+			ips = append(ips, t)
+		} else {
+			users = append(users, t)
+		}
+	}
+	return users, ips
+}
+
+func printBans(list *model.BanList) {
+	fmt.Printf("Banned users (%d):\n", len(list.Players))
+	for _, r := range list.Players {
+		fmt.Printf("- %s (%s) by %s, expires: %s, reason: %s\n", r.Name, r.Uuid, r.Source, r.Expires, r.Reason)
+	}
+	fmt.Printf("Banned ips (%d):\n", len(list.IPs))
+	for _, r := range list.IPs {
+		fmt.Printf("- %s by %s, expires: %s, reason: %s\n", r.IP, r.Source, r.Expires, r.Reason)
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// bansSyncCmd represents the bans sync command
+var bansSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Merge ban lists across registered instances",
+	Long: `Merge ban lists from an instance into other registered instances.
+Instances can be informed by name or by folder.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBansSync(context.Background(), bansSyncOpts.from, bansSyncOpts.to)
+	},
+}
+
+var (
+	bansSyncOpts struct {
+		from string
+		to   []string
+	}
+)
+
+func init() {
+	bansCmd.AddCommand(bansSyncCmd)
+
+	bansSyncCmd.Flags().StringVar(&bansSyncOpts.from, "from", "", "Instance to copy bans from")
+	bansSyncCmd.Flags().StringSliceVar(&bansSyncOpts.to, "to", []string{}, "Instances to copy bans to")
+	_ = bansSyncCmd.MarkFlagRequired("from")
+	_ = bansSyncCmd.MarkFlagRequired("to")
+}
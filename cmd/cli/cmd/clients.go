@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"path/filepath"
+)
+
+// newMojangClient creates a Mojang API client caching
+// responses on app's home folder
+func newMojangClient() mojang.Client {
+	opts := []mojang.ClientOpt{
+		mojang.WithTimeout(cfg.GetMinecraftApiTimeout()),
+		mojang.WithRequestLog(cfg.GetRequestLogEnabled()),
+		mojang.WithUserAgent(userAgent()),
+	}
+	cachePath, err := cfg.GetCachePath()
+	if err != nil {
+		logger.GetLogger().With("error", err).Warn("Failed to find cache folder, Mojang API cache disabled")
+		return mojang.NewClient(opts...)
+	}
+	cache := mojang.NewFileCache(filepath.Join(cachePath, "mojang"), cfg.GetMinecraftApiCacheTTL())
+	return mojang.NewClient(append(opts, mojang.WithCache(cache))...)
+}
+
+func userAgent() string {
+	if v := cfg.GetVersionInfo().Version; v != "" {
+		return mojang.DefaultUserAgent + "/" + v
+	}
+	return mojang.DefaultUserAgent
+}
+
+// openInstancesDatabase opens the instances database (inside app's
+// home folder), creating the home folder when it doesn't exist
+func openInstancesDatabase() (repository.Repository, error) {
+	dbPath, err := cfg.GetDatabasePath()
+	if err != nil {
+		return nil, fmt.Errorf("getting instances database path: %w", err)
+	}
+	repo, err := repository.NewStormRepository(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening instances database: %w", err)
+	}
+	return repo, nil
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the scheduled backups",
+	Long: `Run the scheduled backups configured on 'backup.schedules' until it's stopped.
+Each run saves the backup, applies the retention policy and verifies it. Runs results
+are recorded on a status file (see 'daemon status').`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemon(context.Background(), daemonOpts.once)
+	},
+}
+
+var (
+	daemonOpts struct {
+		once bool
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().BoolVar(&daemonOpts.once, "once", false, "Run every scheduled backup once and exit")
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/scheduler"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"syscall"
+)
+
+func runDaemon(ctx context.Context, once bool) error {
+	jobs, err := backupJobs(ctx)
+	if err != nil {
+		return err
+	}
+	statusPath, err := cfg.GetDaemonStatusPath()
+	if err != nil {
+		return fmt.Errorf("getting status file path: %w", err)
+	}
+	s := scheduler.NewScheduler(jobs, scheduler.WithStatusFile(scheduler.NewStatusFile(statusPath)))
+
+	if once {
+		var failed int
+		for _, j := range jobs {
+			if err := s.RunJob(ctx, j); err != nil {
+				fmt.Printf("- %s: FAILED (%v)\n", j.Name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("- %s: OK\n", j.Name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d scheduled backups failed", failed, len(jobs))
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.GetLogger().With("jobs", len(jobs), "status_file", statusPath).InfoContext(ctx, "Daemon started")
+	return s.Run(ctx)
+}
+
+// backupJobs creates a job for each configured schedule and instance
+func backupJobs(ctx context.Context) ([]scheduler.Job, error) {
+	schedules, err := cfg.GetBackupSchedules()
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, fmt.Errorf("no backup schedules configured (see '%s')", cfg.BackupSchedulesPropKey)
+	}
+	instances, err := scheduledInstances(ctx, schedules)
+	if err != nil {
+		return nil, err
+	}
+	defaultFolder, err := cfg.GetBackupsPath()
+	if err != nil {
+		return nil, fmt.Errorf("getting backups folder: %w", err)
+	}
+
+	runner, err := hooksRunner()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys *encryption.Keys
+	var jobs []scheduler.Job
+	for i, sc := range schedules {
+		schedule, err := scheduler.ParseCron(sc.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("backup schedule %d: %w", i+1, err)
+		}
+		policy, err := retentionPolicy(sc.Retention)
+		if err != nil {
+			return nil, fmt.Errorf("backup schedule %d: %w", i+1, err)
+		}
+		opts := minecraft.BackupJobOpts{
+			BackupFolder: sc.BackupFolder,
+			Incremental:  sc.Incremental,
+			Repository:   sc.Repository,
+			Retention:    policy,
+			Verify:       sc.Verify,
+			TestRestore:  sc.TestRestore,
+		}
+		if opts.BackupFolder == "" {
+			opts.BackupFolder = defaultFolder
+		}
+		svcOpts, err := backupFilesOpts(sc.Format, sc.CompressionLevel, sc.Exclude, sc.Include, sc.WorldOnly)
+		if err != nil {
+			return nil, fmt.Errorf("backup schedule %d: %w", i+1, err)
+		}
+		if sc.Encrypt {
+			if keys == nil {
+				if keys, err = backupKeys(encryption.Config{}); err != nil {
+					return nil, err
+				}
+			}
+			if !keys.CanEncrypt() {
+				return nil, fmt.Errorf("backup schedule %d: %w", i+1, encryption.ErrNoEncryptionKeys)
+			}
+			// encrypted repositories are unlocked to save snapshots
+			if sc.Incremental && !keys.CanDecrypt() {
+				return nil, fmt.Errorf("backup schedule %d: %w", i+1, encryption.ErrNoDecryptionKeys)
+			}
+			svcOpts = append(svcOpts, minecraft.WithEncryption(keys))
+		}
+		svcOpts = append(svcOpts, minecraft.WithTrigger(model.BackupTriggerScheduled), minecraft.WithBackupHooks(runner))
+
+		for _, path := range instances[i] {
+			jobs = append(jobs, scheduler.Job{
+				Name:     fmt.Sprintf("%s (%s)", filepath.Base(path), sc.Cron),
+				Key:      path,
+				Schedule: schedule,
+				Jitter:   sc.Jitter,
+				Run: func(ctx context.Context) error {
+					if !destination.IsRemote(opts.BackupFolder) {
+						if err := os.MkdirAll(opts.BackupFolder, os.ModePerm); err != nil {
+							return fmt.Errorf("creating backup folder: %w", err)
+						}
+					}
+					// the catalog is opened on each run so other
+					// commands can use the database meanwhile
+					jobOpts := slices.Clone(svcOpts)
+					if repo, closeRepo := backupCatalog(ctx); repo != nil {
+						defer closeRepo()
+						jobOpts = append(jobOpts, minecraft.WithBackupRepository(repo))
+					}
+					_, err := minecraft.NewBackupService(jobOpts...).RunJob(ctx, path, opts)
+					return err
+				},
+			})
+		}
+	}
+	return jobs, nil
+}
+
+// scheduledInstances resolves each schedule instances folders. The
+// database is closed afterward so other commands can use it.
+func scheduledInstances(ctx context.Context, schedules []cfg.BackupSchedule) ([][]string, error) {
+	repo, err := openInstancesDatabase()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = repo.Close()
+	}()
+
+	result := make([][]string, len(schedules))
+	for i, sc := range schedules {
+		if sc.Instance == "" {
+			return nil, fmt.Errorf("backup schedule %d: instance is required", i+1)
+		}
+		if sc.Instance != cfg.AllInstances {
+			p, err := instancePath(ctx, repo, sc.Instance)
+			if err != nil {
+				return nil, fmt.Errorf("backup schedule %d: %w", i+1, err)
+			}
+			result[i] = []string{p}
+			continue
+		}
+		list, err := repo.ListInstances(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing instances: %w", err)
+		}
+		for _, inst := range list {
+			result[i] = append(result[i], inst.Path)
+		}
+	}
+	return result, nil
+}
+
+func runDaemonStatus(_ context.Context) error {
+	statusPath, err := cfg.GetDaemonStatusPath()
+	if err != nil {
+		return fmt.Errorf("getting status file path: %w", err)
+	}
+	status, err := scheduler.NewStatusFile(statusPath).Read()
+	if err != nil {
+		return err
+	}
+	if len(status) == 0 {
+		return errors.New("no scheduled backup has run yet")
+	}
+
+	names := make([]string, 0, len(status))
+	for n := range status {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var failing int
+	for _, n := range names {
+		st := status[n]
+		if st.LastFailure.After(st.LastSuccess) {
+			failing++
+		}
+		fmt.Printf("- %s\n", n)
+		fmt.Printf("  runs: %d, failures: %d, running: %t\n", st.Runs, st.Failures, st.Running)
+		fmt.Printf("  last success: %s\n", displayTime(st.LastSuccess))
+		fmt.Printf("  last failure: %s\n", displayTime(st.LastFailure))
+		if st.LastError != "" {
+			fmt.Printf("  last error:   %s\n", st.LastError)
+		}
+		fmt.Printf("  next run:     %s\n", displayTime(st.NextRun))
+	}
+	if failing > 0 {
+		return fmt.Errorf("%d scheduled backups are failing", failing)
+	}
+	return nil
+}
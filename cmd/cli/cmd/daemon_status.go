@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// daemonStatusCmd represents the daemon status command
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the scheduled backups status",
+	Long:  `Show the scheduled backups status (last success and failure of each schedule).`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemonStatus(context.Background())
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStatusCmd)
+}
@@ -44,6 +44,7 @@ type installCmdOpts struct {
 	RconEnabled bool
 
 	users []string
+	ops   []string
 }
 
 var (
@@ -72,6 +73,7 @@ func init() {
 	installCmd.Flags().StringVar(&installOpts.RconPass, "rcon-passwd", "", "RCON password (it will be asked if empty)")
 
 	installCmd.Flags().StringSliceVar(&installOpts.users, "whitelist-user", []string{}, "List of users to whitelist (optional)")
+	installCmd.Flags().StringSliceVar(&installOpts.ops, "op-user", []string{}, "List of server operators, as name[:level] (optional)")
 
 	installCmd.Flags().Duration("download-timeout", 300*time.Second, "Download timeout configuration (defaults to 300s/5m)")
 	if err := viper.BindPFlag(cfg.AppInstallDownloadTimeoutPropKey, installCmd.Flags().Lookup("download-timeout")); err != nil {
@@ -5,19 +5,20 @@ import (
 	"errors"
 	"fmt"
 	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/hooks"
 	"github.com/eldius/mineserver-manager/internal/installer"
 	"github.com/eldius/mineserver-manager/internal/minecraft"
 	"github.com/eldius/mineserver-manager/internal/minecraft/config"
-	"github.com/eldius/mineserver-manager/internal/mojang"
 	"github.com/eldius/mineserver-manager/internal/utils"
 	"log/slog"
 )
 
 func runInstall(ctx context.Context, opts installCmdOpts) error {
+	client := newMojangClient()
 	var flavor installer.ServerFlavor
 	switch opts.Flavor {
 	case "vanilla":
-		flavor = installer.NewVanillaFlavor(mojang.NewClient(mojang.WithTimeout(cfg.GetMinecraftApiTimeout())))
+		flavor = installer.NewVanillaFlavor(client)
 	case "purpur":
 		return errors.New("purpur flavor not yet implemented")
 	default:
@@ -35,27 +36,84 @@ func runInstall(ctx context.Context, opts installCmdOpts) error {
 		return nil
 	}
 
-	client := minecraft.NewInstallService(
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	repo, err := openInstancesDatabase()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = repo.Close()
+	}()
+	svc := minecraft.NewInstallService(
 		minecraft.WithTimeout(cfg.GetMinecraftApiTimeout()),
 		minecraft.WithDownloadTimeout(cfg.GetMinecraftDownloadTimeout()),
 		minecraft.WithFlavor(flavor),
+		minecraft.WithInstallMojangClient(client),
+		minecraft.WithInstallHooks(runner),
+		minecraft.WithRepository(repo),
 	)
 
+	ops, err := opts.Operators()
+	if err != nil {
+		return err
+	}
+
 	instanceOpts := append(
 		opts.ToInstanceOpts(),
+		config.WithOperators(ops),
 		config.WithVersion(opts.ServerVersion),
 		config.ToDestinationFolder(opts.DestinationFolder),
 		config.WithHeadlessConfig(opts.Headless),
 		config.WithServerFlavour(opts.Flavor),
 	)
 
-	if err := client.Install(ctx, instanceOpts...); err != nil {
+	if err := svc.Install(ctx, instanceOpts...); err != nil {
 		return fmt.Errorf("installing server: %w", err)
 	}
 
 	return nil
 }
 
+// hooksRunner returns the runner of the configured hooks (see 'hooks')
+func hooksRunner() (*hooks.Runner, error) {
+	configured, err := cfg.GetHooks()
+	if err != nil {
+		return nil, err
+	}
+	byEvent := make(map[hooks.Event][]hooks.Hook, len(configured))
+	for event, list := range configured {
+		for _, h := range list {
+			byEvent[hooks.Event(event)] = append(byEvent[hooks.Event(event)], hooks.Hook{
+				Command:  h.Command,
+				Template: h.Template,
+				Timeout:  h.Timeout,
+				OnError:  hooks.OnError(h.OnError),
+			})
+		}
+	}
+	r, err := hooks.NewRunner(byEvent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing hooks: %w", err)
+	}
+	return r, nil
+}
+
+// Operators parses the `--op-user` values
+func (o installCmdOpts) Operators() ([]config.Operator, error) {
+	ops := make([]config.Operator, 0, len(o.ops))
+	for _, s := range o.ops {
+		op, err := config.ParseOperator(s)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
 func (o installCmdOpts) ToInstanceOpts() []config.InstanceOpt {
 	opts := []config.InstanceOpt{config.WithMemoryLimit(o.MemoryLimit)}
 	if o.Motd != "" {
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// opsCmd represents the ops command
+var opsCmd = &cobra.Command{
+	Use:   "ops",
+	Short: "Instance operators management",
+	Long:  `Instance operators management.`,
+}
+
+var (
+	opsOpts struct {
+		instance string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(opsCmd)
+
+	opsCmd.PersistentFlags().StringVar(&opsOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// opsAddCmd represents the ops add command
+var opsAddCmd = &cobra.Command{
+	Use:   "add <user[:level]>...",
+	Short: "Add (or update) instance operators",
+	Long: `Add (or update) instance operators.
+Users without level get the instance op-permission-level.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOpsAdd(context.Background(), opsOpts.instance, args, opsAddOpts.bypassPlayerLimit)
+	},
+}
+
+var (
+	opsAddOpts struct {
+		bypassPlayerLimit bool
+	}
+)
+
+func init() {
+	opsCmd.AddCommand(opsAddCmd)
+
+	opsAddCmd.Flags().BoolVar(&opsAddOpts.bypassPlayerLimit, "bypass-player-limit", false, "Allow operators to join when server is full")
+}
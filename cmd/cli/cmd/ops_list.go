@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// opsListCmd represents the ops list command
+var opsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List instance operators",
+	Long:  `List instance operators.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOpsList(context.Background(), opsOpts.instance)
+	},
+}
+
+func init() {
+	opsCmd.AddCommand(opsListCmd)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// opsRemoveCmd represents the ops remove command
+var opsRemoveCmd = &cobra.Command{
+	Use:   "remove <user>...",
+	Short: "Remove instance operators",
+	Long:  `Remove instance operators.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOpsRemove(context.Background(), opsOpts.instance, args)
+	},
+}
+
+func init() {
+	opsCmd.AddCommand(opsRemoveCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/minecraft/config"
+	"github.com/eldius/mineserver-manager/internal/model"
+)
+
+func newOpsService() minecraft.OpsService {
+	return minecraft.NewOpsService(
+		minecraft.WithMojangClient(newMojangClient()),
+	)
+}
+
+func runOpsAdd(ctx context.Context, instance string, users []string, bypassPlayerLimit bool) error {
+	ops := make([]config.Operator, 0, len(users))
+	for _, u := range users {
+		op, err := config.ParseOperator(u)
+		if err != nil {
+			return err
+		}
+		op.BypassesPlayerLimit = bypassPlayerLimit
+		ops = append(ops, op)
+	}
+
+	list, err := newOpsService().Add(ctx, instance, ops...)
+	if err != nil {
+		return fmt.Errorf("adding operators: %w", err)
+	}
+	printOps(list)
+	return nil
+}
+
+func runOpsRemove(ctx context.Context, instance string, users []string) error {
+	list, err := newOpsService().Remove(ctx, instance, users...)
+	if err != nil {
+		return fmt.Errorf("removing operators: %w", err)
+	}
+	printOps(list)
+	return nil
+}
+
+func runOpsList(ctx context.Context, instance string) error {
+	list, err := newOpsService().List(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("listing operators: %w", err)
+	}
+	printOps(list)
+	return nil
+}
+
+func printOps(list []model.OperatorRecord) {
+	fmt.Printf("Operators (%d):\n", len(list))
+	for _, r := range list {
+		fmt.Printf("- %s (%s) level: %d, bypasses player limit: %t\n", r.Name, r.Uuid, r.Level, r.BypassesPlayerLimit)
+	}
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// playersCmd represents the players command
+var playersCmd = &cobra.Command{
+	Use:   "players",
+	Short: "Instance players management",
+	Long:  `Instance players management.`,
+}
+
+var (
+	playersOpts struct {
+		instance string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(playersCmd)
+
+	playersCmd.PersistentFlags().StringVar(&playersOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// playersInfoCmd represents the players info command
+var playersInfoCmd = &cobra.Command{
+	Use:   "info <user>...",
+	Short: "Show players profile info",
+	Long:  `Show players profile info (UUIDs, skin and cape).`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlayersInfo(context.Background(), args)
+	},
+}
+
+func init() {
+	playersCmd.AddCommand(playersInfoCmd)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// playersMigrateUUIDsCmd represents the players migrate-uuids command
+var playersMigrateUUIDsCmd = &cobra.Command{
+	Use:   "migrate-uuids",
+	Short: "Switch instance players between online and offline UUIDs",
+	Long: `Switch instance players between online and offline UUIDs,
+renaming their world files (playerdata, stats and advancements),
+updating the players lists and the online-mode property.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlayersMigrateUUIDs(context.Background(), playersOpts.instance, playersMigrateUUIDsOpts.to, playersMigrateUUIDsOpts.dryRun)
+	},
+}
+
+var (
+	playersMigrateUUIDsOpts struct {
+		to     string
+		dryRun bool
+	}
+)
+
+func init() {
+	playersCmd.AddCommand(playersMigrateUUIDsCmd)
+
+	playersMigrateUUIDsCmd.Flags().StringVar(&playersMigrateUUIDsOpts.to, "to", "", "Target UUID mode (online or offline)")
+	playersMigrateUUIDsCmd.Flags().BoolVar(&playersMigrateUUIDsOpts.dryRun, "dry-run", false, "Only show changes, without applying them")
+	_ = playersMigrateUUIDsCmd.MarkFlagRequired("to")
+}
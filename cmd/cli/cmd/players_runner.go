@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+)
+
+func runPlayersMigrateUUIDs(ctx context.Context, instance, to string, dryRun bool) error {
+	mode, err := minecraft.ParseUUIDMode(to)
+	if err != nil {
+		return err
+	}
+
+	s := minecraft.NewPlayersService(
+		minecraft.WithMojangClient(newMojangClient()),
+	)
+	migrations, err := s.MigrateUUIDs(ctx, instance, mode, dryRun)
+	if err != nil {
+		return fmt.Errorf("migrating players uuids: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("Players to migrate to %s mode (%d):\n", mode, len(migrations))
+	} else {
+		fmt.Printf("Players migrated to %s mode (%d):\n", mode, len(migrations))
+	}
+	for _, m := range migrations {
+		fmt.Printf("- %s: %s -> %s\n", m.Name, m.OldUUID, m.NewUUID)
+		for _, f := range m.Files {
+			fmt.Printf("    %s\n", f)
+		}
+	}
+	return nil
+}
+
+func runPlayersInfo(ctx context.Context, users []string) error {
+	c := newMojangClient()
+	for _, u := range users {
+		id, err := c.GetUserInfo(ctx, u)
+		if errors.Is(err, mojang.ErrNotFound) {
+			fmt.Printf("- %s: not found\n", u)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("getting user info: %w", err)
+		}
+		p, err := c.GetProfile(ctx, id.ID)
+		if err != nil {
+			return fmt.Errorf("getting player profile: %w", err)
+		}
+
+		fmt.Printf("- %s\n", p.Name)
+		fmt.Printf("    uuid:         %s\n", p.UUID())
+		fmt.Printf("    offline uuid: %s\n", minecraft.OfflineUUID(p.Name))
+		textures, err := p.Textures()
+		if errors.Is(err, mojang.ErrTexturesNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("decoding player textures: %w", err)
+		}
+		fmt.Printf("    skin:         %s (%s)\n", textures.SkinURL(), textures.SkinModel())
+		if capeURL := textures.CapeURL(); capeURL != "" {
+			fmt.Printf("    cape:         %s\n", capeURL)
+		}
+	}
+	return nil
+}
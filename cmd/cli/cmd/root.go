@@ -20,6 +20,7 @@ var rootCmd = &cobra.Command{
 		setup.WithDefaultCfgFileName("config"),
 		setup.WithDefaultValues(map[string]any{
 			config.AppMinecraftAPITimeoutPropKey:    "10s",
+			config.AppMinecraftAPICacheTTLPropKey:   "24h",
 			config.AppInstallDownloadTimeoutPropKey: "300s",
 			config.AppDebugModePropKey:              false,
 			config.AppRequestLogPropKey:             false,
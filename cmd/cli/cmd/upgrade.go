@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// upgradeCmd upgrades a minecraft server instance
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrades a Minecraft server instance",
+	Long:  `Upgrades a Minecraft server instance, migrating its server.properties to the new version.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpgrade(context.Background(), upgradeOpts)
+	},
+}
+
+type upgradeCmdOpts struct {
+	Flavor         string
+	ServerVersion  string
+	InstanceFolder string
+	BackupFolder   string
+}
+
+var (
+	upgradeOpts = upgradeCmdOpts{}
+)
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().StringVar(&upgradeOpts.Flavor, "flavor", "vanilla", "Minecraft server flavor (vanilla, purpur)")
+	upgradeCmd.Flags().StringVar(&upgradeOpts.ServerVersion, "version", "latest", "Java Edition server version to upgrade to, ('latest' will use latest stable version)")
+	upgradeCmd.Flags().StringVar(&upgradeOpts.InstanceFolder, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+	upgradeCmd.Flags().StringVar(&upgradeOpts.BackupFolder, "backup-folder", "", "Backup the instance to this folder (or remote destination URL) before upgrading")
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/installer"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"os"
+)
+
+func runUpgrade(ctx context.Context, opts upgradeCmdOpts) error {
+	client := newMojangClient()
+	var flavor installer.ServerFlavor
+	switch opts.Flavor {
+	case "vanilla":
+		flavor = installer.NewVanillaFlavor(client)
+	case "purpur":
+		return errors.New("purpur flavor not yet implemented")
+	default:
+		return fmt.Errorf("invalid flavor: %s", opts.Flavor)
+	}
+
+	runner, err := hooksRunner()
+	if err != nil {
+		return err
+	}
+	svc := minecraft.NewInstallService(
+		minecraft.WithTimeout(cfg.GetMinecraftApiTimeout()),
+		minecraft.WithDownloadTimeout(cfg.GetMinecraftDownloadTimeout()),
+		minecraft.WithFlavor(flavor),
+		minecraft.WithInstallMojangClient(client),
+		minecraft.WithInstallHooks(runner),
+	)
+
+	if opts.BackupFolder != "" {
+		svcOpts, err := backupFilesOpts("", 0, nil, nil, false)
+		if err != nil {
+			return err
+		}
+		svcOpts = append(svcOpts, minecraft.WithTrigger(model.BackupTriggerPreUpgrade), minecraft.WithBackupHooks(runner))
+		if repo, closeRepo := backupCatalog(ctx); repo != nil {
+			defer closeRepo()
+			svcOpts = append(svcOpts, minecraft.WithBackupRepository(repo))
+		}
+		if !destination.IsRemote(opts.BackupFolder) {
+			if err := os.MkdirAll(opts.BackupFolder, os.ModePerm); err != nil {
+				return fmt.Errorf("creating backup folder: %w", err)
+			}
+		}
+		b, err := minecraft.NewBackupService(svcOpts...).Backup(ctx, opts.InstanceFolder, opts.BackupFolder)
+		if err != nil {
+			return fmt.Errorf("backing up server before upgrading: %w", err)
+		}
+		fmt.Printf("Backup completed to '%s'!\n", b.Path)
+	}
+
+	if err := svc.Upgrade(ctx, opts.InstanceFolder, opts.ServerVersion); err != nil {
+		return fmt.Errorf("upgrading server: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// whitelistCmd represents the whitelist command
+var whitelistCmd = &cobra.Command{
+	Use:   "whitelist",
+	Short: "Instance whitelist management",
+	Long:  `Instance whitelist management.`,
+}
+
+var (
+	whitelistOpts struct {
+		instance string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(whitelistCmd)
+
+	whitelistCmd.PersistentFlags().StringVar(&whitelistOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// whitelistAddCmd represents the whitelist add command
+var whitelistAddCmd = &cobra.Command{
+	Use:   "add <user>...",
+	Short: "Add users to instance whitelist",
+	Long:  `Add users to instance whitelist.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWhitelistAdd(context.Background(), whitelistOpts.instance, args)
+	},
+}
+
+func init() {
+	whitelistCmd.AddCommand(whitelistAddCmd)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// whitelistListCmd represents the whitelist list command
+var whitelistListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List instance whitelisted users",
+	Long:  `List instance whitelisted users.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWhitelistList(context.Background(), whitelistOpts.instance)
+	},
+}
+
+func init() {
+	whitelistCmd.AddCommand(whitelistListCmd)
+}
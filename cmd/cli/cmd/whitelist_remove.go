@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// whitelistRemoveCmd represents the whitelist remove command
+var whitelistRemoveCmd = &cobra.Command{
+	Use:   "remove <user>...",
+	Short: "Remove users from instance whitelist",
+	Long:  `Remove users from instance whitelist.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWhitelistRemove(context.Background(), whitelistOpts.instance, args)
+	},
+}
+
+func init() {
+	whitelistCmd.AddCommand(whitelistRemoveCmd)
+}
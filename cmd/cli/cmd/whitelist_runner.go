@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/model"
+)
+
+func newWhitelistService() minecraft.WhitelistService {
+	return minecraft.NewWhitelistService(
+		minecraft.WithMojangClient(newMojangClient()),
+	)
+}
+
+func runWhitelistAdd(ctx context.Context, instance string, users []string) error {
+	list, err := newWhitelistService().Add(ctx, instance, users...)
+	if err != nil {
+		return fmt.Errorf("adding users to whitelist: %w", err)
+	}
+	printWhitelist(list)
+	return nil
+}
+
+func runWhitelistRemove(ctx context.Context, instance string, users []string) error {
+	list, err := newWhitelistService().Remove(ctx, instance, users...)
+	if err != nil {
+		return fmt.Errorf("removing users from whitelist: %w", err)
+	}
+	printWhitelist(list)
+	return nil
+}
+
+func runWhitelistSync(ctx context.Context, instance string, users []string) error {
+	list, err := newWhitelistService().Sync(ctx, instance, users...)
+	if err != nil {
+		return fmt.Errorf("syncing whitelist: %w", err)
+	}
+	printWhitelist(list)
+	return nil
+}
+
+func runWhitelistList(ctx context.Context, instance string) error {
+	list, err := newWhitelistService().List(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("listing whitelist: %w", err)
+	}
+	printWhitelist(list)
+	return nil
+}
+
+func printWhitelist(list []model.WhitelistRecord) {
+	fmt.Printf("Whitelisted users (%d):\n", len(list))
+	for _, r := range list {
+		fmt.Printf("- %s (%s)\n", r.Name, r.Uuid)
+	}
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// whitelistSyncCmd represents the whitelist sync command
+var whitelistSyncCmd = &cobra.Command{
+	Use:   "sync <user>...",
+	Short: "Replace instance whitelist with the informed users",
+	Long:  `Replace instance whitelist with the informed users.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWhitelistSync(context.Background(), whitelistOpts.instance, args)
+	},
+}
+
+func init() {
+	whitelistCmd.AddCommand(whitelistSyncCmd)
+}
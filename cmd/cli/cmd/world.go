@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// worldCmd represents the world command
+var worldCmd = &cobra.Command{
+	Use:   "world",
+	Short: "Instance world inspection",
+	Long:  `Instance world inspection.`,
+}
+
+var (
+	worldOpts struct {
+		instance string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(worldCmd)
+
+	worldCmd.PersistentFlags().StringVar(&worldOpts.instance, "instance-folder", ".", "Installation root directory (defaults to current directory)")
+}
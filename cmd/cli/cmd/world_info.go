@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// worldInfoCmd represents the world info command
+var worldInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the world state",
+	Long: `Show the world state from its level.dat file: seed, spawn, DataVersion, game rules,
+difficulty, hardcore flag, day time, weather and last played time (server.properties
+only holds the initial world settings). Use --snbt to show the whole level data.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWorldInfo(context.Background(), worldOpts.instance, worldInfoOpts.snbt)
+	},
+}
+
+var (
+	worldInfoOpts struct {
+		snbt bool
+	}
+)
+
+func init() {
+	worldCmd.AddCommand(worldInfoCmd)
+
+	worldInfoCmd.Flags().BoolVar(&worldInfoOpts.snbt, "snbt", false, "Show the whole level data as SNBT")
+}
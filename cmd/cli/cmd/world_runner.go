@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/anvil"
+	"github.com/eldius/mineserver-manager/internal/minecraft"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"maps"
+	"slices"
+)
+
+func runWorldInfo(ctx context.Context, instance string, snbt bool) error {
+	level, err := minecraft.NewWorldService().Info(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("reading world info: %w", err)
+	}
+	if snbt {
+		fmt.Println(nbt.IndentSNBT(level.Raw, "  "))
+		return nil
+	}
+
+	fmt.Printf("World:        %s\n", level.LevelName)
+	fmt.Printf("Seed:         %d\n", level.Seed)
+	fmt.Printf("Spawn:        %d, %d, %d\n", level.SpawnX, level.SpawnY, level.SpawnZ)
+	if level.VersionName != "" {
+		fmt.Printf("Version:      %s (DataVersion %d)\n", level.VersionName, level.DataVersion)
+	} else {
+		fmt.Printf("DataVersion:  %d\n", level.DataVersion)
+	}
+	fmt.Printf("Game mode:    %s\n", level.GameMode)
+	difficulty := level.Difficulty
+	if level.DifficultyLocked {
+		difficulty += " (locked)"
+	}
+	fmt.Printf("Difficulty:   %s\n", difficulty)
+	fmt.Printf("Hardcore:     %t\n", level.Hardcore)
+	fmt.Printf("Day:          %d (time of day %d)\n", level.Day(), level.TimeOfDay())
+	fmt.Printf("Weather:      %s\n", level.Weather())
+	if !level.LastPlayed.IsZero() {
+		fmt.Printf("Last played:  %s\n", level.LastPlayed.Format(bkpDisplayTimeFormat))
+	}
+	if len(level.GameRules) > 0 {
+		fmt.Println("Game rules:")
+		for _, name := range slices.Sorted(maps.Keys(level.GameRules)) {
+			fmt.Printf("  %s: %s\n", name, level.GameRules[name])
+		}
+	}
+	return nil
+}
+
+func runWorldStats(ctx context.Context, instance string) error {
+	stats, err := minecraft.NewWorldService().Stats(ctx, instance)
+	if err != nil {
+		return fmt.Errorf("reading world stats: %w", err)
+	}
+
+	fmt.Printf("Server DataVersion: %d\n", stats.DataVersion)
+	for _, d := range stats.Dimensions {
+		fmt.Printf("- %s (%s)\n", d.Dimension, d.Folder)
+		fmt.Printf("    regions:    %d\n", d.Regions)
+		fmt.Printf("    chunks:     %d\n", d.Chunks)
+		fmt.Printf("    disk usage: %s\n", retention.FormatSize(d.DiskUsage))
+		if d.Oldest != nil {
+			fmt.Printf("    oldest:     chunk %d,%d saved at %s\n", d.Oldest.Pos.X, d.Oldest.Pos.Z, d.Oldest.Modified.Format(bkpDisplayTimeFormat))
+			fmt.Printf("    newest:     chunk %d,%d saved at %s\n", d.Newest.Pos.X, d.Newest.Pos.Z, d.Newest.Modified.Format(bkpDisplayTimeFormat))
+		}
+		if d.Unreadable > 0 {
+			fmt.Printf("    unreadable: %d chunks\n", d.Unreadable)
+		}
+		for _, f := range d.Corrupt {
+			fmt.Printf("    corrupt:    %s\n", f)
+		}
+		if len(d.Stale) == 0 {
+			continue
+		}
+
+		// stale chunks are shown by region, with their oldest DataVersion
+		count := make(map[anvil.RegionPos]int)
+		oldest := make(map[anvil.RegionPos]int)
+		for _, c := range d.Stale {
+			r := c.Pos.Region()
+			if v, ok := oldest[r]; !ok || c.DataVersion < v {
+				oldest[r] = c.DataVersion
+			}
+			count[r]++
+		}
+		fmt.Printf("    stale:      %d chunks\n", len(d.Stale))
+		regions := slices.SortedFunc(maps.Keys(count), func(a, b anvil.RegionPos) int {
+			return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Z, b.Z))
+		})
+		for _, r := range regions {
+			fmt.Printf("      %s: %d chunks (oldest DataVersion %d)\n", r.FileName(), count[r], oldest[r])
+		}
+	}
+	return nil
+}
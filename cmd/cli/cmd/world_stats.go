@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+)
+
+// worldStatsCmd represents the world stats command
+var worldStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the world size by dimension",
+	Long: `Read the world region files and show, by dimension, the region and chunk counts,
+the disk usage, the oldest and newest saved chunks and the chunks saved by older
+server versions (their DataVersion is older than the server one), by region.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWorldStats(context.Background(), worldOpts.instance)
+	},
+}
+
+func init() {
+	worldCmd.AddCommand(worldStatsCmd)
+}
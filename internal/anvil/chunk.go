@@ -0,0 +1,87 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"io"
+	"os"
+)
+
+var (
+	ErrExternalChunk = errors.New("chunk is stored on its own file")
+)
+
+// ExternalFileName returns the name of the file holding the chunk
+// payload when it's too big for its region file (next to the region
+// file, like 'c.-1.40.mcc')
+func (c ChunkPos) ExternalFileName() string {
+	return fmt.Sprintf("c.%d.%d.mcc", c.X, c.Z)
+}
+
+// LoadExternal returns a copy of an external chunk holding the payload
+// from its file (see ChunkPos.ExternalFileName)
+func (c *Chunk) LoadExternal(file string) (*Chunk, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading external chunk: %w", err)
+	}
+	return &Chunk{
+		Timestamp:   c.Timestamp,
+		Compression: c.Compression &^ External,
+		Data:        data,
+	}, nil
+}
+
+// Decompress returns the chunk NBT data, external chunks fail with
+// ErrExternalChunk (see LoadExternal)
+func (c *Chunk) Decompress() ([]byte, error) {
+	if c.IsExternal() {
+		return nil, ErrExternalChunk
+	}
+	var r io.ReadCloser
+	var err error
+	switch c.Compression {
+	case CompressionUncompressed:
+		return c.Data, nil
+	case CompressionLZ4:
+		data, err := decompressLZ4(c.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing chunk: %w", err)
+		}
+		return data, nil
+	case CompressionGzip:
+		r, err = gzip.NewReader(bytes.NewReader(c.Data))
+	case CompressionZlib:
+		r, err = zlib.NewReader(bytes.NewReader(c.Data))
+	default:
+		return nil, fmt.Errorf("unsupported chunk compression type %d", c.Compression)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decompressing chunk: %w", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing chunk: %w", err)
+	}
+	return data, nil
+}
+
+// NBT parses the chunk NBT data
+func (c *Chunk) NBT() (nbt.Compound, error) {
+	data, err := c.Decompress()
+	if err != nil {
+		return nil, err
+	}
+	_, root, err := nbt.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing chunk: %w", err)
+	}
+	return root, nil
+}
@@ -0,0 +1,104 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// lz4Block builds a lz4-java stream block
+func lz4Block(method byte, data []byte, size int) []byte {
+	b := append([]byte{}, lz4BlockMagic...)
+	b = append(b, method)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(size))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	return append(b, data...)
+}
+
+func TestChunk_NBT(t *testing.T) {
+	chunk := nbt.Compound{"DataVersion": int32(4189), "xPos": int32(-1), "zPos": int32(2), "Status": "minecraft:full"}
+	var raw bytes.Buffer
+	require.NoError(t, nbt.Write(&raw, "", chunk))
+
+	compress := func(w io.WriteCloser, b *bytes.Buffer) []byte {
+		_, err := w.Write(raw.Bytes())
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return b.Bytes()
+	}
+	var gz, z bytes.Buffer
+	tests := map[string]*Chunk{
+		"gzip":         {Compression: CompressionGzip, Data: compress(gzip.NewWriter(&gz), &gz)},
+		"zlib":         {Compression: CompressionZlib, Data: compress(zlib.NewWriter(&z), &z)},
+		"uncompressed": {Compression: CompressionUncompressed, Data: raw.Bytes()},
+		"lz4": {Compression: CompressionLZ4, Data: slices.Concat(
+			lz4Block(lz4MethodRaw, raw.Bytes()[:10], 10),
+			lz4Block(lz4MethodRaw, raw.Bytes()[10:], raw.Len()-10),
+			lz4Block(lz4MethodRaw, nil, 0),
+		)},
+	}
+	for name, c := range tests {
+		t.Run("given a "+name+" chunk should parse its nbt data", func(t *testing.T) {
+			root, err := c.NBT()
+			require.NoError(t, err)
+			assert.Equal(t, chunk, root)
+		})
+	}
+
+	t.Run("given an external chunk should load its payload file", func(t *testing.T) {
+		pos := ChunkPos{X: -1, Z: 40}
+		file := filepath.Join(t.TempDir(), pos.ExternalFileName())
+		require.NoError(t, os.WriteFile(file, raw.Bytes(), 0o644))
+		c := &Chunk{Compression: CompressionUncompressed | External}
+
+		_, err := c.NBT()
+		assert.ErrorIs(t, err, ErrExternalChunk)
+
+		loaded, err := c.LoadExternal(file)
+		require.NoError(t, err)
+		root, err := loaded.NBT()
+		require.NoError(t, err)
+		assert.Equal(t, chunk, root)
+		assert.Equal(t, "c.-1.40.mcc", pos.ExternalFileName())
+	})
+}
+
+func TestDecompressLZ4(t *testing.T) {
+	t.Run("given lz4 compressed blocks should decompress them", func(t *testing.T) {
+		// 'abcd' literals, a match copying 8 bytes from 4 bytes back, then 'XYZ' literals
+		block := []byte{0x44, 'a', 'b', 'c', 'd', 0x04, 0x00, 0x30, 'X', 'Y', 'Z'}
+		data, err := decompressLZ4(slices.Concat(lz4Block(lz4MethodLZ4, block, 15), lz4Block(lz4MethodRaw, []byte("!"), 1)))
+		require.NoError(t, err)
+		assert.Equal(t, "abcdabcdabcdXYZ!", string(data))
+	})
+
+	t.Run("given long literals and matches should decompress them", func(t *testing.T) {
+		// 16 'a' literals (15 + 1 extra length byte), then a 4+15+255+6 bytes match
+		block := append([]byte{0xff, 1}, bytes.Repeat([]byte("a"), 16)...)
+		block = append(block, 0x01, 0x00, 255, 6)
+		data, err := decompressLZ4(lz4Block(lz4MethodLZ4, block, 16+280))
+		require.NoError(t, err)
+		assert.Equal(t, bytes.Repeat([]byte("a"), 296), data)
+	})
+
+	t.Run("given corrupt lz4 data should fail", func(t *testing.T) {
+		_, err := decompressLZ4([]byte("LZ4Bloc"))
+		assert.Error(t, err)
+
+		_, err = decompressLZ4(lz4Block(lz4MethodLZ4, []byte{0x10, 'a', 0x05, 0x00}, 5))
+		assert.ErrorContains(t, err, "corrupt lz4 block")
+
+		_, err = decompressLZ4(lz4Block(lz4MethodLZ4, []byte{0x30, 'a'}, 3))
+		assert.ErrorContains(t, err, "corrupt lz4 block")
+	})
+}
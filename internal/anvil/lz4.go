@@ -0,0 +1,124 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	lz4BlockHeaderSize = 21
+	lz4MethodRaw       = 0x10
+	lz4MethodLZ4       = 0x20
+)
+
+var (
+	// lz4BlockMagic starts each block of the lz4-java block streams
+	// the game writes LZ4 chunks with
+	lz4BlockMagic = []byte("LZ4Block")
+)
+
+// decompressLZ4 decompresses a lz4-java block stream (blocks are a
+// header, then raw or LZ4 compressed data). Blocks checksums aren't
+// checked, region chunks are written at once.
+func decompressLZ4(data []byte) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		if len(data) < lz4BlockHeaderSize || !bytes.Equal(data[:len(lz4BlockMagic)], lz4BlockMagic) {
+			return nil, errors.New("invalid lz4 block header")
+		}
+		method := data[8] & 0xf0
+		compressed := int(binary.LittleEndian.Uint32(data[9:]))
+		size := int(binary.LittleEndian.Uint32(data[13:]))
+		data = data[lz4BlockHeaderSize:]
+		if compressed < 0 || compressed > len(data) || size < 0 {
+			return nil, errors.New("invalid lz4 block length")
+		}
+		if size == 0 {
+			// end of stream
+			break
+		}
+		block := data[:compressed]
+		data = data[compressed:]
+		switch method {
+		case lz4MethodRaw:
+			out = append(out, block...)
+		case lz4MethodLZ4:
+			var err error
+			if out, err = decompressLZ4Block(out, block, size); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid lz4 block method %#x", method)
+		}
+	}
+	return out, nil
+}
+
+// decompressLZ4Block appends the size bytes of a LZ4 compressed block
+// to dst (matches can only refer to bytes of the same block)
+func decompressLZ4Block(dst, src []byte, size int) ([]byte, error) {
+	start := len(dst)
+	errCorrupt := errors.New("corrupt lz4 block")
+	length := func(n int, i *int) (int, error) {
+		if n != 15 {
+			return n, nil
+		}
+		for {
+			if *i >= len(src) {
+				return 0, errCorrupt
+			}
+			b := src[*i]
+			*i++
+			n += int(b)
+			if b != 255 {
+				return n, nil
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		literals, err := length(int(token>>4), &i)
+		if err != nil {
+			return nil, err
+		}
+		if literals > len(src)-i {
+			return nil, errCorrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// the last sequence has only literals
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		match, err := length(int(token&0x0f), &i)
+		if err != nil {
+			return nil, err
+		}
+		match += 4
+		if offset == 0 || offset > len(dst)-start {
+			return nil, errCorrupt
+		}
+		// matches can overlap what they copy, so bytes are copied one by one
+		from := len(dst) - offset
+		for j := range match {
+			dst = append(dst, dst[from+j])
+		}
+		if len(dst)-start > size {
+			return nil, errCorrupt
+		}
+	}
+	if len(dst)-start != size {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
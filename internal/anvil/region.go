@@ -0,0 +1,243 @@
+package anvil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// SectorSize is the region files allocation unit
+	SectorSize = 4096
+	// RegionSize is how many chunks a region has on each axis
+	RegionSize = 32
+	// ChunksPerRegion is how many chunks a region file holds
+	ChunksPerRegion = RegionSize * RegionSize
+
+	headerSize = 2 * SectorSize
+	maxSectors = 255
+)
+
+// Chunk payload compression types, External is set along with the
+// type of chunks stored on their own '.mcc' file
+const (
+	CompressionGzip         byte = 1
+	CompressionZlib         byte = 2
+	CompressionUncompressed byte = 3
+	CompressionLZ4          byte = 4
+	CompressionCustom       byte = 127
+	External                byte = 128
+)
+
+var (
+	ErrCorruptRegion = errors.New("corrupt region file")
+)
+
+// ChunkPos is a chunk position (in chunks, a chunk has 16x16 blocks)
+type ChunkPos struct {
+	X, Z int
+}
+
+// ChunkOfBlock returns the chunk holding the block at x, z
+func ChunkOfBlock(x, z int) ChunkPos {
+	return ChunkPos{X: x >> 4, Z: z >> 4}
+}
+
+// Region returns the region holding the chunk
+func (c ChunkPos) Region() RegionPos {
+	return RegionPos{X: c.X >> 5, Z: c.Z >> 5}
+}
+
+// Index returns the chunk index inside its region file
+func (c ChunkPos) Index() int {
+	return (c.X & (RegionSize - 1)) + (c.Z&(RegionSize-1))*RegionSize
+}
+
+// RegionPos is a region position (in regions, a region has 32x32 chunks)
+type RegionPos struct {
+	X, Z int
+}
+
+// ParseRegionFileName parses a region file name like 'r.-1.2.mca'
+func ParseRegionFileName(name string) (RegionPos, error) {
+	var p RegionPos
+	var ext string
+	if _, err := fmt.Sscanf(filepath.Base(name), "r.%d.%d.%s", &p.X, &p.Z, &ext); err != nil || ext != "mca" {
+		return p, fmt.Errorf("invalid region file name '%s'", name)
+	}
+	return p, nil
+}
+
+// FileName returns the region file name
+func (r RegionPos) FileName() string {
+	return fmt.Sprintf("r.%d.%d.mca", r.X, r.Z)
+}
+
+// Chunk returns the position of the chunk at index on the region
+func (r RegionPos) Chunk(index int) ChunkPos {
+	return ChunkPos{
+		X: r.X*RegionSize + index%RegionSize,
+		Z: r.Z*RegionSize + index/RegionSize,
+	}
+}
+
+// Chunk is a region file chunk, its payload is kept compressed
+type Chunk struct {
+	Timestamp   time.Time
+	Compression byte
+	Data        []byte
+}
+
+// IsExternal tells if the chunk payload is on its own '.mcc' file
+func (c *Chunk) IsExternal() bool {
+	return c.Compression&External != 0
+}
+
+// Region is a region (Anvil '.mca') file content, chunks are indexed
+// by ChunkPos.Index and nil for chunks not generated yet
+type Region struct {
+	Chunks [ChunksPerRegion]*Chunk
+}
+
+// Len returns how many chunks the region has
+func (r *Region) Len() int {
+	var n int
+	for _, c := range r.Chunks {
+		if c != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// ReadRegionFile reads a region file, empty files are empty regions
+func ReadRegionFile(file string) (*Region, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening region file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading region file: %w", err)
+	}
+	r, err := ReadRegion(f, st.Size())
+	if err != nil {
+		return nil, fmt.Errorf("reading region file '%s': %w", file, err)
+	}
+	return r, nil
+}
+
+// ReadRegion reads a region of size bytes from r
+func ReadRegion(r io.ReaderAt, size int64) (*Region, error) {
+	region := &Region{}
+	if size == 0 {
+		return region, nil
+	}
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrCorruptRegion, err)
+	}
+	for i := range ChunksPerRegion {
+		loc := binary.BigEndian.Uint32(header[i*4:])
+		offset, sectors := int64(loc>>8), int64(loc&0xff)
+		if offset == 0 && sectors == 0 {
+			continue
+		}
+		if offset < headerSize/SectorSize || (offset+sectors)*SectorSize > size+SectorSize {
+			return nil, fmt.Errorf("%w: chunk %d out of bounds", ErrCorruptRegion, i)
+		}
+		prefix := make([]byte, 5)
+		if _, err := r.ReadAt(prefix, offset*SectorSize); err != nil {
+			return nil, fmt.Errorf("%w: reading chunk %d: %v", ErrCorruptRegion, i, err)
+		}
+		length := int64(binary.BigEndian.Uint32(prefix))
+		if length < 1 || length+4 > sectors*SectorSize {
+			return nil, fmt.Errorf("%w: chunk %d has invalid length %d", ErrCorruptRegion, i, length)
+		}
+		data := make([]byte, length-1)
+		if _, err := r.ReadAt(data, offset*SectorSize+5); err != nil {
+			return nil, fmt.Errorf("%w: reading chunk %d: %v", ErrCorruptRegion, i, err)
+		}
+		ts := binary.BigEndian.Uint32(header[SectorSize+i*4:])
+		region.Chunks[i] = &Chunk{
+			Timestamp:   time.Unix(int64(ts), 0),
+			Compression: prefix[4],
+			Data:        data,
+		}
+	}
+	return region, nil
+}
+
+// WriteTo writes the region file content, chunks are laid out in
+// index order without gaps between them
+func (r *Region) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, headerSize)
+	var body bytes.Buffer
+	offset := headerSize / SectorSize
+	for i, c := range r.Chunks {
+		if c == nil {
+			continue
+		}
+		length := len(c.Data) + 5
+		sectors := (length + SectorSize - 1) / SectorSize
+		if sectors > maxSectors {
+			return 0, fmt.Errorf("chunk %d is too big (%d bytes)", i, length)
+		}
+		binary.BigEndian.PutUint32(header[i*4:], uint32(offset)<<8|uint32(sectors))
+		var ts uint32
+		if !c.Timestamp.IsZero() {
+			ts = uint32(c.Timestamp.Unix())
+		}
+		binary.BigEndian.PutUint32(header[SectorSize+i*4:], ts)
+
+		_ = binary.Write(&body, binary.BigEndian, uint32(len(c.Data)+1))
+		body.WriteByte(c.Compression)
+		body.Write(c.Data)
+		body.Write(make([]byte, sectors*SectorSize-length))
+		offset += sectors
+	}
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := body.WriteTo(w)
+	return int64(n) + m, err
+}
+
+// WriteRegionFile writes the region to file, replacing it only after
+// the whole content is written
+func WriteRegionFile(file string, r *Region) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating region file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := r.WriteTo(tmp); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing region file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing region file: %w", err)
+	}
+	mode := os.FileMode(0o644)
+	if st, err := os.Stat(file); err == nil {
+		mode = st.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("setting region file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("replacing region file: %w", err)
+	}
+	return nil
+}
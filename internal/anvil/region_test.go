@@ -0,0 +1,89 @@
+package anvil
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChunkPos(t *testing.T) {
+	t.Run("given block coordinates should return their chunk and region", func(t *testing.T) {
+		tests := []struct {
+			x, z   int
+			chunk  ChunkPos
+			region RegionPos
+			index  int
+		}{
+			{x: 0, z: 0, chunk: ChunkPos{0, 0}, region: RegionPos{0, 0}, index: 0},
+			{x: 511, z: 15, chunk: ChunkPos{31, 0}, region: RegionPos{0, 0}, index: 31},
+			{x: 512, z: 16, chunk: ChunkPos{32, 1}, region: RegionPos{1, 0}, index: 32},
+			{x: -1, z: -1, chunk: ChunkPos{-1, -1}, region: RegionPos{-1, -1}, index: ChunksPerRegion - 1},
+			{x: -513, z: 100, chunk: ChunkPos{-33, 6}, region: RegionPos{-2, 0}, index: 31 + 6*32},
+		}
+		for _, tt := range tests {
+			c := ChunkOfBlock(tt.x, tt.z)
+			assert.Equal(t, tt.chunk, c)
+			assert.Equal(t, tt.region, c.Region())
+			assert.Equal(t, tt.index, c.Index())
+			assert.Equal(t, c, c.Region().Chunk(c.Index()))
+		}
+	})
+
+	t.Run("given region file names should parse their positions", func(t *testing.T) {
+		p, err := ParseRegionFileName("world/region/r.-1.2.mca")
+		require.NoError(t, err)
+		assert.Equal(t, RegionPos{-1, 2}, p)
+		assert.Equal(t, "r.-1.2.mca", p.FileName())
+
+		_, err = ParseRegionFileName("r.0.0.mcr")
+		assert.Error(t, err)
+		_, err = ParseRegionFileName("c.0.0.mcc")
+		assert.Error(t, err)
+	})
+}
+
+func TestRegion(t *testing.T) {
+	t.Run("given a region should write and read back its chunks", func(t *testing.T) {
+		ts := time.Unix(1735689600, 0)
+		r := &Region{}
+		r.Chunks[0] = &Chunk{Timestamp: ts, Compression: CompressionZlib, Data: []byte("first chunk")}
+		r.Chunks[33] = &Chunk{Timestamp: ts, Compression: CompressionUncompressed, Data: bytes.Repeat([]byte{1}, SectorSize*2)}
+
+		file := filepath.Join(t.TempDir(), "r.0.0.mca")
+		require.NoError(t, WriteRegionFile(file, r))
+		st, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, int64(headerSize+4*SectorSize), st.Size())
+
+		read, err := ReadRegionFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, 2, read.Len())
+		assert.Equal(t, r.Chunks[0], read.Chunks[0])
+		assert.Equal(t, r.Chunks[33], read.Chunks[33])
+		assert.False(t, read.Chunks[0].IsExternal())
+	})
+
+	t.Run("given an empty file should read an empty region", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "r.0.0.mca")
+		require.NoError(t, os.WriteFile(file, nil, 0o644))
+		r, err := ReadRegionFile(file)
+		require.NoError(t, err)
+		assert.Zero(t, r.Len())
+	})
+
+	t.Run("given a chunk out of the file should fail", func(t *testing.T) {
+		r := &Region{}
+		r.Chunks[0] = &Chunk{Compression: CompressionZlib, Data: []byte("chunk")}
+		var b bytes.Buffer
+		_, err := r.WriteTo(&b)
+		require.NoError(t, err)
+
+		truncated := b.Bytes()[:headerSize]
+		_, err = ReadRegion(bytes.NewReader(truncated), int64(len(truncated)))
+		assert.ErrorIs(t, err, ErrCorruptRegion)
+	})
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+// BackupEncryption are the backup encryption keys, from 'backup.encryption' config
+type BackupEncryption struct {
+	// Recipients are age public keys used to encrypt backups
+	Recipients     []string `mapstructure:"recipients"`
+	RecipientsFile string   `mapstructure:"recipients-file"`
+	// IdentityFile holds the age private keys used to decrypt backups
+	IdentityFile   string `mapstructure:"identity-file"`
+	Passphrase     string `mapstructure:"passphrase"`
+	PassphraseFile string `mapstructure:"passphrase-file"`
+}
+
+// GetBackupEncryption returns the configured backup encryption keys
+func GetBackupEncryption() (BackupEncryption, error) {
+	var e BackupEncryption
+	if err := viper.UnmarshalKey(BackupEncryptionPropKey, &e); err != nil {
+		return e, fmt.Errorf("parsing backup encryption: %w", err)
+	}
+	return e, nil
+}
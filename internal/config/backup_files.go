@@ -0,0 +1,27 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// GetBackupExcludes returns the ignore patterns leaving files out of
+// every backup, from 'backup.exclude' config
+func GetBackupExcludes() []string {
+	return viper.GetStringSlice(BackupExcludePropKey)
+}
+
+// GetBackupIncludes returns the patterns including files again in
+// every backup, from 'backup.include' config
+func GetBackupIncludes() []string {
+	return viper.GetStringSlice(BackupIncludePropKey)
+}
+
+// GetBackupFormat returns the default backup files format
+func GetBackupFormat() string {
+	return viper.GetString(BackupFormatPropKey)
+}
+
+// GetBackupCompressionLevel returns the default backup files compression level
+func GetBackupCompressionLevel() int {
+	return viper.GetInt(BackupCompressionLevelPropKey)
+}
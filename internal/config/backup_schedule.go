@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// AllInstances can be used as schedule instance to back up every registered instance
+	AllInstances = "*"
+)
+
+// BackupSchedule is a scheduled backup, from 'backup.schedules' config
+type BackupSchedule struct {
+	// Instance is the registered instance name (or folder), or AllInstances
+	Instance string `mapstructure:"instance"`
+	// Cron is the schedule cron expression ('0 */6 * * *', '@daily')
+	Cron string `mapstructure:"cron"`
+	// Jitter delays each run by a random duration up to it
+	Jitter       time.Duration `mapstructure:"jitter"`
+	BackupFolder string        `mapstructure:"backup-folder"`
+	Incremental  bool          `mapstructure:"incremental"`
+	Repository   string        `mapstructure:"repository"`
+	Verify       bool          `mapstructure:"verify"`
+	TestRestore  bool          `mapstructure:"test-restore"`
+	// Encrypt encrypts backup files with the 'backup.encryption' keys
+	Encrypt bool `mapstructure:"encrypt"`
+	// Format is the backup files format ('zip', 'tar.gz' or 'tar.zst'),
+	// defaults to 'backup.format' config
+	Format           string `mapstructure:"format"`
+	CompressionLevel int    `mapstructure:"compression-level"`
+	// Exclude and Include patterns are added to the 'backup.exclude'
+	// and 'backup.include' ones
+	Exclude   []string  `mapstructure:"exclude"`
+	Include   []string  `mapstructure:"include"`
+	WorldOnly bool      `mapstructure:"world-only"`
+	Retention Retention `mapstructure:"retention"`
+}
+
+// Retention are the backup retention rules
+type Retention struct {
+	KeepLast     int           `mapstructure:"keep-last"`
+	KeepHourly   int           `mapstructure:"keep-hourly"`
+	KeepDaily    int           `mapstructure:"keep-daily"`
+	KeepWeekly   int           `mapstructure:"keep-weekly"`
+	KeepMonthly  int           `mapstructure:"keep-monthly"`
+	KeepYearly   int           `mapstructure:"keep-yearly"`
+	KeepWithin   time.Duration `mapstructure:"keep-within"`
+	MaxTotalSize string        `mapstructure:"max-total-size"`
+}
+
+// GetBackupSchedules returns the configured backup schedules
+func GetBackupSchedules() ([]BackupSchedule, error) {
+	var schedules []BackupSchedule
+	if err := viper.UnmarshalKey(BackupSchedulesPropKey, &schedules); err != nil {
+		return nil, fmt.Errorf("parsing backup schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetBackupsPath returns the default backups folder (inside app's home folder)
+func GetBackupsPath() (string, error) {
+	home, err := appHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, BackupsFolderName), nil
+}
+
+// GetDaemonStatusPath returns the daemon status file path (inside app's home folder)
+func GetDaemonStatusPath() (string, error) {
+	home, err := appHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, DaemonStatusFileName), nil
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/utils"
 	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -13,6 +17,45 @@ func GetMinecraftApiTimeout() time.Duration {
 	return viper.GetDuration(AppMinecraftAPITimeoutPropKey)
 }
 
+func GetMinecraftApiCacheTTL() time.Duration {
+	return viper.GetDuration(AppMinecraftAPICacheTTLPropKey)
+}
+
+// GetRequestLogEnabled returns if HTTP requests should be logged
+func GetRequestLogEnabled() bool {
+	return viper.GetBool(AppRequestLogPropKey)
+}
+
 func GetAppHomePath() string {
 	return viper.GetString(AppHomePathPropKey)
 }
+
+// GetCachePath returns the cache folder path (inside app's home folder)
+func GetCachePath() (string, error) {
+	home, err := appHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, CacheFolderName), nil
+}
+
+// GetDatabasePath returns the instances database file path (inside
+// app's home folder), creating the home folder when it doesn't exist
+func GetDatabasePath() (string, error) {
+	home, err := appHome()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(home, os.ModePerm); err != nil {
+		return "", fmt.Errorf("creating app home folder: %w", err)
+	}
+	return filepath.Join(home, DatabaseFileName), nil
+}
+
+func appHome() (string, error) {
+	home := GetAppHomePath()
+	if home == "" {
+		home = AppHomeDefaultValue
+	}
+	return utils.AbsolutePath(home)
+}
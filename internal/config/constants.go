@@ -3,15 +3,30 @@ package config
 const (
 	AppInstallDownloadTimeoutPropKey = "install.download.timeout"
 	AppMinecraftAPITimeoutPropKey    = "minecraft.api.timeout"
+	AppMinecraftAPICacheTTLPropKey   = "minecraft.api.cache.ttl"
 
 	AppHomePathPropKey    = "app.home.path"
 	AppInstallPathPropKey = "app.install.path"
 	AppRequestLogPropKey  = "app.request.log"
 	AppDebugModePropKey   = "app.debugmode"
 
+	BackupSchedulesPropKey        = "backup.schedules"
+	BackupEncryptionPropKey       = "backup.encryption"
+	BackupExcludePropKey          = "backup.exclude"
+	BackupIncludePropKey          = "backup.include"
+	BackupFormatPropKey           = "backup.format"
+	BackupCompressionLevelPropKey = "backup.compression-level"
+
+	HooksPropKey = "hooks"
+
 	AppHomeDefaultValue = "~/.mineserver"
 
 	VersionsFileName = "versions.json"
+	DatabaseFileName = "mineserver.db"
+	CacheFolderName  = "cache"
+
+	BackupsFolderName    = "backups"
+	DaemonStatusFileName = "daemon-status.json"
 
 	AppName = "mineserver"
 )
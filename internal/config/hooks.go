@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// Hook is a command run around mineserver operations, from 'hooks'
+// config (by event, like 'pre-backup' or 'post-install')
+type Hook struct {
+	// Command is a shell command template
+	Command string `mapstructure:"command"`
+	// Template is a script template file, used when there's no command
+	Template string        `mapstructure:"template"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	// OnError is 'fail' (the default) or 'continue'
+	OnError string `mapstructure:"on-error"`
+}
+
+// GetHooks returns the configured hooks, by event
+func GetHooks() (map[string][]Hook, error) {
+	var hooks map[string][]Hook
+	if err := viper.UnmarshalKey(HooksPropKey, &hooks); err != nil {
+		return nil, fmt.Errorf("parsing hooks: %w", err)
+	}
+	return hooks, nil
+}
@@ -0,0 +1,117 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SchemeFile = "file"
+	SchemeSFTP = "sftp"
+	SchemeS3   = "s3"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported backup destination scheme")
+)
+
+// Destination is where backup files are stored: a local folder,
+// a folder on a SFTP server or an S3 bucket prefix
+type Destination interface {
+	// Put stores the content read from r as name
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get opens the stored file name
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List lists the stored files
+	List(ctx context.Context) ([]File, error)
+	// Delete removes the stored file name
+	Delete(ctx context.Context, name string) error
+	// Location returns the location of the stored file name, it
+	// can be opened again with OpenFile
+	Location(name string) string
+	// Close releases the destination connections
+	Close() error
+}
+
+// File is a stored backup file
+type File struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+type options struct {
+	knownHostsFile string
+}
+
+type Opt func(o *options)
+
+// WithKnownHostsFile defines the known hosts file used to check
+// SFTP servers keys (defaults to ~/.ssh/known_hosts)
+func WithKnownHostsFile(f string) Opt {
+	return func(o *options) {
+		o.knownHostsFile = f
+	}
+}
+
+// Open opens the destination at location, a local folder path or a
+// URL like 'sftp://user@host:22/backups' or 's3://bucket/prefix'
+func Open(ctx context.Context, location string, opts ...Opt) (Destination, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if !IsRemote(location) {
+		return NewLocal(strings.TrimPrefix(location, SchemeFile+"://"))
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("parsing backup destination: %w", err)
+	}
+	switch u.Scheme {
+	case SchemeSFTP:
+		return openSFTP(ctx, u, o)
+	case SchemeS3:
+		return openS3(ctx, u, o)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, u.Scheme)
+	}
+}
+
+// OpenFile opens the destination storing the file at location,
+// returning the destination and the file name
+func OpenFile(ctx context.Context, location string, opts ...Opt) (Destination, string, error) {
+	if !IsRemote(location) {
+		location = strings.TrimPrefix(location, SchemeFile+"://")
+		d, err := NewLocal(filepath.Dir(location))
+		return d, filepath.Base(location), err
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing backup file location: %w", err)
+	}
+	name := path.Base(u.Path)
+	u.Path = path.Dir(u.Path)
+	d, err := Open(ctx, u.String(), opts...)
+	return d, name, err
+}
+
+// IsRemote tells if location is an URL of a remote destination
+func IsRemote(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	return ok && scheme != SchemeFile
+}
+
+// withName returns u with name appended to its path, keeping its query
+func withName(u url.URL, name string) string {
+	u.Path = path.Join(u.Path, name)
+	return u.String()
+}
@@ -0,0 +1,94 @@
+package destination
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testDestination stores, lists, reads and deletes files on d
+func testDestination(t *testing.T, d Destination) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, d.Put(ctx, "first_backup.zip", strings.NewReader("first backup")))
+	require.NoError(t, d.Put(ctx, "second_backup.zip", strings.NewReader("stale content")))
+	// existing files are replaced
+	require.NoError(t, d.Put(ctx, "second_backup.zip", strings.NewReader("second backup content")))
+
+	files, err := d.List(ctx)
+	require.NoError(t, err)
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		sizes[f.Name] = f.Size
+	}
+	assert.Equal(t, map[string]int64{"first_backup.zip": 12, "second_backup.zip": 21}, sizes)
+
+	r, err := d.Get(ctx, "second_backup.zip")
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "second backup content", string(b))
+
+	require.NoError(t, d.Delete(ctx, "first_backup.zip"))
+	files, err = d.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "second_backup.zip", files[0].Name)
+
+	_, err = d.Get(ctx, "first_backup.zip")
+	assert.Error(t, err)
+}
+
+func TestLocal(t *testing.T) {
+	t.Run("given a local folder should store backup files on it", func(t *testing.T) {
+		folder := filepath.Join(t.TempDir(), "backups")
+		d, err := Open(context.Background(), folder)
+		require.NoError(t, err)
+		testDestination(t, d)
+		assert.FileExists(t, filepath.Join(folder, "second_backup.zip"))
+		assert.Equal(t, filepath.Join(folder, "my_backup.zip"), d.Location("my_backup.zip"))
+	})
+
+	t.Run("given a file URL should store backup files on its folder", func(t *testing.T) {
+		folder := t.TempDir()
+		d, err := Open(context.Background(), "file://"+folder)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(folder, "my_backup.zip"), d.Location("my_backup.zip"))
+	})
+}
+
+func TestOpenFile(t *testing.T) {
+	t.Run("given a local file should open its folder", func(t *testing.T) {
+		folder := t.TempDir()
+		d, name, err := OpenFile(context.Background(), filepath.Join(folder, "my_backup.zip"))
+		require.NoError(t, err)
+		assert.Equal(t, "my_backup.zip", name)
+		assert.Equal(t, filepath.Join(folder, "my_backup.zip"), d.Location(name))
+	})
+
+	t.Run("given a remote file should keep the destination parameters", func(t *testing.T) {
+		d, name, err := OpenFile(context.Background(), "s3://my-bucket/mc/my_backup.zip?endpoint=http://localhost:9000&path-style=true")
+		require.NoError(t, err)
+		assert.Equal(t, "my_backup.zip", name)
+		assert.Equal(t, "s3://my-bucket/mc/my_backup.zip?endpoint=http://localhost:9000&path-style=true", d.Location(name))
+	})
+
+	t.Run("given an unknown scheme should fail", func(t *testing.T) {
+		_, _, err := OpenFile(context.Background(), "ftp://my-server/backups/my_backup.zip")
+		assert.ErrorIs(t, err, ErrUnsupportedScheme)
+	})
+}
+
+func TestIsRemote(t *testing.T) {
+	assert.False(t, IsRemote("./backups"))
+	assert.False(t, IsRemote("/var/backups"))
+	assert.False(t, IsRemote("file:///var/backups"))
+	assert.True(t, IsRemote("sftp://backup@my-server/backups"))
+	assert.True(t, IsRemote("s3://my-bucket/mc"))
+}
@@ -0,0 +1,118 @@
+// Package destinationtest provides stand-ins for remote backup
+// destinations to be used on tests
+package destinationtest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeS3 is a local S3 stand-in supporting path style object
+// uploads, downloads, deletes and listings
+type FakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+type fakeS3Object struct {
+	Key          string
+	Size         int64
+	LastModified string
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	IsTruncated bool
+	Contents    []fakeS3Object
+}
+
+// NewS3Server starts a FakeS3 server, setting fake AWS credentials
+// on the environment. It's closed when the test ends.
+func NewS3Server(t testing.TB) (*httptest.Server, *FakeS3) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	s := &FakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return srv, s
+}
+
+// S3Location returns the destination location of a bucket prefix on srv
+func S3Location(srv *httptest.Server, bucketPrefix string) string {
+	return "s3://" + bucketPrefix + "?endpoint=" + srv.URL + "&path-style=true"
+}
+
+// Objects returns the stored objects keys (prefixed by their bucket)
+func (f *FakeS3) Objects() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		prefix := r.URL.Query().Get("prefix")
+		result := fakeS3ListResult{Name: bucket, Prefix: prefix}
+		var keys []string
+		for k := range f.objects {
+			if name, ok := strings.CutPrefix(k, bucket+"/"+prefix); ok && !strings.Contains(name, "/") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result.Contents = append(result.Contents, fakeS3Object{
+				Key:          strings.TrimPrefix(k, bucket+"/"),
+				Size:         int64(len(f.objects[k])),
+				LastModified: time.Now().UTC().Format(time.RFC3339),
+			})
+		}
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[bucket+"/"+key] = b
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet:
+		b, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		_, _ = w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,91 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores backup files on a local folder
+type Local struct {
+	folder string
+}
+
+// NewLocal creates a local destination on folder (it's created
+// when the first file is stored)
+func NewLocal(folder string) (*Local, error) {
+	folder, err := utils.ExpandPath(folder)
+	if err != nil {
+		return nil, fmt.Errorf("parsing backup folder: %w", err)
+	}
+	return &Local{folder: folder}, nil
+}
+
+func (l *Local) Put(_ context.Context, name string, r io.Reader) error {
+	if err := os.MkdirAll(l.folder, os.ModePerm); err != nil {
+		return fmt.Errorf("creating backup folder: %w", err)
+	}
+	tmp := l.Location(name) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("creating backup file: %w", err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing backup file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing backup file: %w", err)
+	}
+	if err := os.Rename(tmp, l.Location(name)); err != nil {
+		return fmt.Errorf("renaming backup file: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(l.Location(name))
+	if err != nil {
+		return nil, fmt.Errorf("opening backup file: %w", err)
+	}
+	return f, nil
+}
+
+func (l *Local) List(_ context.Context) ([]File, error) {
+	entries, err := os.ReadDir(l.folder)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup dir: %w", err)
+	}
+	var files []File
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+func (l *Local) Delete(_ context.Context, name string) error {
+	if err := os.Remove(l.Location(name)); err != nil {
+		return fmt.Errorf("deleting backup file: %w", err)
+	}
+	return nil
+}
+
+func (l *Local) Location(name string) string {
+	return filepath.Join(l.folder, name)
+}
+
+func (l *Local) Close() error {
+	return nil
+}
@@ -0,0 +1,140 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	s3EndpointQueryKey  = "endpoint"
+	s3RegionQueryKey    = "region"
+	s3PathStyleQueryKey = "path-style"
+
+	defaultS3Region = "us-east-1"
+)
+
+// S3 stores backup files on an S3 compatible bucket prefix. Credentials
+// come from the usual AWS environment variables and config files. The
+// 'endpoint', 'region' and 'path-style' query parameters configure
+// other S3 compatible services (e.g. 's3://backups/mc?endpoint=http://minio:9000&path-style=true').
+type S3 struct {
+	u      url.URL
+	bucket string
+	prefix string
+	client *s3.Client
+}
+
+func openS3(ctx context.Context, u *url.URL, _ *options) (*S3, error) {
+	if u.Host == "" {
+		return nil, errors.New("s3 destination bucket is required")
+	}
+	q := u.Query()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading s3 configuration: %w", err)
+	}
+	if r := q.Get(s3RegionQueryKey); r != "" {
+		cfg.Region = r
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+	// most S3 compatible services don't support the newer checksums
+	cfg.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+	cfg.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if e := q.Get(s3EndpointQueryKey); e != "" {
+			o.BaseEndpoint = aws.String(e)
+		}
+		o.UsePathStyle = q.Get(s3PathStyleQueryKey) == "true"
+	})
+	return &S3{
+		u:      *u,
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		client: client,
+	}, nil
+}
+
+func (s *S3) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+func (s *S3) Put(ctx context.Context, name string, r io.Reader) error {
+	// the uploader splits big files in multipart uploads
+	if _, err := manager.NewUploader(s.client).Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+		Body:   r,
+	}); err != nil {
+		return fmt.Errorf("uploading backup file: %w", err)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("downloading backup file: %w", err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3) List(ctx context.Context) ([]File, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	var files []File
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing backup files: %w", err)
+		}
+		for _, o := range page.Contents {
+			files = append(files, File{
+				Name:    strings.TrimPrefix(aws.ToString(o.Key), prefix),
+				Size:    aws.ToInt64(o.Size),
+				ModTime: aws.ToTime(o.LastModified),
+			})
+		}
+	}
+	return files, nil
+}
+
+func (s *S3) Delete(ctx context.Context, name string) error {
+	if _, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	}); err != nil {
+		return fmt.Errorf("deleting backup file: %w", err)
+	}
+	return nil
+}
+
+func (s *S3) Location(name string) string {
+	return withName(s.u, name)
+}
+
+func (s *S3) Close() error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package destination
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/destination/destinationtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestS3(t *testing.T) {
+	t.Run("given an S3 bucket prefix should store backup files on it", func(t *testing.T) {
+		srv, s := destinationtest.NewS3Server(t)
+		location := destinationtest.S3Location(srv, "my-bucket/mc")
+
+		d, err := Open(context.Background(), location)
+		require.NoError(t, err)
+		testDestination(t, d)
+		assert.Equal(t, []string{"my-bucket/mc/second_backup.zip"}, s.Objects())
+		assert.Equal(t, "s3://my-bucket/mc/my_backup.zip?endpoint="+srv.URL+"&path-style=true", d.Location("my_backup.zip"))
+	})
+
+	t.Run("given no bucket should fail", func(t *testing.T) {
+		_, err := Open(context.Background(), "s3:///mc")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,228 @@
+package destination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"time"
+)
+
+const (
+	defaultSSHPort       = "22"
+	defaultKnownHosts    = "~/.ssh/known_hosts"
+	sftpIdentityQueryKey = "identity"
+	sftpDialTimeout      = 30 * time.Second
+)
+
+var (
+	defaultIdentities = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+)
+
+// SFTP stores backup files on a SFTP server folder. The server key
+// must be on the known hosts file. Authentication uses the URL
+// password, the ssh-agent and the private key set on the 'identity'
+// query parameter (or the default ones on ~/.ssh).
+type SFTP struct {
+	u      url.URL
+	folder string
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+func openSFTP(ctx context.Context, u *url.URL, o *options) (*SFTP, error) {
+	username := u.User.Username()
+	if username == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("getting current user: %w", err)
+		}
+		username = usr.Username
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultSSHPort
+	}
+
+	knownHostsFile := o.knownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = defaultKnownHosts
+	}
+	knownHostsFile, err := utils.ExpandPath(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("parsing known hosts file path: %w", err)
+	}
+	hostKeys, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts file: %w", err)
+	}
+
+	addr := net.JoinHostPort(u.Hostname(), port)
+	dialer := net.Dialer{Timeout: sftpDialTimeout}
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to sftp server: %w", err)
+	}
+	auth, agentConn := sftpAuthMethods(u)
+	sshConn, chans, reqs, err := ssh.NewClientConn(c, addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sftpDialTimeout,
+	})
+	if agentConn != nil {
+		// the agent is only needed to authenticate
+		_ = agentConn.Close()
+	}
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("opening ssh connection: %w", err)
+	}
+	conn := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("opening sftp session: %w", err)
+	}
+
+	// the password is never shown on backup locations
+	location := *u
+	location.User = url.User(username)
+	return &SFTP{
+		u:      location,
+		folder: u.Path,
+		conn:   conn,
+		client: client,
+	}, nil
+}
+
+// sftpAuthMethods returns the available authentication methods and
+// the ssh-agent connection, if any, to be closed after authenticating
+func sftpAuthMethods(u *url.URL) ([]ssh.AuthMethod, net.Conn) {
+	var methods []ssh.AuthMethod
+	var agentConn net.Conn
+	if pass, ok := u.User.Password(); ok {
+		methods = append(methods, ssh.Password(pass))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if c, err := net.Dial("unix", sock); err == nil {
+			agentConn = c
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(c).Signers))
+		}
+	}
+
+	identities := defaultIdentities
+	if id := u.Query().Get(sftpIdentityQueryKey); id != "" {
+		identities = []string{id}
+	}
+	var signers []ssh.Signer
+	for _, id := range identities {
+		id, err := utils.ExpandPath(id)
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(id)
+		if err != nil {
+			continue
+		}
+		s, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			continue
+		}
+		signers = append(signers, s)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	return methods, agentConn
+}
+
+func (s *SFTP) path(name string) string {
+	return path.Join(s.folder, name)
+}
+
+func (s *SFTP) Put(_ context.Context, name string, r io.Reader) error {
+	if err := s.client.MkdirAll(s.folder); err != nil {
+		return fmt.Errorf("creating backup folder: %w", err)
+	}
+	tmp := s.path(name) + ".tmp"
+	f, err := s.client.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("creating backup file: %w", err)
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		_ = f.Close()
+		_ = s.client.Remove(tmp)
+		return fmt.Errorf("writing backup file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = s.client.Remove(tmp)
+		return fmt.Errorf("writing backup file: %w", err)
+	}
+	if err := s.rename(tmp, s.path(name)); err != nil {
+		_ = s.client.Remove(tmp)
+		return fmt.Errorf("renaming backup file: %w", err)
+	}
+	return nil
+}
+
+// rename replaces the target file, plain SFTP renames fail when the
+// target exists so servers without the posix-rename extension have
+// the target removed first
+func (s *SFTP) rename(from, to string) error {
+	if _, ok := s.client.HasExtension("posix-rename@openssh.com"); ok {
+		return s.client.PosixRename(from, to)
+	}
+	if err := s.client.Remove(to); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.client.Rename(from, to)
+}
+
+func (s *SFTP) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	f, err := s.client.Open(s.path(name))
+	if err != nil {
+		return nil, fmt.Errorf("opening backup file: %w", err)
+	}
+	return f, nil
+}
+
+func (s *SFTP) List(_ context.Context) ([]File, error) {
+	entries, err := s.client.ReadDir(s.folder)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup dir: %w", err)
+	}
+	var files []File
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, File{Name: e.Name(), Size: e.Size(), ModTime: e.ModTime()})
+	}
+	return files, nil
+}
+
+func (s *SFTP) Delete(_ context.Context, name string) error {
+	if err := s.client.Remove(s.path(name)); err != nil {
+		return fmt.Errorf("deleting backup file: %w", err)
+	}
+	return nil
+}
+
+func (s *SFTP) Location(name string) string {
+	return withName(s.u, name)
+}
+
+func (s *SFTP) Close() error {
+	return errors.Join(s.client.Close(), s.conn.Close())
+}
@@ -0,0 +1,130 @@
+package destination
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	sftpTestUser = "backup"
+	sftpTestPass = "MyP@ss"
+)
+
+// startSFTPServer starts an in-process SFTP server accepting the
+// test user password, returning its address and a known hosts file
+func startSFTPServer(t *testing.T) (string, string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == sftpTestUser && string(pass) == sftpTestPass {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(c, cfg)
+		}
+	}()
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(l.Addr().String())}, signer.PublicKey())
+	require.NoError(t, os.WriteFile(knownHosts, []byte(line+"\n"), 0o600))
+	return l.Addr().String(), knownHosts
+}
+
+func serveSFTP(c net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(c, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				// the payload is the subsystem name prefixed by its length
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+			}
+		}()
+		go func() {
+			defer func() {
+				_ = ch.Close()
+			}()
+			s, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			_ = s.Serve()
+		}()
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	addr, knownHosts := startSFTPServer(t)
+
+	t.Run("given a SFTP server folder should store backup files on it", func(t *testing.T) {
+		folder := filepath.Join(t.TempDir(), "backups")
+		location := fmt.Sprintf("sftp://%s:%s@%s%s", sftpTestUser, sftpTestPass, addr, folder)
+
+		d, err := Open(context.Background(), location, WithKnownHostsFile(knownHosts))
+		require.NoError(t, err)
+		defer func() {
+			_ = d.Close()
+		}()
+
+		testDestination(t, d)
+		assert.FileExists(t, filepath.Join(folder, "second_backup.zip"))
+		assert.Equal(t, fmt.Sprintf("sftp://%s@%s%s/my_backup.zip", sftpTestUser, addr, folder), d.Location("my_backup.zip"))
+	})
+
+	t.Run("given an unknown server key should fail", func(t *testing.T) {
+		emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+		require.NoError(t, os.WriteFile(emptyKnownHosts, nil, 0o600))
+
+		_, err := Open(context.Background(), fmt.Sprintf("sftp://%s:%s@%s/backups", sftpTestUser, sftpTestPass, addr), WithKnownHostsFile(emptyKnownHosts))
+		assert.Error(t, err)
+	})
+
+	t.Run("given invalid credentials should fail", func(t *testing.T) {
+		_, err := Open(context.Background(), fmt.Sprintf("sftp://%s:wrong@%s/backups", sftpTestUser, addr), WithKnownHostsFile(knownHosts))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// Extension is appended to encrypted backup files names
+	Extension = ".age"
+
+	header = "age-encryption.org/"
+)
+
+var (
+	ErrNoDecryptionKeys = errors.New("backup is encrypted, inform an identity file or a passphrase to decrypt it")
+	ErrNoEncryptionKeys = errors.New("inform recipients or a passphrase to encrypt backups")
+)
+
+// Config describes the encryption keys. Backups are encrypted to age
+// recipients (public keys) and decrypted with the matching identities
+// (private keys), or encrypted and decrypted with a passphrase (its
+// key is derived with scrypt). The passphrase can't be used along
+// with recipients.
+type Config struct {
+	// Recipients are age public keys ('age1...')
+	Recipients []string
+	// RecipientsFile is a file listing age public keys, one per line
+	RecipientsFile string
+	// Identities are age private keys ('AGE-SECRET-KEY-1...')
+	Identities []string
+	// IdentityFile is a file with age private keys
+	IdentityFile string
+	Passphrase   string
+	// PassphraseFile is a file holding the passphrase
+	PassphraseFile string
+}
+
+// Keys are the loaded encryption recipients and decryption identities
+type Keys struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// NewKeys loads the keys described by cfg
+func NewKeys(cfg Config) (*Keys, error) {
+	k := &Keys{}
+	for _, r := range cfg.Recipients {
+		rcpt, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("parsing recipient '%s': %w", r, err)
+		}
+		k.recipients = append(k.recipients, rcpt)
+	}
+	if cfg.RecipientsFile != "" {
+		b, err := readFile(cfg.RecipientsFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading recipients file: %w", err)
+		}
+		rcpts, err := age.ParseRecipients(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("parsing recipients file: %w", err)
+		}
+		k.recipients = append(k.recipients, rcpts...)
+	}
+	for _, i := range cfg.Identities {
+		id, err := age.ParseX25519Identity(strings.TrimSpace(i))
+		if err != nil {
+			return nil, fmt.Errorf("parsing identity: %w", err)
+		}
+		k.identities = append(k.identities, id)
+	}
+	if cfg.IdentityFile != "" {
+		b, err := readFile(cfg.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading identity file: %w", err)
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("parsing identity file: %w", err)
+		}
+		k.identities = append(k.identities, ids...)
+	}
+
+	pass := cfg.Passphrase
+	if cfg.PassphraseFile != "" {
+		b, err := readFile(cfg.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase file: %w", err)
+		}
+		pass = strings.TrimRight(string(b), "\r\n")
+	}
+	if pass == "" {
+		return k, nil
+	}
+	if len(k.recipients) > 0 {
+		return nil, errors.New("a passphrase can't be used along with recipients")
+	}
+	rcpt, err := age.NewScryptRecipient(pass)
+	if err != nil {
+		return nil, fmt.Errorf("creating passphrase recipient: %w", err)
+	}
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return nil, fmt.Errorf("creating passphrase identity: %w", err)
+	}
+	k.recipients = append(k.recipients, rcpt)
+	k.identities = append(k.identities, id)
+	return k, nil
+}
+
+func readFile(f string) ([]byte, error) {
+	f, err := utils.ExpandPath(f)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(f)
+}
+
+// CanEncrypt tells if there are keys to encrypt backups
+func (k *Keys) CanEncrypt() bool {
+	return k != nil && len(k.recipients) > 0
+}
+
+// CanDecrypt tells if there are keys to decrypt backups
+func (k *Keys) CanDecrypt() bool {
+	return k != nil && len(k.identities) > 0
+}
+
+// Encrypt returns a writer encrypting to w, it must be closed to
+// flush the last encrypted chunk
+func (k *Keys) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	if !k.CanEncrypt() {
+		return nil, ErrNoEncryptionKeys
+	}
+	return age.Encrypt(w, k.recipients...)
+}
+
+// EncryptReader returns a reader with the encrypted content of r
+func (k *Keys) EncryptReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := k.Encrypt(pw)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			err = errors.Join(err, w.Close())
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// Decrypt returns a reader decrypting r
+func (k *Keys) Decrypt(r io.Reader) (io.Reader, error) {
+	if !k.CanDecrypt() {
+		return nil, ErrNoDecryptionKeys
+	}
+	d, err := age.Decrypt(r, k.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting backup: %w", err)
+	}
+	return d, nil
+}
+
+// IsEncrypted tells if the content of r starts with an encryption
+// header. The returned reader still holds the whole content.
+func IsEncrypted(r io.Reader) (bool, io.Reader, error) {
+	br := bufio.NewReader(r)
+	b, err := br.Peek(len(header))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, br, fmt.Errorf("reading backup header: %w", err)
+	}
+	return string(b) == header, br, nil
+}
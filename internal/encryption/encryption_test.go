@@ -0,0 +1,123 @@
+package encryption
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func roundTrip(t *testing.T, enc, dec *Keys, content string) (string, error) {
+	t.Helper()
+	r := enc.EncryptReader(strings.NewReader(content))
+	encrypted, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), content)
+
+	ok, br, err := IsEncrypted(bytes.NewReader(encrypted))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	d, err := dec.Decrypt(br)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(d)
+	return string(b), err
+}
+
+func TestKeys(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	idFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(idFile, []byte(id.String()+"\n"), 0o600))
+
+	t.Run("given recipients should encrypt to them and decrypt with their identity", func(t *testing.T) {
+		enc, err := NewKeys(Config{Recipients: []string{id.Recipient().String()}})
+		require.NoError(t, err)
+		assert.True(t, enc.CanEncrypt())
+		assert.False(t, enc.CanDecrypt())
+
+		dec, err := NewKeys(Config{IdentityFile: idFile})
+		require.NoError(t, err)
+		content, err := roundTrip(t, enc, dec, "rcon.password=MyP@ss")
+		require.NoError(t, err)
+		assert.Equal(t, "rcon.password=MyP@ss", content)
+	})
+
+	t.Run("given a recipients file should encrypt to its recipients", func(t *testing.T) {
+		rcptFile := filepath.Join(t.TempDir(), "recipients.txt")
+		require.NoError(t, os.WriteFile(rcptFile, []byte("# backup key\n"+id.Recipient().String()+"\n"), 0o600))
+		enc, err := NewKeys(Config{RecipientsFile: rcptFile})
+		require.NoError(t, err)
+
+		dec, err := NewKeys(Config{IdentityFile: idFile})
+		require.NoError(t, err)
+		content, err := roundTrip(t, enc, dec, "level data")
+		require.NoError(t, err)
+		assert.Equal(t, "level data", content)
+	})
+
+	t.Run("given a passphrase file should encrypt and decrypt with it", func(t *testing.T) {
+		passFile := filepath.Join(t.TempDir(), "passphrase")
+		require.NoError(t, os.WriteFile(passFile, []byte("correct horse battery staple\n"), 0o600))
+		k, err := NewKeys(Config{PassphraseFile: passFile})
+		require.NoError(t, err)
+
+		content, err := roundTrip(t, k, k, "level data")
+		require.NoError(t, err)
+		assert.Equal(t, "level data", content)
+
+		wrong, err := NewKeys(Config{Passphrase: "wrong"})
+		require.NoError(t, err)
+		_, err = roundTrip(t, k, wrong, "level data")
+		assert.Error(t, err)
+	})
+
+	t.Run("given another identity should fail to decrypt", func(t *testing.T) {
+		other, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		enc, err := NewKeys(Config{Recipients: []string{other.Recipient().String()}})
+		require.NoError(t, err)
+		dec, err := NewKeys(Config{IdentityFile: idFile})
+		require.NoError(t, err)
+
+		_, err = roundTrip(t, enc, dec, "level data")
+		assert.Error(t, err)
+	})
+
+	t.Run("given no identities should not decrypt", func(t *testing.T) {
+		k, err := NewKeys(Config{})
+		require.NoError(t, err)
+		_, err = k.Decrypt(strings.NewReader("age-encryption.org/v1\n"))
+		assert.ErrorIs(t, err, ErrNoDecryptionKeys)
+	})
+
+	t.Run("given a passphrase along with recipients should fail", func(t *testing.T) {
+		_, err := NewKeys(Config{Recipients: []string{id.Recipient().String()}, Passphrase: "secret"})
+		assert.Error(t, err)
+	})
+
+	t.Run("given an invalid recipient should fail", func(t *testing.T) {
+		_, err := NewKeys(Config{Recipients: []string{"age1invalid"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestIsEncrypted(t *testing.T) {
+	ok, r, err := IsEncrypted(strings.NewReader("PK\x03\x04 zip content"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "PK\x03\x04 zip content", string(b))
+
+	ok, _, err = IsEncrypted(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
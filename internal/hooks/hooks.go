@@ -0,0 +1,264 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Event is when hooks run, around mineserver operations
+type Event string
+
+const (
+	PreInstall  Event = "pre-install"
+	PostInstall Event = "post-install"
+	PreBackup   Event = "pre-backup"
+	PostBackup  Event = "post-backup"
+	PreRestore  Event = "pre-restore"
+	PostRestore Event = "post-restore"
+	PreUpgrade  Event = "pre-upgrade"
+	PostUpgrade Event = "post-upgrade"
+)
+
+// OnError tells what a failing hook does to its operation
+type OnError string
+
+const (
+	// OnErrorFail fails the operation (pre hooks stop it from running)
+	OnErrorFail OnError = "fail"
+	// OnErrorContinue only logs the hook failure
+	OnErrorContinue OnError = "continue"
+
+	// DefaultTimeout is how long hooks can run when they have no timeout
+	DefaultTimeout = time.Minute
+)
+
+var (
+	Events = []Event{PreInstall, PostInstall, PreBackup, PostBackup, PreRestore, PostRestore, PreUpgrade, PostUpgrade}
+
+	ErrUnknownEvent = errors.New("unknown hook event")
+)
+
+// Hook is a shell command (or a script template file) run around an
+// operation. Both are text/template templates rendered with Data, its
+// values are shell quoted (so they must not be quoted again).
+type Hook struct {
+	Command string
+	// Template is a script template file, used when there's no Command
+	Template string
+	Timeout  time.Duration
+	// OnError defaults to OnErrorFail
+	OnError OnError
+}
+
+// Data describes the operation a hook runs around, hooks get it on
+// MINESERVER_* environment variables too
+type Data struct {
+	Event        Event
+	InstancePath string
+	InstanceName string
+	MineFlavour  string
+	MineVersion  string
+	BackupFile   string
+	// Err is the operation error (post hooks only)
+	Err error
+}
+
+// Status returns 'success' or 'failure', from the operation error
+func (d Data) Status() string {
+	if d.Err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Env returns the hook environment variables
+func (d Data) Env() []string {
+	env := []string{
+		"MINESERVER_EVENT=" + string(d.Event),
+		"MINESERVER_INSTANCE_PATH=" + d.InstancePath,
+		"MINESERVER_INSTANCE_NAME=" + d.InstanceName,
+		"MINESERVER_FLAVOUR=" + d.MineFlavour,
+		"MINESERVER_VERSION=" + d.MineVersion,
+		"MINESERVER_BACKUP_FILE=" + d.BackupFile,
+		"MINESERVER_STATUS=" + d.Status(),
+	}
+	if d.Err != nil {
+		env = append(env, "MINESERVER_ERROR="+d.Err.Error())
+	}
+	return env
+}
+
+// templateData is Data with shell quoted values, for rendering hooks
+type templateData struct {
+	Event        string
+	InstancePath string
+	InstanceName string
+	MineFlavour  string
+	MineVersion  string
+	BackupFile   string
+	Status       string
+	Err          string
+}
+
+func (d Data) templateData() templateData {
+	var errMsg string
+	if d.Err != nil {
+		errMsg = d.Err.Error()
+	}
+	return templateData{
+		Event:        shellQuote(string(d.Event)),
+		InstancePath: shellQuote(d.InstancePath),
+		InstanceName: shellQuote(d.InstanceName),
+		MineFlavour:  shellQuote(d.MineFlavour),
+		MineVersion:  shellQuote(d.MineVersion),
+		BackupFile:   shellQuote(d.BackupFile),
+		Status:       shellQuote(d.Status()),
+		Err:          shellQuote(errMsg),
+	}
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Runner runs the hooks of each event
+type Runner struct {
+	hooks  map[Event][]hook
+	shell  string
+	output io.Writer
+}
+
+type hook struct {
+	Hook
+	tmpl *template.Template
+}
+
+type Opt func(r *Runner)
+
+// WithShell defines the shell running the hooks (defaults to 'sh')
+func WithShell(shell string) Opt {
+	return func(r *Runner) {
+		r.shell = shell
+	}
+}
+
+// WithOutput defines where hooks output goes (defaults to stdout)
+func WithOutput(w io.Writer) Opt {
+	return func(r *Runner) {
+		r.output = w
+	}
+}
+
+// NewRunner parses the hooks of each event
+func NewRunner(hooks map[Event][]Hook, opts ...Opt) (*Runner, error) {
+	r := &Runner{
+		hooks:  make(map[Event][]hook),
+		shell:  "sh",
+		output: os.Stdout,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for event, list := range hooks {
+		if !slices.Contains(Events, event) {
+			return nil, fmt.Errorf("%w: '%s' (use one of %v)", ErrUnknownEvent, event, Events)
+		}
+		for i, h := range list {
+			parsed, err := parseHook(h)
+			if err != nil {
+				return nil, fmt.Errorf("%s hook %d: %w", event, i+1, err)
+			}
+			r.hooks[event] = append(r.hooks[event], parsed)
+		}
+	}
+	return r, nil
+}
+
+func parseHook(h Hook) (hook, error) {
+	switch h.OnError {
+	case "":
+		h.OnError = OnErrorFail
+	case OnErrorFail, OnErrorContinue:
+	default:
+		return hook{}, fmt.Errorf("invalid on-error value '%s' (use '%s' or '%s')", h.OnError, OnErrorFail, OnErrorContinue)
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = DefaultTimeout
+	}
+	text := h.Command
+	if text == "" {
+		if h.Template == "" {
+			return hook{}, errors.New("command or template is required")
+		}
+		b, err := os.ReadFile(h.Template)
+		if err != nil {
+			return hook{}, fmt.Errorf("reading template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("hook").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return hook{}, fmt.Errorf("parsing template: %w", err)
+	}
+	return hook{Hook: h, tmpl: tmpl}, nil
+}
+
+// Run runs the event hooks, in order. Hooks failing with OnErrorFail
+// stop the next ones from running. A nil Runner runs nothing.
+func (r *Runner) Run(ctx context.Context, event Event, data Data) error {
+	if r == nil {
+		return nil
+	}
+	data.Event = event
+	for i, h := range r.hooks[event] {
+		log := logger.GetLogger().With("action", "hook", "event", event, "hook", i+1, "instance_path", data.InstancePath)
+		err := r.run(ctx, h, data)
+		if err == nil {
+			log.DebugContext(ctx, "Hook finished")
+			continue
+		}
+		err = fmt.Errorf("%s hook %d: %w", event, i+1, err)
+		if h.OnError == OnErrorContinue {
+			log.With("error", err).WarnContext(ctx, "Hook failed")
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *Runner) run(ctx context.Context, h hook, data Data) error {
+	var script bytes.Buffer
+	if err := h.tmpl.Execute(&script, data.templateData()); err != nil {
+		return fmt.Errorf("rendering: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.shell, "-c", script.String())
+	cmd.Env = append(os.Environ(), data.Env()...)
+	cmd.Stdout = r.output
+	cmd.Stderr = r.output
+	cmd.WaitDelay = time.Second
+	if st, err := os.Stat(data.InstancePath); err == nil && st.IsDir() {
+		cmd.Dir = data.InstancePath
+	}
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s", h.Timeout)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	data := Data{
+		InstancePath: t.TempDir(),
+		InstanceName: "my-server",
+		MineFlavour:  "vanilla",
+		MineVersion:  "1.21.4",
+		BackupFile:   "/backups/my-server.zip",
+	}
+
+	t.Run("given a command hook should run it with the instance environment variables", func(t *testing.T) {
+		var out bytes.Buffer
+		r, err := NewRunner(map[Event][]Hook{
+			PostBackup: {{Command: `echo "$MINESERVER_EVENT $MINESERVER_INSTANCE_NAME $MINESERVER_BACKUP_FILE $MINESERVER_STATUS"; pwd`}},
+		}, WithOutput(&out))
+		require.NoError(t, err)
+
+		require.NoError(t, r.Run(context.Background(), PostBackup, data))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.Equal(t, "post-backup my-server /backups/my-server.zip success", lines[0])
+		wd, err := filepath.EvalSymlinks(data.InstancePath)
+		require.NoError(t, err)
+		assert.Equal(t, wd, lines[1])
+	})
+
+	t.Run("given a template hook should render it with the operation data", func(t *testing.T) {
+		tmpl := filepath.Join(t.TempDir(), "hook.sh.tmpl")
+		require.NoError(t, os.WriteFile(tmpl, []byte(`echo {{ .Event }} {{ .MineFlavour }} {{ .MineVersion }} {{ .Status }}`), 0o644))
+		var out bytes.Buffer
+		r, err := NewRunner(map[Event][]Hook{PostUpgrade: {{Template: tmpl}}}, WithOutput(&out))
+		require.NoError(t, err)
+
+		failed := data
+		failed.Err = errors.New("download failed")
+		require.NoError(t, r.Run(context.Background(), PostUpgrade, failed))
+		assert.Equal(t, "post-upgrade vanilla 1.21.4 failure\n", out.String())
+	})
+
+	t.Run("given values with shell characters should render them quoted", func(t *testing.T) {
+		var out bytes.Buffer
+		r, err := NewRunner(map[Event][]Hook{
+			PostBackup: {{Command: `printf '%s\n' {{ .BackupFile }} {{ .Err }}`}},
+		}, WithOutput(&out))
+		require.NoError(t, err)
+
+		unsafe := data
+		unsafe.BackupFile = "/backups/it's $(echo injected) ; `id`.zip"
+		unsafe.Err = errors.New("'quoted' failure")
+		require.NoError(t, r.Run(context.Background(), PostBackup, unsafe))
+		assert.Equal(t, "/backups/it's $(echo injected) ; `id`.zip\n'quoted' failure\n", out.String())
+	})
+
+	t.Run("given a failing hook should fail and skip the next hooks", func(t *testing.T) {
+		var out bytes.Buffer
+		r, err := NewRunner(map[Event][]Hook{
+			PreRestore: {{Command: "exit 3"}, {Command: "echo next"}},
+		}, WithOutput(&out))
+		require.NoError(t, err)
+
+		err = r.Run(context.Background(), PreRestore, data)
+		assert.ErrorContains(t, err, "pre-restore hook 1")
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("given a failing hook set to continue should run the next hooks", func(t *testing.T) {
+		var out bytes.Buffer
+		r, err := NewRunner(map[Event][]Hook{
+			PreInstall: {{Command: "exit 3", OnError: OnErrorContinue}, {Command: "echo next"}},
+		}, WithOutput(&out))
+		require.NoError(t, err)
+
+		require.NoError(t, r.Run(context.Background(), PreInstall, data))
+		assert.Equal(t, "next\n", out.String())
+	})
+
+	t.Run("given a hook running past its timeout should fail", func(t *testing.T) {
+		r, err := NewRunner(map[Event][]Hook{
+			PreBackup: {{Command: "sleep 5", Timeout: 50 * time.Millisecond}},
+		}, WithOutput(&bytes.Buffer{}))
+		require.NoError(t, err)
+
+		start := time.Now()
+		err = r.Run(context.Background(), PreBackup, data)
+		assert.ErrorContains(t, err, "timed out after 50ms")
+		assert.Less(t, time.Since(start), 3*time.Second)
+	})
+
+	t.Run("given invalid hooks should fail to create the runner", func(t *testing.T) {
+		_, err := NewRunner(map[Event][]Hook{"pre-start": {{Command: "true"}}})
+		assert.ErrorIs(t, err, ErrUnknownEvent)
+
+		_, err = NewRunner(map[Event][]Hook{PreBackup: {{Command: "true", OnError: "ignore"}}})
+		assert.ErrorContains(t, err, "invalid on-error value")
+
+		_, err = NewRunner(map[Event][]Hook{PreBackup: {{}}})
+		assert.ErrorContains(t, err, "command or template is required")
+
+		_, err = NewRunner(map[Event][]Hook{PreBackup: {{Command: "echo {{ .Unknown"}}})
+		assert.ErrorContains(t, err, "parsing template")
+	})
+
+	t.Run("given a nil runner should run nothing", func(t *testing.T) {
+		var r *Runner
+		assert.NoError(t, r.Run(context.Background(), PreBackup, data))
+	})
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"github.com/eldius/mineserver-manager/internal/model"
 	"github.com/eldius/mineserver-manager/internal/mojang"
+	"sync"
 )
 
 type vanillaFlavor struct {
 	client mojang.Client
+
+	// manifest is the versions manifest, fetched once per flavor instance
+	manifest *mojang.VersionsResponse
+	mu       sync.Mutex
 }
 
 func NewVanillaFlavor(client mojang.Client) ServerFlavor {
@@ -22,7 +27,7 @@ func (f *vanillaFlavor) Name() model.MineFlavour {
 }
 
 func (f *vanillaFlavor) ListVersions(ctx context.Context) ([]string, error) {
-	ver, err := f.client.ListVersions(ctx)
+	ver, err := f.versions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing vanilla versions: %w", err)
 	}
@@ -34,7 +39,7 @@ func (f *vanillaFlavor) ListVersions(ctx context.Context) ([]string, error) {
 }
 
 func (f *vanillaFlavor) GetVersionInfo(ctx context.Context, version string) (*FlavorVersionInfo, error) {
-	ver, err := f.client.ListVersions(ctx)
+	ver, err := f.versions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting versions list: %w", err)
 	}
@@ -54,3 +59,19 @@ func (f *vanillaFlavor) GetVersionInfo(ctx context.Context, version string) (*Fl
 		JavaVersion: info.JavaVersion.MajorVersion,
 	}, nil
 }
+
+// versions returns the versions manifest, fetching it on first call
+func (f *vanillaFlavor) versions(ctx context.Context) (*mojang.VersionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.manifest != nil {
+		return f.manifest, nil
+	}
+	ver, err := f.client.ListVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.manifest = ver
+	return ver, nil
+}
@@ -0,0 +1,55 @@
+package installer
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type mockMojangClient struct {
+	mojang.Client
+	mock.Mock
+}
+
+func (m *mockMojangClient) ListVersions(ctx context.Context) (*mojang.VersionsResponse, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*mojang.VersionsResponse), args.Error(1)
+}
+
+func (m *mockMojangClient) GetVersionInfo(ctx context.Context, v mojang.Version) (*mojang.VersionInfoResponse, error) {
+	args := m.Called(ctx, v)
+	return args.Get(0).(*mojang.VersionInfoResponse), args.Error(1)
+}
+
+func TestVanillaFlavor_GetVersionInfo(t *testing.T) {
+	t.Run("given many calls should fetch versions manifest only once", func(t *testing.T) {
+		ctx := context.Background()
+		v := mojang.Version{ID: "1.21.4", URL: "https://piston-meta.mojang.com/1.21.4.json", SHA1: "abc"}
+		c := new(mockMojangClient)
+		c.On("ListVersions", ctx).Return(&mojang.VersionsResponse{
+			Latest:   mojang.Latest{Release: "1.21.4"},
+			Versions: []mojang.Version{v},
+		}, nil).Once()
+		c.On("GetVersionInfo", ctx, v).Return(&mojang.VersionInfoResponse{
+			ID:          "1.21.4",
+			JavaVersion: mojang.JavaVersion{MajorVersion: 21},
+			Downloads:   mojang.Downloads{Server: mojang.Artifact{SHA1: "def", URL: "https://piston-data.mojang.com/server.jar"}},
+		}, nil).Twice()
+
+		f := NewVanillaFlavor(c)
+		for _, version := range []string{"latest", "1.21.4"} {
+			info, err := f.GetVersionInfo(ctx, version)
+			assert.NoError(t, err)
+			assert.Equal(t, "1.21.4", info.Version)
+			assert.Equal(t, 21, info.JavaVersion)
+		}
+
+		versions, err := f.ListVersions(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1.21.4"}, versions)
+
+		c.AssertExpectations(t)
+	})
+}
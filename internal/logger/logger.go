@@ -1,14 +1,18 @@
 package logger
 
-import "log/slog"
+import (
+	"log/slog"
+	"sync"
+)
 
 var (
 	logger *slog.Logger
+	once   sync.Once
 )
 
 func GetLogger() *slog.Logger {
-	if logger == nil {
+	once.Do(func() {
 		logger = slog.With("app", "mineserver")
-	}
+	})
 	return logger
 }
@@ -0,0 +1,234 @@
+package minecraft
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"github.com/google/uuid"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// metadataExtension is appended to backup file names to name
+	// their metadata (sidecar) files
+	metadataExtension = ".meta.json"
+)
+
+var (
+	ErrNoBackupCatalog = errors.New("backups catalog not available")
+)
+
+// BackupFilter selects backups by instance and creation time, zero
+// fields match every backup
+type BackupFilter struct {
+	// Instance is the instance name or ID
+	Instance string
+	Since    time.Time
+	Until    time.Time
+}
+
+// Match tells if the backup matches the filter
+func (f BackupFilter) Match(b BackupInfo) bool {
+	if f.Instance != "" && f.Instance != b.Name {
+		if b.Metadata == nil || (f.Instance != b.Metadata.InstanceID && f.Instance != b.Metadata.InstanceName) {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && b.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && b.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// storedFile is a stored backup file size and checksum
+type storedFile struct {
+	hash     hash.Hash
+	size     int64
+	checksum string
+}
+
+func (f *storedFile) Write(p []byte) (int, error) {
+	f.size += int64(len(p))
+	return f.hash.Write(p)
+}
+
+// hashStoredFile computes a local backup file size and checksum
+func hashStoredFile(file string) (*storedFile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	stored := &storedFile{hash: sha256.New()}
+	if _, err := io.Copy(stored, f); err != nil {
+		return nil, fmt.Errorf("hashing backup file: %w", err)
+	}
+	stored.checksum = hex.EncodeToString(stored.hash.Sum(nil))
+	return stored, nil
+}
+
+// newBackupRecord creates the metadata record of an instance backup,
+// the instance ID comes from the repository (when there's one) and
+// the server flavour and version from the instance versions file
+func (s *backupService) newBackupRecord(ctx context.Context, instancePath, location, fileName string, ts time.Time) *model.BackupRecord {
+	record := &model.BackupRecord{
+		ID:           uuid.New().String(),
+		InstanceName: filepath.Base(instancePath),
+		InstancePath: instancePath,
+		Location:     location,
+		File:         fileName,
+		Format:       string(s.format),
+		Encrypted:    s.keys.CanEncrypt(),
+		Trigger:      s.trigger,
+		CreatedAt:    ts,
+	}
+	if s.repo != nil {
+		if i, err := s.repo.GetInstanceByPath(ctx, instancePath); err == nil {
+			record.InstanceID = i.ID
+			record.InstanceName = i.Name
+		}
+	}
+	if v, err := readVersionFile(instancePath); err == nil {
+		record.MineFlavour = v.MineFlavour
+		record.MineVersion = v.MineVersion
+	}
+	return record
+}
+
+// saveBackupRecord stores the record next to its backup file and on
+// the backups catalog. Failing to update the catalog isn't an error,
+// the metadata file is enough to rebuild it.
+func (s *backupService) saveBackupRecord(ctx context.Context, dest destination.Destination, record *model.BackupRecord) error {
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("serializing backup metadata: %w", err)
+	}
+	if err := dest.Put(ctx, record.File+metadataExtension, bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("writing backup metadata file: %w", err)
+	}
+	if s.repo == nil {
+		return nil
+	}
+	if err := s.repo.SaveBackup(ctx, record); err != nil {
+		logger.GetLogger().With("error", err, "backup_file", record.Location).
+			WarnContext(ctx, "failed to add backup to catalog")
+	}
+	return nil
+}
+
+// deleteBackupRecord removes a backup metadata file and its catalog
+// record
+func (s *backupService) deleteBackupRecord(ctx context.Context, dest destination.Destination, record *model.BackupRecord) error {
+	if err := dest.Delete(ctx, record.File+metadataExtension); err != nil {
+		return fmt.Errorf("deleting backup metadata file: %w", err)
+	}
+	if s.repo == nil {
+		return nil
+	}
+	if err := s.repo.DeleteBackup(ctx, record.ID); err != nil && !errors.Is(err, repository.ErrBackupNotFound) {
+		logger.GetLogger().With("error", err, "backup_file", record.Location).
+			WarnContext(ctx, "failed to remove backup from catalog")
+	}
+	return nil
+}
+
+// readBackupRecords reads the metadata files found on entries, by
+// backup file name. Unreadable metadata files are ignored (their
+// backup files are listed by name).
+func readBackupRecords(ctx context.Context, dest destination.Destination, entries []destination.File) map[string]*model.BackupRecord {
+	records := make(map[string]*model.BackupRecord)
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name, metadataExtension) {
+			continue
+		}
+		record, err := readBackupRecord(ctx, dest, e.Name)
+		if err != nil {
+			logger.GetLogger().With("error", err, "metadata_file", e.Name).
+				WarnContext(ctx, "failed to read backup metadata file")
+			continue
+		}
+		records[strings.TrimSuffix(e.Name, metadataExtension)] = record
+	}
+	return records
+}
+
+func readBackupRecord(ctx context.Context, dest destination.Destination, name string) (*model.BackupRecord, error) {
+	r, err := dest.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	var record model.BackupRecord
+	if err := json.NewDecoder(r).Decode(&record); err != nil {
+		return nil, fmt.Errorf("parsing backup metadata: %w", err)
+	}
+	return &record, nil
+}
+
+// groupByInstance names the backups of the same registered instance
+// after its latest backup instance name, so backups taken before the
+// instance folder was renamed are kept together
+func groupByInstance(backups []BackupInfo) {
+	latest := make(map[string]*model.BackupRecord)
+	for _, b := range backups {
+		if b.Metadata == nil || b.Metadata.InstanceID == "" {
+			continue
+		}
+		if l, ok := latest[b.Metadata.InstanceID]; !ok || l.CreatedAt.Before(b.Metadata.CreatedAt) {
+			latest[b.Metadata.InstanceID] = b.Metadata
+		}
+	}
+	for i, b := range backups {
+		if b.Metadata == nil || b.Metadata.InstanceID == "" {
+			continue
+		}
+		backups[i].Name = latest[b.Metadata.InstanceID].InstanceName
+	}
+}
+
+// listCatalog lists the backups catalog records matching filter
+func (s *backupService) listCatalog(ctx context.Context, filter BackupFilter) ([]BackupInfo, error) {
+	if s.repo == nil {
+		return nil, ErrNoBackupCatalog
+	}
+	records, err := s.repo.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]BackupInfo, 0, len(records))
+	for _, r := range records {
+		backups = append(backups, BackupInfo{
+			Timestamp: r.CreatedAt,
+			Name:      r.InstanceName,
+			Path:      r.Location,
+			Size:      r.Size,
+			Metadata:  &r,
+			file:      r.File,
+		})
+	}
+	groupByInstance(backups)
+	return slices.DeleteFunc(backups, func(b BackupInfo) bool {
+		return !filter.Match(b)
+	}), nil
+}
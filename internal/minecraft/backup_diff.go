@@ -0,0 +1,233 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/anvil"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"github.com/pmezard/go-difflib/difflib"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Change is how a file changed between backups
+type Change string
+
+const (
+	ChangeAdded   Change = "added"
+	ChangeRemoved Change = "removed"
+	ChangeChanged Change = "changed"
+
+	DiffGroupConfig = "config"
+	DiffGroupOther  = "other"
+)
+
+var (
+	// textConfigExtensions are the config files extensions shown on
+	// text diffs (files outside world folders)
+	textConfigExtensions = []string{".properties", ".json", ".yml", ".yaml", ".toml", ".txt", ".conf", ".cfg", ".ini"}
+)
+
+// DiffOpts describes what a backup diff compares
+type DiffOpts struct {
+	// Live compares the backup file with the instance folder (the
+	// files a backup would pack) instead of another backup file
+	Live bool
+	// TextDiffs adds unified diffs of the changed text config files
+	TextDiffs bool
+}
+
+// FileDiff is a file added, removed or changed between backups
+type FileDiff struct {
+	// Path is relative to the instance folder
+	Path   string
+	Change Change
+	// Group is 'config', 'other' or the world dimension (and region)
+	// holding the file, like 'world (nether) region -1,0'
+	Group string
+	// Diff is the unified diff of changed text config files
+	Diff string
+}
+
+// BackupDiff is the difference between two backups
+type BackupDiff struct {
+	From, To string
+	// Files are sorted by group and path
+	Files     []FileDiff
+	Unchanged int
+}
+
+// Count returns how many files had the change
+func (d BackupDiff) Count(c Change) int {
+	var n int
+	for _, f := range d.Files {
+		if f.Change == c {
+			n++
+		}
+	}
+	return n
+}
+
+// Diff compares the files of two backups (or a backup and the live
+// instance, see DiffOpts.Live) through their packed files hashes
+func (s *backupService) Diff(ctx context.Context, from, to string, opts DiffOpts) (*BackupDiff, error) {
+	fromFile, cleanup, err := s.openBackupFile(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	fromSums, err := backupChecksums(ctx, fromFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var toSums map[string]string
+	var readTo func(name string) ([]byte, error)
+	if opts.Live {
+		to, err = utils.AbsolutePath(to)
+		if err != nil {
+			return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+		}
+		rules, err := s.ignoreRules(to)
+		if err != nil {
+			return nil, err
+		}
+		if toSums, err = utils.FileChecksums(ctx, to, utils.WithIgnoreRules(rules)); err != nil {
+			return nil, err
+		}
+		readTo = func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(to, filepath.FromSlash(name)))
+		}
+	} else {
+		toFile, cleanup, err := s.openBackupFile(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		if toSums, err = backupChecksums(ctx, toFile); err != nil {
+			return nil, err
+		}
+		readTo = func(name string) ([]byte, error) {
+			return utils.ReadPackFile(ctx, toFile, name)
+		}
+	}
+
+	worlds := worldFolders(fromSums, toSums)
+	result := &BackupDiff{From: from, To: to}
+	for name, sum := range fromSums {
+		toSum, ok := toSums[name]
+		switch {
+		case !ok:
+			result.Files = append(result.Files, FileDiff{Path: name, Change: ChangeRemoved})
+		case sum != toSum:
+			result.Files = append(result.Files, FileDiff{Path: name, Change: ChangeChanged})
+		default:
+			result.Unchanged++
+		}
+	}
+	for name := range toSums {
+		if _, ok := fromSums[name]; !ok {
+			result.Files = append(result.Files, FileDiff{Path: name, Change: ChangeAdded})
+		}
+	}
+
+	for i, f := range result.Files {
+		result.Files[i].Group = diffGroup(f.Path, worlds)
+		if !opts.TextDiffs || f.Change != ChangeChanged || result.Files[i].Group != DiffGroupConfig {
+			continue
+		}
+		a, err := utils.ReadPackFile(ctx, fromFile, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		b, err := readTo(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("reading '%s': %w", f.Path, err)
+		}
+		result.Files[i].Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(string(a)),
+			B:        splitLines(string(b)),
+			FromFile: f.Path,
+			ToFile:   f.Path,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("comparing '%s': %w", f.Path, err)
+		}
+	}
+	slices.SortFunc(result.Files, func(a, b FileDiff) int {
+		if c := strings.Compare(a.Group, b.Group); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return result, nil
+}
+
+// splitLines splits text keeping the line endings (a last line
+// without ending gets one, so it's not shown as changed)
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// backupChecksums reads a backup file checksums, old backup files
+// without them can't be compared
+func backupChecksums(ctx context.Context, file string) (map[string]string, error) {
+	sums, err := utils.PackChecksums(ctx, file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("backup file has no checksums file (%s)", utils.PackChecksumsFileName)
+	}
+	return sums, err
+}
+
+// worldFolders returns the top level folders holding a level data file
+func worldFolders(files ...map[string]string) []string {
+	var worlds []string
+	for _, l := range files {
+		for name := range l {
+			dir, file := path.Split(name)
+			if file == LevelDataFileName && strings.Count(dir, "/") == 1 {
+				worlds = append(worlds, strings.TrimSuffix(dir, "/"))
+			}
+		}
+	}
+	slices.Sort(worlds)
+	return slices.Compact(worlds)
+}
+
+// diffGroup returns the group of a file: the world dimension (and
+// region, for region files), 'config' for text files outside worlds
+// or 'other'
+func diffGroup(name string, worlds []string) string {
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 || !slices.Contains(worlds, parts[0]) {
+		if slices.Contains(textConfigExtensions, path.Ext(name)) {
+			return DiffGroupConfig
+		}
+		return DiffGroupOther
+	}
+
+	dim := DimensionOverworld
+	switch parts[1] {
+	case "DIM-1":
+		dim = DimensionNether
+	case "DIM1":
+		dim = DimensionEnd
+	}
+	group := fmt.Sprintf("%s (%s)", parts[0], dim)
+	if len(parts) >= 3 && slices.Contains(regionFolders, parts[len(parts)-2]) {
+		if r, err := anvil.ParseRegionFileName(parts[len(parts)-1]); err == nil {
+			group += fmt.Sprintf(" region %d,%d", r.X, r.Z)
+		}
+	}
+	return group
+}
@@ -0,0 +1,158 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/eldius/mineserver-manager/internal/snapshot"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"path/filepath"
+	"strings"
+)
+
+// BackupJobOpts describes a backup run: the backup itself, then
+// the retention policy and the verification
+type BackupJobOpts struct {
+	BackupFolder string
+	// Incremental saves a snapshot to Repository instead of a backup file
+	Incremental bool
+	// Repository is the backup repository folder (defaults to 'repository' inside BackupFolder)
+	Repository string
+	Retention  retention.Policy
+	// Verify checks the backup file (or the repository) after saving it
+	Verify bool
+	// TestRestore also restores the backup file when verifying it
+	TestRestore bool
+}
+
+// BackupJobResult describes what a backup run did
+type BackupJobResult struct {
+	Backup   *BackupInfo
+	Snapshot *snapshot.Snapshot
+	// Removed is how many backups the retention policy removed
+	Removed int
+}
+
+func (o BackupJobOpts) repository() (string, error) {
+	if o.Repository != "" {
+		return o.Repository, nil
+	}
+	if destination.IsRemote(o.BackupFolder) {
+		return "", ErrRemoteRepository
+	}
+	return filepath.Join(o.BackupFolder, "repository"), nil
+}
+
+func (s *backupService) RunJob(ctx context.Context, instancePath string, opts BackupJobOpts) (*BackupJobResult, error) {
+	log := logger.GetLogger().With("action", "backup_job", "instance_path", instancePath, "incremental", opts.Incremental)
+	if opts.Incremental {
+		return s.runIncrementalJob(ctx, instancePath, opts)
+	}
+
+	bkp, err := s.Backup(ctx, instancePath, opts.BackupFolder)
+	if err != nil {
+		return nil, err
+	}
+	result := &BackupJobResult{Backup: bkp}
+	log = log.With("backup_file", bkp.Path)
+
+	if !opts.Retention.Empty() {
+		removed, err := s.applyJobRetention(ctx, opts, bkp.Name)
+		if err != nil {
+			return result, err
+		}
+		result.Removed = removed
+	}
+
+	if opts.Verify {
+		report, err := s.Verify(ctx, bkp.Path, opts.TestRestore)
+		if err != nil {
+			return result, err
+		}
+		if !report.OK() {
+			return result, fmt.Errorf("backup file verification failed: %s", reportSummary(report))
+		}
+	}
+	log.With("removed", result.Removed).DebugContext(ctx, "Backup job completed")
+	return result, nil
+}
+
+// applyJobRetention applies the job retention policy to the backup files of name
+func (s *backupService) applyJobRetention(ctx context.Context, opts BackupJobOpts, name string) (int, error) {
+	dest, err := destination.Open(ctx, opts.BackupFolder)
+	if err != nil {
+		return 0, fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer func() {
+		_ = dest.Close()
+	}()
+	files, err := listBackupFiles(ctx, dest)
+	if err != nil {
+		return 0, fmt.Errorf("getting backup files: %w", err)
+	}
+	decisions, err := s.applyRetention(ctx, dest, files[name], opts.Retention, false)
+	if err != nil {
+		return 0, fmt.Errorf("applying retention policy: %w", err)
+	}
+	return countRemoved(decisions), nil
+}
+
+func (s *backupService) runIncrementalJob(ctx context.Context, instancePath string, opts BackupJobOpts) (*BackupJobResult, error) {
+	repository, err := opts.repository()
+	if err != nil {
+		return nil, err
+	}
+	snap, err := s.Snapshot(ctx, instancePath, repository)
+	if err != nil {
+		return nil, err
+	}
+	result := &BackupJobResult{Snapshot: snap}
+
+	repo, err := snapshot.Open(repository, snapshot.WithEncryption(s.keys))
+	if err != nil {
+		return result, fmt.Errorf("opening backup repository: %w", err)
+	}
+	if !opts.Retention.Empty() {
+		pruned, err := repo.Prune(ctx, opts.Retention, false, snap.Name)
+		if err != nil {
+			return result, fmt.Errorf("applying retention policy: %w", err)
+		}
+		result.Removed = len(pruned.Removed)
+	}
+
+	if opts.Verify {
+		check, err := repo.Check(ctx, false)
+		if err != nil {
+			return result, fmt.Errorf("checking backup repository: %w", err)
+		}
+		if !check.OK() {
+			return result, fmt.Errorf("backup repository check failed: %s", strings.Join(check.Errors, "; "))
+		}
+	}
+	return result, nil
+}
+
+func countRemoved(decisions []retention.Decision) int {
+	var n int
+	for _, d := range decisions {
+		if !d.Keep {
+			n++
+		}
+	}
+	return n
+}
+
+func reportSummary(r *utils.PackReport) string {
+	var problems []string
+	for _, p := range r.Missing {
+		problems = append(problems, "missing "+p)
+	}
+	for _, p := range r.Extra {
+		problems = append(problems, "extra "+p)
+	}
+	problems = append(problems, r.Corrupt...)
+	problems = append(problems, r.Errors...)
+	return strings.Join(problems, "; ")
+}
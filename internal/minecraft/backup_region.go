@@ -0,0 +1,336 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/anvil"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Dimension is a world dimension
+type Dimension string
+
+const (
+	DimensionOverworld Dimension = "overworld"
+	DimensionNether    Dimension = "nether"
+	DimensionEnd       Dimension = "end"
+)
+
+var (
+	Dimensions = []Dimension{DimensionOverworld, DimensionNether, DimensionEnd}
+
+	ErrUnknownDimension = errors.New("unknown dimension")
+
+	// regionFolders are the dimension folders holding region files:
+	// blocks, entities and points of interest
+	regionFolders = []string{"region", "entities", "poi"}
+)
+
+// ParseDimension parses a dimension name, with or without the
+// 'minecraft:' namespace (like 'nether' or 'minecraft:the_nether')
+func ParseDimension(s string) (Dimension, error) {
+	name := strings.TrimPrefix(strings.ToLower(s), "minecraft:")
+	d := Dimension(strings.TrimPrefix(name, "the_"))
+	if !slices.Contains(Dimensions, d) {
+		return "", fmt.Errorf("%w: '%s' (use one of %v)", ErrUnknownDimension, s, Dimensions)
+	}
+	return d, nil
+}
+
+// dimensionFolder returns the dimension folder relative to the
+// instance folder. Bukkit based servers (like Purpur) keep the nether
+// and the end on their own world folders.
+func dimensionFolder(instancePath string, d Dimension) (string, error) {
+	world, err := worldFolder(instancePath)
+	if err != nil {
+		return "", err
+	}
+	level := filepath.Base(world)
+	var suffix, dim string
+	switch d {
+	case DimensionOverworld:
+		return level, nil
+	case DimensionNether:
+		suffix, dim = "_nether", "DIM-1"
+	case DimensionEnd:
+		suffix, dim = "_the_end", "DIM1"
+	default:
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownDimension, d)
+	}
+	if st, err := os.Stat(filepath.Join(instancePath, level+suffix)); err == nil && st.IsDir() {
+		return path.Join(level+suffix, dim), nil
+	}
+	return path.Join(level, dim), nil
+}
+
+// BlockArea is an area of blocks, both corners are included
+type BlockArea struct {
+	FromX, FromZ int
+	ToX, ToZ     int
+}
+
+// Chunks returns the area chunks by region
+func (a BlockArea) Chunks() map[anvil.RegionPos][]anvil.ChunkPos {
+	from := anvil.ChunkOfBlock(min(a.FromX, a.ToX), min(a.FromZ, a.ToZ))
+	to := anvil.ChunkOfBlock(max(a.FromX, a.ToX), max(a.FromZ, a.ToZ))
+	chunks := make(map[anvil.RegionPos][]anvil.ChunkPos)
+	for x := from.X; x <= to.X; x++ {
+		for z := from.Z; z <= to.Z; z++ {
+			c := anvil.ChunkPos{X: x, Z: z}
+			chunks[c.Region()] = append(chunks[c.Region()], c)
+		}
+	}
+	return chunks
+}
+
+// RegionRestoreOpts describes which world area is restored from a backup
+type RegionRestoreOpts struct {
+	Dimension Dimension
+	Area      BlockArea
+	// Chunks restores only the area chunks, instead of the whole
+	// region files holding the area
+	Chunks bool
+	// DryRun only tells what would be restored
+	DryRun bool
+}
+
+// RegionRestoreResult describes what a region restore did (or would
+// do, on dry runs)
+type RegionRestoreResult struct {
+	// Files are the restored region files (relative to the instance folder)
+	Files []string
+	// Chunks is how many chunks were restored, on chunks restores
+	Chunks int
+	// Rollback is the folder keeping the replaced region files
+	Rollback string
+}
+
+// RestoreRegion restores a world area from a backup file, leaving the
+// rest of the world untouched. Whole region files are swapped like
+// Restore does, chunks restores rewrite the instance region files
+// with the backup chunks (chunks missing from the backup are removed,
+// so they're generated again). Replaced region files are kept on the
+// rollback folder. Restores (but dry runs) run between the restore hooks.
+func (s *backupService) RestoreRegion(ctx context.Context, instancePath, backupFile string, opts RegionRestoreOpts) (*RegionRestoreResult, error) {
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	if IsServerRunning(instancePath) {
+		return nil, ErrServerRunning
+	}
+	dim, err := dimensionFolder(instancePath, opts.Dimension)
+	if err != nil {
+		return nil, err
+	}
+
+	// region files (relative to the instance folder) to the restored chunks indexes
+	files := make(map[string][]int)
+	for region, chunks := range opts.Area.Chunks() {
+		for _, folder := range regionFolders {
+			f := path.Join(dim, folder, region.FileName())
+			for _, c := range chunks {
+				files[f] = append(files[f], c.Index())
+			}
+		}
+	}
+
+	var result *RegionRestoreResult
+	err = s.withRestoreHooks(ctx, instancePath, backupFile, opts.DryRun, func() error {
+		if opts.Chunks {
+			result, err = s.restoreChunks(ctx, instancePath, backupFile, files, opts.DryRun)
+			return err
+		}
+		restored, err := s.restore(ctx, instancePath, backupFile, RestoreOpts{
+			Only:   slices.Sorted(maps.Keys(files)),
+			DryRun: opts.DryRun,
+		})
+		if err != nil {
+			return err
+		}
+		result = &RegionRestoreResult{Files: restored.Files, Rollback: restored.Rollback}
+		return nil
+	})
+	return result, err
+}
+
+// restoreChunks replaces the chunks of each instance region file with
+// the backup ones
+func (s *backupService) restoreChunks(ctx context.Context, instancePath, backupFile string, files map[string][]int, dryRun bool) (*RegionRestoreResult, error) {
+	log := logger.GetLogger().With("action", "restore_chunks", "instance_path", instancePath, "backup_file", backupFile)
+
+	local, cleanup, err := s.openBackupFile(ctx, backupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	only := slices.Sorted(maps.Keys(files))
+	packed, err := utils.ListPack(ctx, local, utils.WithOnly(only...))
+	if err != nil {
+		return nil, err
+	}
+	result := &RegionRestoreResult{}
+	rollback := RestoreRollbackFolder(instancePath)
+	// blocks, entities and points of interest files share the chunks
+	counted := make(map[string]bool)
+	for _, f := range only {
+		_, err := os.Stat(filepath.Join(instancePath, filepath.FromSlash(f)))
+		if err == nil {
+			result.Rollback = rollback
+		}
+		if !slices.Contains(packed, f) && err != nil {
+			continue
+		}
+		result.Files = append(result.Files, f)
+		if !counted[path.Base(f)] {
+			counted[path.Base(f)] = true
+			result.Chunks += len(files[f])
+		}
+	}
+	if len(result.Files) == 0 {
+		return nil, fmt.Errorf("no region files to restore from '%s'", backupFile)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	staging := filepath.Clean(instancePath) + restoreStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, fmt.Errorf("cleaning restore staging folder: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+	if err := utils.Unpack(ctx, staging, local, utils.WithOnly(result.Files...)); err != nil {
+		return nil, err
+	}
+
+	regions := make(map[string]*anvil.Region, len(result.Files))
+	for _, f := range result.Files {
+		region, err := mergeChunks(
+			filepath.Join(instancePath, filepath.FromSlash(f)),
+			filepath.Join(staging, filepath.FromSlash(f)),
+			files[f],
+		)
+		if err != nil {
+			return nil, fmt.Errorf("restoring '%s' chunks: %w", f, err)
+		}
+		regions[f] = region
+	}
+
+	if result.Rollback != "" {
+		if err := os.RemoveAll(rollback); err != nil {
+			return nil, fmt.Errorf("removing previous restore rollback folder: %w", err)
+		}
+	}
+	if err := writeRegions(instancePath, rollback, regions); err != nil {
+		return nil, err
+	}
+	log.InfoContext(ctx, "Chunks restored", "files", len(result.Files), "chunks", result.Chunks, "rollback", rollback)
+	return result, nil
+}
+
+// mergeChunks returns the instance region with the chunks at indexes
+// taken from the backup region (missing files are empty regions)
+func mergeChunks(instanceFile, backupFile string, indexes []int) (*anvil.Region, error) {
+	readRegion := func(file string) (*anvil.Region, error) {
+		if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+			return &anvil.Region{}, nil
+		}
+		return anvil.ReadRegionFile(file)
+	}
+	current, err := readRegion(instanceFile)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := readRegion(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range indexes {
+		for _, c := range []*anvil.Chunk{current.Chunks[i], backup.Chunks[i]} {
+			if c != nil && c.IsExternal() {
+				return nil, fmt.Errorf("chunk %d is stored on its own file, restore the whole region instead", i)
+			}
+		}
+		current.Chunks[i] = backup.Chunks[i]
+	}
+	return current, nil
+}
+
+// writeRegions copies the instance region files to the rollback folder
+// and writes the restored regions, the copies are moved back if
+// writing fails
+func writeRegions(instancePath, rollback string, regions map[string]*anvil.Region) (err error) {
+	var written []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, f := range written {
+			instanceFile := filepath.Join(instancePath, filepath.FromSlash(f))
+			kept := filepath.Join(rollback, filepath.FromSlash(f))
+			if _, e := os.Stat(kept); e == nil {
+				e = os.Rename(kept, instanceFile)
+				err = errors.Join(err, e)
+				continue
+			}
+			err = errors.Join(err, os.Remove(instanceFile))
+		}
+	}()
+
+	for _, f := range slices.Sorted(maps.Keys(regions)) {
+		instanceFile := filepath.Join(instancePath, filepath.FromSlash(f))
+		if _, err := os.Stat(instanceFile); err == nil {
+			if err := copyFile(instanceFile, filepath.Join(rollback, filepath.FromSlash(f))); err != nil {
+				return fmt.Errorf("copying '%s' to restore rollback folder: %w", f, err)
+			}
+		} else if regions[f].Len() == 0 {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(instanceFile), os.ModePerm); err != nil {
+			return fmt.Errorf("creating region folder: %w", err)
+		}
+		written = append(written, f)
+		if err := anvil.WriteRegionFile(instanceFile, regions[f]); err != nil {
+			return fmt.Errorf("writing '%s': %w", f, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+		return err
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	st, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, st.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,228 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/hooks"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// restoreStagingSuffix names the folder (next to the instance one)
+	// where backup files are extracted before replacing instance files
+	restoreStagingSuffix = ".restore"
+	// restoreRollbackSuffix names the folder (next to the instance one)
+	// keeping the instance files replaced by the last restore
+	restoreRollbackSuffix = ".rollback"
+)
+
+// RestoreOpts describes what is restored from a backup file
+type RestoreOpts struct {
+	// Only limits the restore to these files or folders (relative to
+	// the instance folder, like 'world/' or 'server.properties')
+	Only []string
+	// DryRun only tells what would be restored
+	DryRun bool
+}
+
+// RestoreResult describes what a restore did (or would do, on dry runs)
+type RestoreResult struct {
+	// Files are the restored files (relative to the instance folder)
+	Files []string
+	// Replaced are the instance files and folders replaced by the
+	// restored ones, they're kept on Rollback
+	Replaced []string
+	// Rollback is the folder keeping the replaced instance files
+	Rollback string
+}
+
+// RestoreRollbackFolder returns the folder keeping the instance files
+// replaced by the last restore
+func RestoreRollbackFolder(instancePath string) string {
+	return filepath.Clean(instancePath) + restoreRollbackSuffix
+}
+
+// Restore extracts the backup file to a staging folder, then swaps each
+// restored path (the Only paths, or every top level backup entry) with
+// the instance one. Replaced files are moved to the rollback folder
+// (replacing the previous rollback point), and moved back if the swap
+// fails. Instance files not found on the backup are kept. Restores
+// (but dry runs) run between the restore hooks.
+func (s *backupService) Restore(ctx context.Context, instancePath, backupFile string, opts RestoreOpts) (*RestoreResult, error) {
+	var result *RestoreResult
+	err := s.withRestoreHooks(ctx, instancePath, backupFile, opts.DryRun, func() error {
+		var err error
+		result, err = s.restore(ctx, instancePath, backupFile, opts)
+		return err
+	})
+	return result, err
+}
+
+// withRestoreHooks runs fn between the restore hooks, dry runs don't
+// run them
+func (s *backupService) withRestoreHooks(ctx context.Context, instancePath, backupFile string, dryRun bool, fn func() error) error {
+	if dryRun {
+		return fn()
+	}
+	data := instanceHookData(instancePath)
+	data.BackupFile = backupFile
+	return withHooks(ctx, s.hooks, hooks.PreRestore, hooks.PostRestore, data, func(*hooks.Data) error {
+		return fn()
+	})
+}
+
+func (s *backupService) restore(ctx context.Context, instancePath, backupFile string, opts RestoreOpts) (*RestoreResult, error) {
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	log := logger.GetLogger().With("action", "restore", "instance_path", instancePath, "backup_file", backupFile)
+
+	if IsServerRunning(instancePath) {
+		return nil, ErrServerRunning
+	}
+
+	local, cleanup, err := s.openBackupFile(ctx, backupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	unpackOpts := []utils.UnpackOpt{utils.WithOnly(opts.Only...)}
+	files, err := utils.ListPack(ctx, local, unpackOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to restore from '%s'", backupFile)
+	}
+	units, err := restoreUnits(files, opts.Only)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{Files: files}
+	for _, u := range units {
+		restored := slices.ContainsFunc(files, func(f string) bool {
+			return f == u || strings.HasPrefix(f, u+"/")
+		})
+		if _, err := os.Lstat(filepath.Join(instancePath, filepath.FromSlash(u))); err == nil && restored {
+			result.Replaced = append(result.Replaced, u)
+		}
+	}
+	if len(result.Replaced) > 0 {
+		result.Rollback = RestoreRollbackFolder(instancePath)
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	staging := filepath.Clean(instancePath) + restoreStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, fmt.Errorf("cleaning restore staging folder: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+	log.DebugContext(ctx, "Extracting backup to staging folder", "staging", staging)
+	if err := utils.Unpack(ctx, staging, local, unpackOpts...); err != nil {
+		return nil, err
+	}
+
+	if err := swapRestored(instancePath, staging, RestoreRollbackFolder(instancePath), units); err != nil {
+		return nil, err
+	}
+	log.InfoContext(ctx, "Backup restored", "files", len(files), "rollback", result.Rollback)
+	return result, nil
+}
+
+// restoreUnits returns the paths swapped by a restore: the only paths,
+// or the top level entries of the restored files
+func restoreUnits(files, only []string) ([]string, error) {
+	var units []string
+	if len(only) > 0 {
+		for _, p := range only {
+			clean, err := utils.CleanRelativePath(p)
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, clean)
+		}
+	} else {
+		for _, f := range files {
+			units = append(units, strings.SplitN(path.Clean(f), "/", 2)[0])
+		}
+	}
+	slices.Sort(units)
+	units = slices.Compact(units)
+
+	// nested only paths are swapped along with their parent
+	var result []string
+	for _, u := range units {
+		if len(result) > 0 && strings.HasPrefix(u, result[len(result)-1]+"/") {
+			continue
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// swapRestored moves each instance unit to the rollback folder (the
+// previous rollback point is removed first) and the staged one to the
+// instance folder, undoing the moves on failure
+func swapRestored(instancePath, staging, rollback string, units []string) (err error) {
+	type move struct{ from, to string }
+	var done []move
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, m := range slices.Backward(done) {
+			if e := os.Rename(m.to, m.from); e != nil {
+				err = errors.Join(err, fmt.Errorf("rolling back '%s': %w", m.from, e))
+			}
+		}
+	}()
+	rename := func(from, to string) error {
+		if err := os.MkdirAll(filepath.Dir(to), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		done = append(done, move{from: from, to: to})
+		return nil
+	}
+
+	rollbackCleaned := false
+	for _, u := range units {
+		current := filepath.Join(instancePath, filepath.FromSlash(u))
+		staged := filepath.Join(staging, filepath.FromSlash(u))
+		if _, err := os.Lstat(staged); err != nil {
+			// only paths missing from the backup are left as they are
+			continue
+		}
+		if _, err := os.Lstat(current); err == nil {
+			if !rollbackCleaned {
+				if err := os.RemoveAll(rollback); err != nil {
+					return fmt.Errorf("removing previous restore rollback folder: %w", err)
+				}
+				rollbackCleaned = true
+			}
+			if err := rename(current, filepath.Join(rollback, filepath.FromSlash(u))); err != nil {
+				return fmt.Errorf("moving '%s' to restore rollback folder: %w", u, err)
+			}
+		}
+		if err := rename(staged, current); err != nil {
+			return fmt.Errorf("moving restored '%s' to instance folder: %w", u, err)
+		}
+	}
+	return nil
+}
@@ -2,12 +2,25 @@ package minecraft
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/hooks"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/eldius/mineserver-manager/internal/snapshot"
 	"github.com/eldius/mineserver-manager/internal/utils"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -15,25 +28,192 @@ import (
 
 const (
 	bkpTimestampFormat = "2006-01-02_15-04-05"
+
+	defaultSaveTimeout = 2 * time.Minute
+	savedGameMessage   = "Saved the game"
+	latestLogFile      = "logs/latest.log"
+)
+
+var (
+	ErrRemoteRepository = errors.New("backup repositories must be on a local folder")
 )
 
+// BackupService manages instance backups. Backup folders and files
+// can be local paths or remote destinations URLs (see destination.Open).
 type BackupService interface {
 	// Backup creates a new backup from instance
 	Backup(ctx context.Context, instancePath, backupDestFolder string) (*BackupInfo, error)
-	// Restore restores a backup file to instance
-	Restore(ctx context.Context, instancePath, backupFile string) error
+	// Restore restores a backup file to instance (remote backup files
+	// are downloaded to a temporary folder first). Instances with a
+	// running server can't be restored.
+	Restore(ctx context.Context, instancePath, backupFile string, opts RestoreOpts) (*RestoreResult, error)
+	// RestoreRegion restores a world area (whole region files or just
+	// the area chunks) from a backup file to instance
+	RestoreRegion(ctx context.Context, instancePath, backupFile string, opts RegionRestoreOpts) (*RegionRestoreResult, error)
+	// Diff compares a backup file with another one, or with the
+	// instance folder (see DiffOpts.Live)
+	Diff(ctx context.Context, from, to string, opts DiffOpts) (*BackupDiff, error)
 	// RolloverBackupFiles limits max backup files stored
 	RolloverBackupFiles(ctx context.Context, backupDestFolder, backupName string, maxBkpFiles int) error
+	// ApplyRetention deletes the backup files on backupDestFolder not kept by
+	// policy (rules apply to each instance apart). Nothing is deleted when
+	// dryRun is true. Returns the decision for each backup file, newest first.
+	ApplyRetention(ctx context.Context, backupDestFolder string, policy retention.Policy, dryRun bool) ([]retention.Decision, error)
+	// RunJob runs a backup followed by the retention policy
+	// and the verification, as configured on opts
+	RunJob(ctx context.Context, instancePath string, opts BackupJobOpts) (*BackupJobResult, error)
+	// ListBackups lists the backup files on backupDestFolder matching
+	// filter, older first. The backups catalog is listed when
+	// backupDestFolder is empty.
+	ListBackups(ctx context.Context, backupDestFolder string, filter BackupFilter) ([]BackupInfo, error)
+	// Verify checks a backup file integrity. When testRestore is true
+	// the backup is also restored to a temporary folder and its world
+	// level data is validated.
+	Verify(ctx context.Context, backupFile string, testRestore bool) (*utils.PackReport, error)
+	// Snapshot saves an incremental snapshot from instance to a backup
+	// repository (it's created if it doesn't exist)
+	Snapshot(ctx context.Context, instancePath, repositoryPath string) (*snapshot.Snapshot, error)
+	// RestoreSnapshot restores a backup repository snapshot to instance,
+	// swapping the restored files the same way Restore does
+	RestoreSnapshot(ctx context.Context, instancePath, repositoryPath, snapshotID string) (*snapshot.Snapshot, error)
 }
 
 type backupService struct {
+	console     ConsoleFactory
+	saveTimeout time.Duration
+	keys        *encryption.Keys
+	format      utils.ArchiveFormat
+	level       int
+	excludes    []string
+	includes    []string
+	worldOnly   bool
+	progress    func(utils.PackProgress)
+	repo        repository.Repository
+	trigger     model.BackupTrigger
+	hooks       *hooks.Runner
+}
+
+type BackupServiceOpt func(s *backupService)
+
+// NewBackupService creates a new backup service. Backups of running
+// instances with RCON enabled pause world saving while files are copied.
+func NewBackupService(opts ...BackupServiceOpt) BackupService {
+	s := &backupService{
+		console:     RconConsole,
+		saveTimeout: defaultSaveTimeout,
+		format:      utils.FormatZip,
+		trigger:     model.BackupTriggerManual,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// WithBackupConsole defines how to reach a running server console
+func WithBackupConsole(f ConsoleFactory) BackupServiceOpt {
+	return func(s *backupService) {
+		s.console = f
+	}
+}
+
+// WithEncryption defines the backups encryption keys. Backups are
+// encrypted when keys have recipients, and encrypted backups are
+// decrypted with keys identities.
+func WithEncryption(k *encryption.Keys) BackupServiceOpt {
+	return func(s *backupService) {
+		s.keys = k
+	}
+}
+
+// WithArchiveFormat defines the backup files format and compression
+// level (0 uses the format default level)
+func WithArchiveFormat(f utils.ArchiveFormat, level int) BackupServiceOpt {
+	return func(s *backupService) {
+		if f != "" {
+			s.format = f
+		}
+		s.level = level
+	}
+}
+
+// WithExcludes adds ignore patterns (see utils.IgnoreRules) leaving
+// files out of backups, after the default patterns and the instance
+// ignore file ones
+func WithExcludes(patterns ...string) BackupServiceOpt {
+	return func(s *backupService) {
+		s.excludes = append(s.excludes, patterns...)
+	}
+}
+
+// WithIncludes adds patterns including files again, even when they are
+// left out by the default patterns, the instance ignore file or excludes
+func WithIncludes(patterns ...string) BackupServiceOpt {
+	return func(s *backupService) {
+		s.includes = append(s.includes, patterns...)
+	}
 }
 
-func NewBackupService() BackupService {
-	return &backupService{}
+// WithWorldOnly leaves out everything but the world folders
+func WithWorldOnly(worldOnly bool) BackupServiceOpt {
+	return func(s *backupService) {
+		s.worldOnly = worldOnly
+	}
+}
+
+// WithPackProgress sets a function called after each instance file
+// is packed to a backup file
+func WithPackProgress(fn func(utils.PackProgress)) BackupServiceOpt {
+	return func(s *backupService) {
+		s.progress = fn
+	}
 }
 
+// WithBackupRepository defines where the backups catalog is stored
+// (backup records are also stored next to backup files)
+func WithBackupRepository(r repository.Repository) BackupServiceOpt {
+	return func(s *backupService) {
+		s.repo = r
+	}
+}
+
+// WithTrigger defines what started the backups (defaults to manual)
+func WithTrigger(t model.BackupTrigger) BackupServiceOpt {
+	return func(s *backupService) {
+		s.trigger = t
+	}
+}
+
+// WithBackupHooks defines the hooks run around backups and restores
+func WithBackupHooks(r *hooks.Runner) BackupServiceOpt {
+	return func(s *backupService) {
+		s.hooks = r
+	}
+}
+
+// WithSaveTimeout defines how long to wait for the server to save the world
+func WithSaveTimeout(t time.Duration) BackupServiceOpt {
+	return func(s *backupService) {
+		s.saveTimeout = t
+	}
+}
+
+// Backup creates a new backup from instance, between the backup hooks
+// (post backup hooks get the backup file location)
 func (s *backupService) Backup(ctx context.Context, instancePath, backupDestPath string) (*BackupInfo, error) {
+	var info *BackupInfo
+	err := withHooks(ctx, s.hooks, hooks.PreBackup, hooks.PostBackup, instanceHookData(instancePath), func(data *hooks.Data) error {
+		var err error
+		if info, err = s.backup(ctx, instancePath, backupDestPath); err != nil {
+			return err
+		}
+		data.BackupFile = info.Path
+		return nil
+	})
+	return info, err
+}
+
+func (s *backupService) backup(ctx context.Context, instancePath, backupDestPath string) (*BackupInfo, error) {
 
 	log := slog.With(
 		slog.String("instance_path", instancePath),
@@ -48,125 +228,685 @@ func (s *backupService) Backup(ctx context.Context, instancePath, backupDestPath
 		return nil, err
 	}
 
-	backupDestPath, err = utils.AbsolutePath(backupDestPath)
+	if !destination.IsRemote(backupDestPath) {
+		backupDestPath, err = utils.AbsolutePath(backupDestPath)
+		if err != nil {
+			return nil, fmt.Errorf("parsing backupDestPath: %w", err)
+		}
+	}
+	dest, err := destination.Open(ctx, backupDestPath)
 	if err != nil {
-		return nil, fmt.Errorf("parsing backupDestPath: %w", err)
+		return nil, fmt.Errorf("opening backup destination: %w", err)
 	}
+	defer func() {
+		_ = dest.Close()
+	}()
+
 	instanceName := filepath.Base(instancePath)
-	ts := time.Now()
-	destFile := filepath.Join(
-		backupDestPath,
-		fmt.Sprintf(
-			"%s_%s_backup.zip",
-			instanceName,
-			ts.Format(bkpTimestampFormat),
-		))
-
-	if err := utils.PackFiles(ctx, instancePath, destFile); err != nil {
-		return nil, fmt.Errorf("writing backup file: %w", err)
+	ts := time.Now().Truncate(time.Second)
+	fileName := fmt.Sprintf(
+		"%s_%s_backup%s",
+		instanceName,
+		ts.Format(bkpTimestampFormat),
+		s.format.Extension(),
+	)
+	if s.keys.CanEncrypt() {
+		fileName += encryption.Extension
+	}
+
+	packOpts, err := s.packOpts(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	var packed utils.PackProgress
+	packOpts = append(packOpts, utils.WithProgress(func(p utils.PackProgress) {
+		packed = p
+		if s.progress != nil {
+			s.progress(p)
+		}
+	}))
+
+	var stored *storedFile
+	if local, ok := dest.(*destination.Local); ok && !s.keys.CanEncrypt() {
+		destFile := local.Location(fileName)
+		if err := s.withSavingPaused(ctx, instancePath, func() error {
+			return utils.PackFiles(ctx, instancePath, destFile, packOpts...)
+		}); err != nil {
+			return nil, fmt.Errorf("writing backup file: %w", err)
+		}
+		if stored, err = hashStoredFile(destFile); err != nil {
+			return nil, err
+		}
+	} else if stored, err = s.upload(ctx, instancePath, dest, fileName, packOpts); err != nil {
+		return nil, err
+	}
+
+	record := s.newBackupRecord(ctx, instancePath, dest.Location(fileName), fileName, ts)
+	record.Files = packed.Files
+	record.Size = stored.size
+	record.Checksum = stored.checksum
+	record.Duration = time.Since(ts)
+	if err := s.saveBackupRecord(ctx, dest, record); err != nil {
+		return nil, err
 	}
 
 	return &BackupInfo{
 		Timestamp: ts,
 		Name:      instanceName,
-		Path:      destFile,
+		Path:      dest.Location(fileName),
+		Size:      stored.size,
+		Metadata:  record,
+		file:      fileName,
+	}, nil
+}
+
+// packOpts returns how to pack instance files
+func (s *backupService) packOpts(instancePath string) ([]utils.PackOpt, error) {
+	rules, err := s.ignoreRules(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	return []utils.PackOpt{
+		utils.WithFormat(s.format),
+		utils.WithCompressionLevel(s.level),
+		utils.WithIgnoreRules(rules),
 	}, nil
 }
 
-func (s *backupService) Restore(ctx context.Context, instancePath, backupFile string) error {
+// ignoreRules returns the rules leaving instance files out of backups.
+// Patterns are evaluated in order (the last matching one wins): the
+// default ones, the world only preset, the instance ignore file ones,
+// the excludes and the includes.
+func (s *backupService) ignoreRules(instancePath string) (*utils.IgnoreRules, error) {
+	patterns := slices.Clone(utils.DefaultIgnorePatterns)
+	if s.worldOnly {
+		world, err := worldFolder(instancePath)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, utils.WorldOnlyPatterns(filepath.Base(world))...)
+	}
+	filePatterns, err := utils.ReadIgnoreFile(filepath.Join(instancePath, utils.IgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, filePatterns...)
+	patterns = append(patterns, s.excludes...)
+	for _, p := range s.includes {
+		patterns = append(patterns, "!"+strings.TrimPrefix(p, "!"))
+	}
+	rules, err := utils.NewIgnoreRules(patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing backup ignore patterns: %w", err)
+	}
+	return rules, nil
+}
 
-	if err := os.MkdirAll(instancePath, os.ModePerm); err != nil {
-		return fmt.Errorf("creating backup dir: %w", err)
+// upload packs the instance files to a temporary file and sends it,
+// encrypted when there are encryption keys, to a destination. World
+// saving is resumed before uploading.
+func (s *backupService) upload(ctx context.Context, instancePath string, dest destination.Destination, fileName string, packOpts []utils.PackOpt) (*storedFile, error) {
+	tmp, err := os.MkdirTemp("", "mineserver-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary backup folder: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	tmpFile := filepath.Join(tmp, fileName)
+	if err := s.withSavingPaused(ctx, instancePath, func() error {
+		return utils.PackFiles(ctx, instancePath, tmpFile, packOpts...)
+	}); err != nil {
+		return nil, fmt.Errorf("writing backup file: %w", err)
 	}
 
-	return utils.Unpack(ctx, instancePath, backupFile)
+	f, err := os.Open(tmpFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var r io.Reader = f
+	if s.keys.CanEncrypt() {
+		enc := s.keys.EncryptReader(f)
+		defer func() {
+			_ = enc.Close()
+		}()
+		r = enc
+	}
+	stored := &storedFile{hash: sha256.New()}
+	if err := dest.Put(ctx, fileName, io.TeeReader(r, stored)); err != nil {
+		return nil, fmt.Errorf("sending backup file to '%s': %w", dest.Location(fileName), err)
+	}
+	stored.checksum = hex.EncodeToString(stored.hash.Sum(nil))
+	return stored, nil
 }
 
-func (s *backupService) RolloverBackupFiles(ctx context.Context, backupDestFolder, backupName string, maxBkpFiles int) error {
-	log := slog.With(
-		slog.String("backup_folder", backupDestFolder),
-		slog.Int("max_bkp_files", maxBkpFiles),
-	)
-	stat, err := os.Stat(backupDestFolder)
+// openBackupFile returns a local and decrypted copy of the backup
+// file at location, removed by the returned cleanup function. Local
+// unencrypted backup files are used in place.
+func (s *backupService) openBackupFile(ctx context.Context, location string) (string, func(), error) {
+	file, cleanup, err := fetchBackupFile(ctx, location)
+	if err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	encrypted, r, err := encryption.IsEncrypted(f)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if !encrypted {
+		return file, cleanup, nil
+	}
+
+	dec, err := s.keys.Decrypt(r)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	tmp, err := os.MkdirTemp("", "mineserver-backup-*")
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("creating temporary backup folder: %w", err)
+	}
+	decryptedCleanup := func() {
+		cleanup()
+		_ = os.RemoveAll(tmp)
+	}
+	decrypted := filepath.Join(tmp, strings.TrimSuffix(filepath.Base(file), encryption.Extension))
+	out, err := os.Create(decrypted)
+	if err != nil {
+		decryptedCleanup()
+		return "", nil, fmt.Errorf("creating decrypted backup file: %w", err)
+	}
+	_, err = io.Copy(out, dec)
+	if err = errors.Join(err, out.Close()); err != nil {
+		decryptedCleanup()
+		return "", nil, fmt.Errorf("decrypting backup file: %w", err)
+	}
+	return decrypted, decryptedCleanup, nil
+}
+
+// fetchBackupFile downloads a remote backup file to a temporary
+// folder, removed by the returned cleanup function. Local backup
+// files are used in place.
+func fetchBackupFile(ctx context.Context, location string) (string, func(), error) {
+	if !destination.IsRemote(location) {
+		return location, func() {}, nil
+	}
+
+	dest, name, err := destination.OpenFile(ctx, location)
+	if err != nil {
+		return "", nil, fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer func() {
+		_ = dest.Close()
+	}()
+	r, err := dest.Get(ctx, name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	tmp, err := os.MkdirTemp("", "mineserver-backup-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary backup folder: %w", err)
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(tmp)
+	}
+	local := filepath.Join(tmp, name)
+	f, err := os.Create(local)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("creating backup file: %w", err)
+	}
+	_, err = io.Copy(f, r)
+	if err = errors.Join(err, f.Close()); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("downloading backup file: %w", err)
+	}
+	return local, cleanup, nil
+}
+
+// Snapshot saves the snapshot between the backup hooks, they get the
+// snapshot ID as backup file
+func (s *backupService) Snapshot(ctx context.Context, instancePath, repositoryPath string) (*snapshot.Snapshot, error) {
+	var snap *snapshot.Snapshot
+	err := withHooks(ctx, s.hooks, hooks.PreBackup, hooks.PostBackup, instanceHookData(instancePath), func(data *hooks.Data) error {
+		var err error
+		if snap, err = s.snapshot(ctx, instancePath, repositoryPath); err != nil {
+			return err
+		}
+		data.BackupFile = snap.ID
+		return nil
+	})
+	return snap, err
+}
+
+func (s *backupService) snapshot(ctx context.Context, instancePath, repositoryPath string) (*snapshot.Snapshot, error) {
+	if destination.IsRemote(repositoryPath) {
+		return nil, ErrRemoteRepository
+	}
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	rules, err := s.ignoreRules(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := snapshot.Init(repositoryPath, snapshot.WithEncryption(s.keys))
+	if err != nil {
+		return nil, fmt.Errorf("opening backup repository: %w", err)
+	}
+
+	var snap *snapshot.Snapshot
+	if err := s.withSavingPaused(ctx, instancePath, func() error {
+		snap, err = repo.Save(ctx, instancePath, snapshot.SaveOpts{
+			Name: filepath.Base(instancePath),
+			Skip: func(path string, info os.FileInfo) bool {
+				rel, err := filepath.Rel(instancePath, path)
+				return err == nil && rules.Ignored(rel, info.IsDir())
+			},
+		})
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("saving snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// withSavingPaused runs backup with the running server world saving
+// disabled, after flushing pending changes to disk, so the copied
+// files are consistent. Saving is always enabled again afterward.
+func (s *backupService) withSavingPaused(ctx context.Context, instancePath string, backup func() error) (err error) {
+	log := logger.GetLogger().With("action", "backup", "instance_path", instancePath)
+
+	c, err := s.console(ctx, instancePath)
+	if errors.Is(err, ErrServerNotRunning) {
+		return backup()
+	}
+	if errors.Is(err, ErrRconDisabled) {
+		log.WarnContext(ctx, "Server is running without RCON, the backup may be inconsistent")
+		return backup()
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if _, err := c.Execute(ctx, "save-off"); err != nil {
+		return fmt.Errorf("disabling world saving: %w", err)
+	}
+	announce(ctx, c, "Starting backup, world saving is paused...")
+	defer func() {
+		// the server must save the world again even if the backup was cancelled
+		if _, e := c.Execute(context.WithoutCancel(ctx), "save-on"); e != nil {
+			err = errors.Join(err, fmt.Errorf("enabling world saving: %w", e))
+			return
+		}
+		if err != nil {
+			announce(ctx, c, "Backup failed, world saving is resumed.")
+			return
+		}
+		announce(ctx, c, "Backup completed, world saving is resumed.")
+	}()
+
+	if err := s.flush(ctx, c, instancePath); err != nil {
+		return err
+	}
+	log.DebugContext(ctx, "World saved, copying files")
+	return backup()
+}
+
+// flush makes the server write every pending change to disk, waiting
+// for the confirmation on the command output or on the server log
+func (s *backupService) flush(ctx context.Context, c Console, instancePath string) error {
+	logFile := filepath.Join(instancePath, filepath.FromSlash(latestLogFile))
+	var offset int64
+	if info, err := os.Stat(logFile); err == nil {
+		offset = info.Size()
+	}
+
+	out, err := c.Execute(ctx, "save-all flush")
+	if err != nil {
+		return fmt.Errorf("saving the world: %w", err)
+	}
+	if strings.Contains(out, savedGameMessage) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.saveTimeout)
+	defer cancel()
+	t := time.NewTicker(500 * time.Millisecond)
+	defer t.Stop()
+	for {
+		if logContains(logFile, offset, savedGameMessage) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the world to be saved: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// logContains tells if the log file contains msg after offset
+func logContains(logFile string, offset int64, msg string) bool {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if info, err := f.Stat(); err != nil || info.Size() < offset {
+		// the log was rotated
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return false
+	}
+	b, err := io.ReadAll(f)
+	return err == nil && strings.Contains(string(b), msg)
+}
+
+// announce sends a message to the players, failures are only logged
+func announce(ctx context.Context, c Console, msg string) {
+	if _, err := c.Execute(context.WithoutCancel(ctx), "say [Backup] "+msg); err != nil {
+		logger.GetLogger().With("error", err, "message", msg).WarnContext(ctx, "Failed to announce backup progress")
+	}
+}
+
+// RestoreSnapshot restores the snapshot to a staging folder, then swaps
+// each top level snapshot entry with the instance one (replaced files
+// are kept on the rollback folder), between the restore hooks. They get
+// the snapshot ID as backup file.
+func (s *backupService) RestoreSnapshot(ctx context.Context, instancePath, repositoryPath, snapshotID string) (*snapshot.Snapshot, error) {
+	var snap *snapshot.Snapshot
+	err := s.withRestoreHooks(ctx, instancePath, snapshotID, false, func() error {
+		var err error
+		snap, err = s.restoreSnapshot(ctx, instancePath, repositoryPath, snapshotID)
+		return err
+	})
+	return snap, err
+}
+
+func (s *backupService) restoreSnapshot(ctx context.Context, instancePath, repositoryPath, snapshotID string) (*snapshot.Snapshot, error) {
+	if destination.IsRemote(repositoryPath) {
+		return nil, ErrRemoteRepository
+	}
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	if IsServerRunning(instancePath) {
+		return nil, ErrServerRunning
+	}
+	repo, err := snapshot.Open(repositoryPath, snapshot.WithEncryption(s.keys))
+	if err != nil {
+		return nil, fmt.Errorf("opening backup repository: %w", err)
+	}
+
+	staging := filepath.Clean(instancePath) + restoreStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, fmt.Errorf("cleaning restore staging folder: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+	}()
+	snap, err := repo.Restore(ctx, snapshotID, staging)
+	if err != nil {
+		return nil, fmt.Errorf("restoring snapshot: %w", err)
+	}
+
+	files := make([]string, 0, len(snap.Nodes))
+	for _, n := range snap.Nodes {
+		files = append(files, n.Path)
+	}
+	units, err := restoreUnits(files, nil)
 	if err != nil {
-		return fmt.Errorf("getting backup dir info: %w", err)
+		return nil, err
+	}
+	if err := swapRestored(instancePath, staging, RestoreRollbackFolder(instancePath), units); err != nil {
+		return nil, err
+	}
+	logger.GetLogger().With("action", "restore_snapshot", "instance_path", instancePath, "snapshot", snap.ShortID()).
+		InfoContext(ctx, "Snapshot restored", "files", len(files))
+	return snap, nil
+}
+
+func (s *backupService) ListBackups(ctx context.Context, backupDestFolder string, filter BackupFilter) ([]BackupInfo, error) {
+	if backupDestFolder == "" {
+		result, err := s.listCatalog(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("listing backups catalog: %w", err)
+		}
+		return result, nil
+	}
+	files, err := mapBackupFiles(ctx, backupDestFolder)
+	if err != nil {
+		return nil, fmt.Errorf("getting backup files: %w", err)
+	}
+	var result []BackupInfo
+	for _, l := range files {
+		for _, b := range l {
+			if filter.Match(b) {
+				result = append(result, b)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+func (s *backupService) Verify(ctx context.Context, backupFile string, testRestore bool) (*utils.PackReport, error) {
+	local, cleanup, err := s.openBackupFile(ctx, backupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	report, err := utils.VerifyPack(ctx, local)
+	if report != nil {
+		report.File = backupFile
+	}
+	if err != nil {
+		return report, fmt.Errorf("verifying backup file: %w", err)
+	}
+	if !testRestore || !report.OK() {
+		return report, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "mineserver-verify-*")
+	if err != nil {
+		return report, fmt.Errorf("creating test restore folder: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	if err := utils.Unpack(ctx, tmp, local); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("test restore failed: %v", err))
+		return report, nil
+	}
+
+	levelName := defaultLevelName
+	if props, err := model.LoadFromFile(filepath.Join(tmp, ServerPropertiesFileName)); err == nil && props.LevelName != "" {
+		levelName = props.LevelName
 	}
-	if !stat.IsDir() {
-		return fmt.Errorf("backup dir is not a directory: %s", backupDestFolder)
+	if err := validateLevelData(filepath.Join(tmp, levelName, LevelDataFileName)); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("invalid world '%s': %v", levelName, err))
 	}
+	return report, nil
+}
 
-	bkpFiles, err := mapBackupFiles(ctx, backupDestFolder)
+func (s *backupService) RolloverBackupFiles(ctx context.Context, backupDestFolder, backupName string, maxBkpFiles int) error {
+	dest, err := destination.Open(ctx, backupDestFolder)
+	if err != nil {
+		return fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer func() {
+		_ = dest.Close()
+	}()
+
+	bkpFiles, err := listBackupFiles(ctx, dest)
 	if err != nil {
 		return fmt.Errorf("getting backup files: %w", err)
 	}
 
-	bkpList := bkpFiles[backupName]
-	deleteAllBeforeIdx := len(bkpList) - maxBkpFiles
+	_, err = s.applyRetention(ctx, dest, bkpFiles[backupName], retention.Policy{KeepLast: maxBkpFiles}, false)
+	return err
+}
+
+func (s *backupService) ApplyRetention(ctx context.Context, backupDestFolder string, policy retention.Policy, dryRun bool) ([]retention.Decision, error) {
+	dest, err := destination.Open(ctx, backupDestFolder)
+	if err != nil {
+		return nil, fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer func() {
+		_ = dest.Close()
+	}()
+
+	files, err := listBackupFiles(ctx, dest)
+	if err != nil {
+		return nil, fmt.Errorf("getting backup files: %w", err)
+	}
+	var backups []BackupInfo
+	for _, l := range files {
+		backups = append(backups, l...)
+	}
+	return s.applyRetention(ctx, dest, backups, policy, dryRun)
+}
+
+// applyRetention evaluates policy over backups deleting from dest
+// the backup files it doesn't keep (unless dryRun is true)
+func (s *backupService) applyRetention(ctx context.Context, dest destination.Destination, backups []BackupInfo, policy retention.Policy, dryRun bool) ([]retention.Decision, error) {
+	log := logger.GetLogger().With("action", "backup_retention", "dry_run", dryRun)
 
-	log = log.With(slog.Int("delete_all_before_idx", deleteAllBeforeIdx), slog.Int("bkp_count", len(bkpList)))
+	items := make([]retention.Item, 0, len(backups))
+	files := make(map[string]BackupInfo, len(backups))
+	for _, b := range backups {
+		items = append(items, retention.Item{ID: b.Path, Group: b.Name, Time: b.Timestamp, Size: b.Size})
+		files[b.Path] = b
+	}
+	decisions := policy.Apply(items, time.Now())
+	if dryRun {
+		return decisions, nil
+	}
 
-	for i, b := range bkpList[:deleteAllBeforeIdx] {
-		l := log.With(
-			slog.String("bkp_path", b.Path),
-			slog.String("bkp_name", b.Name),
-			slog.Int("bkp_idx", i),
-		)
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		l := log.With("bkp_path", d.Item.ID, "bkp_name", d.Item.Group, "reasons", d.Reasons)
 		l.DebugContext(ctx, "deleting backup file")
-		if err := os.Remove(b.Path); err != nil {
+		b := files[d.Item.ID]
+		if err := dest.Delete(ctx, b.file); err != nil {
 			err := fmt.Errorf("deleting backup file: %w", err)
 			l.With("error", err).ErrorContext(ctx, "deleting backup file")
-			return err
+			return decisions, err
+		}
+		if b.Metadata == nil {
+			continue
+		}
+		if err := s.deleteBackupRecord(ctx, dest, b.Metadata); err != nil {
+			l.With("error", err).ErrorContext(ctx, "deleting backup metadata")
+			return decisions, err
 		}
 	}
-	return nil
+	return decisions, nil
 }
 
 func mapBackupFiles(ctx context.Context, backupDestFolder string) (backupsMapping, error) {
+	dest, err := destination.Open(ctx, backupDestFolder)
+	if err != nil {
+		return make(backupsMapping), fmt.Errorf("opening backup destination: %w", err)
+	}
+	defer func() {
+		_ = dest.Close()
+	}()
+	return listBackupFiles(ctx, dest)
+}
+
+func listBackupFiles(ctx context.Context, dest destination.Destination) (backupsMapping, error) {
 	filesMap := make(backupsMapping)
 
-	entries, err := os.ReadDir(backupDestFolder)
+	entries, err := dest.List(ctx)
 	if err != nil {
-		return filesMap, fmt.Errorf("reading backup dir: %w", err)
+		return filesMap, err
 	}
 
-	rgxp, err := regexp.Compile("[0-9]{4}-[0-9]{2}-[0-9]{2}_[0-9]{2}-[0-9]{2}-[0-9]{2}_backup.zip")
+	rgxp, err := regexp.Compile(`([0-9]{4}-[0-9]{2}-[0-9]{2}_[0-9]{2}-[0-9]{2}-[0-9]{2})_backup\.(zip|tar\.gz|tar\.zst)(\.age)?$`)
 	if err != nil {
 		return filesMap, fmt.Errorf("compile regexp: %w", err)
 	}
 
+	records := readBackupRecords(ctx, dest, entries)
+	var backups []BackupInfo
 	for _, entry := range entries {
-		log := slog.With("entry_name", entry.Name())
-		str := rgxp.FindString(entry.Name())
+		log := slog.With("entry_name", entry.Name)
+		if record, ok := records[entry.Name]; ok {
+			backups = append(backups, BackupInfo{
+				Timestamp: record.CreatedAt,
+				Name:      record.InstanceName,
+				Path:      dest.Location(entry.Name),
+				Size:      entry.Size,
+				Metadata:  record,
+				file:      entry.Name,
+			})
+			continue
+		}
+		m := rgxp.FindStringSubmatch(entry.Name)
 		log.With(
-			slog.String("find_str", str),
+			slog.Any("find_str", m),
 		).DebugContext(ctx, "parsing backup file")
-		if len(str) > 0 {
-			bkpName := strings.TrimSuffix(entry.Name(), "_"+str)
-			tsStr := strings.TrimSuffix(str, "_backup.zip")
+		if len(m) > 0 {
+			bkpName := strings.TrimSuffix(entry.Name, "_"+m[0])
+			tsStr := m[1]
 
 			log = log.With("error", err, "ts_str", tsStr, "bkp_name", bkpName, "ts_str", tsStr)
 
-			var bkpList []BackupInfo
-
-			if l, ok := filesMap[bkpName]; ok {
-				bkpList = l
-			}
-
 			ts, err := time.Parse(bkpTimestampFormat, tsStr)
 			if err != nil {
 				log.With("error", err, "ts_str", tsStr, "bkp_name", bkpName, "ts_str", tsStr).
 					WarnContext(ctx, "backup file Timestamp parsing failed")
 				continue
 			}
-			filesMap[bkpName] = append(bkpList, BackupInfo{
+			backups = append(backups, BackupInfo{
 				Timestamp: ts,
 				Name:      bkpName,
-				Path:      filepath.Join(backupDestFolder, entry.Name()),
+				Path:      dest.Location(entry.Name),
+				Size:      entry.Size,
+				file:      entry.Name,
 			})
 
 		}
 	}
 
+	groupByInstance(backups)
+	for _, b := range backups {
+		filesMap[b.Name] = append(filesMap[b.Name], b)
+	}
+
 	for k := range filesMap {
 		sort.Slice(filesMap[k], func(i, j int) bool {
 			return filesMap[k][i].Timestamp.Before(filesMap[k][j].Timestamp)
@@ -179,7 +919,14 @@ func mapBackupFiles(ctx context.Context, backupDestFolder string) (backupsMappin
 type BackupInfo struct {
 	Timestamp time.Time
 	Name      string
-	Path      string
+	// Path is the backup file location, a local path or a remote URL
+	Path string
+	Size int64
+	// Metadata is the backup record (nil for backup files
+	// without a metadata file)
+	Metadata *model.BackupRecord
+	// file is the backup file name on its destination
+	file string
 }
 
 type backupsMapping map[string]backupList
@@ -1,11 +1,31 @@
 package minecraft
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"filippo.io/age"
 	"github.com/eldius/initial-config-go/configs"
 	"github.com/eldius/initial-config-go/setup"
+	"github.com/eldius/mineserver-manager/internal/anvil"
 	"github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/destination"
+	"github.com/eldius/mineserver-manager/internal/destination/destinationtest"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/hooks"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"github.com/eldius/mineserver-manager/internal/repository"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/eldius/mineserver-manager/internal/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -40,3 +60,1008 @@ func TestMapBackupFiles(t *testing.T) {
 		assert.Empty(t, files)
 	})
 }
+
+func TestBackupService_Snapshot(t *testing.T) {
+	t.Run("given an instance should save and restore a snapshot without the excluded files", func(t *testing.T) {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		for name, content := range map[string]string{
+			"server.properties":  "motd=My Server\n",
+			"world/level.dat":    "level data",
+			"logs/latest.log":    "some logs",
+			"java/bin/java":      "java binary",
+			"libraries/some.jar": "library",
+			"server.pid":         "1234",
+		} {
+			p := filepath.Join(instance, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		}
+		repository := filepath.Join(t.TempDir(), "repository")
+
+		s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+		snap, err := s.Snapshot(context.Background(), instance, repository)
+		require.NoError(t, err)
+		assert.Equal(t, "my-server", snap.Name)
+
+		dest := t.TempDir()
+		restored, err := s.RestoreSnapshot(context.Background(), dest, repository, snap.ShortID())
+		require.NoError(t, err)
+		assert.Equal(t, snap.ID, restored.ID)
+
+		assert.FileExists(t, filepath.Join(dest, "server.properties"))
+		assert.FileExists(t, filepath.Join(dest, "world", "level.dat"))
+		assert.NoFileExists(t, filepath.Join(dest, "logs", "latest.log"))
+		assert.NoFileExists(t, filepath.Join(dest, "server.pid"))
+		assert.NoDirExists(t, filepath.Join(dest, "java"))
+		assert.NoDirExists(t, filepath.Join(dest, "libraries"))
+	})
+
+	t.Run("given an existing instance should swap restored files keeping a rollback", func(t *testing.T) {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), []byte("saved level"), 0o644))
+		repository := filepath.Join(t.TempDir(), "repository")
+
+		s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+		snap, err := s.Snapshot(context.Background(), instance, repository)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), []byte("broken level"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "server.properties"), []byte("motd=Kept\n"), 0o644))
+
+		_, err = s.RestoreSnapshot(context.Background(), instance, repository, snap.ShortID())
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(filepath.Join(instance, "world", "level.dat"))
+		require.NoError(t, err)
+		assert.Equal(t, "saved level", string(b))
+		b, err = os.ReadFile(filepath.Join(RestoreRollbackFolder(instance), "world", "level.dat"))
+		require.NoError(t, err)
+		assert.Equal(t, "broken level", string(b))
+		assert.FileExists(t, filepath.Join(instance, "server.properties"))
+		assert.NoDirExists(t, instance+".restore")
+	})
+
+	t.Run("given a running server should refuse to restore", func(t *testing.T) {
+		instance := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(instance, ServerPIDFileName), []byte(strconv.Itoa(os.Getpid())), 0o644))
+
+		_, err := NewBackupService().RestoreSnapshot(context.Background(), instance, filepath.Join(t.TempDir(), "repository"), "latest")
+		assert.ErrorIs(t, err, ErrServerRunning)
+	})
+}
+
+func TestBackupService_LiveBackup(t *testing.T) {
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), []byte("level data"), 0o644))
+		return instance
+	}
+
+	t.Run("given a running server should pause world saving while packing files", func(t *testing.T) {
+		console := &fakeConsole{outputs: map[string]string{
+			"save-all flush": "Saving the game (this may take a moment!)Saved the game",
+		}}
+		s := NewBackupService(WithBackupConsole(console.factory(true)))
+
+		bkp, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+		assert.FileExists(t, bkp.Path)
+		assert.Equal(t, []string{
+			"save-off",
+			"say [Backup] Starting backup, world saving is paused...",
+			"save-all flush",
+			"save-on",
+			"say [Backup] Backup completed, world saving is resumed.",
+		}, console.commands)
+		assert.True(t, console.closed)
+	})
+
+	t.Run("given the save confirmation on server log should wait for it", func(t *testing.T) {
+		instance := newInstance(t)
+		logFile := filepath.Join(instance, "logs", "latest.log")
+		require.NoError(t, os.MkdirAll(filepath.Dir(logFile), os.ModePerm))
+		require.NoError(t, os.WriteFile(logFile, []byte("[Server thread/INFO]: Saved the game\n"), 0o644))
+
+		console := &fakeConsole{}
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			f, _ := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0o644)
+			_, _ = f.WriteString("[Server thread/INFO]: Saved the game\n")
+			_ = f.Close()
+		}()
+		s := NewBackupService(WithBackupConsole(console.factory(true)), WithSaveTimeout(5*time.Second))
+
+		_, err := s.Snapshot(context.Background(), instance, filepath.Join(t.TempDir(), "repository"))
+		require.NoError(t, err)
+		assert.Contains(t, console.commands, "save-on")
+	})
+
+	t.Run("given the server never confirms the save should fail and enable saving again", func(t *testing.T) {
+		console := &fakeConsole{}
+		s := NewBackupService(WithBackupConsole(console.factory(true)), WithSaveTimeout(100*time.Millisecond))
+
+		_, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []string{
+			"save-off",
+			"say [Backup] Starting backup, world saving is paused...",
+			"save-all flush",
+			"save-on",
+			"say [Backup] Backup failed, world saving is resumed.",
+		}, console.commands)
+	})
+
+	t.Run("given packing fails should enable saving again", func(t *testing.T) {
+		console := &fakeConsole{outputs: map[string]string{"save-all flush": "Saved the game"}}
+		s := NewBackupService(WithBackupConsole(console.factory(true)))
+
+		_, err := s.Backup(context.Background(), newInstance(t), filepath.Join(t.TempDir(), "missing", "folder"))
+		assert.Error(t, err)
+		assert.Contains(t, console.commands, "save-on")
+		assert.Equal(t, "say [Backup] Backup failed, world saving is resumed.", console.commands[len(console.commands)-1])
+	})
+
+	t.Run("given a stopped server should not send commands", func(t *testing.T) {
+		console := &fakeConsole{}
+		s := NewBackupService(WithBackupConsole(console.factory(false)))
+
+		_, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+		assert.Empty(t, console.commands)
+	})
+}
+
+// levelData builds a minimal gzip compressed level.dat
+func levelData(t *testing.T, withData bool) []byte {
+	t.Helper()
+	root := nbt.Compound{}
+	if withData {
+		root["Data"] = nbt.Compound{
+			"LevelName":    "world",
+			"ServerBrands": nbt.List{Type: nbt.TagString, Values: []any{"vanilla"}},
+			"Time":         int64(1234),
+		}
+	}
+	var b bytes.Buffer
+	require.NoError(t, nbt.Encode(&b, "", root, nbt.CompressionGzip))
+	return b.Bytes()
+}
+
+func TestBackupService_Verify(t *testing.T) {
+	backup := func(t *testing.T, levelName string, level []byte) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, levelName), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, ServerPropertiesFileName), []byte("level-name="+levelName+"\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, levelName, LevelDataFileName), level, 0o644))
+		s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+		bkp, err := s.Backup(context.Background(), instance, t.TempDir())
+		require.NoError(t, err)
+		return bkp.Path
+	}
+
+	t.Run("given a valid backup should test restore it", func(t *testing.T) {
+		report, err := NewBackupService().Verify(context.Background(), backup(t, "survival", levelData(t, true)), true)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), report)
+	})
+
+	t.Run("given a backup with an invalid level data should report it on test restore", func(t *testing.T) {
+		p := backup(t, "world", levelData(t, false))
+
+		report, err := NewBackupService().Verify(context.Background(), p, false)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), report)
+
+		report, err = NewBackupService().Verify(context.Background(), p, true)
+		require.NoError(t, err)
+		require.Len(t, report.Errors, 1)
+		assert.Contains(t, report.Errors[0], "no 'Data' tag")
+	})
+
+	t.Run("given a backup with a truncated level data should report it on test restore", func(t *testing.T) {
+		level := levelData(t, true)
+		report, err := NewBackupService().Verify(context.Background(), backup(t, "world", level[:len(level)-12]), true)
+		require.NoError(t, err)
+		assert.False(t, report.OK())
+	})
+}
+
+func TestBackupService_Encryption(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	idFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(idFile, []byte(id.String()), 0o600))
+	encKeys, err := encryption.NewKeys(encryption.Config{Recipients: []string{id.Recipient().String()}})
+	require.NoError(t, err)
+	decKeys, err := encryption.NewKeys(encryption.Config{IdentityFile: idFile})
+	require.NoError(t, err)
+
+	instance := filepath.Join(t.TempDir(), "my-server")
+	require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(instance, ServerPropertiesFileName), []byte("rcon.password=MyP@ss\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(instance, "world", LevelDataFileName), levelData(t, true), 0o644))
+
+	folder := t.TempDir()
+	bkp, err := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)), WithEncryption(encKeys)).
+		Backup(context.Background(), instance, folder)
+	require.NoError(t, err)
+
+	t.Run("given encryption keys should write an encrypted backup file", func(t *testing.T) {
+		assert.True(t, strings.HasSuffix(bkp.Path, "_backup.zip"+encryption.Extension))
+		b, err := os.ReadFile(bkp.Path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), "MyP@ss")
+
+		files, err := mapBackupFiles(context.Background(), folder)
+		require.NoError(t, err)
+		require.Len(t, files["my-server"], 1)
+		assert.Equal(t, bkp.Timestamp.Truncate(time.Second), files["my-server"][0].Timestamp.In(bkp.Timestamp.Location()))
+	})
+
+	t.Run("given the identity should verify and restore the encrypted backup", func(t *testing.T) {
+		s := NewBackupService(WithEncryption(decKeys))
+		report, err := s.Verify(context.Background(), bkp.Path, true)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), report)
+		assert.Equal(t, bkp.Path, report.File)
+
+		restored := filepath.Join(t.TempDir(), "restored")
+		_, err = s.Restore(context.Background(), restored, bkp.Path, RestoreOpts{})
+		require.NoError(t, err)
+		b, err := os.ReadFile(filepath.Join(restored, ServerPropertiesFileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "MyP@ss")
+	})
+
+	t.Run("given no identity should fail to restore the encrypted backup", func(t *testing.T) {
+		_, err := NewBackupService().Restore(context.Background(), t.TempDir(), bkp.Path, RestoreOpts{})
+		assert.ErrorIs(t, err, encryption.ErrNoDecryptionKeys)
+	})
+
+	t.Run("given encryption keys should save and restore an encrypted snapshot", func(t *testing.T) {
+		repository := filepath.Join(t.TempDir(), "repository")
+		snap, err := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)), WithEncryption(encKeys)).
+			Snapshot(context.Background(), instance, repository)
+		require.NoError(t, err)
+
+		_, err = NewBackupService().RestoreSnapshot(context.Background(), filepath.Join(t.TempDir(), "restored"), repository, snap.ShortID())
+		assert.ErrorIs(t, err, encryption.ErrNoDecryptionKeys)
+
+		restored := filepath.Join(t.TempDir(), "restored")
+		_, err = NewBackupService(WithEncryption(decKeys)).RestoreSnapshot(context.Background(), restored, repository, snap.ShortID())
+		require.NoError(t, err)
+		b, err := os.ReadFile(filepath.Join(restored, ServerPropertiesFileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "MyP@ss")
+	})
+}
+
+func TestBackupService_Retention(t *testing.T) {
+	copySamples := func(t *testing.T) string {
+		dir := t.TempDir()
+		entries, err := os.ReadDir("./test_samples")
+		require.NoError(t, err)
+		for _, e := range entries {
+			b, err := os.ReadFile(filepath.Join("./test_samples", e.Name()))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, e.Name()), b, 0o644))
+		}
+		return dir
+	}
+
+	t.Run("given fewer backups than max backup files should keep all of them", func(t *testing.T) {
+		dir := copySamples(t)
+		s := NewBackupService()
+		require.NoError(t, s.RolloverBackupFiles(context.Background(), dir, "my_other_backup_file", 5))
+
+		files, err := mapBackupFiles(context.Background(), dir)
+		require.NoError(t, err)
+		assert.Len(t, files["my_other_backup_file"], 1)
+		assert.Len(t, files["mybackup_file"], 5)
+	})
+
+	t.Run("given max backup files should delete the older ones", func(t *testing.T) {
+		dir := copySamples(t)
+		s := NewBackupService()
+		require.NoError(t, s.RolloverBackupFiles(context.Background(), dir, "mybackup_file", 2))
+
+		files, err := mapBackupFiles(context.Background(), dir)
+		require.NoError(t, err)
+		require.Len(t, files["mybackup_file"], 2)
+		assert.Equal(t, "2025-01-01", files["mybackup_file"][0].Timestamp.Format("2006-01-02"))
+		assert.Len(t, files["my_other_backup_file"], 1)
+	})
+
+	t.Run("given a dry run should only explain the policy decisions", func(t *testing.T) {
+		dir := copySamples(t)
+		s := NewBackupService()
+		decisions, err := s.ApplyRetention(context.Background(), dir, retention.Policy{KeepDaily: 2}, true)
+		require.NoError(t, err)
+		require.Len(t, decisions, 6)
+
+		var removed []string
+		for _, d := range decisions {
+			if !d.Keep {
+				removed = append(removed, filepath.Base(d.Item.ID))
+			}
+		}
+		assert.ElementsMatch(t, []string{
+			"mybackup_file_2024-12-29_00-00-01_backup.zip",
+			"mybackup_file_2024-12-30_00-00-01_backup.zip",
+			"mybackup_file_2024-12-31_00-00-01_backup.zip",
+		}, removed)
+
+		files, err := mapBackupFiles(context.Background(), dir)
+		require.NoError(t, err)
+		assert.Len(t, files["mybackup_file"], 5)
+
+		_, err = s.ApplyRetention(context.Background(), dir, retention.Policy{KeepDaily: 2}, false)
+		require.NoError(t, err)
+		files, err = mapBackupFiles(context.Background(), dir)
+		require.NoError(t, err)
+		assert.Len(t, files["mybackup_file"], 2)
+	})
+}
+
+func TestBackupService_RunJob(t *testing.T) {
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), levelData(t, true), 0o644))
+		return instance
+	}
+	s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+
+	t.Run("given a backup job should save, apply retention and verify the backup file", func(t *testing.T) {
+		instance := newInstance(t)
+		folder := t.TempDir()
+		for _, ts := range []string{"2024-12-29_00-00-01", "2024-12-30_00-00-01"} {
+			require.NoError(t, os.WriteFile(filepath.Join(folder, "my-server_"+ts+"_backup.zip"), []byte("old backup"), 0o644))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(folder, "other-server_2024-12-29_00-00-01_backup.zip"), []byte("other backup"), 0o644))
+
+		result, err := s.RunJob(context.Background(), instance, BackupJobOpts{
+			BackupFolder: folder,
+			Retention:    retention.Policy{KeepLast: 2},
+			Verify:       true,
+			TestRestore:  true,
+		})
+		require.NoError(t, err)
+		assert.FileExists(t, result.Backup.Path)
+		assert.Equal(t, 1, result.Removed)
+		assert.NoFileExists(t, filepath.Join(folder, "my-server_2024-12-29_00-00-01_backup.zip"))
+		assert.FileExists(t, filepath.Join(folder, "other-server_2024-12-29_00-00-01_backup.zip"))
+	})
+
+	t.Run("given an incremental backup job should save, prune and check the repository", func(t *testing.T) {
+		instance := newInstance(t)
+		folder := t.TempDir()
+		opts := BackupJobOpts{
+			BackupFolder: folder,
+			Incremental:  true,
+			Retention:    retention.Policy{KeepLast: 1},
+			Verify:       true,
+		}
+
+		first, err := s.RunJob(context.Background(), instance, opts)
+		require.NoError(t, err)
+		require.NotNil(t, first.Snapshot)
+		assert.Zero(t, first.Removed)
+
+		second, err := s.RunJob(context.Background(), instance, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1, second.Removed)
+		assert.DirExists(t, filepath.Join(folder, "repository", "snapshots"))
+	})
+
+	t.Run("given a remote backup job should upload, apply retention remotely and restore the backup file", func(t *testing.T) {
+		instance := newInstance(t)
+		srv, s3 := destinationtest.NewS3Server(t)
+		location := destinationtest.S3Location(srv, "my-bucket/mc")
+
+		dest, err := destination.Open(context.Background(), location)
+		require.NoError(t, err)
+		for _, ts := range []string{"2024-12-29_00-00-01", "2024-12-30_00-00-01"} {
+			require.NoError(t, dest.Put(context.Background(), "my-server_"+ts+"_backup.zip", bytes.NewReader([]byte("old backup"))))
+		}
+
+		result, err := s.RunJob(context.Background(), instance, BackupJobOpts{
+			BackupFolder: location,
+			Retention:    retention.Policy{KeepLast: 2},
+			Verify:       true,
+			TestRestore:  true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Removed)
+		assert.Contains(t, result.Backup.Path, "s3://my-bucket/mc/my-server_")
+		assert.NotContains(t, s3.Objects(), "my-bucket/mc/my-server_2024-12-29_00-00-01_backup.zip")
+		// the kept backup files and the new backup metadata file
+		assert.Len(t, s3.Objects(), 3)
+		assert.Contains(t, s3.Objects(), "my-bucket/mc/"+result.Backup.Metadata.File+metadataExtension)
+
+		restored := filepath.Join(t.TempDir(), "restored")
+		_, err = s.Restore(context.Background(), restored, result.Backup.Path, RestoreOpts{})
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(restored, "world", "level.dat"))
+	})
+
+	t.Run("given an incremental job without a local repository should fail", func(t *testing.T) {
+		_, err := s.RunJob(context.Background(), newInstance(t), BackupJobOpts{
+			BackupFolder: "s3://my-bucket/mc",
+			Incremental:  true,
+		})
+		assert.ErrorIs(t, err, ErrRemoteRepository)
+	})
+}
+
+func TestBackupService_Files(t *testing.T) {
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		for name, content := range map[string]string{
+			ServerPropertiesFileName:           "level-name=my_world\n",
+			"my_world/level.dat":               "level data",
+			"my_world_nether/DIM-1/r.0.0.mca":  "nether region",
+			"plugins/dynmap/web/tiles/0_0.png": "tile",
+			"plugins/dynmap/configuration.txt": "dynmap config",
+			"logs/latest.log":                  "some logs",
+			utils.IgnoreFileName:               "# dynmap tiles\n/plugins/dynmap/web/\n",
+		} {
+			p := filepath.Join(instance, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		}
+		return instance
+	}
+	console := WithBackupConsole((&fakeConsole{}).factory(false))
+
+	t.Run("given a tar.zst format should write and restore a tar.zst backup file", func(t *testing.T) {
+		folder := t.TempDir()
+		s := NewBackupService(console, WithArchiveFormat(utils.FormatTarZst, 19))
+		bkp, err := s.Backup(context.Background(), newInstance(t), folder)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(bkp.Path, "_backup.tar.zst"))
+
+		files, err := mapBackupFiles(context.Background(), folder)
+		require.NoError(t, err)
+		require.Len(t, files["my-server"], 1)
+
+		restored := t.TempDir()
+		_, err = s.Restore(context.Background(), restored, bkp.Path, RestoreOpts{})
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(restored, "my_world", "level.dat"))
+		assert.FileExists(t, filepath.Join(restored, "plugins", "dynmap", "configuration.txt"))
+		assert.NoDirExists(t, filepath.Join(restored, "plugins", "dynmap", "web"))
+		assert.NoDirExists(t, filepath.Join(restored, "logs"))
+	})
+
+	t.Run("given excludes and includes should apply them after the ignore file", func(t *testing.T) {
+		s := NewBackupService(console, WithExcludes("*.txt"), WithIncludes("/plugins/dynmap/web/", "*.log"))
+		bkp, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+
+		restored := t.TempDir()
+		_, err = s.Restore(context.Background(), restored, bkp.Path, RestoreOpts{})
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(restored, "plugins", "dynmap", "web", "tiles", "0_0.png"))
+		assert.FileExists(t, filepath.Join(restored, "logs", "latest.log"))
+		assert.NoFileExists(t, filepath.Join(restored, "plugins", "dynmap", "configuration.txt"))
+	})
+
+	t.Run("given world only should only back up the world folders", func(t *testing.T) {
+		s := NewBackupService(console, WithWorldOnly(true))
+		bkp, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+
+		restored := t.TempDir()
+		_, err = s.Restore(context.Background(), restored, bkp.Path, RestoreOpts{})
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(restored, "my_world", "level.dat"))
+		assert.FileExists(t, filepath.Join(restored, "my_world_nether", "DIM-1", "r.0.0.mca"))
+		assert.NoFileExists(t, filepath.Join(restored, ServerPropertiesFileName))
+		assert.NoDirExists(t, filepath.Join(restored, "plugins"))
+	})
+
+	t.Run("given a pack progress function should report the packed files", func(t *testing.T) {
+		var last utils.PackProgress
+		s := NewBackupService(console, WithPackProgress(func(p utils.PackProgress) {
+			last = p
+		}))
+		_, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, 5, last.Files)
+		assert.Equal(t, last.TotalBytes, last.Bytes)
+	})
+
+	t.Run("given world only should save snapshots with the world folders", func(t *testing.T) {
+		s := NewBackupService(console, WithWorldOnly(true))
+		repository := filepath.Join(t.TempDir(), "repository")
+		snap, err := s.Snapshot(context.Background(), newInstance(t), repository)
+		require.NoError(t, err)
+
+		restored := t.TempDir()
+		_, err = s.RestoreSnapshot(context.Background(), restored, repository, snap.ShortID())
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(restored, "my_world", "level.dat"))
+		assert.NoFileExists(t, filepath.Join(restored, ServerPropertiesFileName))
+	})
+}
+
+func TestBackupService_Restore(t *testing.T) {
+	writeFiles := func(t *testing.T, folder string, files map[string]string) {
+		for name, content := range files {
+			p := filepath.Join(folder, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		}
+	}
+	s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+	newBackup := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		writeFiles(t, instance, map[string]string{
+			ServerPropertiesFileName: "motd=Backup\n",
+			"world/level.dat":        "backup level",
+			"world/region/r.0.0.mca": "backup region",
+			"plugins/plugin.jar":     "backup plugin",
+		})
+		bkp, err := s.Backup(context.Background(), instance, t.TempDir())
+		require.NoError(t, err)
+		return bkp.Path
+	}
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		writeFiles(t, instance, map[string]string{
+			ServerPropertiesFileName: "motd=Current\n",
+			"world/level.dat":        "current level",
+			"world/playerdata/p.dat": "current player",
+			"libraries/some.jar":     "library",
+		})
+		return instance
+	}
+	read := func(t *testing.T, p ...string) string {
+		b, err := os.ReadFile(filepath.Join(p...))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("given an instance should swap the restored paths and keep the replaced ones", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.Restore(context.Background(), instance, newBackup(t), RestoreOpts{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"plugins/plugin.jar", ServerPropertiesFileName, "world/level.dat", "world/region/r.0.0.mca"}, result.Files)
+		assert.Equal(t, []string{ServerPropertiesFileName, "world"}, result.Replaced)
+		assert.Equal(t, instance+".rollback", result.Rollback)
+
+		assert.Equal(t, "motd=Backup\n", read(t, instance, ServerPropertiesFileName))
+		assert.Equal(t, "backup region", read(t, instance, "world", "region", "r.0.0.mca"))
+		assert.NoFileExists(t, filepath.Join(instance, "world", "playerdata", "p.dat"))
+		assert.FileExists(t, filepath.Join(instance, "libraries", "some.jar"))
+
+		assert.Equal(t, "motd=Current\n", read(t, result.Rollback, ServerPropertiesFileName))
+		assert.Equal(t, "current player", read(t, result.Rollback, "world", "playerdata", "p.dat"))
+		assert.NoDirExists(t, instance+".restore")
+	})
+
+	t.Run("given only paths should restore just them", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.Restore(context.Background(), instance, newBackup(t), RestoreOpts{Only: []string{"world/region/", "plugins"}})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"plugins/plugin.jar", "world/region/r.0.0.mca"}, result.Files)
+		assert.Empty(t, result.Replaced)
+		assert.Empty(t, result.Rollback)
+		assert.Equal(t, "motd=Current\n", read(t, instance, ServerPropertiesFileName))
+		assert.Equal(t, "current level", read(t, instance, "world", "level.dat"))
+		assert.Equal(t, "backup region", read(t, instance, "world", "region", "r.0.0.mca"))
+		assert.Equal(t, "backup plugin", read(t, instance, "plugins", "plugin.jar"))
+	})
+
+	t.Run("given a dry run should not change the instance", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.Restore(context.Background(), instance, newBackup(t), RestoreOpts{Only: []string{"world"}, DryRun: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"world/level.dat", "world/region/r.0.0.mca"}, result.Files)
+		assert.Equal(t, []string{"world"}, result.Replaced)
+		assert.Equal(t, "current level", read(t, instance, "world", "level.dat"))
+		assert.NoDirExists(t, instance+".rollback")
+	})
+
+	t.Run("given a running server should refuse to restore", func(t *testing.T) {
+		instance := newInstance(t)
+		writeFiles(t, instance, map[string]string{ServerPIDFileName: strconv.Itoa(os.Getpid())})
+
+		_, err := s.Restore(context.Background(), instance, newBackup(t), RestoreOpts{})
+		assert.ErrorIs(t, err, ErrServerRunning)
+		assert.Equal(t, "current level", read(t, instance, "world", "level.dat"))
+	})
+
+	t.Run("given an only path escaping the instance should fail", func(t *testing.T) {
+		_, err := s.Restore(context.Background(), newInstance(t), newBackup(t), RestoreOpts{Only: []string{"../other"}})
+		assert.ErrorIs(t, err, utils.ErrUnsafePath)
+	})
+}
+
+func TestBackupService_Catalog(t *testing.T) {
+	newRepo := func(t *testing.T, instance string) repository.Repository {
+		repo, err := repository.NewStormRepository(filepath.Join(t.TempDir(), "catalog.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = repo.Close()
+		})
+		require.NoError(t, repo.SaveInstance(context.Background(), &model.Instance{ID: "instance-id", Name: "my-server", Path: instance}))
+		return repo
+	}
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), levelData(t, true), 0o644))
+		b, err := json.Marshal(model.VersionsInfo{MineFlavour: model.MineFlavourVanilla, MineVersion: "1.21.4"})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(instance, config.VersionsFileName), b, 0o644))
+		return instance
+	}
+	writeRecord := func(t *testing.T, folder string, record model.BackupRecord) {
+		require.NoError(t, os.WriteFile(filepath.Join(folder, record.File), []byte("old backup"), 0o644))
+		b, err := json.Marshal(record)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(folder, record.File+metadataExtension), b, 0o644))
+	}
+
+	t.Run("given a backup should write its metadata file and catalog record", func(t *testing.T) {
+		instance := newInstance(t)
+		repo := newRepo(t, instance)
+		folder := t.TempDir()
+		s := NewBackupService(WithBackupRepository(repo), WithTrigger(model.BackupTriggerScheduled))
+
+		bkp, err := s.Backup(context.Background(), instance, folder)
+		require.NoError(t, err)
+
+		b, err := os.ReadFile(bkp.Path + metadataExtension)
+		require.NoError(t, err)
+		var record model.BackupRecord
+		require.NoError(t, json.Unmarshal(b, &record))
+		assert.Equal(t, "instance-id", record.InstanceID)
+		assert.Equal(t, "my-server", record.InstanceName)
+		assert.Equal(t, model.MineFlavourVanilla, record.MineFlavour)
+		assert.Equal(t, "1.21.4", record.MineVersion)
+		assert.Equal(t, model.BackupTriggerScheduled, record.Trigger)
+		assert.Equal(t, "zip", record.Format)
+		assert.Equal(t, 2, record.Files)
+		assert.Equal(t, bkp.Size, record.Size)
+		content, err := os.ReadFile(bkp.Path)
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+		assert.Equal(t, hex.EncodeToString(sum[:]), record.Checksum)
+
+		records, err := repo.ListBackups(context.Background())
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, record.ID, records[0].ID)
+	})
+
+	t.Run("given a renamed instance should keep its older backups on rollover", func(t *testing.T) {
+		instance := newInstance(t)
+		repo := newRepo(t, instance)
+		folder := t.TempDir()
+		old := model.BackupRecord{
+			ID:           "old-backup",
+			InstanceID:   "instance-id",
+			InstanceName: "old-name",
+			File:         "old-name_2024-12-29_00-00-01_backup.zip",
+			CreatedAt:    time.Date(2024, 12, 29, 0, 0, 1, 0, time.Local),
+		}
+		writeRecord(t, folder, old)
+		require.NoError(t, repo.SaveBackup(context.Background(), &old))
+		s := NewBackupService(WithBackupRepository(repo))
+
+		bkp, err := s.Backup(context.Background(), instance, folder)
+		require.NoError(t, err)
+
+		files, err := mapBackupFiles(context.Background(), folder)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Len(t, files["my-server"], 2)
+
+		require.NoError(t, s.RolloverBackupFiles(context.Background(), folder, bkp.Name, 1))
+		assert.NoFileExists(t, filepath.Join(folder, old.File))
+		assert.NoFileExists(t, filepath.Join(folder, old.File+metadataExtension))
+		assert.FileExists(t, bkp.Path+metadataExtension)
+		records, err := repo.ListBackups(context.Background())
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, bkp.Metadata.ID, records[0].ID)
+	})
+
+	t.Run("given a filter should list the matching catalog backups", func(t *testing.T) {
+		instance := newInstance(t)
+		repo := newRepo(t, instance)
+		for i, name := range []string{"my-server", "other-server"} {
+			require.NoError(t, repo.SaveBackup(context.Background(), &model.BackupRecord{
+				ID:           name,
+				InstanceID:   name + "-id",
+				InstanceName: name,
+				CreatedAt:    time.Date(2025, 1, 1+i, 0, 0, 0, 0, time.Local),
+			}))
+		}
+		s := NewBackupService(WithBackupRepository(repo))
+
+		backups, err := s.ListBackups(context.Background(), "", BackupFilter{})
+		require.NoError(t, err)
+		assert.Len(t, backups, 2)
+
+		backups, err = s.ListBackups(context.Background(), "", BackupFilter{Instance: "other-server-id"})
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, "other-server", backups[0].Name)
+
+		backups, err = s.ListBackups(context.Background(), "", BackupFilter{Until: time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)})
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, "my-server", backups[0].Name)
+	})
+
+	t.Run("given no repository should fail listing the catalog", func(t *testing.T) {
+		_, err := NewBackupService().ListBackups(context.Background(), "", BackupFilter{})
+		assert.ErrorIs(t, err, ErrNoBackupCatalog)
+	})
+}
+
+func TestBackupService_RestoreRegion(t *testing.T) {
+	writeRegion := func(t *testing.T, file string, chunks map[int]string) {
+		r := &anvil.Region{}
+		for i, data := range chunks {
+			r.Chunks[i] = &anvil.Chunk{Compression: anvil.CompressionUncompressed, Data: []byte(data)}
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.NoError(t, anvil.WriteRegionFile(file, r))
+	}
+	readChunk := func(t *testing.T, file string, index int) string {
+		r, err := anvil.ReadRegionFile(file)
+		require.NoError(t, err)
+		if r.Chunks[index] == nil {
+			return ""
+		}
+		return string(r.Chunks[index].Data)
+	}
+	s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+	newBackup := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		writeRegion(t, filepath.Join(instance, "world", "region", "r.0.0.mca"), map[int]string{0: "backup 0", 1: "backup 1"})
+		writeRegion(t, filepath.Join(instance, "world", "entities", "r.0.0.mca"), map[int]string{0: "backup entities"})
+		writeRegion(t, filepath.Join(instance, "world", "region", "r.1.0.mca"), map[int]string{0: "backup far"})
+		bkp, err := s.Backup(context.Background(), instance, t.TempDir())
+		require.NoError(t, err)
+		return bkp.Path
+	}
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		writeRegion(t, filepath.Join(instance, "world", "region", "r.0.0.mca"), map[int]string{0: "griefed 0", 1: "griefed 1", 2: "new 2"})
+		writeRegion(t, filepath.Join(instance, "world", "region", "r.1.0.mca"), map[int]string{0: "current far"})
+		return instance
+	}
+	area := BlockArea{FromX: 3, FromZ: 15, ToX: 0, ToZ: 0}
+
+	t.Run("given an area should restore its whole region files", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.RestoreRegion(context.Background(), instance, newBackup(t), RegionRestoreOpts{Dimension: DimensionOverworld, Area: area})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"world/entities/r.0.0.mca", "world/region/r.0.0.mca"}, result.Files)
+
+		region := filepath.Join(instance, "world", "region")
+		assert.Equal(t, "backup 0", readChunk(t, filepath.Join(region, "r.0.0.mca"), 0))
+		assert.Equal(t, "backup 1", readChunk(t, filepath.Join(region, "r.0.0.mca"), 1))
+		assert.Empty(t, readChunk(t, filepath.Join(region, "r.0.0.mca"), 2))
+		assert.Equal(t, "current far", readChunk(t, filepath.Join(region, "r.1.0.mca"), 0))
+		assert.Equal(t, "griefed 0", readChunk(t, filepath.Join(result.Rollback, "world", "region", "r.0.0.mca"), 0))
+	})
+
+	t.Run("given chunks should only restore the area chunks", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.RestoreRegion(context.Background(), instance, newBackup(t), RegionRestoreOpts{
+			Dimension: DimensionOverworld,
+			Area:      area,
+			Chunks:    true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"world/entities/r.0.0.mca", "world/region/r.0.0.mca"}, result.Files)
+		assert.Equal(t, 1, result.Chunks)
+
+		region := filepath.Join(instance, "world", "region")
+		assert.Equal(t, "backup 0", readChunk(t, filepath.Join(region, "r.0.0.mca"), 0))
+		assert.Equal(t, "griefed 1", readChunk(t, filepath.Join(region, "r.0.0.mca"), 1))
+		assert.Equal(t, "new 2", readChunk(t, filepath.Join(region, "r.0.0.mca"), 2))
+		assert.Equal(t, "backup entities", readChunk(t, filepath.Join(instance, "world", "entities", "r.0.0.mca"), 0))
+		assert.Equal(t, "current far", readChunk(t, filepath.Join(region, "r.1.0.mca"), 0))
+
+		assert.Equal(t, "griefed 0", readChunk(t, filepath.Join(result.Rollback, "world", "region", "r.0.0.mca"), 0))
+		assert.NoFileExists(t, filepath.Join(result.Rollback, "world", "entities", "r.0.0.mca"))
+	})
+
+	t.Run("given chunks missing from the backup should remove them", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.RestoreRegion(context.Background(), instance, newBackup(t), RegionRestoreOpts{
+			Dimension: DimensionOverworld,
+			Area:      BlockArea{FromX: 32, FromZ: 0, ToX: 32, ToZ: 0},
+			Chunks:    true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Chunks)
+		assert.Empty(t, readChunk(t, filepath.Join(instance, "world", "region", "r.0.0.mca"), 2))
+		assert.Equal(t, "griefed 0", readChunk(t, filepath.Join(instance, "world", "region", "r.0.0.mca"), 0))
+	})
+
+	t.Run("given a dry run should not change the instance", func(t *testing.T) {
+		instance := newInstance(t)
+		result, err := s.RestoreRegion(context.Background(), instance, newBackup(t), RegionRestoreOpts{
+			Dimension: DimensionOverworld,
+			Area:      area,
+			Chunks:    true,
+			DryRun:    true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Chunks)
+		assert.Equal(t, "griefed 0", readChunk(t, filepath.Join(instance, "world", "region", "r.0.0.mca"), 0))
+		assert.NoDirExists(t, result.Rollback)
+	})
+
+	t.Run("given a dimension should find its folder", func(t *testing.T) {
+		instance := t.TempDir()
+		d, err := ParseDimension("minecraft:the_nether")
+		require.NoError(t, err)
+		assert.Equal(t, DimensionNether, d)
+		_, err = ParseDimension("moon")
+		assert.ErrorIs(t, err, ErrUnknownDimension)
+
+		folder, err := dimensionFolder(instance, DimensionNether)
+		require.NoError(t, err)
+		assert.Equal(t, "world/DIM-1", folder)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world_the_end", "DIM1"), os.ModePerm))
+		folder, err = dimensionFolder(instance, DimensionEnd)
+		require.NoError(t, err)
+		assert.Equal(t, "world_the_end/DIM1", folder)
+	})
+}
+
+func TestBackupService_Diff(t *testing.T) {
+	writeFiles := func(t *testing.T, folder string, files map[string]string) {
+		for name, content := range files {
+			p := filepath.Join(folder, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+			require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+		}
+	}
+	s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)))
+	instance := filepath.Join(t.TempDir(), "my-server")
+	writeFiles(t, instance, map[string]string{
+		ServerPropertiesFileName:        "motd=Before\npvp=true\n",
+		"ops.json":                      "[]",
+		"world/level.dat":               "level",
+		"world/region/r.0.0.mca":        "region",
+		"world/DIM-1/region/r.-1.0.mca": "nether region",
+		"plugins/plugin.jar":            "plugin",
+	})
+	first, err := s.Backup(context.Background(), instance, t.TempDir())
+	require.NoError(t, err)
+
+	writeFiles(t, instance, map[string]string{
+		ServerPropertiesFileName:        "motd=After\npvp=true\n",
+		"world/DIM-1/region/r.-1.0.mca": "griefed nether region",
+		"world/region/r.1.0.mca":        "new region",
+		"whitelist.json":                "[]",
+	})
+	require.NoError(t, os.Remove(filepath.Join(instance, "plugins", "plugin.jar")))
+
+	t.Run("given two backups should group the changed files", func(t *testing.T) {
+		second, err := s.Backup(context.Background(), instance, t.TempDir())
+		require.NoError(t, err)
+
+		diff, err := s.Diff(context.Background(), first.Path, second.Path, DiffOpts{TextDiffs: true})
+		require.NoError(t, err)
+		assert.Equal(t, []FileDiff{
+			{Path: "server.properties", Change: ChangeChanged, Group: DiffGroupConfig, Diff: strings.Join([]string{
+				"--- server.properties",
+				"+++ server.properties",
+				"@@ -1,2 +1,2 @@",
+				"-motd=Before",
+				"+motd=After",
+				" pvp=true",
+				"",
+			}, "\n")},
+			{Path: "whitelist.json", Change: ChangeAdded, Group: DiffGroupConfig},
+			{Path: "plugins/plugin.jar", Change: ChangeRemoved, Group: DiffGroupOther},
+			{Path: "world/DIM-1/region/r.-1.0.mca", Change: ChangeChanged, Group: "world (nether) region -1,0"},
+			{Path: "world/region/r.1.0.mca", Change: ChangeAdded, Group: "world (overworld) region 1,0"},
+		}, diff.Files)
+		assert.Equal(t, 3, diff.Unchanged)
+		assert.Equal(t, 2, diff.Count(ChangeAdded))
+	})
+
+	t.Run("given the live instance should compare the files a backup would pack", func(t *testing.T) {
+		writeFiles(t, instance, map[string]string{"logs/latest.log": "ignored"})
+		diff, err := s.Diff(context.Background(), first.Path, instance, DiffOpts{Live: true})
+		require.NoError(t, err)
+		require.Len(t, diff.Files, 5)
+		assert.Empty(t, diff.Files[0].Diff)
+		assert.Equal(t, 3, diff.Unchanged)
+	})
+}
+
+func TestBackupService_Hooks(t *testing.T) {
+	newInstance := func(t *testing.T) string {
+		instance := filepath.Join(t.TempDir(), "my-server")
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(instance, "world", "level.dat"), []byte("level data"), 0o644))
+		return instance
+	}
+	newRunner := func(t *testing.T, out *bytes.Buffer, h map[hooks.Event][]hooks.Hook) *hooks.Runner {
+		r, err := hooks.NewRunner(h, hooks.WithOutput(out))
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("given backup hooks should run them around the backup", func(t *testing.T) {
+		var out bytes.Buffer
+		s := NewBackupService(WithBackupHooks(newRunner(t, &out, map[hooks.Event][]hooks.Hook{
+			hooks.PreBackup:  {{Command: `echo "pre $MINESERVER_INSTANCE_NAME $MINESERVER_BACKUP_FILE"`}},
+			hooks.PostBackup: {{Command: `echo "post $MINESERVER_STATUS $MINESERVER_BACKUP_FILE"`}},
+		})))
+
+		bkp, err := s.Backup(context.Background(), newInstance(t), t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "pre my-server \npost success "+bkp.Path+"\n", out.String())
+	})
+
+	t.Run("given a failing pre backup hook should not backup the instance", func(t *testing.T) {
+		var out bytes.Buffer
+		s := NewBackupService(WithBackupHooks(newRunner(t, &out, map[hooks.Event][]hooks.Hook{
+			hooks.PreBackup:  {{Command: "exit 1"}},
+			hooks.PostBackup: {{Command: "echo post"}},
+		})))
+
+		folder := t.TempDir()
+		_, err := s.Backup(context.Background(), newInstance(t), folder)
+		assert.ErrorContains(t, err, "pre-backup hook 1")
+		entries, err := os.ReadDir(folder)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("given a failing restore should run post restore hooks with the error", func(t *testing.T) {
+		var out bytes.Buffer
+		s := NewBackupService(WithBackupHooks(newRunner(t, &out, map[hooks.Event][]hooks.Hook{
+			hooks.PostRestore: {{Command: `echo "$MINESERVER_STATUS $MINESERVER_BACKUP_FILE"`}},
+		})))
+
+		missing := filepath.Join(t.TempDir(), "missing.zip")
+		_, err := s.Restore(context.Background(), newInstance(t), missing, RestoreOpts{})
+		assert.Error(t, err)
+		assert.Equal(t, "failure "+missing+"\n", out.String())
+
+		out.Reset()
+		_, err = s.Restore(context.Background(), newInstance(t), missing, RestoreOpts{DryRun: true})
+		assert.Error(t, err)
+		assert.Empty(t, out.String(), "dry runs shouldn't run hooks")
+	})
+
+	t.Run("given backup and restore hooks should run them around snapshots", func(t *testing.T) {
+		var out bytes.Buffer
+		s := NewBackupService(WithBackupConsole((&fakeConsole{}).factory(false)), WithBackupHooks(newRunner(t, &out, map[hooks.Event][]hooks.Hook{
+			hooks.PostBackup:  {{Command: `echo "backup $MINESERVER_STATUS $MINESERVER_BACKUP_FILE"`}},
+			hooks.PreRestore:  {{Command: "echo restoring {{ .BackupFile }}"}},
+			hooks.PostRestore: {{Command: `echo "restore $MINESERVER_STATUS"`}},
+		})))
+
+		instance := newInstance(t)
+		repository := filepath.Join(t.TempDir(), "repository")
+		snap, err := s.Snapshot(context.Background(), instance, repository)
+		require.NoError(t, err)
+		_, err = s.RestoreSnapshot(context.Background(), instance, repository, snap.ShortID())
+		require.NoError(t, err)
+		assert.Equal(t, "backup success "+snap.ID+"\nrestoring "+snap.ShortID()+"\nrestore success\n", out.String())
+	})
+}
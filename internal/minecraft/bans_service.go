@@ -0,0 +1,350 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	BannedIPsFileName = "banned-ips.json"
+
+	defaultBanSource = "Server"
+	defaultBanReason = "Banned by an operator."
+)
+
+// BanOpts describes a ban
+type BanOpts struct {
+	Reason string
+	Source string
+	// Expires is when the ban ends (zero means it never ends)
+	Expires time.Time
+}
+
+type BansService interface {
+	// Ban bans (or updates the ban of) users. A running server gets
+	// them through `ban` command, which can't expire, so timed bans are
+	// only written to the ban list and applied after a restart.
+	Ban(ctx context.Context, instancePath string, opts BanOpts, users ...string) ([]model.BannedPlayerRecord, error)
+	// Pardon removes users bans
+	Pardon(ctx context.Context, instancePath string, users ...string) ([]model.BannedPlayerRecord, error)
+	// BanIP bans (or updates the ban of) IP addresses
+	BanIP(ctx context.Context, instancePath string, opts BanOpts, ips ...string) ([]model.BannedIPRecord, error)
+	// PardonIP removes IP addresses bans
+	PardonIP(ctx context.Context, instancePath string, ips ...string) ([]model.BannedIPRecord, error)
+	// List lists instance banned players and IP addresses
+	List(ctx context.Context, instancePath string) (*model.BanList, error)
+	// Import merges list into instance ban lists, keeping the existing
+	// bans and skipping the expired ones. Returns the imported bans.
+	Import(ctx context.Context, instancePath string, list *model.BanList) (*model.BanList, error)
+	// Sync merges the ban lists from instance 'from' into every 'to'
+	// instance. Returns the imported bans by instance path.
+	Sync(ctx context.Context, from string, to ...string) (map[string]*model.BanList, error)
+}
+
+type bansService struct {
+	cfg PlayerServiceConfig
+}
+
+// NewBansService creates a new ban lists management service
+func NewBansService(opts ...PlayerServiceOpt) BansService {
+	return &bansService{
+		cfg: newPlayerServiceConfig(opts...),
+	}
+}
+
+func (s *bansService) Ban(ctx context.Context, instancePath string, opts BanOpts, users ...string) ([]model.BannedPlayerRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolvePlayersFor(ctx, s.cfg.Client, instancePath, users...)
+	if err != nil {
+		return nil, err
+	}
+
+	created, source, expires, reason := opts.record(time.Now())
+	cmds := make([]string, 0, len(users))
+	for _, u := range users {
+		id := resolved[strings.ToLower(u)]
+		r := model.BannedPlayerRecord{
+			Uuid:    id.UUID(),
+			Name:    id.Name,
+			Created: created,
+			Source:  source,
+			Expires: expires,
+			Reason:  reason,
+		}
+		if idx := indexOfBannedPlayer(list.Players, id.Name); idx >= 0 {
+			list.Players[idx] = r
+		} else {
+			list.Players = append(list.Players, r)
+		}
+		if isPermanentBan(expires) {
+			cmds = append(cmds, banCommand("ban", id.Name, reason))
+		}
+	}
+
+	return list.Players, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *bansService) Pardon(ctx context.Context, instancePath string, users ...string) ([]model.BannedPlayerRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]string, 0, len(users))
+	for _, u := range users {
+		idx := indexOfBannedPlayer(list.Players, u)
+		if idx < 0 {
+			return nil, fmt.Errorf("user '%s' is not banned", u)
+		}
+		cmds = append(cmds, "pardon "+list.Players[idx].Name)
+		list.Players = append(list.Players[:idx], list.Players[idx+1:]...)
+	}
+
+	return list.Players, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *bansService) BanIP(ctx context.Context, instancePath string, opts BanOpts, ips ...string) ([]model.BannedIPRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	created, source, expires, reason := opts.record(time.Now())
+	cmds := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid ip address: '%s'", ip)
+		}
+		r := model.BannedIPRecord{
+			IP:      ip,
+			Created: created,
+			Source:  source,
+			Expires: expires,
+			Reason:  reason,
+		}
+		if idx := indexOfBannedIP(list.IPs, ip); idx >= 0 {
+			list.IPs[idx] = r
+		} else {
+			list.IPs = append(list.IPs, r)
+		}
+		if isPermanentBan(expires) {
+			cmds = append(cmds, banCommand("ban-ip", ip, reason))
+		}
+	}
+
+	return list.IPs, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *bansService) PardonIP(ctx context.Context, instancePath string, ips ...string) ([]model.BannedIPRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		idx := indexOfBannedIP(list.IPs, ip)
+		if idx < 0 {
+			return nil, fmt.Errorf("ip address '%s' is not banned", ip)
+		}
+		cmds = append(cmds, "pardon-ip "+ip)
+		list.IPs = append(list.IPs[:idx], list.IPs[idx+1:]...)
+	}
+
+	return list.IPs, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *bansService) List(_ context.Context, instancePath string) (*model.BanList, error) {
+	players, err := readJSONList[model.BannedPlayerRecord](filepath.Join(instancePath, BannedPlayersFileName))
+	if err != nil {
+		return nil, fmt.Errorf("reading banned players: %w", err)
+	}
+	ips, err := readJSONList[model.BannedIPRecord](filepath.Join(instancePath, BannedIPsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("reading banned ips: %w", err)
+	}
+	return &model.BanList{Players: players, IPs: ips}, nil
+}
+
+func (s *bansService) Import(ctx context.Context, instancePath string, src *model.BanList) (*model.BanList, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	added := &model.BanList{}
+	var cmds []string
+	for _, r := range src.Players {
+		if isBanExpired(r.Expires, now) || indexOfBannedPlayer(list.Players, r.Name) >= 0 {
+			continue
+		}
+		list.Players = append(list.Players, r)
+		added.Players = append(added.Players, r)
+		if isPermanentBan(r.Expires) {
+			cmds = append(cmds, banCommand("ban", r.Name, r.Reason))
+		}
+	}
+	for _, r := range src.IPs {
+		if isBanExpired(r.Expires, now) || indexOfBannedIP(list.IPs, r.IP) >= 0 {
+			continue
+		}
+		list.IPs = append(list.IPs, r)
+		added.IPs = append(added.IPs, r)
+		if isPermanentBan(r.Expires) {
+			cmds = append(cmds, banCommand("ban-ip", r.IP, r.Reason))
+		}
+	}
+
+	return added, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *bansService) Sync(ctx context.Context, from string, to ...string) (map[string]*model.BanList, error) {
+	log := logger.GetLogger().With("action", "sync_bans", "from", from, "to", to)
+
+	src, err := s.List(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	srcMode, err := instanceUUIDMode(from)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*model.BanList)
+	for _, dest := range to {
+		if filepath.Clean(dest) == filepath.Clean(from) {
+			continue
+		}
+		list := src
+		mode, err := instanceUUIDMode(dest)
+		if err != nil {
+			return result, err
+		}
+		if mode != srcMode {
+			// players UUIDs depend on the server online-mode
+			list, err = s.convertPlayersUUIDs(ctx, src, mode)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		added, err := s.Import(ctx, dest, list)
+		if err != nil {
+			return result, fmt.Errorf("importing bans to %s: %w", dest, err)
+		}
+		log.With("dest", dest, "players", len(added.Players), "ips", len(added.IPs)).DebugContext(ctx, "Bans synced")
+		result[dest] = added
+	}
+	return result, nil
+}
+
+// convertPlayersUUIDs returns a copy of list with players
+// UUIDs following the mode scheme
+func (s *bansService) convertPlayersUUIDs(ctx context.Context, list *model.BanList, mode UUIDMode) (*model.BanList, error) {
+	names := make([]string, 0, len(list.Players))
+	for _, r := range list.Players {
+		names = append(names, r.Name)
+	}
+	resolved, missing, err := lookupPlayersAs(ctx, s.cfg.Client, mode, names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		logger.GetLogger().With("players", missing).WarnContext(ctx, "Skipping players without a Mojang account")
+	}
+
+	converted := &model.BanList{IPs: list.IPs}
+	for _, r := range list.Players {
+		id, ok := resolved[strings.ToLower(r.Name)]
+		if !ok {
+			continue
+		}
+		r.Uuid = id.UUID()
+		converted.Players = append(converted.Players, r)
+	}
+	return converted, nil
+}
+
+// save sends cmds to the running server before writing ban
+// files, as the server rewrites them when executing the commands
+func (s *bansService) save(ctx context.Context, instancePath string, list *model.BanList, cmds ...string) error {
+	if err := notifyServer(ctx, s.cfg.Console, instancePath, cmds...); err != nil {
+		return err
+	}
+
+	if err := writeJSONList(filepath.Join(instancePath, BannedPlayersFileName), list.Players); err != nil {
+		return fmt.Errorf("writing banned players: %w", err)
+	}
+	if err := writeJSONList(filepath.Join(instancePath, BannedIPsFileName), list.IPs); err != nil {
+		return fmt.Errorf("writing banned ips: %w", err)
+	}
+	return nil
+}
+
+// record returns the ban list fields (created, source, expires and reason)
+func (o BanOpts) record(now time.Time) (string, string, string, string) {
+	source := o.Source
+	if source == "" {
+		source = defaultBanSource
+	}
+	reason := o.Reason
+	if reason == "" {
+		reason = defaultBanReason
+	}
+	expires := model.BanExpiresForever
+	if !o.Expires.IsZero() {
+		expires = o.Expires.Format(model.BanDateLayout)
+	}
+	return now.Format(model.BanDateLayout), source, expires, reason
+}
+
+func isBanExpired(expires string, now time.Time) bool {
+	if isPermanentBan(expires) {
+		return false
+	}
+	t, err := time.Parse(model.BanDateLayout, expires)
+	if err != nil {
+		return false
+	}
+	return t.Before(now)
+}
+
+// isPermanentBan tells if a ban never ends, the only ones the server
+// commands can apply
+func isPermanentBan(expires string) bool {
+	return expires == "" || strings.EqualFold(expires, model.BanExpiresForever)
+}
+
+func banCommand(cmd, target, reason string) string {
+	if reason == "" {
+		return cmd + " " + target
+	}
+	return cmd + " " + target + " " + reason
+}
+
+func indexOfBannedPlayer(list []model.BannedPlayerRecord, name string) int {
+	for i, r := range list {
+		if strings.EqualFold(r.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfBannedIP(list []model.BannedIPRecord, ip string) int {
+	for i, r := range list {
+		if r.IP == ip {
+			return i
+		}
+	}
+	return -1
+}
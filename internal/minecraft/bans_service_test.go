@@ -0,0 +1,106 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBansService(t *testing.T) {
+	t.Run("given users and ips should write ban lists and ban permanent ones on running server", func(t *testing.T) {
+		dest := t.TempDir()
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{"griefer"}).Return(mojang.UserIDResponse{
+			{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "Griefer"},
+		}, nil)
+		console := &fakeConsole{}
+
+		s := NewBansService(WithMojangClient(c), WithConsole(console.factory(true)))
+		expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		players, err := s.Ban(context.Background(), dest, BanOpts{Reason: "griefing", Expires: expires}, "griefer")
+		assert.NoError(t, err)
+		assert.Len(t, players, 1)
+		assert.Equal(t, "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", players[0].Uuid)
+		assert.Equal(t, "Griefer", players[0].Name)
+		assert.Equal(t, "griefing", players[0].Reason)
+		assert.Equal(t, defaultBanSource, players[0].Source)
+		assert.Equal(t, "2030-01-02 03:04:05 +0000", players[0].Expires)
+
+		ips, err := s.BanIP(context.Background(), dest, BanOpts{}, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Len(t, ips, 1)
+		assert.Equal(t, model.BanExpiresForever, ips[0].Expires)
+		assert.Equal(t, defaultBanReason, ips[0].Reason)
+
+		_, err = s.BanIP(context.Background(), dest, BanOpts{}, "not-an-ip")
+		assert.Error(t, err)
+
+		// the ban command would make the timed ban permanent
+		assert.Equal(t, []string{"ban-ip 10.0.0.1 Banned by an operator."}, console.commands)
+
+		list, err := s.List(context.Background(), dest)
+		assert.NoError(t, err)
+		assert.Len(t, list.Players, 1)
+		assert.Len(t, list.IPs, 1)
+
+		players, err = s.Pardon(context.Background(), dest, "GRIEFER")
+		assert.NoError(t, err)
+		assert.Empty(t, players)
+		ips, err = s.PardonIP(context.Background(), dest, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.Empty(t, ips)
+		assert.Equal(t, "pardon Griefer", console.commands[1])
+		assert.Equal(t, "pardon-ip 10.0.0.1", console.commands[2])
+
+		c.AssertExpectations(t)
+	})
+
+	t.Run("given an exported list should import only new and active bans", func(t *testing.T) {
+		dest := t.TempDir()
+		assert.NoError(t, writeJSONList(filepath.Join(dest, BannedPlayersFileName), []model.BannedPlayerRecord{
+			{Uuid: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_", Expires: model.BanExpiresForever, Reason: "original"},
+		}))
+
+		s := NewBansService(WithMojangClient(new(mockMojangClient)), WithConsole((&fakeConsole{}).factory(false)))
+		added, err := s.Import(context.Background(), dest, &model.BanList{
+			Players: []model.BannedPlayerRecord{
+				{Uuid: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_", Expires: model.BanExpiresForever, Reason: "imported"},
+				{Uuid: "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", Name: "Griefer", Expires: model.BanExpiresForever},
+				{Uuid: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch", Expires: "2020-01-01 00:00:00 +0000"},
+			},
+			IPs: []model.BannedIPRecord{{IP: "10.0.0.1", Expires: model.BanExpiresForever}},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, added.Players, 1)
+		assert.Equal(t, "Griefer", added.Players[0].Name)
+		assert.Len(t, added.IPs, 1)
+
+		list, err := s.List(context.Background(), dest)
+		assert.NoError(t, err)
+		assert.Len(t, list.Players, 2)
+		assert.Equal(t, "original", list.Players[0].Reason)
+	})
+
+	t.Run("given instances with different online-mode should sync bans converting UUIDs", func(t *testing.T) {
+		from := t.TempDir()
+		to := t.TempDir()
+		assert.NoError(t, writeJSONList(filepath.Join(from, BannedPlayersFileName), []model.BannedPlayerRecord{
+			{Uuid: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch", Expires: model.BanExpiresForever},
+		}))
+		assert.NoError(t, setOnlineMode(to, false))
+
+		s := NewBansService(WithMojangClient(new(mockMojangClient)), WithConsole((&fakeConsole{}).factory(false)))
+		result, err := s.Sync(context.Background(), from, to)
+		assert.NoError(t, err)
+		assert.Len(t, result[to].Players, 1)
+
+		list, err := s.List(context.Background(), to)
+		assert.NoError(t, err)
+		assert.Equal(t, OfflineUUID("Notch"), list.Players[0].Uuid)
+	})
+}
@@ -2,11 +2,13 @@ package config
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/eldius/mineserver-manager/internal/model"
 	"github.com/eldius/mineserver-manager/internal/mojang"
 	"github.com/eldius/mineserver-manager/internal/utils"
 	"gopkg.in/yaml.v3"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -18,12 +20,39 @@ const (
 
 type ServerSoftware string
 
+// Operator is a server operator (op) definition
+type Operator struct {
+	Name string
+	// Level is the operator permission level (zero means
+	// the instance `op-permission-level` value)
+	Level               int
+	BypassesPlayerLimit bool
+}
+
+// ParseOperator parses an operator definition in the `name[:level]` format
+func ParseOperator(s string) (Operator, error) {
+	name, level, hasLevel := strings.Cut(strings.TrimSpace(s), ":")
+	if name == "" {
+		return Operator{}, fmt.Errorf("invalid operator '%s': empty name", s)
+	}
+	op := Operator{Name: name}
+	if hasLevel {
+		l, err := strconv.Atoi(level)
+		if err != nil {
+			return Operator{}, fmt.Errorf("invalid operator '%s' level: %w", s, err)
+		}
+		op.Level = l
+	}
+	return op, nil
+}
+
 type InstanceOpts struct {
 	SrvProps           *model.ServerProperties
 	VersionInfo        *mojang.VersionInfoResponse
 	Dest               string
 	VersionName        string
 	WhitelistUsernames []string
+	Operators          []Operator
 	MemoryOpt          string
 	AddLogConfig       bool
 	Headless           bool
@@ -34,6 +63,10 @@ func (o InstanceOpts) HasWhitelist() bool {
 	return len(o.WhitelistUsernames) > 0
 }
 
+func (o InstanceOpts) HasOperators() bool {
+	return len(o.Operators) > 0
+}
+
 func (o InstanceOpts) AbsoluteDestPath() string {
 	d, err := filepath.Abs(o.Dest)
 	if err != nil {
@@ -65,6 +98,16 @@ func WithWhitelistedUsers(users []string) InstanceOpt {
 	}
 }
 
+// WithOperators defines the server operators
+func WithOperators(ops []Operator) InstanceOpt {
+	return func(c *InstanceOpts) {
+		if len(ops) == 0 {
+			return
+		}
+		c.Operators = ops
+	}
+}
+
 func ToDestinationFolder(t string) InstanceOpt {
 	return func(c *InstanceOpts) {
 		c.Dest = t
@@ -37,3 +37,25 @@ func TestWithServerFlavour(t *testing.T) {
 		assert.Equal(t, EmptyServerSoftware, f.Flavor)
 	})
 }
+
+func TestParseOperator(t *testing.T) {
+	t.Run("given a name without level should use the instance default level", func(t *testing.T) {
+		op, err := ParseOperator("Eldius")
+		assert.NoError(t, err)
+		assert.Equal(t, Operator{Name: "Eldius"}, op)
+	})
+
+	t.Run("given a name with level should parse both", func(t *testing.T) {
+		op, err := ParseOperator("jeb_:2")
+		assert.NoError(t, err)
+		assert.Equal(t, Operator{Name: "jeb_", Level: 2}, op)
+	})
+
+	t.Run("given an invalid level should fail", func(t *testing.T) {
+		_, err := ParseOperator("jeb_:admin")
+		assert.Error(t, err)
+
+		_, err = ParseOperator(":2")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServerPropertyKey describes a server.properties key lifecycle
+type ServerPropertyKey struct {
+	// Name is the key name
+	Name string
+	// Default is the value written by a fresh server
+	Default string
+	// Since is the first version shipping this key (empty means it always existed)
+	Since string
+	// Until is the first version without this key (empty means it's still supported)
+	Until string
+}
+
+// serverPropertyRename renames a key, or some of its values, starting on Version
+type serverPropertyRename struct {
+	Version string
+	Key     string
+	// NewKey is the key name from Version on (empty keeps the current name)
+	NewKey string
+	// Values maps old values to the new ones (optional)
+	Values map[string]string
+}
+
+// serverPropertyKeys is the server.properties keys table.
+// references from: https://minecraft.wiki/w/Server.properties#History
+var serverPropertyKeys = []ServerPropertyKey{
+	{Name: "accepts-transfers", Default: "false", Since: "1.20.5"},
+	{Name: "allow-flight", Default: "false"},
+	{Name: "allow-nether", Default: "true"},
+	{Name: "announce-player-achievements", Default: "true", Until: "1.12"},
+	{Name: "broadcast-console-to-ops", Default: "true"},
+	{Name: "broadcast-rcon-to-ops", Default: "true"},
+	{Name: "bug-report-link", Default: "", Since: "1.21"},
+	{Name: "difficulty", Default: "easy"},
+	{Name: "enable-command-block", Default: "false"},
+	{Name: "enable-jmx-monitoring", Default: "false", Since: "1.16"},
+	{Name: "enable-query", Default: "false"},
+	{Name: "enable-rcon", Default: "false"},
+	{Name: "enable-status", Default: "true", Since: "1.16"},
+	{Name: "enforce-secure-profile", Default: "true", Since: "1.19"},
+	{Name: "enforce-whitelist", Default: "false"},
+	{Name: "entity-broadcast-range-percentage", Default: "100", Since: "1.16"},
+	{Name: "force-gamemode", Default: "false"},
+	{Name: "function-permission-level", Default: "2", Since: "1.14.4"},
+	{Name: "gamemode", Default: "survival"},
+	{Name: "generate-structures", Default: "true"},
+	{Name: "generator-settings", Default: "{}"},
+	{Name: "hardcore", Default: "false"},
+	{Name: "hide-online-players", Default: "false", Since: "1.18"},
+	{Name: "initial-disabled-packs", Default: "", Since: "1.19.3"},
+	{Name: "initial-enabled-packs", Default: "vanilla", Since: "1.19.3"},
+	{Name: "level-name", Default: "world"},
+	{Name: "level-seed", Default: ""},
+	{Name: "level-type", Default: "minecraft:normal"},
+	{Name: "log-ips", Default: "true", Since: "1.20.2"},
+	{Name: "max-build-height", Default: "256", Until: "1.17"},
+	{Name: "max-chained-neighbor-updates", Default: "1000000", Since: "1.19"},
+	{Name: "max-players", Default: "20"},
+	{Name: "max-tick-time", Default: "60000"},
+	{Name: "max-world-size", Default: "29999984"},
+	{Name: "motd", Default: "A Minecraft Server"},
+	{Name: "network-compression-threshold", Default: "256"},
+	{Name: "online-mode", Default: "true"},
+	{Name: "op-permission-level", Default: "4"},
+	{Name: "pause-when-empty-seconds", Default: "60", Since: "1.21.2"},
+	{Name: "player-idle-timeout", Default: "0"},
+	{Name: "prevent-proxy-connections", Default: "false"},
+	{Name: "previews-chat", Default: "false", Since: "1.19", Until: "1.19.3"},
+	{Name: "pvp", Default: "true"},
+	{Name: "query.port", Default: "25565"},
+	{Name: "rate-limit", Default: "0", Since: "1.16"},
+	{Name: "rcon.password", Default: ""},
+	{Name: "rcon.port", Default: "25575"},
+	{Name: "region-file-compression", Default: "deflate", Since: "1.20.5"},
+	{Name: "require-resource-pack", Default: "false", Since: "1.17"},
+	{Name: "resource-pack", Default: ""},
+	{Name: "resource-pack-id", Default: "", Since: "1.20.3"},
+	{Name: "resource-pack-prompt", Default: "", Since: "1.17"},
+	{Name: "resource-pack-sha1", Default: ""},
+	{Name: "server-ip", Default: ""},
+	{Name: "server-port", Default: "25565"},
+	{Name: "simulation-distance", Default: "10", Since: "1.18"},
+	{Name: "snooper-enabled", Default: "true", Until: "1.18"},
+	{Name: "spawn-animals", Default: "true", Until: "1.21.2"},
+	{Name: "spawn-monsters", Default: "true"},
+	{Name: "spawn-npcs", Default: "true", Until: "1.21.2"},
+	{Name: "spawn-protection", Default: "16"},
+	{Name: "sync-chunk-writes", Default: "true", Since: "1.16"},
+	{Name: "text-filtering-config", Default: "", Since: "1.16.4"},
+	{Name: "use-native-transport", Default: "true"},
+	{Name: "view-distance", Default: "10"},
+	{Name: "white-list", Default: "false"},
+}
+
+var serverPropertyRenames = []serverPropertyRename{
+	{
+		Version: "1.19",
+		Key:     "level-type",
+		Values: map[string]string{
+			"default":     "minecraft:normal",
+			"flat":        "minecraft:flat",
+			"largebiomes": "minecraft:large_biomes",
+			"amplified":   "minecraft:amplified",
+		},
+	},
+}
+
+// ServerPropertyKeysFor returns the server.properties keys supported by version
+func ServerPropertyKeysFor(version string) []ServerPropertyKey {
+	var keys []ServerPropertyKey
+	for _, k := range serverPropertyKeys {
+		if k.supportedBy(version) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// IsServerPropertySupported returns false when version doesn't
+// know about key. Keys not mapped are always supported.
+func IsServerPropertySupported(key, version string) bool {
+	for _, k := range serverPropertyKeys {
+		if k.Name == key {
+			return k.supportedBy(version)
+		}
+	}
+	return true
+}
+
+// DefaultServerPropertiesDocument returns the server.properties
+// a fresh server of version would write
+func DefaultServerPropertiesDocument(version string) *model.PropertiesDocument {
+	keys := ServerPropertyKeysFor(version)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	doc := model.NewPropertiesDocument()
+	for _, k := range keys {
+		doc.Set(k.Name, defaultValueFor(k, version))
+	}
+	return doc
+}
+
+// ServerPropertiesDocument renders props as a server.properties document
+// for version, dropping the keys this version doesn't support and
+// writing the values as this version knows them
+func ServerPropertiesDocument(version string, props *model.ServerProperties) (*model.PropertiesDocument, error) {
+	doc := DefaultServerPropertiesDocument(version)
+	if err := doc.SetServerProperties(props); err != nil {
+		return nil, fmt.Errorf("setting server properties values: %w", err)
+	}
+	for _, k := range doc.Keys() {
+		if !IsServerPropertySupported(k, version) {
+			doc.Delete(k)
+			continue
+		}
+		v, _ := doc.Get(k)
+		if legacy := valueFor(k, v, version); legacy != v {
+			doc.Set(k, legacy)
+		}
+	}
+	return doc, nil
+}
+
+// MigrateServerProperties updates doc from version 'from' to version 'to',
+// adding, renaming and removing keys as required. Returns a description
+// of every change made.
+func MigrateServerProperties(doc *model.PropertiesDocument, from, to string) []string {
+	var changes []string
+
+	for _, r := range serverPropertyRenames {
+		if !(CompareVersions(r.Version, from) > 0 && CompareVersions(r.Version, to) <= 0) {
+			continue
+		}
+		v, ok := doc.Get(r.Key)
+		if !ok {
+			continue
+		}
+		if newValue, ok := r.Values[strings.ToLower(v)]; ok {
+			doc.Set(r.Key, newValue)
+			changes = append(changes, fmt.Sprintf("changed %s value from '%s' to '%s'", r.Key, v, newValue))
+			v = newValue
+		}
+		if r.NewKey != "" {
+			doc.Delete(r.Key)
+			doc.Set(r.NewKey, v)
+			changes = append(changes, fmt.Sprintf("renamed %s to %s", r.Key, r.NewKey))
+		}
+	}
+
+	for _, k := range serverPropertyKeys {
+		supported := k.supportedBy(to)
+		if !supported && doc.Has(k.Name) {
+			doc.Delete(k.Name)
+			changes = append(changes, fmt.Sprintf("removed %s", k.Name))
+			continue
+		}
+		if supported && !doc.Has(k.Name) {
+			v := defaultValueFor(k, to)
+			doc.Set(k.Name, v)
+			changes = append(changes, fmt.Sprintf("added %s=%s", k.Name, v))
+		}
+	}
+
+	return changes
+}
+
+func (k ServerPropertyKey) supportedBy(version string) bool {
+	if k.Since != "" && CompareVersions(version, k.Since) < 0 {
+		return false
+	}
+	if k.Until != "" && CompareVersions(version, k.Until) >= 0 {
+		return false
+	}
+	return true
+}
+
+// defaultValueFor returns k default value as version knows it
+func defaultValueFor(k ServerPropertyKey, version string) string {
+	return valueFor(k.Name, k.Default, version)
+}
+
+// valueFor reverts the key value renames introduced after version.
+// Values are compared without the (optional) 'minecraft:' namespace.
+func valueFor(key, v, version string) string {
+	for i := len(serverPropertyRenames) - 1; i >= 0; i-- {
+		r := serverPropertyRenames[i]
+		if r.Key != key || CompareVersions(r.Version, version) <= 0 {
+			continue
+		}
+		for oldValue, newValue := range r.Values {
+			if strings.TrimPrefix(newValue, "minecraft:") == strings.TrimPrefix(strings.ToLower(v), "minecraft:") {
+				v = oldValue
+				break
+			}
+		}
+	}
+	return v
+}
+
+// CompareVersions compares two release versions (like "1.21.3"),
+// returning -1, 0 or 1. Pre-releases and release candidates are compared
+// as their release and versions that can't be parsed (snapshots, for
+// example) are considered newer than any release.
+func CompareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return strings.Compare(a, b)
+	case !okA:
+		return 1
+	case !okB:
+		return -1
+	}
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v, _, _ = strings.Cut(v, "-")
+	v, _, _ = strings.Cut(v, " ")
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, CompareVersions("1.21", "1.21.0"))
+	assert.Equal(t, -1, CompareVersions("1.20.4", "1.20.5"))
+	assert.Equal(t, 1, CompareVersions("1.21.10", "1.21.9"))
+	assert.Equal(t, 0, CompareVersions("1.21.2-rc1", "1.21.2"))
+	assert.Equal(t, 1, CompareVersions("24w14a", "1.21.4"))
+	assert.Equal(t, -1, CompareVersions("1.21.4", "24w14a"))
+}
+
+func TestDefaultServerPropertiesDocument(t *testing.T) {
+	t.Run("given a recent version should render new keys without the removed ones", func(t *testing.T) {
+		doc := DefaultServerPropertiesDocument("1.21.4")
+
+		for _, k := range []string{"accepts-transfers", "log-ips", "pause-when-empty-seconds", "region-file-compression", "bug-report-link"} {
+			assert.True(t, doc.Has(k), "should contain %s", k)
+		}
+		for _, k := range []string{"snooper-enabled", "previews-chat", "spawn-animals", "spawn-npcs"} {
+			assert.False(t, doc.Has(k), "should not contain %s", k)
+		}
+
+		v, _ := doc.Get("level-type")
+		assert.Equal(t, "minecraft:normal", v)
+	})
+
+	t.Run("given an old version should render legacy keys and values", func(t *testing.T) {
+		doc := DefaultServerPropertiesDocument("1.16.5")
+
+		assert.True(t, doc.Has("snooper-enabled"))
+		assert.True(t, doc.Has("spawn-animals"))
+		assert.False(t, doc.Has("log-ips"))
+		assert.False(t, doc.Has("simulation-distance"))
+
+		v, _ := doc.Get("level-type")
+		assert.Equal(t, "default", v)
+	})
+}
+
+func TestServerPropertiesDocument(t *testing.T) {
+	p, err := DefaultServerProperties()
+	assert.Nil(t, err)
+	p.Motd = "My server"
+
+	doc, err := ServerPropertiesDocument("1.21.4", p)
+	assert.Nil(t, err)
+
+	v, _ := doc.Get("motd")
+	assert.Equal(t, "My server", v)
+	assert.True(t, doc.Has("accepts-transfers"))
+	assert.False(t, doc.Has("snooper-enabled"))
+	assert.False(t, doc.Has("previews-chat"))
+
+	t.Run("given an old version should render legacy values", func(t *testing.T) {
+		p, err := DefaultServerProperties()
+		assert.Nil(t, err)
+		p.LevelType = model.LevelTypeLargeBiomes
+
+		doc, err := ServerPropertiesDocument("1.16.5", p)
+		assert.Nil(t, err)
+		v, _ := doc.Get("level-type")
+		assert.Equal(t, "largebiomes", v)
+
+		p.LevelType = "normal"
+		doc, err = ServerPropertiesDocument("1.16.5", p)
+		assert.Nil(t, err)
+		v, _ = doc.Get("level-type")
+		assert.Equal(t, "default", v)
+	})
+}
+
+func TestMigrateServerProperties(t *testing.T) {
+	doc, err := model.ParsePropertiesDocument(strings.NewReader(`#Minecraft server properties
+level-type=DEFAULT
+motd=Old server
+snooper-enabled=true
+spawn-animals=true
+custom-key=kept
+`))
+	assert.Nil(t, err)
+
+	changes := MigrateServerProperties(doc, "1.17.1", "1.21.4")
+	assert.NotEmpty(t, changes)
+
+	v, _ := doc.Get("level-type")
+	assert.Equal(t, "minecraft:normal", v)
+	v, _ = doc.Get("motd")
+	assert.Equal(t, "Old server", v)
+	v, _ = doc.Get("custom-key")
+	assert.Equal(t, "kept", v)
+
+	assert.False(t, doc.Has("snooper-enabled"))
+	assert.False(t, doc.Has("spawn-animals"))
+	assert.True(t, doc.Has("simulation-distance"))
+	assert.True(t, doc.Has("pause-when-empty-seconds"))
+	assert.True(t, strings.HasPrefix(doc.String(), "#Minecraft server properties\n"))
+}
@@ -0,0 +1,100 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/rcon"
+	"net"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	consoleTimeout = 10 * time.Second
+)
+
+var (
+	ErrServerNotRunning = errors.New("server is not running")
+	ErrRconDisabled     = errors.New("rcon is disabled for this instance")
+)
+
+// Console sends commands to a running server
+type Console interface {
+	// Execute runs a command on server console returning its output
+	Execute(ctx context.Context, cmd string) (string, error)
+	// Close closes server connection
+	Close() error
+}
+
+// ConsoleFactory opens a console for the instance on instancePath.
+// It must return ErrServerNotRunning when the server isn't running.
+type ConsoleFactory func(ctx context.Context, instancePath string) (Console, error)
+
+// RconConsole opens a RCON connection to the instance server,
+// using the port and password from its server.properties
+func RconConsole(ctx context.Context, instancePath string) (Console, error) {
+	if !IsServerRunning(instancePath) {
+		return nil, ErrServerNotRunning
+	}
+	props, err := model.LoadFromFile(filepath.Join(instancePath, ServerPropertiesFileName))
+	if err != nil {
+		return nil, fmt.Errorf("reading server properties: %w", err)
+	}
+	if !props.EnableRcon {
+		return nil, ErrRconDisabled
+	}
+	host := props.ServerIP
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	c, err := rcon.Dial(ctx, net.JoinHostPort(host, strconv.Itoa(props.RconPort)), props.RconPassword, consoleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to server console: %w", err)
+	}
+	return c, nil
+}
+
+// runConsoleCommands runs cmds on the instance console when its server
+// is running, returning the commands output. It's a no-op when the server
+// is stopped.
+func runConsoleCommands(ctx context.Context, factory ConsoleFactory, instancePath string, cmds ...string) ([]string, error) {
+	c, err := factory(ctx, instancePath)
+	if errors.Is(err, ErrServerNotRunning) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+
+	var out []string
+	for _, cmd := range cmds {
+		o, err := c.Execute(ctx, cmd)
+		if err != nil {
+			return out, fmt.Errorf("executing '%s': %w", cmd, err)
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// notifyServer applies changes made to the instance files on its running
+// server. When RCON is disabled the server only gets them after a restart.
+func notifyServer(ctx context.Context, factory ConsoleFactory, instancePath string, cmds ...string) error {
+	_, err := runConsoleCommands(ctx, factory, instancePath, cmds...)
+	if errors.Is(err, ErrRconDisabled) {
+		logger.GetLogger().With("instance_path", instancePath, "commands", cmds).
+			WarnContext(ctx, "Server is running without RCON, changes will be applied after a restart")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("notifying running server: %w", err)
+	}
+	return nil
+}
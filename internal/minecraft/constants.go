@@ -1,6 +1,6 @@
 package minecraft
 
 const (
-	VersionsURL   = "https://launchermeta.mojang.com/mc/game/version_manifest.json"
+	VersionsURL   = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
 	LatestVersion = "latest"
 )
@@ -0,0 +1,38 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"github.com/eldius/mineserver-manager/internal/hooks"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"path/filepath"
+)
+
+// instanceHookData returns the hooks data of an instance, with the
+// server flavour and version from its versions file (when there's one)
+func instanceHookData(instancePath string) hooks.Data {
+	if abs, err := utils.AbsolutePath(instancePath); err == nil {
+		instancePath = abs
+	}
+	data := hooks.Data{
+		InstancePath: instancePath,
+		InstanceName: filepath.Base(instancePath),
+	}
+	if v, err := readVersionFile(instancePath); err == nil {
+		data.MineFlavour = string(v.MineFlavour)
+		data.MineVersion = v.MineVersion
+	}
+	return data
+}
+
+// withHooks runs fn between the pre and post event hooks. Post hooks
+// also run when fn fails (getting its error on Data.Err), so they can
+// undo what pre hooks did. fn can add its results to data.
+func withHooks(ctx context.Context, r *hooks.Runner, pre, post hooks.Event, data hooks.Data, fn func(data *hooks.Data) error) error {
+	if err := r.Run(ctx, pre, data); err != nil {
+		return err
+	}
+	err := fn(&data)
+	data.Err = err
+	return errors.Join(err, r.Run(ctx, post, data))
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	cfg "github.com/eldius/mineserver-manager/internal/config"
+	"github.com/eldius/mineserver-manager/internal/hooks"
 	"github.com/eldius/mineserver-manager/internal/installer"
 	"github.com/eldius/mineserver-manager/internal/logger"
 	"github.com/eldius/mineserver-manager/internal/minecraft/config"
@@ -22,6 +23,8 @@ import (
 
 var (
 	ErrChecksumValidationFailed = utils.ErrChecksumValidationFailed
+	ErrServerRunning            = errors.New("server is running")
+	ErrFlavourMismatch          = errors.New("instance flavour doesn't match the installer flavour")
 )
 
 type InstallServiceConfig struct {
@@ -34,13 +37,18 @@ type InstallServiceConfig struct {
 	RuntimeManager installer.RuntimeManager
 	Provisioner    provisioner.Provisioner
 	Flavor         installer.ServerFlavor
+	MojangClient   mojang.Client
 	Repository     repository.Repository
+	Hooks          *hooks.Runner
 }
 
 type InstallServiceOpt func(config *InstallServiceConfig)
 
 type Installer interface {
+	// Install installs a new server instance
 	Install(ctx context.Context, configs ...config.InstanceOpt) error
+	// Upgrade upgrades an installed instance to version
+	Upgrade(ctx context.Context, instancePath, version string) error
 }
 
 type vanillaInstaller struct {
@@ -70,14 +78,11 @@ func NewInstallService(configs ...InstallServiceOpt) Installer {
 	if svcCfg.Provisioner == nil {
 		svcCfg.Provisioner = provisioner.NewProvisioner()
 	}
-	if svcCfg.Flavor == nil {
-		svcCfg.Flavor = installer.NewVanillaFlavor(mojang.NewClient(mojang.WithTimeout(svcCfg.Timeout)))
+	if svcCfg.MojangClient == nil {
+		svcCfg.MojangClient = mojang.NewClient(mojang.WithTimeout(svcCfg.Timeout))
 	}
-	if svcCfg.Repository == nil {
-		repo, err := repository.NewStormRepository(cfg.GetAppHomePath())
-		if err == nil {
-			svcCfg.Repository = repo
-		}
+	if svcCfg.Flavor == nil {
+		svcCfg.Flavor = installer.NewVanillaFlavor(svcCfg.MojangClient)
 	}
 
 	return &vanillaInstaller{
@@ -90,10 +95,21 @@ func NewInstallService(configs ...InstallServiceOpt) Installer {
 	}
 }
 
-// Install installs selected version
+// Install installs selected version, between the install hooks
 func (i *vanillaInstaller) Install(ctx context.Context, configs ...config.InstanceOpt) error {
 	opts := config.NewInstanceOpts(configs...)
+	data := hooks.Data{
+		InstancePath: opts.AbsoluteDestPath(),
+		InstanceName: filepath.Base(opts.AbsoluteDestPath()),
+		MineFlavour:  string(opts.Flavor),
+		MineVersion:  opts.VersionName,
+	}
+	return withHooks(ctx, i.cfg.Hooks, hooks.PreInstall, hooks.PostInstall, data, func(*hooks.Data) error {
+		return i.install(ctx, opts)
+	})
+}
 
+func (i *vanillaInstaller) install(ctx context.Context, opts *config.InstanceOpts) error {
 	log := logger.GetLogger().With("action", "install_server", "version_name", opts.VersionName)
 
 	if err := os.MkdirAll(opts.AbsoluteDestPath(), os.ModePerm); err != nil {
@@ -111,7 +127,12 @@ func (i *vanillaInstaller) Install(ctx context.Context, configs ...config.Instan
 		return fmt.Errorf("getting version info for %s: %w", opts.VersionName, err)
 	}
 
-	if err := i.p.CreateServerProperties(opts.AbsoluteDestPath(), opts.SrvProps); err != nil {
+	props, err := config.ServerPropertiesDocument(info.Version, opts.SrvProps)
+	if err != nil {
+		return fmt.Errorf("rendering server properties for %s: %w", info.Version, err)
+	}
+
+	if err := i.p.CreateServerProperties(opts.AbsoluteDestPath(), props); err != nil {
 		return fmt.Errorf("creating server properties file: %w", err)
 	}
 
@@ -150,7 +171,7 @@ func (i *vanillaInstaller) Install(ctx context.Context, configs ...config.Instan
 		return fmt.Errorf("creating eula.txt file: %w", err)
 	}
 
-	if err := i.createVersionFile(ctx, opts.AbsoluteDestPath(), *opts, info); err != nil {
+	if err := i.createVersionFile(ctx, opts.AbsoluteDestPath(), info); err != nil {
 		return fmt.Errorf("creating version file: %w", err)
 	}
 
@@ -161,6 +182,10 @@ func (i *vanillaInstaller) Install(ctx context.Context, configs ...config.Instan
 		return fmt.Errorf("creating whitelist file: %w", err)
 	}
 
+	if err := i.createOpsFile(ctx, *opts); err != nil {
+		return fmt.Errorf("creating ops file: %w", err)
+	}
+
 	if i.repo != nil {
 		inst := model.NewInstance(filepath.Base(opts.AbsoluteDestPath()), opts.AbsoluteDestPath(), *opts.SrvProps)
 		if err := i.repo.SaveInstance(ctx, inst); err != nil {
@@ -171,34 +196,132 @@ func (i *vanillaInstaller) Install(ctx context.Context, configs ...config.Instan
 	return nil
 }
 
-func (i *vanillaInstaller) createWhitelistFile(_ context.Context, opts config.InstanceOpts) error {
-	if !opts.HasWhitelist() {
+// Upgrade upgrades an installed instance to version, replacing the server
+// file, the Java runtime (when required) and migrating server.properties,
+// between the upgrade hooks
+func (i *vanillaInstaller) Upgrade(ctx context.Context, instancePath, version string) error {
+	data := instanceHookData(instancePath)
+	return withHooks(ctx, i.cfg.Hooks, hooks.PreUpgrade, hooks.PostUpgrade, data, func(data *hooks.Data) error {
+		return i.upgrade(ctx, instancePath, version, data)
+	})
+}
+
+func (i *vanillaInstaller) upgrade(ctx context.Context, instancePath, version string, data *hooks.Data) error {
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return fmt.Errorf("parsing instance path: %w", err)
+	}
+
+	log := logger.GetLogger().With("action", "upgrade_server", "instance_path", instancePath, "version_name", version)
+
+	if IsServerRunning(instancePath) {
+		return ErrServerRunning
+	}
+
+	current, err := readVersionFile(instancePath)
+	if err != nil {
+		return fmt.Errorf("reading current version info: %w", err)
+	}
+	if current.MineFlavour != "" && current.MineFlavour != i.f.Name() {
+		return fmt.Errorf("%w: instance is %s, upgrading to %s", ErrFlavourMismatch, current.MineFlavour, i.f.Name())
+	}
+
+	info, err := i.f.GetVersionInfo(ctx, version)
+	if err != nil {
+		return fmt.Errorf("getting version info for %s: %w", version, err)
+	}
+
+	if current.MineVersion == info.Version {
+		log.InfoContext(ctx, "Instance already on requested version")
 		return nil
 	}
 
-	f, err := os.Create(filepath.Join(opts.Dest, "whitelist.json"))
+	log.With("current_version", current.MineVersion).InfoContext(ctx, "Upgrading server")
+
+	// the new files are downloaded next to the instance, and only
+	// replace the current ones when every download succeeds
+	staging, err := os.MkdirTemp(filepath.Dir(instancePath), "."+filepath.Base(instancePath)+".upgrade-*")
 	if err != nil {
-		return fmt.Errorf("creating whitelist file: %w", err)
+		return fmt.Errorf("creating upgrade staging folder: %w", err)
 	}
 	defer func() {
-		_ = f.Close()
+		_ = os.RemoveAll(staging)
 	}()
 
-	// Direct use of mojang client for whitelist for now
-	c := mojang.NewClient(mojang.WithTimeout(i.cfg.Timeout))
-	usrs, err := c.GetUsersInfo(opts.WhitelistUsernames...)
+	sf, err := i.d.DownloadServer(ctx, info.DownloadURL, info.SHA1, staging)
 	if err != nil {
-		return fmt.Errorf("getting users info: %w", err)
+		return fmt.Errorf("downloading server file: %w", err)
 	}
+	log.With("server_file", sf).DebugContext(ctx, "Dowloaded server file")
 
-	if err := json.NewEncoder(f).Encode(usrs); err != nil {
+	javaPath := filepath.Join(instancePath, "java")
+	previousJava := filepath.Join(staging, "java.previous")
+	upgradeJava := current.JavaVersion != info.JavaVersion
+	if upgradeJava {
+		if _, err := i.r.InstallJava(ctx, filepath.Join(staging, "java"), info.JavaVersion, runtime.GOARCH, runtime.GOOS); err != nil {
+			return fmt.Errorf("installing jdk: %w", err)
+		}
+		if err := replaceFolder(filepath.Join(staging, "java"), javaPath, previousJava); err != nil {
+			return fmt.Errorf("replacing jdk: %w", err)
+		}
+	}
+	if err := os.Rename(sf, filepath.Join(instancePath, filepath.Base(sf))); err != nil {
+		if upgradeJava {
+			_ = os.RemoveAll(javaPath)
+			_ = os.Rename(previousJava, javaPath)
+		}
+		return fmt.Errorf("replacing server file: %w", err)
+	}
+
+	propsFile := filepath.Join(instancePath, ServerPropertiesFileName)
+	props, err := model.LoadOrNewPropertiesDocument(propsFile)
+	if err != nil {
+		return fmt.Errorf("reading server properties file: %w", err)
+	}
+	for _, c := range config.MigrateServerProperties(props, current.MineVersion, info.Version) {
+		log.With("change", c).InfoContext(ctx, "Migrated server.properties")
+	}
+	if err := props.SaveTo(propsFile, 0644); err != nil {
+		return fmt.Errorf("writing server properties file: %w", err)
+	}
+
+	// written last, so a failed upgrade keeps reporting the previous version
+	if err := i.createVersionFile(ctx, instancePath, info); err != nil {
+		return fmt.Errorf("creating version file: %w", err)
+	}
+	// post upgrade hooks get the new version
+	data.MineVersion = info.Version
+
+	return nil
+}
+
+func (i *vanillaInstaller) createWhitelistFile(ctx context.Context, opts config.InstanceOpts) error {
+	if !opts.HasWhitelist() {
+		return nil
+	}
+
+	s := NewWhitelistService(WithMojangClient(i.cfg.MojangClient))
+	if _, err := s.Sync(ctx, opts.AbsoluteDestPath(), opts.WhitelistUsernames...); err != nil {
 		return fmt.Errorf("writing whitelist file: %w", err)
 	}
 
 	return nil
 }
 
-func (i *vanillaInstaller) createVersionFile(_ context.Context, destFolder string, opts config.InstanceOpts, info *installer.FlavorVersionInfo) error {
+func (i *vanillaInstaller) createOpsFile(ctx context.Context, opts config.InstanceOpts) error {
+	if !opts.HasOperators() {
+		return nil
+	}
+
+	s := NewOpsService(WithMojangClient(i.cfg.MojangClient))
+	if _, err := s.Add(ctx, opts.AbsoluteDestPath(), opts.Operators...); err != nil {
+		return fmt.Errorf("writing ops file: %w", err)
+	}
+
+	return nil
+}
+
+func (i *vanillaInstaller) createVersionFile(_ context.Context, destFolder string, info *installer.FlavorVersionInfo) error {
 
 	f, err := os.Create(filepath.Join(destFolder, cfg.VersionsFileName))
 	if err != nil {
@@ -258,14 +381,61 @@ func WithFlavor(f installer.ServerFlavor) InstallServiceOpt {
 	}
 }
 
+// WithInstallMojangClient defines the Mojang API client used to find
+// server versions (for the default flavor) and to resolve the
+// whitelisted users and operators
+func WithInstallMojangClient(c mojang.Client) InstallServiceOpt {
+	return func(cfg *InstallServiceConfig) {
+		cfg.MojangClient = c
+	}
+}
+
+// WithRepository defines the instances database, where installed
+// instances are registered (they aren't registered without one)
 func WithRepository(r repository.Repository) InstallServiceOpt {
 	return func(cfg *InstallServiceConfig) {
 		cfg.Repository = r
 	}
 }
 
+// WithInstallHooks defines the hooks run around installs and upgrades
+func WithInstallHooks(r *hooks.Runner) InstallServiceOpt {
+	return func(cfg *InstallServiceConfig) {
+		cfg.Hooks = r
+	}
+}
+
 func WithInstanceOpts(opts ...config.InstanceOpt) InstallServiceOpt {
 	return func(cfg *InstallServiceConfig) {
 		cfg.Instance = config.NewInstanceOpts(opts...)
 	}
 }
+
+// replaceFolder moves src to dest, moving the current dest to previous
+// first and back again when src can't be moved
+func replaceFolder(src, dest, previous string) error {
+	if err := os.Rename(dest, previous); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("moving current folder: %w", err)
+	}
+	if err := os.Rename(src, dest); err != nil {
+		_ = os.Rename(previous, dest)
+		return fmt.Errorf("moving new folder: %w", err)
+	}
+	return nil
+}
+
+func readVersionFile(instancePath string) (*model.VersionsInfo, error) {
+	f, err := os.Open(filepath.Join(instancePath, cfg.VersionsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s file: %w", cfg.VersionsFileName, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var info model.VersionsInfo
+	if err := json.NewDecoder(f).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decoding %s file: %w", cfg.VersionsFileName, err)
+	}
+	return &info, nil
+}
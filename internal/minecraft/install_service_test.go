@@ -2,15 +2,23 @@ package minecraft
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	cfg "github.com/eldius/mineserver-manager/internal/config"
 	"github.com/eldius/mineserver-manager/internal/installer"
 	"github.com/eldius/mineserver-manager/internal/minecraft/config"
 	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
 	"github.com/eldius/mineserver-manager/internal/provisioner"
+	"github.com/eldius/mineserver-manager/internal/repository"
 	"github.com/h2non/gock"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -21,6 +29,10 @@ type mockDownloader struct {
 
 func (m *mockDownloader) DownloadServer(ctx context.Context, url, sha1, dest string) (string, error) {
 	args := m.Called(ctx, url, sha1, dest)
+	// a function result computes the file path from dest
+	if f, ok := args.Get(0).(func(dest string) string); ok {
+		return f(dest), args.Error(1)
+	}
 	return args.String(0), args.Error(1)
 }
 
@@ -30,6 +42,9 @@ type mockRuntimeManager struct {
 
 func (m *mockRuntimeManager) InstallJava(ctx context.Context, dest string, version int, arch, osName string) (string, error) {
 	args := m.Called(ctx, dest, version, arch, osName)
+	if f, ok := args.Get(0).(func(dest string) string); ok {
+		return f(dest), args.Error(1)
+	}
 	return args.String(0), args.Error(1)
 }
 
@@ -37,7 +52,7 @@ type mockProvisioner struct {
 	mock.Mock
 }
 
-func (m *mockProvisioner) CreateServerProperties(dest string, props *model.ServerProperties) error {
+func (m *mockProvisioner) CreateServerProperties(dest string, props *model.PropertiesDocument) error {
 	args := m.Called(dest, props)
 	return args.Error(0)
 }
@@ -97,16 +112,41 @@ func (m *mockRepository) GetInstance(ctx context.Context, id string) (*model.Ins
 	return args.Get(0).(*model.Instance), args.Error(1)
 }
 
+func (m *mockRepository) GetInstanceByName(ctx context.Context, name string) (*model.Instance, error) {
+	args := m.Called(ctx, name)
+	return args.Get(0).(*model.Instance), args.Error(1)
+}
+
 func (m *mockRepository) ListInstances(ctx context.Context) ([]model.Instance, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]model.Instance), args.Error(1)
 }
 
+func (m *mockRepository) GetInstanceByPath(ctx context.Context, path string) (*model.Instance, error) {
+	args := m.Called(ctx, path)
+	return args.Get(0).(*model.Instance), args.Error(1)
+}
+
 func (m *mockRepository) DeleteInstance(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *mockRepository) SaveBackup(ctx context.Context, b *model.BackupRecord) error {
+	args := m.Called(ctx, b)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ListBackups(ctx context.Context) ([]model.BackupRecord, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.BackupRecord), args.Error(1)
+}
+
+func (m *mockRepository) DeleteBackup(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func (m *mockRepository) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -167,4 +207,252 @@ func TestInstaller_Install(t *testing.T) {
 		mf.AssertExpectations(t)
 		mrepo.AssertExpectations(t)
 	})
+
+	t.Run("given the instances database should register the instance on app home", func(t *testing.T) {
+		ctx := context.Background()
+		home := t.TempDir()
+		viper.Set(cfg.AppHomePathPropKey, home)
+		t.Cleanup(func() {
+			viper.Set(cfg.AppHomePathPropKey, "")
+		})
+		dest := filepath.Join(t.TempDir(), "my-server")
+
+		md := new(mockDownloader)
+		mr := new(mockRuntimeManager)
+		mp := new(mockProvisioner)
+		mf := new(mockFlavor)
+
+		info := &installer.FlavorVersionInfo{
+			Version:     "1.20",
+			DownloadURL: "https://example.com/server.jar",
+			SHA1:        "abc",
+			JavaVersion: 17,
+		}
+		mf.On("GetVersionInfo", mock.Anything, "1.20").Return(info, nil)
+		mf.On("Name").Return(model.MineFlavourVanilla)
+		md.On("DownloadServer", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(filepath.Join(dest, "server.jar"), nil)
+		mr.On("InstallJava", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(filepath.Join(dest, "java", "jdk"), nil)
+		mp.On("CreateServerProperties", mock.Anything, mock.Anything).Return(nil)
+		mp.On("CreateStartScript", mock.Anything, mock.Anything).Return(nil)
+		mp.On("CreateStopScript", mock.Anything).Return(nil)
+		mp.On("CreateEula", mock.Anything, mock.Anything).Return(nil)
+
+		dbPath, err := cfg.GetDatabasePath()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(home, "mineserver.db"), dbPath)
+		repo, err := repository.NewStormRepository(dbPath)
+		require.NoError(t, err)
+
+		s := NewInstallService(
+			WithDownloader(md),
+			WithRuntimeManager(mr),
+			WithProvisioner(mp),
+			WithFlavor(mf),
+			WithRepository(repo),
+		)
+		err = s.Install(ctx, config.WithVersion("1.20"), config.ToDestinationFolder(dest))
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		repo, err = repository.NewStormRepository(filepath.Join(home, "mineserver.db"))
+		require.NoError(t, err)
+		defer func() {
+			_ = repo.Close()
+		}()
+		inst, err := repo.GetInstanceByName(ctx, "my-server")
+		require.NoError(t, err)
+		assert.Equal(t, dest, inst.Path)
+	})
+
+	t.Run("given whitelisted users should resolve them with the informed mojang client", func(t *testing.T) {
+		dest := t.TempDir()
+
+		md := new(mockDownloader)
+		mr := new(mockRuntimeManager)
+		mp := new(mockProvisioner)
+		mf := new(mockFlavor)
+		mc := new(mockMojangClient)
+
+		mf.On("GetVersionInfo", mock.Anything, "1.20").Return(&installer.FlavorVersionInfo{Version: "1.20", JavaVersion: 17}, nil)
+		mf.On("Name").Return(model.MineFlavourVanilla)
+		md.On("DownloadServer", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(filepath.Join(dest, "server.jar"), nil)
+		mr.On("InstallJava", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(filepath.Join(dest, "java", "jdk"), nil)
+		mp.On("CreateServerProperties", mock.Anything, mock.Anything).Return(nil)
+		mp.On("CreateStartScript", mock.Anything, mock.Anything).Return(nil)
+		mp.On("CreateStopScript", mock.Anything).Return(nil)
+		mp.On("CreateEula", mock.Anything, mock.Anything).Return(nil)
+		mc.On("GetUsersInfo", mock.Anything, []string{"Eldius"}).Return(mojang.UserIDResponse{
+			{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "Eldius"},
+		}, nil)
+
+		s := NewInstallService(
+			WithDownloader(md),
+			WithRuntimeManager(mr),
+			WithProvisioner(mp),
+			WithFlavor(mf),
+			WithInstallMojangClient(mc),
+		)
+		err := s.Install(context.Background(),
+			config.WithVersion("1.20"),
+			config.ToDestinationFolder(dest),
+			config.WithWhitelistedUsers([]string{"Eldius"}),
+		)
+		require.NoError(t, err)
+
+		mc.AssertExpectations(t)
+		b, err := os.ReadFile(filepath.Join(dest, WhitelistFileName))
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a")
+	})
+}
+
+func TestInstaller_Upgrade(t *testing.T) {
+	t.Run("should upgrade server files and migrate server.properties", func(t *testing.T) {
+		ctx := context.Background()
+		dest := filepath.Join(t.TempDir(), "my-server")
+		assert.NoError(t, os.MkdirAll(dest, os.ModePerm))
+		writeUpgradeInstance(t, dest)
+
+		md := new(mockDownloader)
+		mr := new(mockRuntimeManager)
+		mf := new(mockFlavor)
+
+		info := &installer.FlavorVersionInfo{
+			Version:     "1.21.4",
+			DownloadURL: "https://example.com/server.jar",
+			SHA1:        "abc",
+			JavaVersion: 21,
+		}
+
+		mf.On("GetVersionInfo", mock.Anything, "1.21.4").Return(info, nil)
+		mf.On("Name").Return(model.MineFlavourVanilla)
+		md.On("DownloadServer", mock.Anything, info.DownloadURL, info.SHA1, mock.Anything).Return(fakeDownload(t, "server.jar", "new server"), nil)
+		mr.On("InstallJava", mock.Anything, mock.Anything, 21, mock.Anything, mock.Anything).Return(fakeDownload(t, "jdk/release", "21"), nil)
+
+		s := NewInstallService(
+			WithDownloader(md),
+			WithRuntimeManager(mr),
+			WithProvisioner(new(mockProvisioner)),
+			WithFlavor(mf),
+			WithRepository(new(mockRepository)),
+		)
+
+		assert.NoError(t, s.Upgrade(ctx, dest, "1.21.4"))
+
+		md.AssertExpectations(t)
+		mr.AssertExpectations(t)
+
+		props, err := model.LoadPropertiesDocument(filepath.Join(dest, "server.properties"))
+		assert.NoError(t, err)
+		v, _ := props.Get("motd")
+		assert.Equal(t, "My server", v)
+		assert.False(t, props.Has("snooper-enabled"))
+		assert.True(t, props.Has("pause-when-empty-seconds"))
+
+		current, err := readVersionFile(dest)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.21.4", current.MineVersion)
+		assert.Equal(t, 21, current.JavaVersion)
+
+		assertFileContent(t, filepath.Join(dest, "server.jar"), "new server")
+		assertFileContent(t, filepath.Join(dest, "java", "jdk", "release"), "21")
+		entries, err := os.ReadDir(filepath.Dir(dest))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1, "should remove the staging folder")
+	})
+
+	t.Run("given a failed jdk download should keep the current server files", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "my-server")
+		assert.NoError(t, os.MkdirAll(dest, os.ModePerm))
+		writeUpgradeInstance(t, dest)
+
+		md := new(mockDownloader)
+		mr := new(mockRuntimeManager)
+		mf := new(mockFlavor)
+
+		info := &installer.FlavorVersionInfo{
+			Version:     "1.21.4",
+			DownloadURL: "https://example.com/server.jar",
+			SHA1:        "abc",
+			JavaVersion: 21,
+		}
+		mf.On("GetVersionInfo", mock.Anything, "1.21.4").Return(info, nil)
+		mf.On("Name").Return(model.MineFlavourVanilla)
+		md.On("DownloadServer", mock.Anything, info.DownloadURL, info.SHA1, mock.Anything).Return(fakeDownload(t, "server.jar", "new server"), nil)
+		mr.On("InstallJava", mock.Anything, mock.Anything, 21, mock.Anything, mock.Anything).Return("", errors.New("download failed"))
+
+		s := NewInstallService(
+			WithDownloader(md),
+			WithRuntimeManager(mr),
+			WithProvisioner(new(mockProvisioner)),
+			WithFlavor(mf),
+			WithRepository(new(mockRepository)),
+		)
+
+		assert.Error(t, s.Upgrade(context.Background(), dest, "1.21.4"))
+
+		assertFileContent(t, filepath.Join(dest, "server.jar"), "old server")
+		assertFileContent(t, filepath.Join(dest, "java", "jdk", "release"), "16")
+		current, err := readVersionFile(dest)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.17.1", current.MineVersion)
+		entries, err := os.ReadDir(filepath.Dir(dest))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1, "should remove the staging folder")
+	})
+
+	t.Run("given an instance of another flavour should fail", func(t *testing.T) {
+		dest := t.TempDir()
+		versions, _ := json.Marshal(model.VersionsInfo{MineVersion: "1.21.1", JavaVersion: 21, MineFlavour: model.MineFlavourPurpur})
+		assert.NoError(t, os.WriteFile(filepath.Join(dest, cfg.VersionsFileName), versions, 0644))
+
+		md := new(mockDownloader)
+		mf := new(mockFlavor)
+		mf.On("Name").Return(model.MineFlavourVanilla)
+
+		s := NewInstallService(
+			WithDownloader(md),
+			WithRuntimeManager(new(mockRuntimeManager)),
+			WithProvisioner(new(mockProvisioner)),
+			WithFlavor(mf),
+			WithRepository(new(mockRepository)),
+		)
+
+		assert.ErrorIs(t, s.Upgrade(context.Background(), dest, "1.21.4"), ErrFlavourMismatch)
+		md.AssertNotCalled(t, "DownloadServer", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+		current, err := readVersionFile(dest)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.21.1", current.MineVersion)
+	})
+}
+
+// writeUpgradeInstance writes a 1.17.1 instance, running on Java 16
+func writeUpgradeInstance(t *testing.T, dest string) {
+	t.Helper()
+	versions, _ := json.Marshal(model.VersionsInfo{MineVersion: "1.17.1", JavaVersion: 16, MineFlavour: model.MineFlavourVanilla})
+	assert.NoError(t, os.WriteFile(filepath.Join(dest, cfg.VersionsFileName), versions, 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dest, "server.properties"), []byte("motd=My server\nsnooper-enabled=true\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dest, "server.jar"), []byte("old server"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dest, "java", "jdk"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(dest, "java", "jdk", "release"), []byte("16"), 0644))
+}
+
+// fakeDownload returns a mock result writing content to name inside
+// the download destination
+func fakeDownload(t *testing.T, name, content string) func(dest string) string {
+	return func(dest string) string {
+		f := filepath.Join(dest, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(f), os.ModePerm))
+		assert.NoError(t, os.WriteFile(f, []byte(content), 0644))
+		return f
+	}
+}
+
+func assertFileContent(t *testing.T, file, expected string) {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, string(b))
+	}
 }
@@ -0,0 +1,144 @@
+package minecraft
+
+import (
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"time"
+)
+
+const (
+	LevelDataFileName = "level.dat"
+
+	levelDataTag = "Data"
+	// ticksPerDay is how long a day lasts (in game ticks)
+	ticksPerDay = 24000
+)
+
+var (
+	difficulties = []string{"peaceful", "easy", "normal", "hard"}
+	gameModes    = []string{"survival", "creative", "adventure", "spectator"}
+)
+
+// LevelData is the world actual state, from its level.dat file
+// (server.properties only holds the initial world settings)
+type LevelData struct {
+	LevelName   string
+	Seed        int64
+	SpawnX      int
+	SpawnY      int
+	SpawnZ      int
+	DataVersion int
+	// VersionName is the server version that last saved the world
+	VersionName      string
+	GameMode         string
+	Difficulty       string
+	DifficultyLocked bool
+	Hardcore         bool
+	// Time is the world age and DayTime the time of day (growing
+	// with each day), both in game ticks
+	Time             int64
+	DayTime          int64
+	Raining          bool
+	Thundering       bool
+	RainTime         int
+	ThunderTime      int
+	ClearWeatherTime int
+	LastPlayed       time.Time
+	// GameRules values are strings, or SNBT for other tags
+	GameRules map[string]string
+	// Raw is the whole level data
+	Raw nbt.Compound
+}
+
+// Day returns the current world day (starting on day 0)
+func (l LevelData) Day() int64 {
+	return l.DayTime / ticksPerDay
+}
+
+// TimeOfDay returns the ticks since the current day started (0 is
+// sunrise, 6000 is noon, 12000 is sunset and 18000 is midnight)
+func (l LevelData) TimeOfDay() int64 {
+	return l.DayTime % ticksPerDay
+}
+
+// Weather returns 'clear', 'rain' or 'thunder'
+func (l LevelData) Weather() string {
+	switch {
+	case l.Thundering:
+		return "thunder"
+	case l.Raining:
+		return "rain"
+	default:
+		return "clear"
+	}
+}
+
+// ReadLevelData reads a level.dat file (a gzip compressed NBT compound
+// holding a 'Data' compound). Older and newer worlds fields are read
+// (like 'RandomSeed' before 1.16 and the 'spawn' compound after 1.21.9).
+func ReadLevelData(file string) (*LevelData, error) {
+	root, err := nbt.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading level data: %w", err)
+	}
+	data, ok := nbt.Get[nbt.Compound](root, levelDataTag)
+	if !ok {
+		return nil, errors.New("level data has no 'Data' tag")
+	}
+
+	integer := func(path ...string) int64 {
+		n, _ := nbt.Int(data, path...)
+		return n
+	}
+	level := &LevelData{
+		DataVersion:      int(integer("DataVersion")),
+		Hardcore:         integer("hardcore") != 0,
+		DifficultyLocked: integer("DifficultyLocked") != 0,
+		Time:             integer("Time"),
+		DayTime:          integer("DayTime"),
+		Raining:          integer("raining") != 0,
+		Thundering:       integer("thundering") != 0,
+		RainTime:         int(integer("rainTime")),
+		ThunderTime:      int(integer("thunderTime")),
+		ClearWeatherTime: int(integer("clearWeatherTime")),
+		GameRules:        make(map[string]string),
+		Raw:              data,
+	}
+	level.LevelName, _ = nbt.Get[string](data, "LevelName")
+	level.VersionName, _ = nbt.Get[string](data, "Version", "Name")
+	if seed, ok := nbt.Int(data, "WorldGenSettings", "seed"); ok {
+		level.Seed = seed
+	} else {
+		level.Seed = integer("RandomSeed")
+	}
+	if pos, ok := nbt.Get[[]int32](data, "spawn", "pos"); ok && len(pos) == 3 {
+		level.SpawnX, level.SpawnY, level.SpawnZ = int(pos[0]), int(pos[1]), int(pos[2])
+	} else {
+		level.SpawnX, level.SpawnY, level.SpawnZ = int(integer("SpawnX")), int(integer("SpawnY")), int(integer("SpawnZ"))
+	}
+	if d, ok := nbt.Int(data, "Difficulty"); ok && d >= 0 && int(d) < len(difficulties) {
+		level.Difficulty = difficulties[d]
+	}
+	if m, ok := nbt.Int(data, "GameType"); ok && m >= 0 && int(m) < len(gameModes) {
+		level.GameMode = gameModes[m]
+	}
+	if ms, ok := nbt.Int(data, "LastPlayed"); ok && ms > 0 {
+		level.LastPlayed = time.UnixMilli(ms)
+	}
+	rules, _ := nbt.Get[nbt.Compound](data, "GameRules")
+	for name, v := range rules {
+		if s, ok := v.(string); ok {
+			level.GameRules[name] = s
+			continue
+		}
+		level.GameRules[name] = nbt.SNBT(v)
+	}
+	return level, nil
+}
+
+// validateLevelData checks that file is a well-formed level.dat
+func validateLevelData(file string) error {
+	_, err := ReadLevelData(file)
+	return err
+}
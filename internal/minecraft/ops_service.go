@@ -0,0 +1,158 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/minecraft/config"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultOpPermissionLevel is the `op-permission-level` used when it isn't defined
+	defaultOpPermissionLevel = 4
+)
+
+type OpsService interface {
+	// Add adds (or updates) instance operators
+	Add(ctx context.Context, instancePath string, ops ...config.Operator) ([]model.OperatorRecord, error)
+	// Remove removes users from instance operators
+	Remove(ctx context.Context, instancePath string, users ...string) ([]model.OperatorRecord, error)
+	// List lists instance operators
+	List(ctx context.Context, instancePath string) ([]model.OperatorRecord, error)
+}
+
+type opsService struct {
+	cfg PlayerServiceConfig
+}
+
+// NewOpsService creates a new operators management service
+func NewOpsService(opts ...PlayerServiceOpt) OpsService {
+	return &opsService{
+		cfg: newPlayerServiceConfig(opts...),
+	}
+}
+
+// Add adds (or updates) instance operators. A running server gets
+// them through `op` command, which always grants the default level, so
+// operators with a custom level (or bypassing the player limit) are
+// only written to the ops file and applied after a restart.
+func (s *opsService) Add(ctx context.Context, instancePath string, ops ...config.Operator) ([]model.OperatorRecord, error) {
+	maxLevel, err := opPermissionLevel(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	ops = append([]config.Operator(nil), ops...)
+	names := make([]string, 0, len(ops))
+	for i := range ops {
+		if ops[i].Level == 0 {
+			ops[i].Level = maxLevel
+		}
+		if ops[i].Level < 1 || ops[i].Level > maxLevel {
+			return nil, fmt.Errorf("invalid level %d for operator '%s' (should be between 1 and %d)", ops[i].Level, ops[i].Name, maxLevel)
+		}
+		names = append(names, ops[i].Name)
+	}
+
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolvePlayersFor(ctx, s.cfg.Client, instancePath, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]string, 0, len(ops))
+	for _, op := range ops {
+		id := resolved[strings.ToLower(op.Name)]
+		r := model.OperatorRecord{
+			Uuid:                id.UUID(),
+			Name:                id.Name,
+			Level:               op.Level,
+			BypassesPlayerLimit: op.BypassesPlayerLimit,
+		}
+		if idx := indexOfOperator(list, op.Name); idx >= 0 {
+			list[idx] = r
+		} else {
+			list = append(list, r)
+		}
+		if r.Level == maxLevel && !r.BypassesPlayerLimit {
+			cmds = append(cmds, "op "+id.Name)
+		}
+	}
+
+	return list, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *opsService) Remove(ctx context.Context, instancePath string, users ...string) ([]model.OperatorRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]string, 0, len(users))
+	for _, u := range users {
+		idx := indexOfOperator(list, u)
+		if idx < 0 {
+			return nil, fmt.Errorf("user '%s' is not an operator", u)
+		}
+		cmds = append(cmds, "deop "+list[idx].Name)
+		list = append(list[:idx], list[idx+1:]...)
+	}
+
+	return list, s.save(ctx, instancePath, list, cmds...)
+}
+
+func (s *opsService) List(_ context.Context, instancePath string) ([]model.OperatorRecord, error) {
+	list, err := readJSONList[model.OperatorRecord](opsFile(instancePath))
+	if err != nil {
+		return nil, fmt.Errorf("reading operators: %w", err)
+	}
+	return list, nil
+}
+
+// save sends cmds to the running server before writing ops file,
+// as the server rewrites it when executing them
+func (s *opsService) save(ctx context.Context, instancePath string, list []model.OperatorRecord, cmds ...string) error {
+	if err := notifyServer(ctx, s.cfg.Console, instancePath, cmds...); err != nil {
+		return err
+	}
+
+	if err := writeJSONList(opsFile(instancePath), list); err != nil {
+		return fmt.Errorf("writing operators: %w", err)
+	}
+	return nil
+}
+
+// opPermissionLevel returns the instance `op-permission-level` property
+func opPermissionLevel(instancePath string) (int, error) {
+	doc, err := model.LoadOrNewPropertiesDocument(filepath.Join(instancePath, ServerPropertiesFileName))
+	if err != nil {
+		return 0, fmt.Errorf("reading server properties: %w", err)
+	}
+	v, ok := doc.Get("op-permission-level")
+	if !ok || v == "" {
+		return defaultOpPermissionLevel, nil
+	}
+	level, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("parsing op-permission-level property: %w", err)
+	}
+	return level, nil
+}
+
+func opsFile(instancePath string) string {
+	return filepath.Join(instancePath, OpsFileName)
+}
+
+func indexOfOperator(list []model.OperatorRecord, name string) int {
+	for i, r := range list {
+		if strings.EqualFold(r.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
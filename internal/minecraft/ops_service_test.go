@@ -0,0 +1,69 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/minecraft/config"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpsService(t *testing.T) {
+	t.Run("given new operators should write ops file and op default level ones on running server", func(t *testing.T) {
+		dest := t.TempDir()
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{"Eldius", "jeb_"}).Return(mojang.UserIDResponse{
+			{ID: "853c80ef3c3749fdaa49938b674adae6", Name: "jeb_"},
+			{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "Eldius"},
+		}, nil)
+		console := &fakeConsole{}
+
+		s := NewOpsService(WithMojangClient(c), WithConsole(console.factory(true)))
+		list, err := s.Add(context.Background(), dest,
+			config.Operator{Name: "Eldius"},
+			config.Operator{Name: "jeb_", Level: 2, BypassesPlayerLimit: true},
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []model.OperatorRecord{
+			{Uuid: "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", Name: "Eldius", Level: 4},
+			{Uuid: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_", Level: 2, BypassesPlayerLimit: true},
+		}, list)
+		// the op command would override jeb_ custom level
+		assert.Equal(t, []string{"op Eldius"}, console.commands)
+
+		b, err := os.ReadFile(filepath.Join(dest, OpsFileName))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"bypassesPlayerLimit": true`)
+
+		list, err = s.Remove(context.Background(), dest, "ELDIUS")
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, []string{"op Eldius", "deop Eldius"}, console.commands)
+
+		c.AssertExpectations(t)
+	})
+
+	t.Run("given a level above op-permission-level should fail without touching ops file", func(t *testing.T) {
+		dest := t.TempDir()
+		doc := model.NewPropertiesDocument()
+		doc.Set("op-permission-level", "2")
+		assert.NoError(t, doc.SaveTo(filepath.Join(dest, ServerPropertiesFileName), 0644))
+
+		s := NewOpsService(WithMojangClient(new(mockMojangClient)), WithConsole((&fakeConsole{}).factory(false)))
+		_, err := s.Add(context.Background(), dest, config.Operator{Name: "jeb_", Level: 3})
+		assert.Error(t, err)
+
+		_, err = os.Stat(filepath.Join(dest, OpsFileName))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("given a non operator user should fail to remove it", func(t *testing.T) {
+		s := NewOpsService(WithMojangClient(new(mockMojangClient)), WithConsole((&fakeConsole{}).factory(false)))
+		_, err := s.Remove(context.Background(), t.TempDir(), "jeb_")
+		assert.Error(t, err)
+	})
+}
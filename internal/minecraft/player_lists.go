@@ -0,0 +1,125 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"os"
+	"strings"
+)
+
+const (
+	ServerPropertiesFileName = "server.properties"
+	WhitelistFileName        = "whitelist.json"
+)
+
+// PlayerServiceConfig holds the dependencies shared by the
+// player lists services (whitelist, ops and bans)
+type PlayerServiceConfig struct {
+	Client  mojang.Client
+	Console ConsoleFactory
+}
+
+type PlayerServiceOpt func(cfg *PlayerServiceConfig)
+
+func newPlayerServiceConfig(opts ...PlayerServiceOpt) PlayerServiceConfig {
+	cfg := &PlayerServiceConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.Client == nil {
+		cfg.Client = mojang.NewClient()
+	}
+	if cfg.Console == nil {
+		cfg.Console = RconConsole
+	}
+	return *cfg
+}
+
+// WithMojangClient defines the client used to resolve player names
+func WithMojangClient(c mojang.Client) PlayerServiceOpt {
+	return func(cfg *PlayerServiceConfig) {
+		cfg.Client = c
+	}
+}
+
+// WithConsole defines how to reach a running server console
+func WithConsole(f ConsoleFactory) PlayerServiceOpt {
+	return func(cfg *PlayerServiceConfig) {
+		cfg.Console = f
+	}
+}
+
+// resolvePlayers resolves player names to their Mojang UUIDs,
+// failing if any of them doesn't exist
+func resolvePlayers(ctx context.Context, c mojang.Client, names ...string) (map[string]mojang.UserID, error) {
+	resolved, missing, err := lookupPlayers(ctx, c, names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("players not found: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// lookupPlayers resolves player names to their Mojang UUIDs, returning
+// the names not found. Resolved users are mapped by lower case name.
+func lookupPlayers(ctx context.Context, c mojang.Client, names ...string) (map[string]mojang.UserID, []string, error) {
+	resolved := make(map[string]mojang.UserID)
+	if len(names) == 0 {
+		return resolved, nil, nil
+	}
+	users, err := c.GetUsersInfo(ctx, names...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving players: %w", err)
+	}
+	for _, u := range users {
+		resolved[strings.ToLower(u.Name)] = u
+	}
+
+	var missing []string
+	for _, n := range names {
+		if _, ok := resolved[strings.ToLower(n)]; !ok {
+			missing = append(missing, n)
+		}
+	}
+	return resolved, missing, nil
+}
+
+// readJSONList reads a server JSON list file (whitelist.json, ops.json...),
+// returning an empty list if file doesn't exist
+func readJSONList[T any](path string) ([]T, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []T{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var list []T
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return []T{}, nil
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// writeJSONList writes a server JSON list file the same way the server does
+func writeJSONList[T any](path string, list []T) error {
+	if list == nil {
+		list = []T{}
+	}
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package minecraft
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/google/uuid"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UUIDMode is the scheme used by a server to identify players
+type UUIDMode string
+
+const (
+	// UUIDModeOnline identifies players by their Mojang account UUID
+	UUIDModeOnline UUIDMode = "online"
+	// UUIDModeOffline identifies players by the name based UUID
+	// used by servers with `online-mode=false`
+	UUIDModeOffline UUIDMode = "offline"
+)
+
+// ParseUUIDMode parses a UUID mode name
+func ParseUUIDMode(m string) (UUIDMode, error) {
+	switch UUIDMode(strings.ToLower(m)) {
+	case UUIDModeOnline:
+		return UUIDModeOnline, nil
+	case UUIDModeOffline:
+		return UUIDModeOffline, nil
+	default:
+		return "", fmt.Errorf("invalid uuid mode: '%s' (should be '%s' or '%s')", m, UUIDModeOnline, UUIDModeOffline)
+	}
+}
+
+// OfflineUUID returns the UUID an offline mode server gives to player name.
+// It's the same as Java's `UUID.nameUUIDFromBytes("OfflinePlayer:" + name)`
+// (a version 3 UUID without namespace).
+func OfflineUUID(name string) string {
+	h := md5.Sum([]byte("OfflinePlayer:" + name))
+	h[6] = (h[6] & 0x0f) | 0x30
+	h[8] = (h[8] & 0x3f) | 0x80
+	return uuid.UUID(h).String()
+}
+
+// instanceUUIDMode returns the UUID scheme used by instance, based
+// on its `online-mode` property (defaults to online)
+func instanceUUIDMode(instancePath string) (UUIDMode, error) {
+	doc, err := model.LoadOrNewPropertiesDocument(filepath.Join(instancePath, ServerPropertiesFileName))
+	if err != nil {
+		return "", fmt.Errorf("reading server properties: %w", err)
+	}
+	v, ok := doc.Get("online-mode")
+	if !ok {
+		return UUIDModeOnline, nil
+	}
+	online, err := strconv.ParseBool(v)
+	if err != nil {
+		return "", fmt.Errorf("parsing online-mode property: %w", err)
+	}
+	if !online {
+		return UUIDModeOffline, nil
+	}
+	return UUIDModeOnline, nil
+}
+
+// resolvePlayersFor resolves player names to the UUIDs used by the
+// instance, choosing the UUID scheme from its `online-mode` property
+func resolvePlayersFor(ctx context.Context, c mojang.Client, instancePath string, names ...string) (map[string]mojang.UserID, error) {
+	mode, err := instanceUUIDMode(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	return resolvePlayersAs(ctx, c, mode, names...)
+}
+
+// resolvePlayersAs resolves player names to their UUIDs using the mode scheme,
+// failing if any of them doesn't exist
+func resolvePlayersAs(ctx context.Context, c mojang.Client, mode UUIDMode, names ...string) (map[string]mojang.UserID, error) {
+	resolved, missing, err := lookupPlayersAs(ctx, c, mode, names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("players not found: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// lookupPlayersAs resolves player names to their UUIDs using the mode scheme,
+// returning the names not found
+func lookupPlayersAs(ctx context.Context, c mojang.Client, mode UUIDMode, names ...string) (map[string]mojang.UserID, []string, error) {
+	if mode == UUIDModeOnline {
+		return lookupPlayers(ctx, c, names...)
+	}
+	resolved := make(map[string]mojang.UserID)
+	for _, n := range names {
+		resolved[strings.ToLower(n)] = mojang.UserID{ID: OfflineUUID(n), Name: n}
+	}
+	return resolved, nil, nil
+}
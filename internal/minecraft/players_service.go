@@ -0,0 +1,250 @@
+package minecraft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	UserCacheFileName     = "usercache.json"
+	OpsFileName           = "ops.json"
+	BannedPlayersFileName = "banned-players.json"
+	defaultLevelName      = "world"
+)
+
+var (
+	// playerFilesFolders are the world folders holding per player files named by UUID
+	playerFilesFolders = []string{"playerdata", "stats", "advancements"}
+	// playerListFiles are the instance files identifying players by UUID
+	playerListFiles = []string{WhitelistFileName, OpsFileName, BannedPlayersFileName, UserCacheFileName}
+)
+
+type PlayersService interface {
+	// MigrateUUIDs switches instance players UUIDs to the mode scheme,
+	// renaming their world files and updating the players lists
+	MigrateUUIDs(ctx context.Context, instancePath string, mode UUIDMode, dryRun bool) ([]UUIDMigration, error)
+}
+
+// UUIDMigration describes a player UUID change
+type UUIDMigration struct {
+	Name    string
+	OldUUID string
+	NewUUID string
+	// Files are the renamed world files (relative to instance folder)
+	Files []string
+}
+
+type playersService struct {
+	cfg PlayerServiceConfig
+}
+
+// NewPlayersService creates a new players management service
+func NewPlayersService(opts ...PlayerServiceOpt) PlayersService {
+	return &playersService{
+		cfg: newPlayerServiceConfig(opts...),
+	}
+}
+
+func (s *playersService) MigrateUUIDs(ctx context.Context, instancePath string, mode UUIDMode, dryRun bool) ([]UUIDMigration, error) {
+	log := logger.GetLogger().With("action", "migrate_uuids", "instance_path", instancePath, "mode", mode, "dry_run", dryRun)
+
+	if IsServerRunning(instancePath) {
+		return nil, ErrServerRunning
+	}
+
+	worldPath, err := worldFolder(instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := knownPlayerNames(instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := playerFiles(worldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make(map[string]*UUIDMigration)
+	var toResolve []string
+	for id := range files {
+		name, ok := names[id]
+		if !ok {
+			log.With("uuid", id).WarnContext(ctx, "Skipping player files without a known name")
+			continue
+		}
+		migrations[id] = &UUIDMigration{Name: name, OldUUID: id}
+		toResolve = append(toResolve, name)
+	}
+	for id, name := range names {
+		if _, ok := migrations[id]; !ok {
+			migrations[id] = &UUIDMigration{Name: name, OldUUID: id}
+			toResolve = append(toResolve, name)
+		}
+	}
+	sort.Strings(toResolve)
+
+	resolved, missing, err := lookupPlayersAs(ctx, s.cfg.Client, mode, toResolve...)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		log.With("players", missing).WarnContext(ctx, "Skipping players without a Mojang account")
+	}
+
+	var result []UUIDMigration
+	newIDs := make(map[string]string)
+	for id, m := range migrations {
+		u, ok := resolved[strings.ToLower(m.Name)]
+		if !ok || u.UUID() == m.OldUUID {
+			continue
+		}
+		m.NewUUID = u.UUID()
+		newIDs[id] = m.NewUUID
+		for _, f := range files[id] {
+			dir, file := filepath.Split(f)
+			target := filepath.Join(dir, strings.Replace(file, id, m.NewUUID, 1))
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("migrating %s: target file already exists: %s", m.Name, target)
+			}
+			rel, _ := filepath.Rel(instancePath, f)
+			m.Files = append(m.Files, rel)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, m := range result {
+		for _, f := range m.Files {
+			src := filepath.Join(instancePath, f)
+			dir, file := filepath.Split(src)
+			dest := filepath.Join(dir, strings.Replace(file, m.OldUUID, m.NewUUID, 1))
+			log.With("src", src, "dest", dest).DebugContext(ctx, "Renaming player file")
+			if err := os.Rename(src, dest); err != nil {
+				return nil, fmt.Errorf("renaming player file %s: %w", f, err)
+			}
+		}
+	}
+
+	for _, f := range playerListFiles {
+		if err := replaceListUUIDs(filepath.Join(instancePath, f), newIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setOnlineMode(instancePath, mode == UUIDModeOnline); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// worldFolder returns the instance world folder (from `level-name` property)
+func worldFolder(instancePath string) (string, error) {
+	doc, err := model.LoadOrNewPropertiesDocument(filepath.Join(instancePath, ServerPropertiesFileName))
+	if err != nil {
+		return "", fmt.Errorf("reading server properties: %w", err)
+	}
+	levelName, ok := doc.Get("level-name")
+	if !ok || levelName == "" {
+		levelName = defaultLevelName
+	}
+	return filepath.Join(instancePath, levelName), nil
+}
+
+// knownPlayerNames maps UUIDs to player names from the instance players lists
+func knownPlayerNames(instancePath string) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, f := range playerListFiles {
+		records, err := readJSONList[map[string]any](filepath.Join(instancePath, f))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			id, _ := r["uuid"].(string)
+			name, _ := r["name"].(string)
+			if id != "" && name != "" {
+				names[id] = name
+			}
+		}
+	}
+	return names, nil
+}
+
+// playerFiles maps UUIDs to their world files (playerdata, stats and advancements)
+func playerFiles(worldPath string) (map[string][]string, error) {
+	files := make(map[string][]string)
+	for _, folder := range playerFilesFolders {
+		entries, err := os.ReadDir(filepath.Join(worldPath, folder))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s folder: %w", folder, err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			id, _, _ := strings.Cut(e.Name(), ".")
+			if _, err := uuid.Parse(id); err != nil {
+				continue
+			}
+			files[id] = append(files[id], filepath.Join(worldPath, folder, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// replaceListUUIDs updates the `uuid` attribute from the JSON list on path,
+// keeping every other attribute untouched
+func replaceListUUIDs(path string, newIDs map[string]string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	records, err := readJSONList[map[string]json.RawMessage](path)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		var id string
+		if err := json.Unmarshal(r["uuid"], &id); err != nil {
+			continue
+		}
+		if newID, ok := newIDs[id]; ok {
+			r["uuid"], _ = json.Marshal(newID)
+		}
+	}
+	return writeJSONList(path, records)
+}
+
+func setOnlineMode(instancePath string, online bool) error {
+	propsFile := filepath.Join(instancePath, ServerPropertiesFileName)
+	doc, err := model.LoadOrNewPropertiesDocument(propsFile)
+	if err != nil {
+		return fmt.Errorf("reading server properties: %w", err)
+	}
+	doc.Set("online-mode", strconv.FormatBool(online))
+	if err := doc.SaveTo(propsFile, 0644); err != nil {
+		return fmt.Errorf("writing server properties: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOfflineUUID(t *testing.T) {
+	t.Run("given a player name should return the offline server UUID", func(t *testing.T) {
+		assert.Equal(t, "b50ad385-829d-3141-a216-7e7d7539ba7f", OfflineUUID("Notch"))
+	})
+}
+
+func TestPlayersService_MigrateUUIDs(t *testing.T) {
+	const (
+		onlineUUID = "069a79f4-44e9-4726-a5be-fca90e38aaf5"
+		playerName = "Notch"
+	)
+	offlineUUID := OfflineUUID(playerName)
+
+	setup := func(t *testing.T) string {
+		t.Helper()
+		dest := t.TempDir()
+		assert.NoError(t, writeJSONList(filepath.Join(dest, WhitelistFileName), []model.WhitelistRecord{
+			{Uuid: onlineUUID, Name: playerName},
+		}))
+		assert.NoError(t, writeJSONList(filepath.Join(dest, UserCacheFileName), []map[string]string{
+			{"uuid": onlineUUID, "name": playerName, "expiresOn": "2025-02-01 12:00:00 +0000"},
+		}))
+		for _, f := range []string{
+			filepath.Join("playerdata", onlineUUID+".dat"),
+			filepath.Join("playerdata", onlineUUID+".dat_old"),
+			filepath.Join("stats", onlineUUID+".json"),
+			filepath.Join("advancements", onlineUUID+".json"),
+		} {
+			p := filepath.Join(dest, defaultLevelName, f)
+			assert.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+			assert.NoError(t, os.WriteFile(p, []byte("data"), 0644))
+		}
+		return dest
+	}
+
+	t.Run("given online players should rename files and lists to offline UUIDs", func(t *testing.T) {
+		dest := setup(t)
+
+		migrations, err := NewPlayersService(WithMojangClient(new(mockMojangClient))).
+			MigrateUUIDs(context.Background(), dest, UUIDModeOffline, false)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 1)
+		assert.Equal(t, playerName, migrations[0].Name)
+		assert.Equal(t, onlineUUID, migrations[0].OldUUID)
+		assert.Equal(t, offlineUUID, migrations[0].NewUUID)
+		assert.Len(t, migrations[0].Files, 4)
+
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "playerdata", offlineUUID+".dat"))
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "playerdata", offlineUUID+".dat_old"))
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "stats", offlineUUID+".json"))
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "advancements", offlineUUID+".json"))
+		assert.NoFileExists(t, filepath.Join(dest, defaultLevelName, "playerdata", onlineUUID+".dat"))
+
+		whitelist, err := readJSONList[model.WhitelistRecord](filepath.Join(dest, WhitelistFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, []model.WhitelistRecord{{Uuid: offlineUUID, Name: playerName}}, whitelist)
+
+		cache, err := readJSONList[map[string]string](filepath.Join(dest, UserCacheFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, offlineUUID, cache[0]["uuid"])
+		assert.Equal(t, "2025-02-01 12:00:00 +0000", cache[0]["expiresOn"])
+
+		doc, err := model.LoadPropertiesDocument(filepath.Join(dest, ServerPropertiesFileName))
+		assert.NoError(t, err)
+		v, _ := doc.Get("online-mode")
+		assert.Equal(t, "false", v)
+	})
+
+	t.Run("given dry run should not change anything", func(t *testing.T) {
+		dest := setup(t)
+
+		migrations, err := NewPlayersService(WithMojangClient(new(mockMojangClient))).
+			MigrateUUIDs(context.Background(), dest, UUIDModeOffline, true)
+		assert.NoError(t, err)
+		assert.Len(t, migrations, 1)
+
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "playerdata", onlineUUID+".dat"))
+		assert.NoFileExists(t, filepath.Join(dest, ServerPropertiesFileName))
+	})
+
+	t.Run("given offline players should resolve online UUIDs from Mojang", func(t *testing.T) {
+		dest := t.TempDir()
+		assert.NoError(t, writeJSONList(filepath.Join(dest, WhitelistFileName), []model.WhitelistRecord{
+			{Uuid: offlineUUID, Name: playerName},
+		}))
+		p := filepath.Join(dest, defaultLevelName, "playerdata", offlineUUID+".dat")
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		assert.NoError(t, os.WriteFile(p, []byte("data"), 0644))
+
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{playerName}).Return(mojang.UserIDResponse{
+			{ID: "069a79f444e94726a5befca90e38aaf5", Name: playerName},
+		}, nil)
+
+		_, err := NewPlayersService(WithMojangClient(c)).
+			MigrateUUIDs(context.Background(), dest, UUIDModeOnline, false)
+		assert.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dest, defaultLevelName, "playerdata", onlineUUID+".dat"))
+
+		c.AssertExpectations(t)
+	})
+}
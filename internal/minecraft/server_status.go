@@ -0,0 +1,31 @@
+package minecraft
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ServerPIDFileName is the file where start.sh stores the server process ID
+	ServerPIDFileName = "server.pid"
+)
+
+// IsServerRunning returns true when the instance server process is alive
+func IsServerRunning(instancePath string) bool {
+	b, err := os.ReadFile(filepath.Join(instancePath, ServerPIDFileName))
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,118 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"path/filepath"
+	"strings"
+)
+
+type WhitelistService interface {
+	// Add adds users to instance whitelist
+	Add(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error)
+	// Remove removes users from instance whitelist
+	Remove(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error)
+	// List lists instance whitelisted users
+	List(ctx context.Context, instancePath string) ([]model.WhitelistRecord, error)
+	// Sync replaces instance whitelist with users
+	Sync(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error)
+}
+
+type whitelistService struct {
+	cfg PlayerServiceConfig
+}
+
+// NewWhitelistService creates a new whitelist management service
+func NewWhitelistService(opts ...PlayerServiceOpt) WhitelistService {
+	return &whitelistService{
+		cfg: newPlayerServiceConfig(opts...),
+	}
+}
+
+func (s *whitelistService) Add(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var toResolve []string
+	for _, u := range users {
+		if indexOfWhitelisted(list, u) < 0 {
+			toResolve = append(toResolve, u)
+		}
+	}
+
+	resolved, err := resolvePlayersFor(ctx, s.cfg.Client, instancePath, toResolve...)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range toResolve {
+		id := resolved[strings.ToLower(u)]
+		list = append(list, model.WhitelistRecord{Uuid: id.UUID(), Name: id.Name})
+	}
+
+	return list, s.save(ctx, instancePath, list)
+}
+
+func (s *whitelistService) Remove(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error) {
+	list, err := s.List(ctx, instancePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		idx := indexOfWhitelisted(list, u)
+		if idx < 0 {
+			return nil, fmt.Errorf("user '%s' is not whitelisted", u)
+		}
+		list = append(list[:idx], list[idx+1:]...)
+	}
+
+	return list, s.save(ctx, instancePath, list)
+}
+
+func (s *whitelistService) List(_ context.Context, instancePath string) ([]model.WhitelistRecord, error) {
+	list, err := readJSONList[model.WhitelistRecord](whitelistFile(instancePath))
+	if err != nil {
+		return nil, fmt.Errorf("reading whitelist: %w", err)
+	}
+	return list, nil
+}
+
+func (s *whitelistService) Sync(ctx context.Context, instancePath string, users ...string) ([]model.WhitelistRecord, error) {
+	resolved, err := resolvePlayersFor(ctx, s.cfg.Client, instancePath, users...)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]model.WhitelistRecord, 0, len(users))
+	for _, u := range users {
+		id := resolved[strings.ToLower(u)]
+		if indexOfWhitelisted(list, id.Name) >= 0 {
+			continue
+		}
+		list = append(list, model.WhitelistRecord{Uuid: id.UUID(), Name: id.Name})
+	}
+
+	return list, s.save(ctx, instancePath, list)
+}
+
+func (s *whitelistService) save(ctx context.Context, instancePath string, list []model.WhitelistRecord) error {
+	if err := writeJSONList(whitelistFile(instancePath), list); err != nil {
+		return fmt.Errorf("writing whitelist: %w", err)
+	}
+
+	return notifyServer(ctx, s.cfg.Console, instancePath, "whitelist reload")
+}
+
+func whitelistFile(instancePath string) string {
+	return filepath.Join(instancePath, WhitelistFileName)
+}
+
+func indexOfWhitelisted(list []model.WhitelistRecord, name string) int {
+	for i, r := range list {
+		if strings.EqualFold(r.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package minecraft
+
+import (
+	"context"
+	"github.com/eldius/mineserver-manager/internal/model"
+	"github.com/eldius/mineserver-manager/internal/mojang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type mockMojangClient struct {
+	mock.Mock
+}
+
+func (m *mockMojangClient) ListVersions(ctx context.Context) (*mojang.VersionsResponse, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*mojang.VersionsResponse), args.Error(1)
+}
+
+func (m *mockMojangClient) GetVersionInfo(ctx context.Context, v mojang.Version) (*mojang.VersionInfoResponse, error) {
+	args := m.Called(ctx, v)
+	return args.Get(0).(*mojang.VersionInfoResponse), args.Error(1)
+}
+
+func (m *mockMojangClient) GetUsersInfo(ctx context.Context, users ...string) (mojang.UserIDResponse, error) {
+	args := m.Called(ctx, users)
+	return args.Get(0).(mojang.UserIDResponse), args.Error(1)
+}
+
+func (m *mockMojangClient) GetUserInfo(ctx context.Context, user string) (*mojang.UserID, error) {
+	args := m.Called(ctx, user)
+	return args.Get(0).(*mojang.UserID), args.Error(1)
+}
+
+func (m *mockMojangClient) GetProfile(ctx context.Context, id string) (*mojang.Profile, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*mojang.Profile), args.Error(1)
+}
+
+func (m *mockMojangClient) IsServerBlocked(ctx context.Context, address string) (bool, error) {
+	args := m.Called(ctx, address)
+	return args.Bool(0), args.Error(1)
+}
+
+type fakeConsole struct {
+	commands []string
+	outputs  map[string]string
+	closed   bool
+}
+
+func (c *fakeConsole) Execute(_ context.Context, cmd string) (string, error) {
+	c.commands = append(c.commands, cmd)
+	return c.outputs[cmd], nil
+}
+
+func (c *fakeConsole) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConsole) factory(running bool) ConsoleFactory {
+	return func(_ context.Context, _ string) (Console, error) {
+		if !running {
+			return nil, ErrServerNotRunning
+		}
+		return c, nil
+	}
+}
+
+func TestWhitelistService(t *testing.T) {
+	t.Run("given new users should write dashed UUIDs and reload running server whitelist", func(t *testing.T) {
+		dest := t.TempDir()
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{"Eldius", "jeb_"}).Return(mojang.UserIDResponse{
+			{ID: "853c80ef3c3749fdaa49938b674adae6", Name: "jeb_"},
+			{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "Eldius"},
+		}, nil)
+		console := &fakeConsole{}
+
+		s := NewWhitelistService(WithMojangClient(c), WithConsole(console.factory(true)))
+		list, err := s.Add(context.Background(), dest, "Eldius", "jeb_")
+		assert.NoError(t, err)
+		assert.Equal(t, []model.WhitelistRecord{
+			{Uuid: "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", Name: "Eldius"},
+			{Uuid: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_"},
+		}, list)
+		assert.Equal(t, []string{"whitelist reload"}, console.commands)
+
+		b, err := os.ReadFile(filepath.Join(dest, WhitelistFileName))
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `"uuid": "853c80ef-3c37-49fd-aa49-938b674adae6"`)
+
+		c.AssertExpectations(t)
+	})
+
+	t.Run("given already whitelisted users should only resolve the new ones", func(t *testing.T) {
+		dest := t.TempDir()
+		assert.NoError(t, writeJSONList(filepath.Join(dest, WhitelistFileName), []model.WhitelistRecord{
+			{Uuid: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_"},
+		}))
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{"Eldius"}).Return(mojang.UserIDResponse{
+			{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "Eldius"},
+		}, nil)
+		console := &fakeConsole{}
+
+		s := NewWhitelistService(WithMojangClient(c), WithConsole(console.factory(false)))
+		list, err := s.Add(context.Background(), dest, "JEB_", "Eldius")
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+		assert.Empty(t, console.commands)
+
+		list, err = s.Remove(context.Background(), dest, "jeb_")
+		assert.NoError(t, err)
+		assert.Equal(t, []model.WhitelistRecord{{Uuid: "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", Name: "Eldius"}}, list)
+
+		c.AssertExpectations(t)
+	})
+
+	t.Run("given an unknown user should fail without touching whitelist", func(t *testing.T) {
+		dest := t.TempDir()
+		c := new(mockMojangClient)
+		c.On("GetUsersInfo", mock.Anything, []string{"not-a-player"}).Return(mojang.UserIDResponse{}, nil)
+
+		s := NewWhitelistService(WithMojangClient(c), WithConsole((&fakeConsole{}).factory(false)))
+		_, err := s.Sync(context.Background(), dest, "not-a-player")
+		assert.Error(t, err)
+
+		_, err = os.Stat(filepath.Join(dest, WhitelistFileName))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,37 @@
+package minecraft
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"path/filepath"
+)
+
+type WorldService interface {
+	// Info reads the instance world state from its level data
+	Info(ctx context.Context, instancePath string) (*LevelData, error)
+	// Stats reads the instance world region files, telling how big
+	// each dimension is and which chunks are stale
+	Stats(ctx context.Context, instancePath string) (*WorldStats, error)
+}
+
+type worldService struct{}
+
+// NewWorldService creates a new instance worlds service
+func NewWorldService() WorldService {
+	return &worldService{}
+}
+
+func (s *worldService) Info(ctx context.Context, instancePath string) (*LevelData, error) {
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	world, err := worldFolder(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	logger.GetLogger().With("action", "world_info", "world", world).DebugContext(ctx, "Reading level data")
+	return ReadLevelData(filepath.Join(world, LevelDataFileName))
+}
@@ -0,0 +1,153 @@
+package minecraft
+
+import (
+	"bytes"
+	"context"
+	"github.com/eldius/mineserver-manager/internal/anvil"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWorldService_Info(t *testing.T) {
+	writeLevel := func(t *testing.T, data nbt.Compound) string {
+		instance := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(instance, ServerPropertiesFileName), []byte("level-name=survival\n"), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(instance, "survival"), os.ModePerm))
+		require.NoError(t, nbt.WriteFile(filepath.Join(instance, "survival", LevelDataFileName), "", nbt.Compound{"Data": data}, nbt.CompressionGzip))
+		return instance
+	}
+	lastPlayed := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	t.Run("given a world level data should read its state", func(t *testing.T) {
+		instance := writeLevel(t, nbt.Compound{
+			"LevelName":        "survival",
+			"DataVersion":      int32(4189),
+			"Version":          nbt.Compound{"Name": "1.21.4", "Id": int32(4189)},
+			"WorldGenSettings": nbt.Compound{"seed": int64(-123456789)},
+			"SpawnX":           int32(16),
+			"SpawnY":           int32(70),
+			"SpawnZ":           int32(-32),
+			"GameType":         int32(0),
+			"Difficulty":       int8(3),
+			"hardcore":         int8(1),
+			"DayTime":          int64(3*24000 + 6000),
+			"raining":          int8(1),
+			"thundering":       int8(0),
+			"LastPlayed":       lastPlayed.UnixMilli(),
+			"GameRules":        nbt.Compound{"keepInventory": "true", "doDaylightCycle": "false"},
+		})
+
+		level, err := NewWorldService().Info(context.Background(), instance)
+		require.NoError(t, err)
+		assert.Equal(t, "survival", level.LevelName)
+		assert.Equal(t, int64(-123456789), level.Seed)
+		assert.Equal(t, []int{16, 70, -32}, []int{level.SpawnX, level.SpawnY, level.SpawnZ})
+		assert.Equal(t, 4189, level.DataVersion)
+		assert.Equal(t, "1.21.4", level.VersionName)
+		assert.Equal(t, "survival", level.GameMode)
+		assert.Equal(t, "hard", level.Difficulty)
+		assert.True(t, level.Hardcore)
+		assert.Equal(t, int64(3), level.Day())
+		assert.Equal(t, int64(6000), level.TimeOfDay())
+		assert.Equal(t, "rain", level.Weather())
+		assert.True(t, lastPlayed.Equal(level.LastPlayed))
+		assert.Equal(t, map[string]string{"keepInventory": "true", "doDaylightCycle": "false"}, level.GameRules)
+	})
+
+	t.Run("given older and newer level data fields should read them", func(t *testing.T) {
+		level, err := NewWorldService().Info(context.Background(), writeLevel(t, nbt.Compound{
+			"RandomSeed": int64(42),
+			"spawn":      nbt.Compound{"pos": []int32{1, 2, 3}, "dimension": "minecraft:overworld"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), level.Seed)
+		assert.Equal(t, []int{1, 2, 3}, []int{level.SpawnX, level.SpawnY, level.SpawnZ})
+		assert.Equal(t, "clear", level.Weather())
+	})
+
+	t.Run("given an instance without a world should fail", func(t *testing.T) {
+		_, err := NewWorldService().Info(context.Background(), t.TempDir())
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestWorldService_Stats(t *testing.T) {
+	chunk := func(t *testing.T, dataVersion int32, saved time.Time) *anvil.Chunk {
+		var b bytes.Buffer
+		require.NoError(t, nbt.Encode(&b, "", nbt.Compound{"DataVersion": dataVersion, "Status": "minecraft:full"}, nbt.CompressionZlib))
+		return &anvil.Chunk{Timestamp: saved, Compression: anvil.CompressionZlib, Data: b.Bytes()}
+	}
+	writeRegion := func(t *testing.T, file string, chunks map[int]*anvil.Chunk) {
+		r := &anvil.Region{}
+		for i, c := range chunks {
+			r.Chunks[i] = c
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.NoError(t, anvil.WriteRegionFile(file, r))
+	}
+	day := func(d int) time.Time {
+		return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	instance := t.TempDir()
+	world := filepath.Join(instance, "world")
+	require.NoError(t, os.MkdirAll(world, os.ModePerm))
+	require.NoError(t, nbt.WriteFile(filepath.Join(world, LevelDataFileName), "", nbt.Compound{"Data": nbt.Compound{"DataVersion": int32(4189)}}, nbt.CompressionGzip))
+	writeRegion(t, filepath.Join(world, "region", "r.0.0.mca"), map[int]*anvil.Chunk{
+		0:  chunk(t, 4189, day(10)),
+		33: chunk(t, 3465, day(2)),
+		5:  {Timestamp: day(5), Compression: anvil.CompressionZlib, Data: []byte("corrupt")},
+	})
+	writeRegion(t, filepath.Join(world, "region", "r.-1.0.mca"), map[int]*anvil.Chunk{
+		31: chunk(t, 3700, day(20)),
+	})
+	writeRegion(t, filepath.Join(world, "DIM-1", "region", "r.0.0.mca"), map[int]*anvil.Chunk{
+		1: {Timestamp: day(3), Compression: anvil.CompressionZlib | anvil.External},
+	})
+	external := chunk(t, 4189, day(3))
+	require.NoError(t, os.WriteFile(filepath.Join(world, "DIM-1", "region", anvil.ChunkPos{X: 1, Z: 0}.ExternalFileName()), external.Data, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(world, "region", "r.2.2.mca"), []byte("corrupt"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(world, "entities"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(world, "entities", "r.0.0.mca"), make([]byte, 100), 0o644))
+
+	stats, err := NewWorldService().Stats(context.Background(), instance)
+	require.NoError(t, err)
+	assert.Equal(t, 4189, stats.DataVersion)
+	require.Len(t, stats.Dimensions, 2)
+
+	overworld := stats.Dimensions[0]
+	assert.Equal(t, DimensionOverworld, overworld.Dimension)
+	assert.Equal(t, "world", overworld.Folder)
+	assert.Equal(t, 2, overworld.Regions)
+	assert.Equal(t, 4, overworld.Chunks)
+	assert.Equal(t, 1, overworld.Unreadable)
+	assert.Equal(t, []string{"world/region/r.2.2.mca"}, overworld.Corrupt)
+	assert.Equal(t, []StaleChunk{
+		{Pos: anvil.ChunkPos{X: -1, Z: 0}, DataVersion: 3700},
+		{Pos: anvil.ChunkPos{X: 1, Z: 1}, DataVersion: 3465},
+	}, overworld.Stale)
+	require.NotNil(t, overworld.Oldest)
+	assert.Equal(t, anvil.ChunkPos{X: 1, Z: 1}, overworld.Oldest.Pos)
+	assert.True(t, day(2).Equal(overworld.Oldest.Modified))
+	require.NotNil(t, overworld.Newest)
+	assert.Equal(t, anvil.ChunkPos{X: -1, Z: 0}, overworld.Newest.Pos)
+	sizes := int64(100 + len("corrupt"))
+	for _, f := range []string{"r.0.0.mca", "r.-1.0.mca"} {
+		st, err := os.Stat(filepath.Join(world, "region", f))
+		require.NoError(t, err)
+		sizes += st.Size()
+	}
+	assert.Equal(t, sizes, overworld.DiskUsage)
+
+	nether := stats.Dimensions[1]
+	assert.Equal(t, DimensionNether, nether.Dimension)
+	assert.Equal(t, "world/DIM-1", nether.Folder)
+	assert.Equal(t, 1, nether.Chunks)
+	assert.Zero(t, nether.Unreadable)
+	assert.Empty(t, nether.Stale)
+}
@@ -0,0 +1,236 @@
+package minecraft
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/anvil"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/nbt"
+	"github.com/eldius/mineserver-manager/internal/utils"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// WorldStats describes how big a world is, by dimension
+type WorldStats struct {
+	// DataVersion is the server data version, from the world level data
+	DataVersion int
+	// Dimensions are the generated dimensions
+	Dimensions []DimensionStats
+}
+
+// DimensionStats describes a world dimension region files
+type DimensionStats struct {
+	Dimension Dimension
+	// Folder is relative to the instance folder
+	Folder  string
+	Regions int
+	Chunks  int
+	// DiskUsage is the size of the blocks, entities and points of
+	// interest region files (in bytes)
+	DiskUsage int64
+	// Oldest and Newest are the least and the most recently saved
+	// chunks (nil without chunks)
+	Oldest *ChunkTime
+	Newest *ChunkTime
+	// Stale are the chunks saved by older server versions (sorted by
+	// position)
+	Stale []StaleChunk
+	// Unreadable is how many chunks couldn't be parsed
+	Unreadable int
+	// Corrupt are the region files that couldn't be read (relative to
+	// the instance folder)
+	Corrupt []string
+}
+
+// ChunkTime is a chunk and when it was last saved
+type ChunkTime struct {
+	Pos      anvil.ChunkPos
+	Modified time.Time
+}
+
+// StaleChunk is a chunk saved by an older server version
+type StaleChunk struct {
+	Pos         anvil.ChunkPos
+	DataVersion int
+}
+
+// regionStats are the stats of a single region file
+type regionStats struct {
+	chunks         int
+	oldest, newest *ChunkTime
+	stale          []StaleChunk
+	unreadable     int
+}
+
+// Stats reads the region files of each world dimension, chunks are
+// parsed for their DataVersion (region files are read in parallel)
+func (s *worldService) Stats(ctx context.Context, instancePath string) (*WorldStats, error) {
+	instancePath, err := utils.AbsolutePath(instancePath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing to absolute Path: %w", err)
+	}
+	world, err := worldFolder(instancePath)
+	if err != nil {
+		return nil, err
+	}
+	level, err := ReadLevelData(filepath.Join(world, LevelDataFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &WorldStats{DataVersion: level.DataVersion}
+	for _, d := range Dimensions {
+		folder, err := dimensionFolder(instancePath, d)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(instancePath, folder, "region")); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		dim, err := dimensionStats(ctx, instancePath, folder, level.DataVersion)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s stats: %w", d, err)
+		}
+		dim.Dimension = d
+		stats.Dimensions = append(stats.Dimensions, *dim)
+	}
+	return stats, nil
+}
+
+func dimensionStats(ctx context.Context, instancePath, folder string, dataVersion int) (*DimensionStats, error) {
+	log := logger.GetLogger().With("action", "world_stats", "instance_path", instancePath, "folder", folder)
+	dim := &DimensionStats{Folder: filepath.ToSlash(folder)}
+
+	for _, f := range regionFolders {
+		size, err := folderSize(filepath.Join(instancePath, folder, f))
+		if err != nil {
+			return nil, err
+		}
+		dim.DiskUsage += size
+	}
+
+	files, err := filepath.Glob(filepath.Join(instancePath, folder, "region", "r.*.*.mca"))
+	if err != nil {
+		return nil, fmt.Errorf("listing region files: %w", err)
+	}
+	results := make([]*regionStats, len(files))
+	errs := make([]error, len(files))
+	workers := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, f := range files {
+		wg.Go(func() {
+			workers <- struct{}{}
+			defer func() {
+				<-workers
+			}()
+			if errs[i] = ctx.Err(); errs[i] == nil {
+				results[i], errs[i] = regionFileStats(f, dataVersion)
+			}
+		})
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		if errs[i] != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			rel, _ := filepath.Rel(instancePath, files[i])
+			log.With("error", errs[i], "file", rel).WarnContext(ctx, "Reading region file")
+			dim.Corrupt = append(dim.Corrupt, filepath.ToSlash(rel))
+			continue
+		}
+		dim.Regions++
+		dim.Chunks += r.chunks
+		dim.Unreadable += r.unreadable
+		dim.Stale = append(dim.Stale, r.stale...)
+		if r.oldest != nil && (dim.Oldest == nil || r.oldest.Modified.Before(dim.Oldest.Modified)) {
+			dim.Oldest = r.oldest
+		}
+		if r.newest != nil && (dim.Newest == nil || r.newest.Modified.After(dim.Newest.Modified)) {
+			dim.Newest = r.newest
+		}
+	}
+	slices.SortFunc(dim.Stale, func(a, b StaleChunk) int {
+		return cmp.Or(cmp.Compare(a.Pos.X, b.Pos.X), cmp.Compare(a.Pos.Z, b.Pos.Z))
+	})
+	return dim, nil
+}
+
+// regionFileStats reads a region file chunks, chunks that can't be
+// parsed are only counted
+func regionFileStats(file string, dataVersion int) (*regionStats, error) {
+	pos, err := anvil.ParseRegionFileName(file)
+	if err != nil {
+		return nil, err
+	}
+	region, err := anvil.ReadRegionFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &regionStats{}
+	for i, c := range region.Chunks {
+		if c == nil {
+			continue
+		}
+		stats.chunks++
+		chunkPos := pos.Chunk(i)
+		if t := c.Timestamp; t.Unix() > 0 {
+			if stats.oldest == nil || t.Before(stats.oldest.Modified) {
+				stats.oldest = &ChunkTime{Pos: chunkPos, Modified: t}
+			}
+			if stats.newest == nil || t.After(stats.newest.Modified) {
+				stats.newest = &ChunkTime{Pos: chunkPos, Modified: t}
+			}
+		}
+
+		if c.IsExternal() {
+			if c, err = c.LoadExternal(filepath.Join(filepath.Dir(file), chunkPos.ExternalFileName())); err != nil {
+				stats.unreadable++
+				continue
+			}
+		}
+		data, err := c.NBT()
+		if err != nil {
+			stats.unreadable++
+			continue
+		}
+		if v, ok := nbt.Int(data, "DataVersion"); ok && int(v) < dataVersion {
+			stats.stale = append(stats.stale, StaleChunk{Pos: chunkPos, DataVersion: int(v)})
+		}
+	}
+	return stats, nil
+}
+
+// folderSize returns the size of the folder files, missing folders
+// are empty
+func folderSize(folder string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == folder {
+			return fs.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reading '%s' size: %w", filepath.Base(folder), err)
+	}
+	return size, nil
+}
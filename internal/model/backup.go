@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// BackupTrigger tells what started a backup
+type BackupTrigger string
+
+const (
+	BackupTriggerManual     BackupTrigger = "manual"
+	BackupTriggerScheduled  BackupTrigger = "scheduled"
+	BackupTriggerPreUpgrade BackupTrigger = "pre-upgrade"
+)
+
+// BackupRecord is a backup file metadata. It's stored next to the
+// backup file (as a sidecar file) and on the instances database.
+type BackupRecord struct {
+	ID string `json:"id"`
+	// InstanceID is the registered instance ID (empty for
+	// instances not registered on the database)
+	InstanceID   string `json:"instance_id,omitempty" storm:"index"`
+	InstanceName string `json:"instance_name" storm:"index"`
+	InstancePath string `json:"instance_path"`
+	// MineFlavour and MineVersion come from the instance versions.json file
+	MineFlavour MineFlavour `json:"mine_flavour,omitempty"`
+	MineVersion string      `json:"mine_version,omitempty"`
+	// Location is the backup file path or remote URL, File its name
+	Location  string `json:"location"`
+	File      string `json:"file"`
+	Format    string `json:"format"`
+	Encrypted bool   `json:"encrypted"`
+	// Files is how many instance files were packed
+	Files int `json:"files"`
+	// Size and Checksum (SHA-256) are from the stored backup file
+	Size      int64         `json:"size"`
+	Checksum  string        `json:"checksum"`
+	Trigger   BackupTrigger `json:"trigger"`
+	CreatedAt time.Time     `json:"created_at" storm:"index"`
+	Duration  time.Duration `json:"duration"`
+}
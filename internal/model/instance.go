@@ -55,3 +55,44 @@ type WhitelistRecord struct {
 	Uuid string `json:"uuid"`
 	Name string `json:"name"`
 }
+
+// OperatorRecord is an ops.json entry
+type OperatorRecord struct {
+	Uuid                string `json:"uuid"`
+	Name                string `json:"name"`
+	Level               int    `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+}
+
+const (
+	// BanDateLayout is the date layout used on ban lists
+	BanDateLayout = "2006-01-02 15:04:05 -0700"
+	// BanExpiresForever is the expires value of permanent bans
+	BanExpiresForever = "forever"
+)
+
+// BannedPlayerRecord is a banned-players.json entry
+type BannedPlayerRecord struct {
+	Uuid    string `json:"uuid"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// BannedIPRecord is a banned-ips.json entry
+type BannedIPRecord struct {
+	IP      string `json:"ip"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// BanList holds both instance ban lists, it's the
+// ban export/import format
+type BanList struct {
+	Players []BannedPlayerRecord `json:"players"`
+	IPs     []BannedIPRecord     `json:"ips"`
+}
@@ -0,0 +1,363 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/eldius/properties"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PropertiesDocument is a line oriented representation of a `.properties` file.
+// Unlike ServerProperties it keeps comments, blank lines, unknown keys and
+// the original key ordering, so a file can be rewritten without losing the
+// entries the typed model doesn't know about (keys added by newer server
+// versions, for example).
+type PropertiesDocument struct {
+	lines []propertiesLine
+}
+
+type propertiesLine struct {
+	// raw is the original text for this line (including continuation lines)
+	raw string
+	// key is the unescaped key (empty for comments and blank lines)
+	key string
+	// value is the unescaped value
+	value string
+}
+
+func (l propertiesLine) isEntry() bool {
+	return l.key != ""
+}
+
+// NewPropertiesDocument creates an empty document
+func NewPropertiesDocument() *PropertiesDocument {
+	return &PropertiesDocument{}
+}
+
+// ParsePropertiesDocument parses a `.properties` content
+func ParsePropertiesDocument(r io.Reader) (*PropertiesDocument, error) {
+	doc := NewPropertiesDocument()
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var pending []string
+	for s.Scan() {
+		line := s.Text()
+		if len(pending) == 0 && isCommentOrBlank(line) {
+			doc.lines = append(doc.lines, propertiesLine{raw: line})
+			continue
+		}
+		pending = append(pending, line)
+		if hasContinuation(line) {
+			continue
+		}
+		doc.lines = append(doc.lines, parseEntry(pending))
+		pending = nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading properties content: %w", err)
+	}
+	if len(pending) > 0 {
+		doc.lines = append(doc.lines, parseEntry(pending))
+	}
+
+	return doc, nil
+}
+
+// LoadPropertiesDocument reads a `.properties` file from disk
+func LoadPropertiesDocument(path string) (*PropertiesDocument, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		err = fmt.Errorf("opening file: %w", err)
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	doc, err := ParsePropertiesDocument(f)
+	if err != nil {
+		err = fmt.Errorf("parsing file: %w", err)
+		return nil, err
+	}
+	return doc, nil
+}
+
+// LoadOrNewPropertiesDocument reads a `.properties` file from disk,
+// returning an empty document if it doesn't exist yet
+func LoadOrNewPropertiesDocument(path string) (*PropertiesDocument, error) {
+	doc, err := LoadPropertiesDocument(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewPropertiesDocument(), nil
+	}
+	return doc, err
+}
+
+// Get returns the value for key
+func (d *PropertiesDocument) Get(key string) (string, bool) {
+	idx := d.indexOf(key)
+	if idx < 0 {
+		return "", false
+	}
+	return d.lines[idx].value, true
+}
+
+// Has returns true if key is defined in document
+func (d *PropertiesDocument) Has(key string) bool {
+	return d.indexOf(key) >= 0
+}
+
+// Set defines the value for key, keeping its position if it
+// already exists or appending it to the end of the document
+func (d *PropertiesDocument) Set(key, value string) {
+	idx := d.indexOf(key)
+	if idx >= 0 {
+		if d.lines[idx].value == value {
+			return
+		}
+		d.lines[idx] = newEntry(key, value)
+		return
+	}
+	d.lines = append(d.lines, newEntry(key, value))
+}
+
+// Delete removes every occurrence of key from document
+func (d *PropertiesDocument) Delete(key string) bool {
+	found := false
+	lines := d.lines[:0]
+	for _, l := range d.lines {
+		if l.key == key {
+			found = true
+			continue
+		}
+		lines = append(lines, l)
+	}
+	d.lines = lines
+	return found
+}
+
+// Keys returns the document keys in file order
+func (d *PropertiesDocument) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, l := range d.lines {
+		if !l.isEntry() || seen[l.key] {
+			continue
+		}
+		seen[l.key] = true
+		keys = append(keys, l.key)
+	}
+	return keys
+}
+
+// WriteTo writes document content to w
+func (d *PropertiesDocument) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, l := range d.lines {
+		n, err := io.WriteString(w, l.raw+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// String returns document content
+func (d *PropertiesDocument) String() string {
+	var b bytes.Buffer
+	_, _ = d.WriteTo(&b)
+	return b.String()
+}
+
+// SaveTo writes document content to the file on path
+func (d *PropertiesDocument) SaveTo(path string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("creating properties file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if _, err := d.WriteTo(f); err != nil {
+		return fmt.Errorf("writing properties file: %w", err)
+	}
+	return nil
+}
+
+// ServerProperties decodes the known keys into a ServerProperties
+func (d *PropertiesDocument) ServerProperties() (*ServerProperties, error) {
+	var b bytes.Buffer
+	for _, k := range d.Keys() {
+		v, _ := d.Get(k)
+		b.WriteString(k + "=" + v + "\n")
+	}
+
+	var p ServerProperties
+	if err := properties.NewDecoder(&b).Decode(&p); err != nil {
+		return nil, fmt.Errorf("decoding server properties: %w", err)
+	}
+	return &p, nil
+}
+
+// SetServerProperties updates document with every key known by ServerProperties,
+// keeping the unknown ones untouched
+func (d *PropertiesDocument) SetServerProperties(p *ServerProperties) error {
+	var b bytes.Buffer
+	if err := properties.NewEncoder(&b).Encode(p); err != nil {
+		return fmt.Errorf("encoding server properties: %w", err)
+	}
+	encoded, err := ParsePropertiesDocument(&b)
+	if err != nil {
+		return fmt.Errorf("parsing encoded server properties: %w", err)
+	}
+	for _, k := range encoded.Keys() {
+		v, _ := encoded.Get(k)
+		d.Set(k, v)
+	}
+	return nil
+}
+
+func (d *PropertiesDocument) indexOf(key string) int {
+	// last occurrence wins, as in java.util.Properties
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if d.lines[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func newEntry(key, value string) propertiesLine {
+	return propertiesLine{
+		raw:   escapeProperty(key, true) + "=" + escapeProperty(value, false),
+		key:   key,
+		value: value,
+	}
+}
+
+func isCommentOrBlank(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t\f")
+	return trimmed == "" || trimmed[0] == '#' || trimmed[0] == '!'
+}
+
+// hasContinuation returns true when line ends with an odd number of backslashes
+func hasContinuation(line string) bool {
+	count := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		count++
+	}
+	return count%2 == 1
+}
+
+func parseEntry(rawLines []string) propertiesLine {
+	var logical strings.Builder
+	for i, l := range rawLines {
+		if i > 0 {
+			l = strings.TrimLeft(l, " \t\f")
+		}
+		if hasContinuation(l) {
+			l = l[:len(l)-1]
+		}
+		logical.WriteString(l)
+	}
+	line := strings.TrimLeft(logical.String(), " \t\f")
+
+	keyEnd := len(line)
+	valueStart := len(line)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			keyEnd = i
+			valueStart = i
+			break
+		}
+	}
+
+	rest := strings.TrimLeft(line[valueStart:], " \t\f")
+	if len(rest) > 0 && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+
+	return propertiesLine{
+		raw:   strings.Join(rawLines, "\n"),
+		key:   unescapeProperty(line[:keyEnd]),
+		value: unescapeProperty(rest),
+	}
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// escapeProperty escapes a key or value the same way java.util.Properties#store does
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch c {
+		case ' ':
+			if isKey || i == 0 {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteRune(c)
+			}
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\\', '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const sampleServerProperties = `#Minecraft server properties
+#Sat Jan 04 12:00:00 UTC 2025
+accepts-transfers=false
+allow-flight=false
+bug-report-link=
+level-type=minecraft\:normal
+motd=A Minecraft Server
+log-ips=true
+
+# custom comment
+pause-when-empty-seconds=60
+region-file-compression=deflate
+server-port=25565
+long-value=first \
+    second
+`
+
+func TestParsePropertiesDocument(t *testing.T) {
+	t.Run("given a server.properties file should parse keys keeping its order", func(t *testing.T) {
+		doc, err := ParsePropertiesDocument(strings.NewReader(sampleServerProperties))
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"accepts-transfers",
+			"allow-flight",
+			"bug-report-link",
+			"level-type",
+			"motd",
+			"log-ips",
+			"pause-when-empty-seconds",
+			"region-file-compression",
+			"server-port",
+			"long-value",
+		}, doc.Keys())
+
+		v, ok := doc.Get("level-type")
+		assert.True(t, ok)
+		assert.Equal(t, "minecraft:normal", v)
+
+		v, ok = doc.Get("bug-report-link")
+		assert.True(t, ok)
+		assert.Empty(t, v)
+
+		v, ok = doc.Get("long-value")
+		assert.True(t, ok)
+		assert.Equal(t, "first second", v)
+
+		_, ok = doc.Get("non-existing-key")
+		assert.False(t, ok)
+	})
+
+	t.Run("given an unchanged document should render the same content", func(t *testing.T) {
+		doc, err := ParsePropertiesDocument(strings.NewReader(sampleServerProperties))
+		assert.NoError(t, err)
+
+		assert.Equal(t, sampleServerProperties, doc.String())
+	})
+
+	t.Run("given a value update should only change the updated line", func(t *testing.T) {
+		doc, err := ParsePropertiesDocument(strings.NewReader(sampleServerProperties))
+		assert.NoError(t, err)
+
+		doc.Set("motd", "My: server")
+		doc.Set("new-key", "new value")
+		assert.True(t, doc.Delete("log-ips"))
+		assert.False(t, doc.Delete("log-ips"))
+
+		expected := strings.Replace(sampleServerProperties, "motd=A Minecraft Server", `motd=My\: server`, 1)
+		expected = strings.Replace(expected, "log-ips=true\n", "", 1)
+		expected += "new-key=new value\n"
+
+		assert.Equal(t, expected, doc.String())
+	})
+}
+
+func TestPropertiesDocument_ServerProperties(t *testing.T) {
+	t.Run("given a document should decode known keys", func(t *testing.T) {
+		doc, err := ParsePropertiesDocument(strings.NewReader(sampleServerProperties))
+		assert.NoError(t, err)
+
+		p, err := doc.ServerProperties()
+		assert.NoError(t, err)
+
+		assert.Equal(t, "A Minecraft Server", p.Motd)
+		assert.Equal(t, LevelTypeNormal, p.LevelType)
+		assert.Equal(t, 25565, p.ServerPort)
+	})
+
+	t.Run("given typed properties should keep unknown keys and comments", func(t *testing.T) {
+		doc, err := ParsePropertiesDocument(strings.NewReader(sampleServerProperties))
+		assert.NoError(t, err)
+
+		p, err := doc.ServerProperties()
+		assert.NoError(t, err)
+
+		p.Motd = "Another server"
+		p.ServerPort = 25570
+		assert.NoError(t, doc.SetServerProperties(p))
+
+		content := doc.String()
+		assert.Contains(t, content, "#Minecraft server properties\n")
+		assert.Contains(t, content, "# custom comment\n")
+		assert.Contains(t, content, "accepts-transfers=false\n")
+		assert.Contains(t, content, "pause-when-empty-seconds=60\n")
+		assert.Contains(t, content, "region-file-compression=deflate\n")
+		assert.Contains(t, content, "motd=Another server\n")
+		assert.Contains(t, content, "server-port=25570\n")
+
+		assert.Less(t, strings.Index(content, "accepts-transfers"), strings.Index(content, "motd"))
+	})
+}
@@ -0,0 +1,111 @@
+package mojang
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long API responses are kept on cache by default
+	DefaultCacheTTL = 24 * time.Hour
+)
+
+// FileCache is an on-disk cache for API responses, it keeps one
+// JSON file per entry. A nil *FileCache is a valid (always empty) cache.
+type FileCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	Expires time.Time       `json:"expires"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// NewFileCache creates a cache keeping entries on dir for ttl
+func NewFileCache(dir string, ttl time.Duration) *FileCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &FileCache{
+		dir: dir,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// Get decodes the entry for key into v, returning false when there is
+// no entry. Expired entries are only returned when allowStale is true.
+func (c *FileCache) Get(key string, v any, allowStale bool) bool {
+	if c == nil {
+		return false
+	}
+	b, err := os.ReadFile(c.file(key))
+	if err != nil {
+		return false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return false
+	}
+	if !allowStale && c.now().After(e.Expires) {
+		return false
+	}
+	return json.Unmarshal(e.Data, v) == nil
+}
+
+// Set stores v for key using the cache TTL
+func (c *FileCache) Set(key string, v any) error {
+	if c == nil {
+		return nil
+	}
+	return c.SetWithTTL(key, v, c.ttl)
+}
+
+// SetWithTTL stores v for key for ttl
+func (c *FileCache) SetWithTTL(key string, v any, ttl time.Duration) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+	b, err := json.Marshal(cacheEntry{Expires: c.now().Add(ttl), Data: data})
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return fmt.Errorf("creating cache folder: %w", err)
+	}
+	// writing to a temp file first so concurrent readers never get partial entries
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("creating cache entry: %w", err)
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.file(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	return nil
+}
+
+func (c *FileCache) file(key string) string {
+	h := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(h[:])+".json")
+}
@@ -3,10 +3,17 @@ package mojang
 import (
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/eldius/mineserver-manager/internal/utils"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -16,52 +23,92 @@ type Client interface {
 	// GetVersionInfo gets a specific version info
 	GetVersionInfo(ctx context.Context, v Version) (*VersionInfoResponse, error)
 	// GetUsersInfo fetch users identification
-	GetUsersInfo(users ...string) (UserIDResponse, error)
+	GetUsersInfo(ctx context.Context, users ...string) (UserIDResponse, error)
+	// GetUserInfo fetch a single user identification
+	GetUserInfo(ctx context.Context, user string) (*UserID, error)
+	// GetProfile fetch a player profile (with textures) by UUID
+	GetProfile(ctx context.Context, id string) (*Profile, error)
+	// IsServerBlocked checks if address is blocked by Mojang
+	IsServerBlocked(ctx context.Context, address string) (bool, error)
+}
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrRateLimited      = errors.New("rate limited")
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Endpoints are the API endpoints used by client
+type Endpoints struct {
+	Versions       string
+	UsersInfoBulk  string
+	UserInfo       string
+	Profile        string
+	BlockedServers string
 }
 
 type ClientConfig struct {
-	Timeout time.Duration
+	// Timeout limits each request attempt (retry waits aren't included)
+	Timeout    time.Duration
+	MaxRetries int
+	// Cache keeps API responses between runs (optional)
+	Cache *FileCache
+	// Transport executes the HTTP requests (defaults to http.DefaultTransport)
+	Transport   http.RoundTripper
+	UserAgent   string
+	LogRequests bool
+	Endpoints   Endpoints
 }
 
 type ClientOpt func(config *ClientConfig) *ClientConfig
 
 type apiClient struct {
-	cfg ClientConfig
+	cfg    ClientConfig
+	client *http.Client
 }
 
 // NewClient creates a new client
 func NewClient(configs ...ClientOpt) Client {
 	cfg := &ClientConfig{
-		Timeout: 1 * time.Second,
+		Timeout:    1 * time.Second,
+		MaxRetries: 3,
+		UserAgent:  DefaultUserAgent,
+		Endpoints:  DefaultEndpoints(),
 	}
 	for _, c := range configs {
 		c(cfg)
 	}
 	return &apiClient{
 		cfg: *cfg,
+		client: &http.Client{
+			Transport: newTransport(*cfg),
+		},
 	}
 }
 
-// ListVersions lists all available versions
-func (c *apiClient) ListVersions(ctx context.Context) (*VersionsResponse, error) {
-	client := c.httpClient()
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, VersionsURL, nil)
-	if err != nil {
-		err = fmt.Errorf("creating mojang query request instance: %w", err)
-		return nil, err
+// DefaultEndpoints returns Mojang API endpoints
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Versions:       VersionsURL,
+		UsersInfoBulk:  UsersInfoBulkURL,
+		UserInfo:       UserInfoURL,
+		Profile:        ProfileURL,
+		BlockedServers: BlockedServersURL,
 	}
-	//res, err := mojang.Get(VersionsURL)
-	res, err := client.Do(r)
+}
+
+// ListVersions lists all available versions. The manifest is cached
+// and revalidated on each call, the cached one is used when offline.
+func (c *apiClient) ListVersions(ctx context.Context) (*VersionsResponse, error) {
+	body, err := c.fetchRevalidated(ctx, c.cfg.Endpoints.Versions)
 	if err != nil {
 		err = fmt.Errorf("getting available mojang: %w", err)
 		return nil, err
 	}
-	defer func() {
-		_ = res.Body.Close()
-	}()
 
 	var versions VersionsResponse
-	if err = json.NewDecoder(res.Body).Decode(&versions); err != nil {
+	if err = json.Unmarshal(body, &versions); err != nil {
 		err = fmt.Errorf("decoding available mojang response: %w", err)
 		return nil, err
 	}
@@ -69,25 +116,31 @@ func (c *apiClient) ListVersions(ctx context.Context) (*VersionsResponse, error)
 	return &versions, nil
 }
 
-// GetVersionInfo gets a specific version info
+// GetVersionInfo gets a specific version info, verifying it
+// against the version SHA1 hash (when informed)
 func (c *apiClient) GetVersionInfo(ctx context.Context, v Version) (*VersionInfoResponse, error) {
-	client := c.httpClient()
-	r, err := http.NewRequestWithContext(ctx, http.MethodGet, v.URL, nil)
+	key := httpCacheKey(v.URL)
+	var cached httpCacheEntry
+	// version info files never change, a cached file matching the hash is enough
+	if v.SHA1 != "" && c.cfg.Cache.Get(key, &cached, true) && checksum(cached.Body) == v.SHA1 {
+		return decodeVersionInfo(cached.Body)
+	}
+
+	body, err := c.fetchRevalidated(ctx, v.URL)
 	if err != nil {
 		err = fmt.Errorf("getting version info for '%s': %w", v.ID, err)
 		return nil, err
 	}
-	res, err := client.Do(r)
-	if err != nil {
-		err = fmt.Errorf("getting version info: %w", err)
-		return nil, err
+	if v.SHA1 != "" && checksum(body) != v.SHA1 {
+		return nil, fmt.Errorf("version info for '%s' (expected: %s, got: %s): %w", v.ID, v.SHA1, checksum(body), ErrChecksumMismatch)
 	}
-	defer func() {
-		_ = res.Body.Close()
-	}()
 
+	return decodeVersionInfo(body)
+}
+
+func decodeVersionInfo(body []byte) (*VersionInfoResponse, error) {
 	var version VersionInfoResponse
-	if err = json.NewDecoder(res.Body).Decode(&version); err != nil {
+	if err := json.Unmarshal(body, &version); err != nil {
 		err = fmt.Errorf("decoding version info response: %w", err)
 		return nil, err
 	}
@@ -95,34 +148,298 @@ func (c *apiClient) GetVersionInfo(ctx context.Context, v Version) (*VersionInfo
 	return &version, nil
 }
 
-func (c *apiClient) GetUsersInfo(users ...string) (UserIDResponse, error) {
+// GetUsersInfo fetch users identification, querying the bulk
+// endpoint in chunks of UsersInfoBulkMaxSize names
+func (c *apiClient) GetUsersInfo(ctx context.Context, users ...string) (UserIDResponse, error) {
+	var response UserIDResponse
+	var missing []string
+	for _, u := range users {
+		var id UserID
+		if c.cfg.Cache.Get(userCacheKey(u), &id, false) {
+			response = append(response, id)
+			continue
+		}
+		missing = append(missing, u)
+	}
+
+	for start := 0; start < len(missing); start += UsersInfoBulkMaxSize {
+		end := min(start+UsersInfoBulkMaxSize, len(missing))
+		chunk, err := c.getUsersInfoChunk(ctx, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range chunk {
+			_ = c.cfg.Cache.Set(userCacheKey(id.Name), id)
+		}
+		response = append(response, chunk...)
+	}
+
+	return response, nil
+}
+
+func (c *apiClient) getUsersInfoChunk(ctx context.Context, users []string) (UserIDResponse, error) {
 	b, err := json.Marshal(users)
 	if err != nil {
 		err = fmt.Errorf("marshalling users info: %w", err)
 		return nil, err
 	}
-	client := c.httpClient()
-	buff := bytes.NewBuffer(b)
-	res, err := client.Post(UsersInfoBulkURL, "application/json", buff)
+
+	body, err := c.send(ctx, http.MethodPost, c.cfg.Endpoints.UsersInfoBulk, b)
+	if err != nil {
+		return nil, fmt.Errorf("getting users info: %w", err)
+	}
+
+	var response UserIDResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		err = fmt.Errorf("decoding users info response: %w", err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetUserInfo fetch a single user identification
+func (c *apiClient) GetUserInfo(ctx context.Context, user string) (*UserID, error) {
+	var id UserID
+	err := c.cached(userCacheKey(user), &id, func() error {
+		body, err := c.send(ctx, http.MethodGet, c.cfg.Endpoints.UserInfo+url.PathEscape(user), nil)
+		if err != nil {
+			return fmt.Errorf("getting user info for '%s': %w", user, err)
+		}
+		if err := json.Unmarshal(body, &id); err != nil {
+			return fmt.Errorf("decoding user info response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// GetProfile fetch a player profile (with its signed textures) from session server
+func (c *apiClient) GetProfile(ctx context.Context, id string) (*Profile, error) {
+	var p Profile
+	err := c.cached("profile:"+undashedUUID(id), &p, func() error {
+		body, err := c.send(ctx, http.MethodGet, c.cfg.Endpoints.Profile+url.PathEscape(undashedUUID(id))+"?unsigned=false", nil)
+		if err != nil {
+			return fmt.Errorf("getting profile for '%s': %w", id, err)
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return fmt.Errorf("decoding profile response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// IsServerBlocked checks if address (a host name or IP) is on
+// Mojang's blocked servers list, which holds SHA1 hashes of addresses
+// and wildcard patterns
+func (c *apiClient) IsServerBlocked(ctx context.Context, address string) (bool, error) {
+	var hashes []string
+	err := c.cached("blockedservers", &hashes, func() error {
+		body, err := c.send(ctx, http.MethodGet, c.cfg.Endpoints.BlockedServers, nil)
+		if err != nil {
+			return fmt.Errorf("getting blocked servers: %w", err)
+		}
+		hashes = strings.Fields(string(body))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	blocked := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		blocked[strings.ToLower(h)] = true
+	}
+	for _, candidate := range blockedServerCandidates(address) {
+		if blocked[checksum([]byte(candidate))] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// blockedServerCandidates returns the address and the wildcard patterns
+// matching it (`*.example.com` for host names and `10.0.0.*` for IPs)
+func blockedServerCandidates(address string) []string {
+	address = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(address), "."))
+	candidates := []string{address}
+	parts := strings.Split(address, ".")
+	if net.ParseIP(address).To4() != nil {
+		for i := len(parts) - 1; i > 0; i-- {
+			candidates = append(candidates, strings.Join(parts[:i], ".")+".*")
+		}
+		return candidates
+	}
+	for i := 1; i < len(parts); i++ {
+		candidates = append(candidates, "*."+strings.Join(parts[i:], "."))
+	}
+	return candidates
+}
+
+// cached decodes the cached entry for key into v, calling fetch to populate
+// v when there is no fresh entry. Stale entries are used when the API is
+// rate limiting requests.
+func (c *apiClient) cached(key string, v any, fetch func() error) error {
+	if c.cfg.Cache.Get(key, v, false) {
+		return nil
+	}
+	err := fetch()
+	if errors.Is(err, ErrRateLimited) && c.cfg.Cache.Get(key, v, true) {
+		logger.GetLogger().With("key", key).Warn("api rate limited, using stale cache entry")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_ = c.cfg.Cache.Set(key, v)
+	return nil
+}
+
+// send executes a request returning the response body
+func (c *apiClient) send(ctx context.Context, method, u string, payload []byte) ([]byte, error) {
+	res, err := c.sendRequest(ctx, method, u, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.body, nil
+}
+
+// httpResponse is a fully read HTTP response
+type httpResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// sendRequest executes a request, mapping failure status codes to typed
+// errors. When the API keeps rate limiting requests (after transport
+// retries) further requests to the same host fail with ErrRateLimited
+// until the Retry-After interval is over.
+func (c *apiClient) sendRequest(ctx context.Context, method, u string, payload []byte, header http.Header) (*httpResponse, error) {
+	limitKey := rateLimitCacheKey(u)
+	var until time.Time
+	if c.cfg.Cache.Get(limitKey, &until, false) && time.Now().Before(until) {
+		return nil, fmt.Errorf("waiting until %s: %w", until.Format(time.RFC3339), ErrRateLimited)
+	}
+
+	var body io.Reader = http.NoBody
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	if payload != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.client.Do(r)
 	if err != nil {
-		err = fmt.Errorf("getting users info: %w", err)
 		return nil, err
 	}
 	defer func() {
 		_ = res.Body.Close()
 	}()
 
-	var response UserIDResponse
-	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-		err = fmt.Errorf("decoding users info response: %w", err)
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		wait := retryAfter(res, time.Second)
+		_ = c.cfg.Cache.SetWithTTL(limitKey, time.Now().Add(wait), wait)
+		return nil, ErrRateLimited
+	case res.StatusCode == http.StatusNotFound, res.StatusCode == http.StatusNoContent:
+		return nil, ErrNotFound
+	case res.StatusCode == http.StatusNotModified:
+	case res.StatusCode/100 != 2:
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	return &httpResponse{status: res.StatusCode, header: res.Header, body: b}, nil
+}
+
+// httpCacheEntry is a cached response with its validators
+type httpCacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// fetchRevalidated gets u revalidating the cached response (using
+// `ETag` and `Last-Modified` validators). The cached response is
+// returned when the API can't be reached.
+func (c *apiClient) fetchRevalidated(ctx context.Context, u string) ([]byte, error) {
+	key := httpCacheKey(u)
+	var cached httpCacheEntry
+	hasCached := c.cfg.Cache.Get(key, &cached, true)
+
+	header := http.Header{}
+	if hasCached {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	res, err := c.sendRequest(ctx, http.MethodGet, u, nil, header)
+	if err != nil {
+		if hasCached && !errors.Is(err, ErrNotFound) && ctx.Err() == nil {
+			logger.GetLogger().With("url", u, "error", err).WarnContext(ctx, "Failed to revalidate cached response, using cached one")
+			return cached.Body, nil
+		}
 		return nil, err
 	}
 
-	return response, nil
+	if res.status == http.StatusNotModified {
+		if !hasCached {
+			return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, res.status)
+		}
+		_ = c.cfg.Cache.Set(key, cached)
+		return cached.Body, nil
+	}
+
+	_ = c.cfg.Cache.Set(key, httpCacheEntry{
+		ETag:         res.header.Get("ETag"),
+		LastModified: res.header.Get("Last-Modified"),
+		Body:         res.body,
+	})
+	return res.body, nil
+}
+
+func checksum(b []byte) string {
+	h := sha1.Sum(b)
+	return hex.EncodeToString(h[:])
 }
 
-func (c *apiClient) httpClient() http.Client {
-	return utils.HTTPClient(c.cfg.Timeout)
+func httpCacheKey(u string) string {
+	return "http:" + u
+}
+
+func userCacheKey(name string) string {
+	return "user:" + strings.ToLower(name)
+}
+
+func rateLimitCacheKey(u string) string {
+	host := u
+	if parsed, err := url.Parse(u); err == nil {
+		host = parsed.Host
+	}
+	return "ratelimit:" + host
 }
 
 func WithTimeout(d time.Duration) ClientOpt {
@@ -131,3 +448,51 @@ func WithTimeout(d time.Duration) ClientOpt {
 		return cfg
 	}
 }
+
+// WithMaxRetries defines how many times a rate limited (or failed) request is tried
+func WithMaxRetries(n int) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.MaxRetries = n
+		return cfg
+	}
+}
+
+// WithCache defines the cache used to keep API responses
+func WithCache(cache *FileCache) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.Cache = cache
+		return cfg
+	}
+}
+
+// WithTransport defines the http.RoundTripper executing requests
+func WithTransport(t http.RoundTripper) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.Transport = t
+		return cfg
+	}
+}
+
+// WithUserAgent defines the User-Agent header sent on requests
+func WithUserAgent(ua string) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.UserAgent = ua
+		return cfg
+	}
+}
+
+// WithRequestLog enables requests logging
+func WithRequestLog(enabled bool) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.LogRequests = enabled
+		return cfg
+	}
+}
+
+// WithEndpoints defines the API endpoints (useful for tests and mirrors)
+func WithEndpoints(e Endpoints) ClientOpt {
+	return func(cfg *ClientConfig) *ClientConfig {
+		cfg.Endpoints = e
+		return cfg
+	}
+}
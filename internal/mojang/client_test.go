@@ -2,31 +2,58 @@ package mojang
 
 import (
 	"context"
-	"github.com/h2non/gock"
+	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-func TestListVersions(t *testing.T) {
-	t.Run("given a simple version query should return success with the right data", func(t *testing.T) {
-		defer gock.Off() // Flush pending mocks after test execution
+// newTestServer starts a server answering requests with handler and
+// returns a client pointing every endpoint to it
+func newTestServer(t *testing.T, handler http.Handler, opts ...ClientOpt) (*httptest.Server, Client) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
 
-		gock.New("https://launchermeta.mojang.com").
-			Get("/mc/game/version_manifest.json").
-			Reply(200).
-			File("./samples/versions.json")
+	c := NewClient(append([]ClientOpt{
+		WithTimeout(1 * time.Second),
+		WithEndpoints(Endpoints{
+			Versions:       srv.URL + "/mc/game/version_manifest.json",
+			UsersInfoBulk:  srv.URL + "/minecraft/profile/lookup/bulk/byname",
+			UserInfo:       srv.URL + "/minecraft/profile/lookup/name/",
+			Profile:        srv.URL + "/session/minecraft/profile/",
+			BlockedServers: srv.URL + "/blockedservers",
+		}),
+	}, opts...)...)
+	return srv, c
+}
 
-		gock.New("https://piston-meta.mojang.com").
-			Get("/v1/packages/7efb232e2903bea16d7bf7b4a5ea768453cf92ea/1.20.json").
-			Reply(200).
-			File("./samples/1.20.json")
+func serveFile(t *testing.T, file string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}
+}
 
-		ctx := context.Background()
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
 
-		c := NewClient(WithTimeout(1 * time.Second))
+func TestListVersions(t *testing.T) {
+	t.Run("given a simple version query should return success with the right data", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/mc/game/version_manifest.json", serveFile(t, "./samples/versions.json"))
+		_, c := newTestServer(t, mux)
 
-		v, err := c.ListVersions(ctx)
+		v, err := c.ListVersions(context.Background())
 		assert.Nil(t, err)
 		assert.NotNil(t, v)
 		assert.Equal(t, 696, len(v.Versions))
@@ -41,22 +68,12 @@ func TestListVersions(t *testing.T) {
 	})
 
 	t.Run("given an specific version should return its info", func(t *testing.T) {
-		defer gock.Off() // Flush pending mocks after test execution
-
-		gock.New("https://launchermeta.mojang.com").
-			Get("/mc/game/version_manifest.json").
-			Reply(200).
-			File("./samples/versions.json")
-
-		gock.New("https://piston-meta.mojang.com").
-			Get("/v1/packages/7efb232e2903bea16d7bf7b4a5ea768453cf92ea/1.20.json").
-			Reply(200).
-			File("./samples/1.20.json")
+		mux := http.NewServeMux()
+		mux.HandleFunc("/mc/game/version_manifest.json", serveFile(t, "./samples/versions.json"))
+		mux.HandleFunc("/v1/packages/7efb232e2903bea16d7bf7b4a5ea768453cf92ea/1.20.json", serveFile(t, "./samples/1.20.json"))
+		srv, c := newTestServer(t, mux)
 
 		ctx := context.Background()
-
-		c := NewClient(WithTimeout(1 * time.Second))
-
 		v, err := c.ListVersions(ctx)
 		assert.Nil(t, err)
 		assert.NotNil(t, v)
@@ -64,7 +81,9 @@ func TestListVersions(t *testing.T) {
 		lr, err := v.GetLatestRelease()
 		assert.Nil(t, err)
 		assert.NotNil(t, lr)
+		assert.Equal(t, "https://piston-meta.mojang.com/v1/packages/7efb232e2903bea16d7bf7b4a5ea768453cf92ea/1.20.json", lr.URL)
 
+		lr.URL = srv.URL + "/v1/packages/7efb232e2903bea16d7bf7b4a5ea768453cf92ea/1.20.json"
 		info, err := c.GetVersionInfo(ctx, *lr)
 		assert.Nil(t, err)
 		assert.NotNil(t, info)
@@ -76,21 +95,302 @@ func TestListVersions(t *testing.T) {
 		assert.Equal(t, 17, info.JavaVersion.MajorVersion)
 	})
 
-	t.Run("given an specific version should return its info", func(t *testing.T) {
-		defer gock.Off() // Flush pending mocks after test execution
+	t.Run("given a slow server should fail with timeout", func(t *testing.T) {
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}), WithTimeout(100*time.Millisecond))
 
-		gock.New("https://launchermeta.mojang.com").
-			Get("/mc/game/version_manifest.json").
-			Reply(200).
-			Delay(5 * time.Second).
-			File("./samples/versions.json")
+		v, err := c.ListVersions(context.Background())
+		assert.NotNil(t, err)
+		assert.Nil(t, v)
+	})
 
-		ctx := context.Background()
+	t.Run("given slow attempts should limit each one to the timeout", func(t *testing.T) {
+		var calls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				// the retry wait isn't part of the attempts timeout
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			serveFile(t, "./samples/versions.json")(w, r)
+		}), WithTimeout(500*time.Millisecond), WithMaxRetries(2))
 
-		c := NewClient(WithTimeout(1 * time.Second))
+		v, err := c.ListVersions(context.Background())
+		assert.NoError(t, err)
+		assert.NotNil(t, v)
+		assert.Equal(t, int32(2), calls.Load())
+	})
 
-		v, err := c.ListVersions(ctx)
-		assert.NotNil(t, err)
+	t.Run("given a server error should retry and return a typed error", func(t *testing.T) {
+		var calls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusInternalServerError)
+		}), WithMaxRetries(2))
+
+		v, err := c.ListVersions(context.Background())
+		assert.ErrorIs(t, err, ErrUnexpectedStatus)
 		assert.Nil(t, v)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestGetUsersInfo(t *testing.T) {
+	t.Run("given more than 10 users should query the bulk endpoint in chunks", func(t *testing.T) {
+		var users []string
+		for i := 0; i < 12; i++ {
+			users = append(users, fmt.Sprintf("user%02d", i))
+		}
+
+		var secondChunkCalls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/minecraft/profile/lookup/bulk/byname", r.URL.Path)
+			assert.Equal(t, DefaultUserAgent, r.Header.Get("User-Agent"))
+
+			var names []string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&names))
+			switch {
+			case assert.ObjectsAreEqual(users[:10], names):
+				writeJSON(w, []UserID{{ID: "853c80ef3c3749fdaa49938b674adae6", Name: "user00"}})
+			case assert.ObjectsAreEqual(users[10:], names):
+				if secondChunkCalls.Add(1) == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				writeJSON(w, []UserID{{ID: "0f0a3c3b5f6e4b8e9a3f0f6a1d2c3b4a", Name: "user11"}})
+			default:
+				t.Errorf("unexpected names: %v", names)
+			}
+		}))
+
+		res, err := c.GetUsersInfo(context.Background(), users...)
+		assert.Nil(t, err)
+		assert.Len(t, res, 2)
+		assert.Equal(t, "853c80ef-3c37-49fd-aa49-938b674adae6", res[0].UUID())
+		assert.Equal(t, "0f0a3c3b-5f6e-4b8e-9a3f-0f6a1d2c3b4a", res[1].UUID())
+		assert.Equal(t, int32(2), secondChunkCalls.Load())
+	})
+
+	t.Run("given a canceled context should not query the API", func(t *testing.T) {
+		var calls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := c.GetUsersInfo(ctx, "Notch")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int32(0), calls.Load())
+	})
+}
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, r)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestGetProfile(t *testing.T) {
+	t.Run("given a player UUID should return its profile with decoded textures", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/session/minecraft/profile/069a79f444e94726a5befca90e38aaf5", func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "false", r.URL.Query().Get("unsigned"))
+			serveFile(t, "./samples/profile.json")(w, r)
+		})
+		rt := &recordingTransport{}
+		_, c := newTestServer(t, mux, WithTransport(rt), WithUserAgent("mineserver-test"))
+
+		p, err := c.GetProfile(context.Background(), "069a79f4-44e9-4726-a5be-fca90e38aaf5")
+		assert.Nil(t, err)
+		assert.Equal(t, "Notch", p.Name)
+		assert.Equal(t, "069a79f4-44e9-4726-a5be-fca90e38aaf5", p.UUID())
+
+		prop, ok := p.Property("textures")
+		assert.True(t, ok)
+		assert.Equal(t, "c2lnbmF0dXJl", prop.Signature)
+
+		textures, err := p.Textures()
+		assert.Nil(t, err)
+		assert.Equal(t, "http://textures.minecraft.net/texture/292009a4925b58f02c77dadc3ecef07ea4c7472f64e0fdc32ce5522489362680", textures.SkinURL())
+		assert.Equal(t, "http://textures.minecraft.net/texture/2340c0e03dd24a11b15a8b33c2a7e9e32abb2051b2481d0ba7defd635ca7a933", textures.CapeURL())
+		assert.Equal(t, "classic", textures.SkinModel())
+
+		assert.Len(t, rt.requests, 1)
+		assert.Equal(t, "mineserver-test", rt.requests[0].Header.Get("User-Agent"))
+	})
+
+	t.Run("given an unknown player UUID should return not found", func(t *testing.T) {
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		_, err := c.GetProfile(context.Background(), "00000000-0000-0000-0000-000000000000")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestGetUserInfo(t *testing.T) {
+	t.Run("given a cache should only query the API once", func(t *testing.T) {
+		var calls atomic.Int32
+		mux := http.NewServeMux()
+		mux.HandleFunc("/minecraft/profile/lookup/name/Notch", func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			writeJSON(w, UserID{ID: "069a79f444e94726a5befca90e38aaf5", Name: "Notch"})
+		})
+		_, c := newTestServer(t, mux, WithCache(NewFileCache(t.TempDir(), time.Hour)))
+
+		for i := 0; i < 2; i++ {
+			id, err := c.GetUserInfo(context.Background(), "Notch")
+			assert.Nil(t, err)
+			assert.Equal(t, "069a79f4-44e9-4726-a5be-fca90e38aaf5", id.UUID())
+		}
+
+		res, err := c.GetUsersInfo(context.Background(), "notch")
+		assert.Nil(t, err)
+		assert.Len(t, res, 1)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("given a rate limited API should use stale entries and wait for Retry-After", func(t *testing.T) {
+		cache := NewFileCache(t.TempDir(), time.Hour)
+		assert.NoError(t, cache.SetWithTTL(userCacheKey("Notch"), UserID{ID: "069a79f444e94726a5befca90e38aaf5", Name: "Notch"}, -time.Minute))
+
+		var calls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}), WithMaxRetries(1), WithCache(cache))
+
+		id, err := c.GetUserInfo(context.Background(), "Notch")
+		assert.Nil(t, err)
+		assert.Equal(t, "Notch", id.Name)
+
+		_, err = c.GetUserInfo(context.Background(), "jeb_")
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("given a Retry-After past the request deadline should not wait for it", func(t *testing.T) {
+		cache := NewFileCache(t.TempDir(), time.Hour)
+		assert.NoError(t, cache.SetWithTTL(userCacheKey("Notch"), UserID{ID: "069a79f444e94726a5befca90e38aaf5", Name: "Notch"}, -time.Minute))
+
+		var calls atomic.Int32
+		_, c := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}), WithMaxRetries(3), WithCache(cache))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		start := time.Now()
+		id, err := c.GetUserInfo(ctx, "Notch")
+		assert.Nil(t, err)
+		assert.Equal(t, "Notch", id.Name)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestIsServerBlocked(t *testing.T) {
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blockedservers", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte("950ab7ca6ed37d1395b694584453c54116a9b451\naa9198662aa821b38228ff65342b7f06a40e7747\n"))
+	})
+	_, c := newTestServer(t, mux, WithCache(NewFileCache(t.TempDir(), time.Hour)))
+
+	for address, expected := range map[string]bool{
+		"mc.blocked.example.com": true,
+		"blocked.example.com":    false,
+		"10.0.0.15":              true,
+		"10.0.1.15":              false,
+		"play.example.org":       false,
+	} {
+		t.Run(fmt.Sprintf("given %s should return %t", address, expected), func(t *testing.T) {
+			blocked, err := c.IsServerBlocked(context.Background(), address)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, blocked)
+		})
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestListVersions_Cache(t *testing.T) {
+	t.Run("given a cached manifest should revalidate it with ETag", func(t *testing.T) {
+		var calls, notModified atomic.Int32
+		mux := http.NewServeMux()
+		mux.HandleFunc("/mc/game/version_manifest.json", func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			if r.Header.Get("If-None-Match") == `"manifest-v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"manifest-v1"`)
+			serveFile(t, "./samples/versions.json")(w, r)
+		})
+		_, c := newTestServer(t, mux, WithCache(NewFileCache(t.TempDir(), time.Hour)))
+
+		for i := 0; i < 2; i++ {
+			v, err := c.ListVersions(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, 696, len(v.Versions))
+		}
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, int32(1), notModified.Load())
+	})
+
+	t.Run("given an offline API should use cached manifest and version info", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/mc/game/version_manifest.json", serveFile(t, "./samples/versions.json"))
+		mux.HandleFunc("/1.20.json", serveFile(t, "./samples/1.20.json"))
+		cache := NewFileCache(t.TempDir(), time.Hour)
+		srv, c := newTestServer(t, mux, WithCache(cache), WithMaxRetries(1))
+
+		ctx := context.Background()
+		v, err := c.ListVersions(ctx)
+		assert.Nil(t, err)
+		lr, err := v.GetLatestRelease()
+		assert.Nil(t, err)
+		lr.URL = srv.URL + "/1.20.json"
+		_, err = c.GetVersionInfo(ctx, *lr)
+		assert.Nil(t, err)
+
+		srv.Close()
+
+		v, err = c.ListVersions(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.20", v.Latest.Release)
+		info, err := c.GetVersionInfo(ctx, *lr)
+		assert.Nil(t, err)
+		assert.Equal(t, "1.20", info.ID)
+	})
+
+	t.Run("given a version info not matching its hash should fail", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/1.20.json", serveFile(t, "./samples/1.20.json"))
+		srv, c := newTestServer(t, mux)
+
+		_, err := c.GetVersionInfo(context.Background(), Version{
+			ID:   "1.20",
+			URL:  srv.URL + "/1.20.json",
+			SHA1: "0000000000000000000000000000000000000000",
+		})
+		assert.ErrorIs(t, err, ErrChecksumMismatch)
 	})
 }
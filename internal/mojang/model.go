@@ -1,7 +1,12 @@
 package mojang
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
@@ -10,8 +15,13 @@ const (
 )
 
 const (
-	VersionsURL      = "https://launchermeta.mojang.com/mc/game/version_manifest.json"
+	VersionsURL      = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
 	UsersInfoBulkURL = "https://api.minecraftservices.com/minecraft/profile/lookup/bulk/byname"
+	// UsersInfoBulkMaxSize is the max number of names accepted by bulk endpoint
+	UsersInfoBulkMaxSize = 10
+	UserInfoURL          = "https://api.minecraftservices.com/minecraft/profile/lookup/name/"
+	ProfileURL           = "https://sessionserver.mojang.com/session/minecraft/profile/"
+	BlockedServersURL    = "https://sessionserver.mojang.com/blockedservers"
 	//LatestVersion = "latest"
 )
 
@@ -34,6 +44,9 @@ type Version struct {
 	URL         string    `json:"url"`
 	Time        time.Time `json:"time"`
 	ReleaseTime time.Time `json:"releaseTime"`
+	// SHA1 is the version info file hash (only on manifest v2)
+	SHA1            string `json:"sha1,omitempty"`
+	ComplianceLevel int    `json:"complianceLevel,omitempty"`
 }
 
 // VersionInfoResponse is a specific version info
@@ -97,3 +110,125 @@ type UserID struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
+
+// UUID returns the user ID in its dashed form
+// (the API returns it without dashes)
+func (u UserID) UUID() string {
+	id, err := uuid.Parse(u.ID)
+	if err != nil {
+		return u.ID
+	}
+	return id.String()
+}
+
+// Profile is a player profile from session server
+type Profile struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Properties     []ProfileProperty `json:"properties"`
+	ProfileActions []string          `json:"profileActions,omitempty"`
+}
+
+// ProfileProperty is a signed profile property (like textures)
+type ProfileProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Signature is the Yggdrasil signature for Value (base64 encoded)
+	Signature string `json:"signature,omitempty"`
+}
+
+// ProfileTextures is the decoded `textures` profile property
+type ProfileTextures struct {
+	Timestamp         int64    `json:"timestamp"`
+	ProfileID         string   `json:"profileId"`
+	ProfileName       string   `json:"profileName"`
+	SignatureRequired bool     `json:"signatureRequired,omitempty"`
+	Textures          Textures `json:"textures"`
+}
+
+// Textures holds the player skin and cape
+type Textures struct {
+	Skin *Texture `json:"SKIN,omitempty"`
+	Cape *Texture `json:"CAPE,omitempty"`
+}
+
+// Texture is a texture file reference
+type Texture struct {
+	URL      string           `json:"url"`
+	Metadata *TextureMetadata `json:"metadata,omitempty"`
+}
+
+// TextureMetadata is the skin metadata
+type TextureMetadata struct {
+	// Model is `slim` for the Alex model (empty means the classic Steve model)
+	Model string `json:"model"`
+}
+
+const (
+	texturesPropertyName = "textures"
+)
+
+var (
+	ErrTexturesNotFound = errors.New("profile has no textures")
+)
+
+// UUID returns the profile ID in its dashed form
+func (p *Profile) UUID() string {
+	return UserID{ID: p.ID, Name: p.Name}.UUID()
+}
+
+// Property returns the profile property with name
+func (p *Profile) Property(name string) (*ProfileProperty, bool) {
+	for _, prop := range p.Properties {
+		if prop.Name == name {
+			return &prop, true
+		}
+	}
+	return nil, false
+}
+
+// Textures decodes the profile `textures` property
+func (p *Profile) Textures() (*ProfileTextures, error) {
+	prop, ok := p.Property(texturesPropertyName)
+	if !ok {
+		return nil, ErrTexturesNotFound
+	}
+	b, err := base64.StdEncoding.DecodeString(prop.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding textures property: %w", err)
+	}
+	var textures ProfileTextures
+	if err := json.Unmarshal(b, &textures); err != nil {
+		return nil, fmt.Errorf("parsing textures property: %w", err)
+	}
+	return &textures, nil
+}
+
+// SkinURL returns the player skin URL (empty for default skins)
+func (t *ProfileTextures) SkinURL() string {
+	if t.Textures.Skin == nil {
+		return ""
+	}
+	return t.Textures.Skin.URL
+}
+
+// CapeURL returns the player cape URL (empty when there is no cape)
+func (t *ProfileTextures) CapeURL() string {
+	if t.Textures.Cape == nil {
+		return ""
+	}
+	return t.Textures.Cape.URL
+}
+
+// SkinModel returns the player skin model (`slim` or `classic`)
+func (t *ProfileTextures) SkinModel() string {
+	if t.Textures.Skin != nil && t.Textures.Skin.Metadata != nil && t.Textures.Skin.Metadata.Model != "" {
+		return t.Textures.Skin.Metadata.Model
+	}
+	return "classic"
+}
+
+// undashedUUID returns id without dashes, as expected by session server
+func undashedUUID(id string) string {
+	return strings.ReplaceAll(id, "-", "")
+}
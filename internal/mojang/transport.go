@@ -0,0 +1,149 @@
+package mojang
+
+import (
+	"context"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultUserAgent = "mineserver-manager"
+)
+
+// transport decorates a http.RoundTripper setting the user agent,
+// limiting each attempt duration, retrying rate limited and failed
+// requests and logging requests
+type transport struct {
+	next        http.RoundTripper
+	timeout     time.Duration
+	userAgent   string
+	maxRetries  int
+	logRequests bool
+}
+
+func newTransport(cfg ClientConfig) http.RoundTripper {
+	next := cfg.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{
+		next:        next,
+		timeout:     cfg.Timeout,
+		userAgent:   cfg.UserAgent,
+		maxRetries:  cfg.MaxRetries,
+		logRequests: cfg.LogRequests,
+	}
+}
+
+// RoundTrip executes a request, retrying it up to maxRetries times
+// when it's rate limited (429) or fails with a server error (5xx).
+// The last response is returned when every attempt fails, or right
+// away when the request deadline is over before the retry wait.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewinding request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		start := time.Now()
+		res, err := t.attempt(req)
+		t.log(req, res, err, attempt, time.Since(start))
+		if err != nil {
+			return nil, err
+		}
+		if !shouldRetry(res) || attempt >= t.maxRetries {
+			return res, nil
+		}
+
+		wait := retryAfter(res, time.Duration(attempt)*time.Second)
+		if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res, nil
+		}
+		_ = res.Body.Close()
+		logger.GetLogger().With("attempt", attempt, "wait", wait.String(), "url", req.URL.String(), "status", res.StatusCode).
+			WarnContext(req.Context(), "Retrying request")
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt executes a single request attempt limited by timeout, which
+// keeps running until the response body is closed
+func (t *transport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	res, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelBody cancels the attempt context when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (t *transport) log(req *http.Request, res *http.Response, err error, attempt int, elapsed time.Duration) {
+	if !t.logRequests {
+		return
+	}
+	log := logger.GetLogger().With(
+		"method", req.Method,
+		"url", req.URL.String(),
+		"attempt", attempt,
+		"elapsed", elapsed.String(),
+	)
+	if err != nil {
+		log.With("error", err).InfoContext(req.Context(), "Request failed")
+		return
+	}
+	log.With("status", res.StatusCode).InfoContext(req.Context(), "Request executed")
+}
+
+func shouldRetry(res *http.Response) bool {
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+}
+
+// retryAfter parses the Retry-After header (in seconds), using def if it's missing
+func retryAfter(res *http.Response, def time.Duration) time.Duration {
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	return def
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
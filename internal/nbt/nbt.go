@@ -0,0 +1,557 @@
+package nbt
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Tag is a NBT tag type
+type Tag byte
+
+const (
+	TagEnd Tag = iota
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
+
+	// MaxDepth is how deep compounds and lists can be nested
+	MaxDepth = 512
+)
+
+// Compression is how NBT data is compressed
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZlib
+)
+
+var (
+	ErrInvalidTag = errors.New("invalid nbt tag")
+)
+
+// Compound is a NBT compound, tag values are mapped to Go types:
+// int8 (byte), int16, int32, int64, float32, float64, []int8 (byte
+// array), string, List, Compound, []int32 and []int64
+type Compound map[string]any
+
+// List is a NBT list, all values have the Type tag
+type List struct {
+	Type   Tag
+	Values []any
+}
+
+// Get returns the value at the path of nested compounds, when it has
+// the T type
+func Get[T any](c Compound, path ...string) (T, bool) {
+	var zero T
+	var v any = c
+	for _, name := range path {
+		parent, ok := v.(Compound)
+		if !ok {
+			return zero, false
+		}
+		if v, ok = parent[name]; !ok {
+			return zero, false
+		}
+	}
+	t, ok := v.(T)
+	return t, ok
+}
+
+// Int returns the integer (of any size) at the path of nested compounds
+func Int(c Compound, path ...string) (int64, bool) {
+	v, ok := Get[any](c, path...)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// TagOf returns the tag type of a value, TagEnd for unsupported types
+func TagOf(v any) Tag {
+	switch v.(type) {
+	case int8:
+		return TagByte
+	case int16:
+		return TagShort
+	case int32:
+		return TagInt
+	case int64:
+		return TagLong
+	case float32:
+		return TagFloat
+	case float64:
+		return TagDouble
+	case []int8:
+		return TagByteArray
+	case string:
+		return TagString
+	case List:
+		return TagList
+	case Compound:
+		return TagCompound
+	case []int32:
+		return TagIntArray
+	case []int64:
+		return TagLongArray
+	default:
+		return TagEnd
+	}
+}
+
+// ReadFile reads a (gzip, zlib or not compressed) NBT file root compound
+func ReadFile(file string) (Compound, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("opening nbt file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	_, root, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s': %w", filepath.Base(file), err)
+	}
+	return root, nil
+}
+
+// Decode reads a NBT root compound (and its name), detecting gzip and
+// zlib compressed data
+func Decode(r io.Reader) (string, Compound, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading nbt data: %w", noEOF(err))
+	}
+	var in io.Reader = br
+	switch {
+	case magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return "", nil, fmt.Errorf("decompressing nbt data: %w", err)
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		in = bufio.NewReader(gz)
+	case magic[0] == 0x78:
+		z, err := zlib.NewReader(br)
+		if err != nil {
+			return "", nil, fmt.Errorf("decompressing nbt data: %w", err)
+		}
+		defer func() {
+			_ = z.Close()
+		}()
+		in = bufio.NewReader(z)
+	}
+	return Read(in)
+}
+
+// Read reads a not compressed NBT root compound (and its name).
+// Strings are read as UTF-8 (Java modified UTF-8 only differs on NUL
+// and supplementary characters).
+func Read(r io.Reader) (string, Compound, error) {
+	d := &decoder{r: r}
+	t, err := d.tag()
+	if err != nil {
+		return "", nil, fmt.Errorf("reading nbt data: %w", err)
+	}
+	if t != TagCompound {
+		return "", nil, fmt.Errorf("%w: root tag is %d, not a compound", ErrInvalidTag, t)
+	}
+	name, err := d.string()
+	if err != nil {
+		return "", nil, fmt.Errorf("reading nbt data: %w", err)
+	}
+	v, err := d.payload(TagCompound, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading nbt data: %w", err)
+	}
+	return name, v.(Compound), nil
+}
+
+type decoder struct {
+	r   io.Reader
+	buf [8]byte
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		return nil, noEOF(err)
+	}
+	return d.buf[:n], nil
+}
+
+func (d *decoder) tag() (Tag, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return Tag(b[0]), nil
+}
+
+func (d *decoder) int16() (int16, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *decoder) int32() (int32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (d *decoder) int64() (int64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (d *decoder) length() (int, error) {
+	n, err := d.int32()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid nbt length: %d", n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) string() (string, error) {
+	n, err := d.int16()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, uint16(n))
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", noEOF(err)
+	}
+	return string(b), nil
+}
+
+// capacity limits the preallocated size of arrays, so corrupt lengths
+// fail on EOF instead of allocating huge arrays
+func capacity(n int) int {
+	return min(n, 1<<16)
+}
+
+func (d *decoder) payload(t Tag, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, errors.New("nbt nesting too deep")
+	}
+	switch t {
+	case TagByte:
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return int8(b[0]), nil
+	case TagShort:
+		return d.int16()
+	case TagInt:
+		return d.int32()
+	case TagLong:
+		return d.int64()
+	case TagFloat:
+		n, err := d.int32()
+		return math.Float32frombits(uint32(n)), err
+	case TagDouble:
+		n, err := d.int64()
+		return math.Float64frombits(uint64(n)), err
+	case TagByteArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.Grow(capacity(n))
+		if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+			return nil, noEOF(err)
+		}
+		values := make([]int8, n)
+		for i, v := range buf.Bytes() {
+			values[i] = int8(v)
+		}
+		return values, nil
+	case TagString:
+		return d.string()
+	case TagList:
+		elem, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		if elem == TagEnd && n > 0 || elem > TagLongArray {
+			return nil, fmt.Errorf("%w: list of %d", ErrInvalidTag, elem)
+		}
+		l := List{Type: elem, Values: make([]any, 0, capacity(n))}
+		for range n {
+			v, err := d.payload(elem, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			l.Values = append(l.Values, v)
+		}
+		return l, nil
+	case TagCompound:
+		c := make(Compound)
+		for {
+			t, err := d.tag()
+			if err != nil {
+				return nil, err
+			}
+			if t == TagEnd {
+				return c, nil
+			}
+			name, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.payload(t, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("'%s': %w", name, err)
+			}
+			c[name] = v
+		}
+	case TagIntArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int32, 0, capacity(n))
+		for range n {
+			v, err := d.int32()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case TagLongArray:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]int64, 0, capacity(n))
+		for range n {
+			v, err := d.int64()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrInvalidTag, t)
+	}
+}
+
+// WriteFile writes a NBT file with the root compound
+func WriteFile(file, name string, root Compound, c Compression) error {
+	var b bytes.Buffer
+	if err := Encode(&b, name, root, c); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing nbt file: %w", err)
+	}
+	return nil
+}
+
+// Encode writes a (compressed) NBT root compound
+func Encode(w io.Writer, name string, root Compound, c Compression) error {
+	var out io.WriteCloser
+	switch c {
+	case CompressionNone:
+		return Write(w, name, root)
+	case CompressionGzip:
+		out = gzip.NewWriter(w)
+	case CompressionZlib:
+		out = zlib.NewWriter(w)
+	default:
+		return fmt.Errorf("invalid nbt compression: %d", c)
+	}
+	if err := Write(out, name, root); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("compressing nbt data: %w", err)
+	}
+	return nil
+}
+
+// Write writes a not compressed NBT root compound, compound tags are
+// written sorted by name
+func Write(w io.Writer, name string, root Compound) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+	e.tag(TagCompound)
+	if err := e.string(name); err != nil {
+		return fmt.Errorf("writing nbt data: root name: %w", err)
+	}
+	if err := e.payload(root, 0); err != nil {
+		return fmt.Errorf("writing nbt data: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing nbt data: %w", err)
+	}
+	return nil
+}
+
+// encoder writes to a bufio.Writer, so write errors are only checked
+// on Flush
+type encoder struct {
+	w   *bufio.Writer
+	buf [8]byte
+}
+
+func (e *encoder) tag(t Tag) {
+	_ = e.w.WriteByte(byte(t))
+}
+
+func (e *encoder) int16(v int16) {
+	binary.BigEndian.PutUint16(e.buf[:2], uint16(v))
+	_, _ = e.w.Write(e.buf[:2])
+}
+
+func (e *encoder) int32(v int32) {
+	binary.BigEndian.PutUint32(e.buf[:4], uint32(v))
+	_, _ = e.w.Write(e.buf[:4])
+}
+
+func (e *encoder) int64(v int64) {
+	binary.BigEndian.PutUint64(e.buf[:8], uint64(v))
+	_, _ = e.w.Write(e.buf[:8])
+}
+
+// string writes a string (or a tag name), failing when it's too long
+// for its uint16 length
+func (e *encoder) string(s string) error {
+	if len(s) > math.MaxUint16 {
+		return fmt.Errorf("string too long: %d bytes", len(s))
+	}
+	e.int16(int16(uint16(len(s))))
+	_, _ = e.w.WriteString(s)
+	return nil
+}
+
+func (e *encoder) payload(v any, depth int) error {
+	if depth > MaxDepth {
+		return errors.New("nbt nesting too deep")
+	}
+	switch v := v.(type) {
+	case int8:
+		_ = e.w.WriteByte(byte(v))
+	case int16:
+		e.int16(v)
+	case int32:
+		e.int32(v)
+	case int64:
+		e.int64(v)
+	case float32:
+		e.int32(int32(math.Float32bits(v)))
+	case float64:
+		e.int64(int64(math.Float64bits(v)))
+	case []int8:
+		e.int32(int32(len(v)))
+		for _, b := range v {
+			_ = e.w.WriteByte(byte(b))
+		}
+	case string:
+		return e.string(v)
+	case List:
+		t := v.Type
+		if len(v.Values) == 0 {
+			t = TagEnd
+		}
+		e.tag(t)
+		e.int32(int32(len(v.Values)))
+		for i, item := range v.Values {
+			if TagOf(item) != v.Type {
+				return fmt.Errorf("%w: list item %d is not a %d tag", ErrInvalidTag, i, v.Type)
+			}
+			if err := e.payload(item, depth+1); err != nil {
+				return err
+			}
+		}
+	case Compound:
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			t := TagOf(v[name])
+			if t == TagEnd {
+				return fmt.Errorf("%w: '%s' has an unsupported %T value", ErrInvalidTag, name, v[name])
+			}
+			e.tag(t)
+			if err := e.string(name); err != nil {
+				return fmt.Errorf("name: %w", err)
+			}
+			if err := e.payload(v[name], depth+1); err != nil {
+				return fmt.Errorf("'%s': %w", name, err)
+			}
+		}
+		e.tag(TagEnd)
+	case []int32:
+		e.int32(int32(len(v)))
+		for _, n := range v {
+			e.int32(n)
+		}
+	case []int64:
+		e.int32(int32(len(v)))
+		for _, n := range v {
+			e.int64(n)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported %T value", ErrInvalidTag, v)
+	}
+	return nil
+}
+
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
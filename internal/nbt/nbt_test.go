@@ -0,0 +1,121 @@
+package nbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sample() Compound {
+	return Compound{
+		"Data": Compound{
+			"LevelName":   "my world",
+			"DataVersion": int32(4189),
+			"RandomSeed":  int64(-4172144997902289642),
+			"hardcore":    int8(1),
+			"Difficulty":  int8(2),
+			"Health":      int16(20),
+			"BorderSize":  float64(5.9999968e7),
+			"Speed":       float32(0.5),
+			"Spawn":       []int32{10, 64, -20},
+			"Seeds":       []int64{1, -1},
+			"Bytes":       []int8{-1, 0, 1},
+			"ServerBrands": List{Type: TagString, Values: []any{
+				"vanilla",
+				`say "hi"`,
+			}},
+			"Empty":     List{Type: TagEnd, Values: []any{}},
+			"GameRules": Compound{"keepInventory": "true", "doDaylightCycle": "false"},
+		},
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	for name, c := range map[string]Compression{
+		"not compressed":  CompressionNone,
+		"gzip compressed": CompressionGzip,
+		"zlib compressed": CompressionZlib,
+	} {
+		t.Run("given "+name+" nbt data should read what was written", func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, Encode(&b, "root", sample(), c))
+
+			rootName, root, err := Decode(&b)
+			require.NoError(t, err)
+			assert.Equal(t, "root", rootName)
+			assert.Equal(t, sample(), root)
+		})
+	}
+
+	t.Run("given a nbt file should read its root compound", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "level.dat")
+		require.NoError(t, WriteFile(file, "", sample(), CompressionGzip))
+
+		root, err := ReadFile(file)
+		require.NoError(t, err)
+		seed, ok := Int(root, "Data", "RandomSeed")
+		assert.True(t, ok)
+		assert.Equal(t, int64(-4172144997902289642), seed)
+		hardcore, ok := Int(root, "Data", "hardcore")
+		assert.True(t, ok)
+		assert.Equal(t, int64(1), hardcore)
+		rule, ok := Get[string](root, "Data", "GameRules", "keepInventory")
+		assert.True(t, ok)
+		assert.Equal(t, "true", rule)
+		_, ok = Get[string](root, "Data", "LevelName", "missing")
+		assert.False(t, ok)
+	})
+
+	t.Run("given truncated nbt data should fail", func(t *testing.T) {
+		var b bytes.Buffer
+		require.NoError(t, Write(&b, "", sample()))
+
+		_, _, err := Read(bytes.NewReader(b.Bytes()[:b.Len()-10]))
+		assert.ErrorContains(t, err, "unexpected EOF")
+	})
+
+	t.Run("given invalid nbt data should fail", func(t *testing.T) {
+		_, _, err := Read(bytes.NewReader([]byte{byte(TagString), 0, 0}))
+		assert.ErrorIs(t, err, ErrInvalidTag)
+
+		var b bytes.Buffer
+		b.Write([]byte{byte(TagCompound), 0, 0, byte(TagIntArray), 0, 1, 'a'})
+		_ = binary.Write(&b, binary.BigEndian, int32(-1))
+		_, _, err = Read(&b)
+		assert.ErrorContains(t, err, "invalid nbt length")
+
+		err = Write(&bytes.Buffer{}, "", Compound{"n": 1})
+		assert.ErrorIs(t, err, ErrInvalidTag)
+		err = Write(&bytes.Buffer{}, "", Compound{"l": List{Type: TagInt, Values: []any{"a"}}})
+		assert.ErrorIs(t, err, ErrInvalidTag)
+
+		long := strings.Repeat("a", math.MaxUint16+1)
+		assert.ErrorContains(t, Write(&bytes.Buffer{}, "", Compound{long: int32(1)}), "string too long")
+		assert.ErrorContains(t, Write(&bytes.Buffer{}, "", Compound{"s": long}), "string too long")
+		assert.ErrorContains(t, Write(&bytes.Buffer{}, long, Compound{}), "string too long")
+		assert.NoError(t, Write(&bytes.Buffer{}, "", Compound{"s": long[1:]}))
+	})
+}
+
+func TestSNBT(t *testing.T) {
+	t.Run("given tag values should render them as snbt", func(t *testing.T) {
+		assert.Equal(t,
+			`{Data:{BorderSize:59999968d,Bytes:[B;-1b,0b,1b],DataVersion:4189,Difficulty:2b,Empty:[],GameRules:{doDaylightCycle:"false",keepInventory:"true"},Health:20s,LevelName:"my world",RandomSeed:-4172144997902289642L,Seeds:[L;1L,-1L],ServerBrands:["vanilla",'say "hi"'],Spawn:[I;10,64,-20],Speed:0.5f,hardcore:1b}}`,
+			SNBT(sample()),
+		)
+		assert.Equal(t, `{"my key":"a\"b'c"}`, SNBT(Compound{"my key": `a"b'c`}))
+	})
+
+	t.Run("given an indent should render compounds and lists on multiple lines", func(t *testing.T) {
+		assert.Equal(t, "{\n  a: 1,\n  b: [\n    \"x\",\n    \"y\"\n  ],\n  c: {}\n}", IndentSNBT(Compound{
+			"a": int32(1),
+			"b": List{Type: TagString, Values: []any{"x", "y"}},
+			"c": Compound{},
+		}, "  "))
+	})
+}
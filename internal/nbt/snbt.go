@@ -0,0 +1,131 @@
+package nbt
+
+import (
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// unquotedName matches compound names written without quotes
+	unquotedName = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+)
+
+// SNBT renders a tag value as SNBT (stringified NBT, the text format
+// used by game commands), on a single line
+func SNBT(v any) string {
+	return IndentSNBT(v, "")
+}
+
+// IndentSNBT renders a tag value as SNBT, compounds and lists items
+// get their own lines (indented by indent) when indent isn't empty.
+// Compound names are sorted.
+func IndentSNBT(v any, indent string) string {
+	var b strings.Builder
+	writeSNBT(&b, v, indent, 0)
+	return b.String()
+}
+
+func writeSNBT(b *strings.Builder, v any, indent string, depth int) {
+	newline := func(depth int) {
+		if indent != "" {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat(indent, depth))
+		}
+	}
+	separator := func(i int) {
+		if i > 0 {
+			b.WriteByte(',')
+			if indent == "" {
+				return
+			}
+		}
+		newline(depth + 1)
+	}
+
+	switch v := v.(type) {
+	case int8:
+		b.WriteString(strconv.FormatInt(int64(v), 10) + "b")
+	case int16:
+		b.WriteString(strconv.FormatInt(int64(v), 10) + "s")
+	case int32:
+		b.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10) + "L")
+	case float32:
+		b.WriteString(formatFloat(float64(v), 32) + "f")
+	case float64:
+		b.WriteString(formatFloat(v, 64) + "d")
+	case string:
+		b.WriteString(quote(v))
+	case []int8:
+		writeArray(b, "B", v, func(n int8) string { return strconv.FormatInt(int64(n), 10) + "b" })
+	case []int32:
+		writeArray(b, "I", v, func(n int32) string { return strconv.FormatInt(int64(n), 10) })
+	case []int64:
+		writeArray(b, "L", v, func(n int64) string { return strconv.FormatInt(n, 10) + "L" })
+	case List:
+		b.WriteByte('[')
+		for i, item := range v.Values {
+			separator(i)
+			writeSNBT(b, item, indent, depth+1)
+		}
+		if len(v.Values) > 0 {
+			newline(depth)
+		}
+		b.WriteByte(']')
+	case Compound:
+		b.WriteByte('{')
+		for i, name := range slices.Sorted(maps.Keys(v)) {
+			separator(i)
+			if unquotedName.MatchString(name) {
+				b.WriteString(name)
+			} else {
+				b.WriteString(quote(name))
+			}
+			b.WriteByte(':')
+			if indent != "" {
+				b.WriteByte(' ')
+			}
+			writeSNBT(b, v[name], indent, depth+1)
+		}
+		if len(v) > 0 {
+			newline(depth)
+		}
+		b.WriteByte('}')
+	}
+}
+
+// formatFloat formats floats without exponents, but the very large
+// or small ones
+func formatFloat(v float64, bits int) string {
+	if a := math.Abs(v); a != 0 && (a < 1e-4 || a >= 1e16) {
+		return strconv.FormatFloat(v, 'e', -1, bits)
+	}
+	return strconv.FormatFloat(v, 'f', -1, bits)
+}
+
+func writeArray[T any](b *strings.Builder, prefix string, values []T, format func(T) string) {
+	b.WriteString("[" + prefix + ";")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(format(v))
+	}
+	b.WriteByte(']')
+}
+
+// quote quotes a SNBT string, with single quotes when it holds double
+// quotes (like the game does)
+func quote(s string) string {
+	q := `"`
+	if strings.Contains(s, `"`) && !strings.Contains(s, "'") {
+		q = "'"
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return q + strings.ReplaceAll(s, q, `\`+q) + q
+}
@@ -30,7 +30,7 @@ func init() {
 }
 
 type Provisioner interface {
-	CreateServerProperties(dest string, props *model.ServerProperties) error
+	CreateServerProperties(dest string, props *model.PropertiesDocument) error
 	CreateStartScript(dest string, opts ...StartupOption) error
 	CreateStopScript(dest string) error
 	CreateLoggingConfig(dest string, logfileDestDir string) error
@@ -43,18 +43,22 @@ func NewProvisioner() Provisioner {
 	return &vanillaProvisioner{}
 }
 
-func (p *vanillaProvisioner) CreateServerProperties(dest string, props *model.ServerProperties) error {
+// CreateServerProperties writes the server.properties file, merging props into
+// an existing file (if any) so unknown keys, comments and ordering are kept
+func (p *vanillaProvisioner) CreateServerProperties(dest string, props *model.PropertiesDocument) error {
 	destFile := filepath.Join(dest, "server.properties")
-	f, err := os.OpenFile(destFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	doc, err := model.LoadOrNewPropertiesDocument(destFile)
 	if err != nil {
-		return fmt.Errorf("creating server properties file: %w", err)
+		return fmt.Errorf("reading current server properties file: %w", err)
 	}
-	defer func() {
-		_ = f.Close()
-	}()
 
-	if err := properties.NewEncoder(f).Encode(props); err != nil {
-		return fmt.Errorf("encoding server properties: %w", err)
+	for _, k := range props.Keys() {
+		v, _ := props.Get(k)
+		doc.Set(k, v)
+	}
+
+	if err := doc.SaveTo(destFile, 0644); err != nil {
+		return fmt.Errorf("creating server properties file: %w", err)
 	}
 
 	return nil
@@ -1,7 +1,10 @@
 package provisioner
 
 import (
+	"github.com/eldius/mineserver-manager/internal/model"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -81,3 +84,26 @@ func TestScriptParams_ToScript(t *testing.T) {
 		assert.Contains(t, s, "--nogui")
 	})
 }
+
+func TestCreateServerProperties(t *testing.T) {
+	t.Run("given an existing server.properties file should keep unknown keys and comments", func(t *testing.T) {
+		dest := t.TempDir()
+		propsFile := filepath.Join(dest, "server.properties")
+		assert.NoError(t, os.WriteFile(propsFile, []byte("#Minecraft server properties\naccepts-transfers=true\nmotd=Old motd\n"), 0644))
+
+		props := model.NewPropertiesDocument()
+		props.Set("motd", "New motd")
+		props.Set("server-port", "25565")
+
+		err := NewProvisioner().CreateServerProperties(dest, props)
+		assert.Nil(t, err)
+
+		b, err := os.ReadFile(propsFile)
+		assert.Nil(t, err)
+		content := string(b)
+		assert.Contains(t, content, "#Minecraft server properties\n")
+		assert.Contains(t, content, "accepts-transfers=true\n")
+		assert.Contains(t, content, "motd=New motd\n")
+		assert.Contains(t, content, "server-port=25565\n")
+	})
+}
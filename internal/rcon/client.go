@@ -0,0 +1,203 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	packetTypeResponse int32 = 0
+	packetTypeCommand  int32 = 2
+	packetTypeAuth     int32 = 3
+
+	// maxResponseBody is the biggest body a Minecraft server sends in a single packet,
+	// longer responses are split in many packets
+	maxResponseBody = 4096
+	maxPacketSize   = 4096 + 14
+)
+
+var (
+	ErrAuthenticationFailed = errors.New("rcon authentication failed")
+	ErrInvalidPacket        = errors.New("invalid rcon packet")
+)
+
+// Client is a RCON protocol client
+// reference: https://minecraft.wiki/w/RCON
+type Client interface {
+	// Execute runs a command on server console returning its output
+	Execute(ctx context.Context, cmd string) (string, error)
+	// Close closes server connection
+	Close() error
+}
+
+type rconClient struct {
+	conn    net.Conn
+	timeout time.Duration
+	lastID  int32
+	mu      sync.Mutex
+}
+
+// Dial connects and authenticates to a RCON server
+func Dial(ctx context.Context, addr, password string, timeout time.Duration) (Client, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to rcon server: %w", err)
+	}
+	c := &rconClient{
+		conn:    conn,
+		timeout: timeout,
+	}
+
+	if err := c.authenticate(ctx, password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *rconClient) authenticate(ctx context.Context, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextID()
+	if err := c.write(ctx, id, packetTypeAuth, password); err != nil {
+		return fmt.Errorf("sending auth packet: %w", err)
+	}
+	respID, _, _, err := c.read(ctx)
+	if err != nil {
+		return fmt.Errorf("reading auth response: %w", err)
+	}
+	if respID == -1 || respID != id {
+		return ErrAuthenticationFailed
+	}
+	return nil
+}
+
+// Execute runs a command on server console returning its output.
+// A full response packet may be followed by more packets, so the end of
+// the response is found sending an empty packet after it, which the
+// server answers only when it's done with the command.
+func (c *rconClient) Execute(ctx context.Context, cmd string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.nextID()
+	if err := c.write(ctx, id, packetTypeCommand, cmd); err != nil {
+		return "", fmt.Errorf("sending command: %w", err)
+	}
+
+	body, err := c.readResponse(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if len(body) < maxResponseBody {
+		return body, nil
+	}
+
+	// the sentinel is sent only after the first response packet, as the
+	// server drops packets arriving while it reads the command
+	sentinel := c.nextID()
+	if err := c.write(ctx, sentinel, packetTypeResponse, ""); err != nil {
+		return "", fmt.Errorf("sending end of response packet: %w", err)
+	}
+	var out bytes.Buffer
+	out.WriteString(body)
+	for {
+		respID, respType, body, err := c.read(ctx)
+		if err != nil {
+			return "", fmt.Errorf("reading command response: %w", err)
+		}
+		if respID == sentinel {
+			break
+		}
+		if respID != id || respType != packetTypeResponse {
+			return "", fmt.Errorf("unexpected response (id: %d, type: %d): %w", respID, respType, ErrInvalidPacket)
+		}
+		out.WriteString(body)
+	}
+
+	return out.String(), nil
+}
+
+// readResponse reads a single response packet of command id
+func (c *rconClient) readResponse(ctx context.Context, id int32) (string, error) {
+	respID, respType, body, err := c.read(ctx)
+	if err != nil {
+		return "", fmt.Errorf("reading command response: %w", err)
+	}
+	if respID != id || respType != packetTypeResponse {
+		return "", fmt.Errorf("unexpected response (id: %d, type: %d): %w", respID, respType, ErrInvalidPacket)
+	}
+	return body, nil
+}
+
+// Close closes server connection
+func (c *rconClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *rconClient) nextID() int32 {
+	c.lastID++
+	return c.lastID
+}
+
+func (c *rconClient) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+		return ctxDeadline
+	}
+	return d
+}
+
+func (c *rconClient) write(ctx context.Context, id, packetType int32, body string) error {
+	if err := c.conn.SetWriteDeadline(c.deadline(ctx)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(encodePacket(id, packetType, body))
+	return err
+}
+
+func (c *rconClient) read(ctx context.Context) (int32, int32, string, error) {
+	if err := c.conn.SetReadDeadline(c.deadline(ctx)); err != nil {
+		return 0, 0, "", err
+	}
+	return decodePacket(c.conn)
+}
+
+func encodePacket(id, packetType int32, body string) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, int32(len(body)+10))
+	_ = binary.Write(&b, binary.LittleEndian, id)
+	_ = binary.Write(&b, binary.LittleEndian, packetType)
+	b.WriteString(body)
+	b.Write([]byte{0, 0})
+	return b.Bytes()
+}
+
+func decodePacket(r io.Reader) (int32, int32, string, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	}
+	if size < 10 || size > maxPacketSize {
+		return 0, 0, "", fmt.Errorf("packet size %d: %w", size, ErrInvalidPacket)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(payload[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(payload[4:8]))
+	body := bytes.TrimRight(payload[8:], "\x00")
+
+	return id, packetType, string(body), nil
+}
@@ -0,0 +1,138 @@
+package rcon
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeServer starts a RCON server answering commands with handler,
+// splitting responses the way Minecraft servers do
+func startFakeServer(t *testing.T, password string, handler func(cmd string) string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer func() {
+					_ = conn.Close()
+				}()
+				for {
+					id, packetType, body, err := decodePacket(conn)
+					if err != nil {
+						return
+					}
+					switch packetType {
+					case packetTypeAuth:
+						if body != password {
+							id = -1
+						}
+						_, _ = conn.Write(encodePacket(id, packetTypeCommand, ""))
+					case packetTypeCommand:
+						resp := handler(body)
+						for len(resp) > maxResponseBody {
+							_, _ = conn.Write(encodePacket(id, packetTypeResponse, resp[:maxResponseBody]))
+							resp = resp[maxResponseBody:]
+						}
+						_, _ = conn.Write(encodePacket(id, packetTypeResponse, resp))
+					default:
+						_, _ = conn.Write(encodePacket(id, packetTypeResponse, fmt.Sprintf("Unknown request %x", packetType)))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestClient_Execute(t *testing.T) {
+	t.Run("given a valid password should execute commands", func(t *testing.T) {
+		addr := startFakeServer(t, "secret", func(cmd string) string {
+			return "executed: " + cmd
+		})
+
+		c, err := Dial(context.Background(), addr, "secret", time.Second)
+		assert.NoError(t, err)
+		defer func() {
+			_ = c.Close()
+		}()
+
+		out, err := c.Execute(context.Background(), "whitelist reload")
+		assert.NoError(t, err)
+		assert.Equal(t, "executed: whitelist reload", out)
+
+		out, err = c.Execute(context.Background(), "list")
+		assert.NoError(t, err)
+		assert.Equal(t, "executed: list", out)
+	})
+
+	t.Run("given a long response should join every packet", func(t *testing.T) {
+		long := strings.Repeat("a", maxResponseBody*2+10)
+		addr := startFakeServer(t, "secret", func(cmd string) string {
+			return long
+		})
+
+		c, err := Dial(context.Background(), addr, "secret", time.Second)
+		assert.NoError(t, err)
+		defer func() {
+			_ = c.Close()
+		}()
+
+		out, err := c.Execute(context.Background(), "help")
+		assert.NoError(t, err)
+		assert.Equal(t, long, out)
+
+		out, err = c.Execute(context.Background(), "help")
+		assert.NoError(t, err)
+		assert.Equal(t, long, out)
+	})
+
+	t.Run("given a response filling a single packet should not wait for more", func(t *testing.T) {
+		full := strings.Repeat("a", maxResponseBody)
+		addr := startFakeServer(t, "secret", func(cmd string) string {
+			if cmd == "list" {
+				return "executed: list"
+			}
+			return full
+		})
+
+		c, err := Dial(context.Background(), addr, "secret", time.Second)
+		assert.NoError(t, err)
+		defer func() {
+			_ = c.Close()
+		}()
+
+		out, err := c.Execute(context.Background(), "help")
+		assert.NoError(t, err)
+		assert.Equal(t, full, out)
+
+		out, err = c.Execute(context.Background(), "list")
+		assert.NoError(t, err)
+		assert.Equal(t, "executed: list", out)
+	})
+
+	t.Run("given an invalid password should fail to authenticate", func(t *testing.T) {
+		addr := startFakeServer(t, "secret", func(cmd string) string {
+			return ""
+		})
+
+		c, err := Dial(context.Background(), addr, "wrong", time.Second)
+		assert.ErrorIs(t, err, ErrAuthenticationFailed)
+		assert.Nil(t, c)
+	})
+}
@@ -2,13 +2,26 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/eldius/mineserver-manager/internal/model"
 )
 
+var (
+	ErrInstanceNotFound = errors.New("instance not found")
+	ErrBackupNotFound   = errors.New("backup not found")
+)
+
 type Repository interface {
 	SaveInstance(ctx context.Context, i *model.Instance) error
 	GetInstance(ctx context.Context, id string) (*model.Instance, error)
+	GetInstanceByName(ctx context.Context, name string) (*model.Instance, error)
 	ListInstances(ctx context.Context) ([]model.Instance, error)
+	GetInstanceByPath(ctx context.Context, path string) (*model.Instance, error)
 	DeleteInstance(ctx context.Context, id string) error
+	// SaveBackup saves a backup record (the backups catalog)
+	SaveBackup(ctx context.Context, b *model.BackupRecord) error
+	// ListBackups lists the backup records, older first
+	ListBackups(ctx context.Context) ([]model.BackupRecord, error)
+	DeleteBackup(ctx context.Context, id string) error
 	Close() error
 }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/asdine/storm/v3"
 	"github.com/eldius/mineserver-manager/internal/model"
@@ -34,6 +35,17 @@ func (r *stormRepository) GetInstance(ctx context.Context, id string) (*model.In
 	return &i, nil
 }
 
+func (r *stormRepository) GetInstanceByName(ctx context.Context, name string) (*model.Instance, error) {
+	var i model.Instance
+	if err := r.db.One("Name", name, &i); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, fmt.Errorf("getting instance '%s': %w", name, ErrInstanceNotFound)
+		}
+		return nil, fmt.Errorf("getting instance: %w", err)
+	}
+	return &i, nil
+}
+
 func (r *stormRepository) ListInstances(ctx context.Context) ([]model.Instance, error) {
 	var instances []model.Instance
 	if err := r.db.All(&instances); err != nil {
@@ -42,6 +54,17 @@ func (r *stormRepository) ListInstances(ctx context.Context) ([]model.Instance,
 	return instances, nil
 }
 
+func (r *stormRepository) GetInstanceByPath(ctx context.Context, path string) (*model.Instance, error) {
+	var i model.Instance
+	if err := r.db.One("Path", path, &i); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, fmt.Errorf("getting instance on '%s': %w", path, ErrInstanceNotFound)
+		}
+		return nil, fmt.Errorf("getting instance: %w", err)
+	}
+	return &i, nil
+}
+
 func (r *stormRepository) DeleteInstance(ctx context.Context, id string) error {
 	var i model.Instance
 	if err := r.db.One("ID", id, &i); err != nil {
@@ -53,6 +76,35 @@ func (r *stormRepository) DeleteInstance(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *stormRepository) SaveBackup(ctx context.Context, b *model.BackupRecord) error {
+	if err := r.db.Save(b); err != nil {
+		return fmt.Errorf("saving backup: %w", err)
+	}
+	return nil
+}
+
+func (r *stormRepository) ListBackups(ctx context.Context) ([]model.BackupRecord, error) {
+	var backups []model.BackupRecord
+	if err := r.db.AllByIndex("CreatedAt", &backups); err != nil {
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+	return backups, nil
+}
+
+func (r *stormRepository) DeleteBackup(ctx context.Context, id string) error {
+	var b model.BackupRecord
+	if err := r.db.One("ID", id, &b); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return fmt.Errorf("getting backup '%s': %w", id, ErrBackupNotFound)
+		}
+		return fmt.Errorf("finding backup to delete: %w", err)
+	}
+	if err := r.db.DeleteStruct(&b); err != nil {
+		return fmt.Errorf("deleting backup: %w", err)
+	}
+	return nil
+}
+
 func (r *stormRepository) Close() error {
 	return r.db.Close()
 }
@@ -0,0 +1,194 @@
+package retention
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes which backups are kept. A backup is kept when any
+// rule keeps it; an empty policy keeps every backup.
+type Policy struct {
+	// KeepLast keeps the n most recent backups
+	KeepLast int
+	// KeepHourly keeps the most recent backup of each of the last n hours with backups
+	KeepHourly int
+	// KeepDaily keeps the most recent backup of each of the last n days with backups
+	KeepDaily int
+	// KeepWeekly keeps the most recent backup of each of the last n (ISO) weeks with backups
+	KeepWeekly int
+	// KeepMonthly keeps the most recent backup of each of the last n months with backups
+	KeepMonthly int
+	// KeepYearly keeps the most recent backup of each of the last n years with backups
+	KeepYearly int
+	// KeepWithin keeps every backup newer than this duration
+	KeepWithin time.Duration
+	// MaxTotalSize removes the older kept backups of each group (but
+	// the most recent one) while the group kept backups size is bigger
+	// than it
+	MaxTotalSize int64
+}
+
+// Item is a backup evaluated by a policy
+type Item struct {
+	ID string
+	// Group is what is being backed up, rules are applied to each group apart
+	Group string
+	Time  time.Time
+	Size  int64
+}
+
+// Decision tells if an item is kept and the rules that kept
+// (or removed) it
+type Decision struct {
+	Item    Item
+	Keep    bool
+	Reasons []string
+}
+
+// Empty tells if the policy has no rules
+func (p Policy) Empty() bool {
+	return p == Policy{}
+}
+
+type bucketRule struct {
+	name  string
+	count int
+	key   func(t time.Time) string
+}
+
+func (p Policy) bucketRules() []bucketRule {
+	return []bucketRule{
+		{name: "hourly", count: p.KeepHourly, key: func(t time.Time) string { return t.Format("2006-01-02 15h") }},
+		{name: "daily", count: p.KeepDaily, key: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "weekly", count: p.KeepWeekly, key: func(t time.Time) string {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", y, w)
+		}},
+		{name: "monthly", count: p.KeepMonthly, key: func(t time.Time) string { return t.Format("2006-01") }},
+		{name: "yearly", count: p.KeepYearly, key: func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+// Apply evaluates the policy over items, returning the decisions
+// newest first
+func (p Policy) Apply(items []Item, now time.Time) []Decision {
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+
+	type groupState struct {
+		count     int
+		remaining []int
+		lastKey   []string
+	}
+	rules := p.bucketRules()
+	groups := make(map[string]*groupState)
+
+	decisions := make([]Decision, 0, len(sorted))
+	for _, it := range sorted {
+		d := Decision{Item: it}
+		if p.Empty() {
+			d.Keep = true
+			decisions = append(decisions, d)
+			continue
+		}
+
+		g, ok := groups[it.Group]
+		if !ok {
+			g = &groupState{remaining: make([]int, len(rules)), lastKey: make([]string, len(rules))}
+			for i, r := range rules {
+				g.remaining[i] = r.count
+			}
+			groups[it.Group] = g
+		}
+
+		if g.count < p.KeepLast {
+			d.Reasons = append(d.Reasons, fmt.Sprintf("last %d", p.KeepLast))
+		}
+		g.count++
+		if p.KeepWithin > 0 && now.Sub(it.Time) <= p.KeepWithin {
+			d.Reasons = append(d.Reasons, "within "+p.KeepWithin.String())
+		}
+		for i, r := range rules {
+			if g.remaining[i] <= 0 {
+				continue
+			}
+			key := r.key(it.Time)
+			if key == g.lastKey[i] {
+				continue
+			}
+			g.lastKey[i] = key
+			g.remaining[i]--
+			d.Reasons = append(d.Reasons, r.name+" "+key)
+		}
+		d.Keep = len(d.Reasons) > 0
+		decisions = append(decisions, d)
+	}
+
+	if p.MaxTotalSize > 0 {
+		totals := make(map[string]int64)
+		for i := range decisions {
+			if !decisions[i].Keep {
+				continue
+			}
+			group := decisions[i].Item.Group
+			total, kept := totals[group]
+			total += decisions[i].Item.Size
+			if total > p.MaxTotalSize && kept {
+				decisions[i].Keep = false
+				decisions[i].Reasons = []string{"over max total size " + FormatSize(p.MaxTotalSize)}
+				continue
+			}
+			totals[group] = total
+		}
+	}
+	return decisions
+}
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses sizes like '512M', '10GiB' or '1.5GB'
+func ParseSize(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if n, ok := strings.CutSuffix(strings.ToUpper(v), strings.ToUpper(u.suffix)); ok && (u.suffix != "B" || len(n) > 0) {
+			v = strings.TrimSpace(v[:len(n)])
+			unit = u.size
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) || f*float64(unit) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size: '%s'", s)
+	}
+	return int64(f * float64(unit)), nil
+}
+
+// FormatSize returns a human readable size
+func FormatSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,91 @@
+package retention
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func kept(decisions []Decision) []string {
+	var ids []string
+	for _, d := range decisions {
+		if d.Keep {
+			ids = append(ids, d.Item.ID)
+		}
+	}
+	return ids
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	now := time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+	items := []Item{
+		{ID: "a", Group: "survival", Time: time.Date(2024, 10, 15, 3, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "b", Group: "survival", Time: time.Date(2024, 11, 20, 3, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "c", Group: "survival", Time: time.Date(2024, 12, 29, 3, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "d", Group: "survival", Time: time.Date(2024, 12, 30, 3, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "e", Group: "survival", Time: time.Date(2024, 12, 31, 9, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "f", Group: "survival", Time: time.Date(2024, 12, 31, 9, 30, 0, 0, time.UTC), Size: 100},
+		{ID: "g", Group: "survival", Time: time.Date(2024, 12, 31, 11, 0, 0, 0, time.UTC), Size: 100},
+		{ID: "x", Group: "creative", Time: time.Date(2024, 12, 1, 3, 0, 0, 0, time.UTC), Size: 100},
+	}
+
+	t.Run("given an empty policy should keep everything", func(t *testing.T) {
+		assert.Len(t, kept(Policy{}.Apply(items, now)), len(items))
+	})
+
+	t.Run("given keep last should keep the most recent of each group", func(t *testing.T) {
+		d := Policy{KeepLast: 2}.Apply(items, now)
+		assert.Equal(t, []string{"g", "f", "x"}, kept(d))
+		assert.Equal(t, "g", d[0].Item.ID)
+		assert.Equal(t, []string{"last 2"}, d[0].Reasons)
+	})
+
+	t.Run("given fewer backups than keep last should keep all of them", func(t *testing.T) {
+		assert.Len(t, kept(Policy{KeepLast: 20}.Apply(items, now)), len(items))
+	})
+
+	t.Run("given bucket rules should keep the most recent backup of each period", func(t *testing.T) {
+		d := Policy{KeepHourly: 2, KeepDaily: 3, KeepMonthly: 3}.Apply(items, now)
+		assert.Equal(t, []string{"g", "f", "d", "c", "x", "b", "a"}, kept(d))
+		assert.Equal(t, []string{"hourly 2024-12-31 11h", "daily 2024-12-31", "monthly 2024-12"}, d[0].Reasons)
+		assert.Equal(t, []string{"hourly 2024-12-31 09h"}, d[1].Reasons)
+		assert.False(t, d[2].Keep)
+		assert.Equal(t, "e", d[2].Item.ID)
+	})
+
+	t.Run("given weekly and yearly rules should use ISO weeks and years", func(t *testing.T) {
+		d := Policy{KeepWeekly: 2, KeepYearly: 1}.Apply(items, now)
+		assert.Equal(t, []string{"g", "c", "x"}, kept(d))
+		assert.Equal(t, []string{"weekly 2025-W01", "yearly 2024"}, d[0].Reasons)
+	})
+
+	t.Run("given keep within should keep recent backups", func(t *testing.T) {
+		assert.Equal(t, []string{"g", "f", "e", "d"}, kept(Policy{KeepWithin: 48 * time.Hour}.Apply(items, now)))
+	})
+
+	t.Run("given a max total size should remove older kept backups of each group", func(t *testing.T) {
+		d := Policy{KeepLast: 10, MaxTotalSize: 250}.Apply(items, now)
+		assert.Equal(t, []string{"g", "f", "x"}, kept(d))
+		assert.Equal(t, []string{"over max total size 250 B"}, d[2].Reasons)
+		assert.Equal(t, []string{"g", "x"}, kept(Policy{KeepLast: 10, MaxTotalSize: 10}.Apply(items, now)))
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"100":    100,
+		"10B":    10,
+		"2K":     2048,
+		"1.5GiB": 1536 * 1024 * 1024,
+		"20 GB":  20_000_000_000,
+		"512m":   512 * 1024 * 1024,
+	} {
+		got, err := ParseSize(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "GiB", "-1G", "ten", "NaN", "Inf", "+Inf GiB", "1e30"} {
+		_, err := ParseSize(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 12, 30, 10, 17, 42, 0, time.Local) // a monday
+
+	tests := map[string][]time.Time{
+		"*/15 * * * *": {
+			time.Date(2024, 12, 30, 10, 30, 0, 0, time.Local),
+			time.Date(2024, 12, 30, 10, 45, 0, 0, time.Local),
+			time.Date(2024, 12, 30, 11, 0, 0, 0, time.Local),
+		},
+		"0 3 * * *": {
+			time.Date(2024, 12, 31, 3, 0, 0, 0, time.Local),
+			time.Date(2025, 1, 1, 3, 0, 0, 0, time.Local),
+		},
+		"30 2 * * sat,sun": {
+			time.Date(2025, 1, 4, 2, 30, 0, 0, time.Local),
+			time.Date(2025, 1, 5, 2, 30, 0, 0, time.Local),
+			time.Date(2025, 1, 11, 2, 30, 0, 0, time.Local),
+		},
+		"0 0 1 jan-mar/2 *": {
+			time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local),
+			time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local),
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local),
+		},
+		// day of month or day of week
+		"0 12 15 * 3": {
+			time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local),
+			time.Date(2025, 1, 8, 12, 0, 0, 0, time.Local),
+			time.Date(2025, 1, 15, 12, 0, 0, 0, time.Local),
+		},
+		"0 0 * * 7": {
+			time.Date(2025, 1, 5, 0, 0, 0, 0, time.Local),
+		},
+		"@hourly": {
+			time.Date(2024, 12, 30, 11, 0, 0, 0, time.Local),
+			time.Date(2024, 12, 30, 12, 0, 0, 0, time.Local),
+		},
+		"@weekly": {
+			time.Date(2025, 1, 5, 0, 0, 0, 0, time.Local),
+		},
+		"5-10/5 8-9 * * *": {
+			time.Date(2024, 12, 31, 8, 5, 0, 0, time.Local),
+			time.Date(2024, 12, 31, 8, 10, 0, 0, time.Local),
+			time.Date(2024, 12, 31, 9, 5, 0, 0, time.Local),
+		},
+	}
+	for expr, want := range tests {
+		t.Run("given '"+expr+"' should return the next activations", func(t *testing.T) {
+			s, err := ParseCron(expr)
+			require.NoError(t, err)
+			next := base
+			for _, w := range want {
+				next = s.Next(next)
+				assert.Equal(t, w, next)
+			}
+		})
+	}
+
+	t.Run("given an expression that never matches should return zero time", func(t *testing.T) {
+		s, err := ParseCron("0 0 30 2 *")
+		require.NoError(t, err)
+		assert.True(t, s.Next(base).IsZero())
+	})
+
+	t.Run("given invalid expressions should fail", func(t *testing.T) {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@sometimes"} {
+			_, err := ParseCron(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
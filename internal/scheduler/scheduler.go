@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlreadyRunning = errors.New("another run is in progress")
+)
+
+// Job is a scheduled task
+type Job struct {
+	// Name identifies the job on logs and status file
+	Name string
+	// Key groups jobs that must not run at the same time (like
+	// the ones for the same instance), defaults to Name
+	Key      string
+	Schedule Schedule
+	// Jitter delays each run by a random duration up to it, so jobs
+	// scheduled at the same time don't start together
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+func (j Job) key() string {
+	if j.Key == "" {
+		return j.Name
+	}
+	return j.Key
+}
+
+// Scheduler runs jobs on their schedules
+type Scheduler struct {
+	jobs   []Job
+	status *StatusFile
+	now    func() time.Time
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+type SchedulerOpt func(s *Scheduler)
+
+// NewScheduler creates a scheduler for jobs
+func NewScheduler(jobs []Job, opts ...SchedulerOpt) *Scheduler {
+	s := &Scheduler{
+		jobs:    jobs,
+		now:     time.Now,
+		running: make(map[string]bool),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// WithStatusFile records each job runs on f
+func WithStatusFile(f *StatusFile) SchedulerOpt {
+	return func(s *Scheduler) {
+		s.status = f
+	}
+}
+
+// Run runs the jobs until ctx is done, then waits for the running ones
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.jobs) == 0 {
+		return errors.New("no jobs to schedule")
+	}
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		// a previous daemon may have stopped in the middle of a run
+		s.updateStatus(ctx, j.Name, func(st *JobStatus) {
+			st.Running = false
+		})
+		wg.Go(func() {
+			s.loop(ctx, j)
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, j Job) {
+	log := logger.GetLogger().With("job", j.Name)
+	for {
+		next := j.Schedule.Next(s.now())
+		if next.IsZero() {
+			log.WarnContext(ctx, "Job schedule has no next run, stopping it")
+			return
+		}
+		if j.Jitter > 0 {
+			next = next.Add(rand.N(j.Jitter))
+		}
+		s.updateStatus(ctx, j.Name, func(st *JobStatus) {
+			st.NextRun = next
+		})
+		log.With("next_run", next).DebugContext(ctx, "Job scheduled")
+
+		t := time.NewTimer(next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		if err := s.RunJob(ctx, j); errors.Is(err, ErrAlreadyRunning) {
+			log.WarnContext(ctx, "Skipping job run, another run for the same key is in progress")
+		}
+	}
+}
+
+// RunJob runs j now, unless a job with the same key is already running
+// (returning ErrAlreadyRunning). The job result is recorded on status file.
+func (s *Scheduler) RunJob(ctx context.Context, j Job) error {
+	if !s.acquire(j.key()) {
+		return ErrAlreadyRunning
+	}
+	defer s.release(j.key())
+
+	log := logger.GetLogger().With("job", j.Name)
+	start := s.now()
+	s.updateStatus(ctx, j.Name, func(st *JobStatus) {
+		st.Running = true
+		st.LastRun = start
+	})
+	log.InfoContext(ctx, "Job started")
+
+	err := j.Run(ctx)
+
+	end := s.now()
+	s.updateStatus(ctx, j.Name, func(st *JobStatus) {
+		st.Running = false
+		st.LastDuration = end.Sub(start).Round(time.Millisecond).String()
+		st.Runs++
+		if err != nil {
+			st.Failures++
+			st.LastFailure = end
+			st.LastError = err.Error()
+			return
+		}
+		st.LastSuccess = end
+	})
+	if err != nil {
+		log.With("error", err).ErrorContext(ctx, "Job failed")
+		return err
+	}
+	log.InfoContext(ctx, "Job completed")
+	return nil
+}
+
+func (s *Scheduler) acquire(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[key] {
+		return false
+	}
+	s.running[key] = true
+	return true
+}
+
+func (s *Scheduler) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, key)
+}
+
+func (s *Scheduler) updateStatus(ctx context.Context, job string, update func(st *JobStatus)) {
+	if s.status == nil {
+		return
+	}
+	if err := s.status.Update(job, update); err != nil {
+		logger.GetLogger().With("job", job, "error", err).WarnContext(ctx, "Failed to update status file")
+	}
+}
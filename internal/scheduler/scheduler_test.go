@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// everySchedule activates every d
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("given jobs should run them on schedule recording their status", func(t *testing.T) {
+		status := NewStatusFile(filepath.Join(t.TempDir(), "status.json"))
+		var okRuns, failedRuns atomic.Int32
+		s := NewScheduler([]Job{
+			{
+				Name:     "ok",
+				Schedule: everySchedule(20 * time.Millisecond),
+				Jitter:   5 * time.Millisecond,
+				Run: func(ctx context.Context) error {
+					okRuns.Add(1)
+					return nil
+				},
+			},
+			{
+				Name:     "failing",
+				Schedule: everySchedule(20 * time.Millisecond),
+				Run: func(ctx context.Context) error {
+					failedRuns.Add(1)
+					return errors.New("backup failed")
+				},
+			},
+		}, WithStatusFile(status))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+		require.NoError(t, s.Run(ctx))
+
+		assert.GreaterOrEqual(t, okRuns.Load(), int32(2))
+		assert.GreaterOrEqual(t, failedRuns.Load(), int32(2))
+
+		st, err := status.Read()
+		require.NoError(t, err)
+		assert.Equal(t, int(okRuns.Load()), st["ok"].Runs)
+		assert.Zero(t, st["ok"].Failures)
+		assert.False(t, st["ok"].LastSuccess.IsZero())
+		assert.True(t, st["ok"].LastFailure.IsZero())
+		assert.False(t, st["ok"].Running)
+
+		assert.Equal(t, int(failedRuns.Load()), st["failing"].Failures)
+		assert.Equal(t, "backup failed", st["failing"].LastError)
+		assert.True(t, st["failing"].LastSuccess.IsZero())
+		assert.False(t, st["failing"].NextRun.IsZero())
+	})
+
+	t.Run("given jobs with the same key should not run them at the same time", func(t *testing.T) {
+		var running, overlaps, runs atomic.Int32
+		run := func(ctx context.Context) error {
+			if running.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			runs.Add(1)
+			time.Sleep(30 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		}
+		s := NewScheduler([]Job{
+			{Name: "first", Key: "my-server", Schedule: everySchedule(10 * time.Millisecond), Run: run},
+			{Name: "second", Key: "my-server", Schedule: everySchedule(10 * time.Millisecond), Run: run},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+		require.NoError(t, s.Run(ctx))
+		assert.Zero(t, overlaps.Load())
+		assert.Greater(t, runs.Load(), int32(1))
+	})
+
+	t.Run("given a job already running should refuse to run it again", func(t *testing.T) {
+		started := make(chan struct{})
+		done := make(chan struct{})
+		j := Job{Name: "slow", Schedule: everySchedule(time.Hour), Run: func(ctx context.Context) error {
+			close(started)
+			<-done
+			return nil
+		}}
+		s := NewScheduler([]Job{j})
+
+		errs := make(chan error)
+		go func() {
+			errs <- s.RunJob(context.Background(), j)
+		}()
+		<-started
+		assert.ErrorIs(t, s.RunJob(context.Background(), j), ErrAlreadyRunning)
+		close(done)
+		assert.NoError(t, <-errs)
+	})
+
+	t.Run("given no jobs should fail", func(t *testing.T) {
+		assert.Error(t, NewScheduler(nil).Run(context.Background()))
+	})
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JobStatus is a job runs summary
+type JobStatus struct {
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+	LastFailure  time.Time `json:"last_failure,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+}
+
+// Status is the jobs status by job name
+type Status map[string]JobStatus
+
+// StatusFile is a JSON file recording jobs status, so it
+// can be checked (or monitored) while the daemon runs
+type StatusFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewStatusFile creates a status file on path
+func NewStatusFile(path string) *StatusFile {
+	return &StatusFile{path: path}
+}
+
+// Path returns the status file path
+func (f *StatusFile) Path() string {
+	return f.path
+}
+
+// Read reads the jobs status (empty when the file doesn't exist)
+func (f *StatusFile) Read() (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read()
+}
+
+// Update changes a job status
+func (f *StatusFile) Update(job string, update func(st *JobStatus)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status, err := f.read()
+	if err != nil {
+		return err
+	}
+	st := status[job]
+	update(&st)
+	status[job] = st
+
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding status: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), os.ModePerm); err != nil {
+		return fmt.Errorf("creating status folder: %w", err)
+	}
+	// readers must never see a partially written file
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("writing status file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("writing status file: %w", err)
+	}
+	return nil
+}
+
+func (f *StatusFile) read() (Status, error) {
+	status := make(Status)
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading status file: %w", err)
+	}
+	if err := json.Unmarshal(b, &status); err != nil {
+		return nil, fmt.Errorf("parsing status file: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,72 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckResult describes the repository consistency
+type CheckResult struct {
+	Snapshots int
+	Chunks    int
+	// Unreferenced is how many chunks aren't used by any snapshot (removed on prune)
+	Unreferenced int
+	// Errors lists every problem found
+	Errors []string
+}
+
+// OK tells if no problem was found
+func (c CheckResult) OK() bool {
+	return len(c.Errors) == 0
+}
+
+// Check verifies that every chunk referenced by snapshots is stored.
+// When readData is true chunks contents are verified against their hashes.
+func (r *Repository) Check(ctx context.Context, readData bool) (*CheckResult, error) {
+	snaps, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := r.chunks()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		stored[id] = true
+	}
+
+	result := &CheckResult{Snapshots: len(snaps), Chunks: len(ids)}
+	used := make(map[string]bool)
+	for _, s := range snaps {
+		for _, n := range s.Nodes {
+			for _, id := range n.Chunks {
+				if used[id] {
+					continue
+				}
+				used[id] = true
+				if !stored[id] {
+					result.Errors = append(result.Errors, fmt.Sprintf("snapshot %s: '%s': chunk %s is missing", s.ShortID(), n.Path, id))
+				}
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if !used[id] {
+			result.Unreferenced++
+			continue
+		}
+		if !readData {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if _, err := r.readChunk(id); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+package snapshot
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// DefaultMinChunkSize is the smallest chunk cut (except for the last one)
+	DefaultMinChunkSize = 64 * 1024
+	// DefaultMaxChunkSize is the biggest chunk cut
+	DefaultMaxChunkSize = 1024 * 1024
+	// DefaultChunkMaskBits sets the average chunk size (2^bits bytes after the min size)
+	DefaultChunkMaskBits = 18
+
+	// maxEmptyReads is how many reads in a row can return no data (as bufio)
+	maxEmptyReads = 100
+)
+
+// gear is the rolling hash table, it must never change as
+// chunks boundaries (and so deduplication) depend on it
+var gear = newGearTable(0x6d696e6573657276)
+
+// ChunkerConfig are the content defined chunking parameters
+type ChunkerConfig struct {
+	MinSize  int `json:"min_size"`
+	MaxSize  int `json:"max_size"`
+	MaskBits int `json:"mask_bits"`
+}
+
+// DefaultChunkerConfig returns the chunking parameters used by new repositories
+func DefaultChunkerConfig() ChunkerConfig {
+	return ChunkerConfig{
+		MinSize:  DefaultMinChunkSize,
+		MaxSize:  DefaultMaxChunkSize,
+		MaskBits: DefaultChunkMaskBits,
+	}
+}
+
+func (c ChunkerConfig) validate() error {
+	if c.MinSize <= 0 || c.MaxSize < c.MinSize {
+		return errors.New("invalid chunk sizes")
+	}
+	if c.MaskBits <= 0 || c.MaskBits > 63 {
+		return errors.New("invalid chunk mask bits")
+	}
+	return nil
+}
+
+// Chunker splits a stream into content defined chunks using a gear
+// rolling hash, so inserting or removing bytes only changes the
+// chunks around the change.
+type Chunker struct {
+	r    io.Reader
+	cfg  ChunkerConfig
+	mask uint64
+	buf  []byte
+	pos  int
+	end  int
+	eof  bool
+}
+
+// NewChunker creates a chunker reading from r
+func NewChunker(r io.Reader, cfg ChunkerConfig) *Chunker {
+	return &Chunker{
+		r:    r,
+		cfg:  cfg,
+		mask: 1<<cfg.MaskBits - 1,
+		buf:  make([]byte, cfg.MaxSize),
+	}
+}
+
+// Next returns the next chunk or io.EOF after the last one. The returned
+// slice is only valid until the next call.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.end-c.pos < c.cfg.MaxSize && !c.eof {
+		if err := c.fill(); err != nil {
+			return nil, err
+		}
+	}
+	if c.pos == c.end {
+		return nil, io.EOF
+	}
+
+	n := c.cut(c.buf[c.pos:c.end])
+	chunk := c.buf[c.pos : c.pos+n]
+	c.pos += n
+	return chunk, nil
+}
+
+func (c *Chunker) fill() error {
+	c.end = copy(c.buf, c.buf[c.pos:c.end])
+	c.pos = 0
+	empty := 0
+	for c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// readers may return no data without an error, but not forever
+		if n > 0 {
+			empty = 0
+		} else if empty++; empty >= maxEmptyReads {
+			return io.ErrNoProgress
+		}
+	}
+	return nil
+}
+
+// cut returns the size of the chunk at the beginning of data
+func (c *Chunker) cut(data []byte) int {
+	if len(data) <= c.cfg.MinSize {
+		return len(data)
+	}
+	n := min(len(data), c.cfg.MaxSize)
+	var h uint64
+	for i := c.cfg.MinSize; i < n; i++ {
+		h = h<<1 + gear[data[i]]
+		if h&c.mask == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// newGearTable generates the rolling hash table (splitmix64)
+func newGearTable(seed uint64) [256]uint64 {
+	var t [256]uint64
+	for i := range t {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func randomData(seed int64, size int) []byte {
+	b := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+func splitChunks(t *testing.T, data []byte, cfg ChunkerConfig) [][]byte {
+	t.Helper()
+	var chunks [][]byte
+	c := NewChunker(bytes.NewReader(data), cfg)
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if !assert.NoError(t, err) {
+			return chunks
+		}
+		chunks = append(chunks, bytes.Clone(chunk))
+	}
+}
+
+func TestChunker(t *testing.T) {
+	cfg := ChunkerConfig{MinSize: 1024, MaxSize: 16 * 1024, MaskBits: 12}
+
+	t.Run("given some data should split it in chunks inside size limits", func(t *testing.T) {
+		data := randomData(1, 512*1024)
+		chunks := splitChunks(t, data, cfg)
+
+		assert.Greater(t, len(chunks), 1)
+		assert.Equal(t, data, bytes.Join(chunks, nil))
+		for _, c := range chunks[:len(chunks)-1] {
+			assert.GreaterOrEqual(t, len(c), cfg.MinSize)
+			assert.LessOrEqual(t, len(c), cfg.MaxSize)
+		}
+	})
+
+	t.Run("given bytes inserted in the middle of data should keep most chunks", func(t *testing.T) {
+		data := randomData(2, 512*1024)
+		changed := append(bytes.Clone(data[:200*1024]), append([]byte("some inserted bytes"), data[200*1024:]...)...)
+
+		original := make(map[string]bool)
+		for _, c := range splitChunks(t, data, cfg) {
+			original[hashOf(c)] = true
+		}
+		chunks := splitChunks(t, changed, cfg)
+		var reused int
+		for _, c := range chunks {
+			if original[hashOf(c)] {
+				reused++
+			}
+		}
+		assert.GreaterOrEqual(t, reused, len(chunks)-3)
+	})
+
+	t.Run("given empty data should return no chunks", func(t *testing.T) {
+		assert.Empty(t, splitChunks(t, nil, cfg))
+	})
+
+	t.Run("given a reader returning some empty reads should split all data", func(t *testing.T) {
+		data := randomData(3, 64*1024)
+		c := NewChunker(&emptyReadsReader{r: bytes.NewReader(data), empty: 3}, cfg)
+		var read []byte
+		for {
+			chunk, err := c.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			read = append(read, chunk...)
+		}
+		assert.Equal(t, data, read)
+	})
+
+	t.Run("given a reader never returning data should fail", func(t *testing.T) {
+		_, err := NewChunker(&emptyReadsReader{empty: -1}, cfg).Next()
+		assert.ErrorIs(t, err, io.ErrNoProgress)
+	})
+}
+
+// emptyReadsReader returns empty reads (without errors) before each
+// read of r, or forever when empty is negative
+type emptyReadsReader struct {
+	r     io.Reader
+	empty int
+	count int
+}
+
+func (r *emptyReadsReader) Read(p []byte) (int, error) {
+	if r.empty < 0 || r.count < r.empty {
+		r.count++
+		return 0, nil
+	}
+	r.count = 0
+	return r.r.Read(p)
+}
@@ -0,0 +1,112 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"os"
+	"slices"
+	"time"
+)
+
+// PruneResult describes what was removed by a prune
+type PruneResult struct {
+	// Decisions are the retention policy decisions for each snapshot, newest first
+	Decisions []retention.Decision
+	Removed   []Snapshot
+	Chunks    int
+	Bytes     int64
+}
+
+// Prune removes the snapshots not kept by policy (its rules apply to
+// each snapshot name apart) and the chunks no longer referenced. When
+// names are informed only their snapshots are evaluated. When dryRun
+// is true it only evaluates the policy.
+func (r *Repository) Prune(ctx context.Context, policy retention.Policy, dryRun bool, names ...string) (*PruneResult, error) {
+	if policy.MaxTotalSize > 0 {
+		return nil, errors.New("max total size is not supported by backup repositories")
+	}
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	snaps, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]Snapshot, len(snaps))
+	items := make([]retention.Item, 0, len(snaps))
+	for _, s := range snaps {
+		if len(names) > 0 && !slices.Contains(names, s.Name) {
+			continue
+		}
+		byID[s.ID] = s
+		items = append(items, retention.Item{ID: s.ID, Group: s.Name, Time: s.Time, Size: s.Size})
+	}
+
+	result := &PruneResult{Decisions: policy.Apply(items, time.Now())}
+	for _, d := range result.Decisions {
+		if d.Keep {
+			continue
+		}
+		s := byID[d.Item.ID]
+		result.Removed = append(result.Removed, s)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(r.manifestFile(s.ID)); err != nil {
+			return result, fmt.Errorf("removing snapshot %s: %w", s.ShortID(), err)
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	chunks, size, err := r.removeUnreferencedChunks(ctx)
+	result.Chunks = chunks
+	result.Bytes = size
+	return result, err
+}
+
+// removeUnreferencedChunks deletes chunks not used by any snapshot
+func (r *Repository) removeUnreferencedChunks(ctx context.Context) (int, int64, error) {
+	snaps, err := r.Snapshots()
+	if err != nil {
+		return 0, 0, err
+	}
+	used := make(map[string]bool)
+	for _, s := range snaps {
+		for _, n := range s.Nodes {
+			for _, id := range n.Chunks {
+				used[id] = true
+			}
+		}
+	}
+
+	ids, err := r.chunks()
+	if err != nil {
+		return 0, 0, err
+	}
+	var count int
+	var size int64
+	for _, id := range ids {
+		if used[id] {
+			continue
+		}
+		p := r.chunkFile(id)
+		if info, err := os.Stat(p); err == nil {
+			size += info.Size()
+		}
+		if err := os.Remove(p); err != nil {
+			return count, size, fmt.Errorf("removing chunk %s: %w", id, err)
+		}
+		count++
+	}
+	logger.GetLogger().With("action", "snapshot_prune", "repository", r.path, "chunks", count, "bytes", size).
+		DebugContext(ctx, "Unreferenced chunks removed")
+	return count, size, nil
+}
@@ -0,0 +1,399 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"filippo.io/age"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	repositoryVersion = 1
+
+	configFileName  = "config.json"
+	keyFileName     = "key.age"
+	lockFileName    = "lock"
+	chunksFolder    = "chunks"
+	snapshotsFolder = "snapshots"
+)
+
+var (
+	ErrNotARepository   = errors.New("not a backup repository")
+	ErrRepositoryLocked = errors.New("backup repository is locked")
+	ErrNotEncrypted     = errors.New("backup repository is not encrypted (use a new repository for encrypted snapshots)")
+)
+
+// Config is the repository configuration, saved on its root folder
+type Config struct {
+	Version int           `json:"version"`
+	Chunker ChunkerConfig `json:"chunker"`
+	// Recipient is the repository age public key, chunks and snapshot
+	// manifests of encrypted repositories are encrypted to it
+	Recipient string `json:"recipient,omitempty"`
+}
+
+// Repository is a deduplicated backup repository. It keeps file
+// contents split in content defined chunks, stored once by their
+// SHA-256 hash (an HMAC-SHA-256 keyed by the repository key on
+// encrypted repositories), and a manifest for each snapshot listing
+// the files and their chunks.
+type Repository struct {
+	path string
+	cfg  Config
+	// keys protect the repository key of encrypted repositories
+	keys *encryption.Keys
+	// objectKeys encrypt stored objects, and decrypt them once the
+	// repository key is unlocked
+	objectKeys *encryption.Keys
+	// idKey keys the objects IDs of encrypted repositories, so they
+	// don't tell which content is stored (only set once unlocked)
+	idKey []byte
+}
+
+type Opt func(r *Repository)
+
+// WithEncryption defines the keys protecting the repository key.
+// Repositories are created encrypted when keys have recipients, and
+// encrypted repositories are unlocked with keys identities (required
+// to save and read snapshots).
+func WithEncryption(k *encryption.Keys) Opt {
+	return func(r *Repository) {
+		r.keys = k
+	}
+}
+
+// Init creates a new repository on path, or opens it if it already exists
+func Init(path string, opts ...Opt) (*Repository, error) {
+	r, err := Open(path, opts...)
+	if err == nil {
+		if r.keys.CanEncrypt() && r.cfg.Recipient == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNotEncrypted, path)
+		}
+		return r, nil
+	}
+	if !errors.Is(err, ErrNotARepository) {
+		return nil, err
+	}
+
+	for _, dir := range []string{chunksFolder, snapshotsFolder} {
+		if err := os.MkdirAll(filepath.Join(path, dir), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("creating repository folders: %w", err)
+		}
+	}
+	r = &Repository{
+		path: path,
+		cfg: Config{
+			Version: repositoryVersion,
+			Chunker: DefaultChunkerConfig(),
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.keys.CanEncrypt() {
+		if err := r.createKey(); err != nil {
+			return nil, err
+		}
+	}
+	b, err := json.MarshalIndent(r.cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding repository config: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(path, configFileName), b); err != nil {
+		return nil, fmt.Errorf("writing repository config: %w", err)
+	}
+	return r, nil
+}
+
+// Open opens an existing repository
+func Open(path string, opts ...Opt) (*Repository, error) {
+	b, err := os.ReadFile(filepath.Join(path, configFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotARepository, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading repository config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing repository config: %w", err)
+	}
+	if cfg.Version != repositoryVersion {
+		return nil, fmt.Errorf("unsupported repository version: %d", cfg.Version)
+	}
+	if err := cfg.Chunker.validate(); err != nil {
+		return nil, fmt.Errorf("parsing repository config: %w", err)
+	}
+	r := &Repository{path: path, cfg: cfg}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if cfg.Recipient != "" {
+		if err := r.unlock(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// createKey creates the repository key, saving it encrypted with keys
+func (r *Repository) createKey() error {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return fmt.Errorf("creating repository key: %w", err)
+	}
+	var b bytes.Buffer
+	w, err := r.keys.Encrypt(&b)
+	if err != nil {
+		return fmt.Errorf("encrypting repository key: %w", err)
+	}
+	if _, err := io.WriteString(w, id.String()); err != nil {
+		return fmt.Errorf("encrypting repository key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encrypting repository key: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(r.path, keyFileName), b.Bytes()); err != nil {
+		return fmt.Errorf("writing repository key: %w", err)
+	}
+	r.cfg.Recipient = id.Recipient().String()
+	if r.idKey, err = idKeyOf(id.String()); err != nil {
+		return err
+	}
+	r.objectKeys, err = encryption.NewKeys(encryption.Config{Recipients: []string{r.cfg.Recipient}, Identities: []string{id.String()}})
+	return err
+}
+
+// unlock decrypts the repository key with keys identities, without
+// them the repository stays locked
+func (r *Repository) unlock() error {
+	cfg := encryption.Config{Recipients: []string{r.cfg.Recipient}}
+	if r.keys.CanDecrypt() {
+		f, err := os.Open(filepath.Join(r.path, keyFileName))
+		if err != nil {
+			return fmt.Errorf("reading repository key: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		dec, err := r.keys.Decrypt(f)
+		if err != nil {
+			return fmt.Errorf("unlocking repository key: %w", err)
+		}
+		id, err := io.ReadAll(dec)
+		if err != nil {
+			return fmt.Errorf("unlocking repository key: %w", err)
+		}
+		cfg.Identities = []string{strings.TrimSpace(string(id))}
+		if r.idKey, err = idKeyOf(cfg.Identities[0]); err != nil {
+			return err
+		}
+	}
+	keys, err := encryption.NewKeys(cfg)
+	if err != nil {
+		return fmt.Errorf("loading repository key: %w", err)
+	}
+	r.objectKeys = keys
+	return nil
+}
+
+// encrypted tells if the repository objects are encrypted
+func (r *Repository) encrypted() bool {
+	return r.cfg.Recipient != ""
+}
+
+// locked tells if the repository is encrypted and its key isn't unlocked
+func (r *Repository) locked() bool {
+	return r.encrypted() && r.idKey == nil
+}
+
+// idOf returns the object ID of data
+func (r *Repository) idOf(data []byte) string {
+	if r.idKey == nil {
+		return hashOf(data)
+	}
+	h := hmac.New(sha256.New, r.idKey)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idKeyOf derives the objects ID key from the repository key
+func idKeyOf(identity string) ([]byte, error) {
+	k, err := hkdf.Key(sha256.New, []byte(identity), nil, "mineserver-manager snapshot object id", sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("deriving repository id key: %w", err)
+	}
+	return k, nil
+}
+
+// seal encrypts an object of encrypted repositories
+func (r *Repository) seal(data []byte) ([]byte, error) {
+	if !r.encrypted() {
+		return data, nil
+	}
+	var b bytes.Buffer
+	w, err := r.objectKeys.Encrypt(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// unseal decrypts an object of encrypted repositories, which must
+// all be encrypted
+func (r *Repository) unseal(data []byte) ([]byte, error) {
+	if !r.encrypted() {
+		return data, nil
+	}
+	encrypted, rd, err := encryption.IsEncrypted(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return nil, errors.New("object is not encrypted")
+	}
+	dec, err := r.objectKeys.Decrypt(rd)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+// Path returns the repository root folder
+func (r *Repository) Path() string {
+	return r.path
+}
+
+// lock creates the repository lock file, so only one process
+// changes it at a time. The returned func releases the lock.
+func (r *Repository) lock() (func(), error) {
+	p := filepath.Join(r.path, lockFileName)
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%w (remove %s if no other process is using it)", ErrRepositoryLocked, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("locking repository: %w", err)
+	}
+	_, _ = f.WriteString(strconv.Itoa(os.Getpid()))
+	_ = f.Close()
+	return func() {
+		_ = os.Remove(p)
+	}, nil
+}
+
+func (r *Repository) chunkFile(id string) string {
+	return filepath.Join(r.path, chunksFolder, id[:2], id)
+}
+
+func (r *Repository) hasChunk(id string) bool {
+	_, err := os.Stat(r.chunkFile(id))
+	return err == nil
+}
+
+// storeChunk saves data if it's not stored yet, returning its id
+// and whether it was added
+func (r *Repository) storeChunk(data []byte) (string, bool, error) {
+	if r.locked() {
+		return "", false, fmt.Errorf("storing chunk: %w", encryption.ErrNoDecryptionKeys)
+	}
+	id := r.idOf(data)
+	if r.hasChunk(id) {
+		return id, false, nil
+	}
+	sealed, err := r.seal(data)
+	if err != nil {
+		return "", false, fmt.Errorf("encrypting chunk %s: %w", id, err)
+	}
+	p := r.chunkFile(id)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return "", false, fmt.Errorf("creating chunk folder: %w", err)
+	}
+	if err := writeFileAtomic(p, sealed); err != nil {
+		return "", false, fmt.Errorf("writing chunk %s: %w", id, err)
+	}
+	return id, true, nil
+}
+
+// readChunk reads a chunk verifying its content
+func (r *Repository) readChunk(id string) ([]byte, error) {
+	if len(id) < 2 {
+		return nil, fmt.Errorf("invalid chunk id: '%s'", id)
+	}
+	b, err := os.ReadFile(r.chunkFile(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading chunk %s: %w", id, err)
+	}
+	if b, err = r.unseal(b); err != nil {
+		return nil, fmt.Errorf("decrypting chunk %s: %w", id, err)
+	}
+	if r.idOf(b) != id {
+		return nil, fmt.Errorf("chunk %s is corrupted", id)
+	}
+	return b, nil
+}
+
+// chunks lists every stored chunk id
+func (r *Repository) chunks() ([]string, error) {
+	var ids []string
+	root := filepath.Join(r.path, chunksFolder)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && len(d.Name()) == sha256.Size*2 {
+			ids = append(ids, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing chunks: %w", err)
+	}
+	return ids, nil
+}
+
+func hashOf(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// writeFileAtomic writes to a temp file first so an interrupted
+// write never leaves a partial file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/logger"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	NodeFile    = "file"
+	NodeDir     = "dir"
+	NodeSymlink = "symlink"
+
+	// ShortIDLength is how many ID characters are shown to users
+	ShortIDLength = 8
+	// Latest can be used as snapshot ID to refer the most recent one
+	Latest = "latest"
+)
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+)
+
+// Snapshot is a snapshot manifest
+type Snapshot struct {
+	// ID is the manifest SHA-256 hash, keyed on encrypted repositories
+	// like chunk IDs (it's not saved on the manifest)
+	ID   string    `json:"-"`
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	// Size is the snapshot files size
+	Size int64 `json:"size"`
+	// Added is how many bytes were stored on repository by this snapshot
+	Added int64  `json:"added"`
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a snapshot file, folder or symlink
+type Node struct {
+	// Path is the slash separated path relative to the snapshot root
+	Path    string      `json:"path"`
+	Type    string      `json:"type"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	Size    int64       `json:"size,omitempty"`
+	SHA256  string      `json:"sha256,omitempty"`
+	Chunks  []string    `json:"chunks,omitempty"`
+	Target  string      `json:"target,omitempty"`
+}
+
+// ShortID returns the abbreviated snapshot ID
+func (s Snapshot) ShortID() string {
+	if len(s.ID) < ShortIDLength {
+		return s.ID
+	}
+	return s.ID[:ShortIDLength]
+}
+
+// SaveOpts are the snapshot creation options
+type SaveOpts struct {
+	// Name identifies what is being saved (the instance name)
+	Name string
+	// Skip tells if a path (from src) must be left out
+	Skip func(path string, info fs.FileInfo) bool
+}
+
+// Save creates a new snapshot from src folder. Only new chunks are
+// stored and files unchanged since the previous snapshot with the
+// same name (same size and modification time) aren't read again.
+func (r *Repository) Save(ctx context.Context, src string, opts SaveOpts) (*Snapshot, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if r.locked() {
+		return nil, fmt.Errorf("saving snapshot: %w", encryption.ErrNoDecryptionKeys)
+	}
+
+	log := logger.GetLogger().With("action", "snapshot_save", "src", src, "repository", r.path)
+
+	parent := make(map[string]Node)
+	if prev, err := r.latest(opts.Name); err == nil {
+		log = log.With("parent", prev.ShortID())
+		for _, n := range prev.Nodes {
+			parent[n.Path] = n
+		}
+	} else if !errors.Is(err, ErrSnapshotNotFound) {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		Name: opts.Name,
+		Time: time.Now(),
+	}
+	err = filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == src {
+			return nil
+		}
+		if opts.Skip != nil && opts.Skip(path, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		n := Node{
+			Path:    filepath.ToSlash(rel),
+			Mode:    info.Mode().Perm(),
+			ModTime: info.ModTime(),
+		}
+		switch {
+		case info.IsDir():
+			n.Type = NodeDir
+		case info.Mode()&fs.ModeSymlink != 0:
+			n.Type = NodeSymlink
+			if n.Target, err = os.Readlink(path); err != nil {
+				return fmt.Errorf("reading symlink: %w", err)
+			}
+		case info.Mode().IsRegular():
+			n.Type = NodeFile
+			n.Size = info.Size()
+			if p, ok := parent[n.Path]; ok && r.unchanged(p, n) {
+				n.SHA256 = p.SHA256
+				n.Chunks = p.Chunks
+			} else {
+				added, err := r.storeFile(path, &n)
+				if err != nil {
+					return err
+				}
+				snap.Added += added
+			}
+			snap.Size += n.Size
+		default:
+			log.With("path", path, "mode", info.Mode().String()).WarnContext(ctx, "Skipping unsupported file type")
+			return nil
+		}
+		snap.Nodes = append(snap.Nodes, n)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("saving snapshot files: %w", err)
+	}
+
+	if err := r.saveManifest(snap); err != nil {
+		return nil, err
+	}
+	log.With("snapshot", snap.ShortID(), "size", snap.Size, "added", snap.Added).DebugContext(ctx, "Snapshot saved")
+	return snap, nil
+}
+
+// unchanged tells if a file is the same as on the parent snapshot,
+// which chunks must be still stored
+func (r *Repository) unchanged(parent, n Node) bool {
+	if parent.Type != NodeFile || parent.Size != n.Size || !parent.ModTime.Equal(n.ModTime) {
+		return false
+	}
+	for _, id := range parent.Chunks {
+		if !r.hasChunk(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// storeFile splits a file in chunks, storing the new ones. Returns
+// how many bytes were added to repository.
+func (r *Repository) storeFile(path string, n *Node) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var added, size int64
+	h := sha256.New()
+	c := NewChunker(io.TeeReader(f, h), r.cfg.Chunker)
+	for {
+		data, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return added, fmt.Errorf("reading file %s: %w", path, err)
+		}
+		id, stored, err := r.storeChunk(data)
+		if err != nil {
+			return added, err
+		}
+		if stored {
+			added += int64(len(data))
+		}
+		size += int64(len(data))
+		n.Chunks = append(n.Chunks, id)
+	}
+	// the file may have changed after it was listed
+	n.Size = size
+	n.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return added, nil
+}
+
+func (r *Repository) saveManifest(snap *Snapshot) error {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot manifest: %w", err)
+	}
+	snap.ID = r.idOf(b)
+	if b, err = r.seal(b); err != nil {
+		return fmt.Errorf("encrypting snapshot manifest: %w", err)
+	}
+	if err := writeFileAtomic(r.manifestFile(snap.ID), b); err != nil {
+		return fmt.Errorf("writing snapshot manifest: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) manifestFile(id string) string {
+	return filepath.Join(r.path, snapshotsFolder, id+".json")
+}
+
+func (r *Repository) loadManifest(id string) (*Snapshot, error) {
+	b, err := os.ReadFile(r.manifestFile(id))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot manifest: %w", err)
+	}
+	if b, err = r.unseal(b); err != nil {
+		return nil, fmt.Errorf("decrypting snapshot manifest %s: %w", id, err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot manifest %s: %w", id, err)
+	}
+	snap.ID = id
+	return &snap, nil
+}
+
+// Snapshots lists repository snapshots, older first
+func (r *Repository) Snapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(r.path, snapshotsFolder))
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+	var result []Snapshot
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || strings.HasPrefix(id, ".") {
+			continue
+		}
+		snap, err := r.loadManifest(id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *snap)
+	}
+	slices.SortStableFunc(result, func(a, b Snapshot) int {
+		return a.Time.Compare(b.Time)
+	})
+	return result, nil
+}
+
+// Find returns the snapshot identified by id, which can be an ID
+// prefix or Latest (the most recent snapshot)
+func (r *Repository) Find(id string) (*Snapshot, error) {
+	if id == Latest {
+		return r.latest("")
+	}
+	snaps, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	var found *Snapshot
+	for i, s := range snaps {
+		if !strings.HasPrefix(s.ID, id) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("snapshot id '%s' is ambiguous", id)
+		}
+		found = &snaps[i]
+	}
+	if found == nil || id == "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrSnapshotNotFound, id)
+	}
+	return found, nil
+}
+
+// latest returns the most recent snapshot named name (any name when it's empty)
+func (r *Repository) latest(name string) (*Snapshot, error) {
+	snaps, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if name == "" || snaps[i].Name == name {
+			return &snaps[i], nil
+		}
+	}
+	return nil, ErrSnapshotNotFound
+}
+
+// Restore writes snapshot id files to dest folder
+func (r *Repository) Restore(ctx context.Context, id, dest string) (*Snapshot, error) {
+	snap, err := r.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	log := logger.GetLogger().With("action", "snapshot_restore", "snapshot", snap.ShortID(), "dest", dest)
+
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("creating restore folder: %w", err)
+	}
+	var dirs []Node
+	// restored symlinks, nodes below them would be written through the link
+	links := make(map[string]bool)
+	for _, n := range snap.Nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !filepath.IsLocal(filepath.FromSlash(n.Path)) {
+			return nil, fmt.Errorf("invalid snapshot path: '%s'", n.Path)
+		}
+		for parent := path.Dir(n.Path); parent != "."; parent = path.Dir(parent) {
+			if links[parent] {
+				return nil, fmt.Errorf("invalid snapshot path: '%s' is inside symlink '%s'", n.Path, parent)
+			}
+		}
+		p := filepath.Join(dest, filepath.FromSlash(n.Path))
+		log.With("path", n.Path, "type", n.Type).DebugContext(ctx, "Restoring node")
+
+		switch n.Type {
+		case NodeDir:
+			if err := os.MkdirAll(p, os.ModePerm); err != nil {
+				return nil, fmt.Errorf("creating folder: %w", err)
+			}
+			dirs = append(dirs, n)
+			continue
+		case NodeSymlink:
+			if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+				return nil, fmt.Errorf("creating folder: %w", err)
+			}
+			_ = os.Remove(p)
+			if err := os.Symlink(n.Target, p); err != nil {
+				return nil, fmt.Errorf("creating symlink: %w", err)
+			}
+			links[path.Clean(n.Path)] = true
+			continue
+		case NodeFile:
+			if err := r.restoreFile(n, p); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported node type '%s' for '%s'", n.Type, n.Path)
+		}
+		if err := os.Chtimes(p, n.ModTime, n.ModTime); err != nil {
+			return nil, fmt.Errorf("setting file times: %w", err)
+		}
+	}
+
+	// folders are changed while restoring their content, so their
+	// permissions and times are set last (children first)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		p := filepath.Join(dest, filepath.FromSlash(dirs[i].Path))
+		if err := os.Chmod(p, dirs[i].Mode); err != nil {
+			return nil, fmt.Errorf("setting folder permissions: %w", err)
+		}
+		if err := os.Chtimes(p, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return nil, fmt.Errorf("setting folder times: %w", err)
+		}
+	}
+	return snap, nil
+}
+
+func (r *Repository) restoreFile(n Node, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return fmt.Errorf("creating folder: %w", err)
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, n.Mode)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	w := io.MultiWriter(f, h)
+	for _, id := range n.Chunks {
+		data, err := r.readChunk(id)
+		if err != nil {
+			return fmt.Errorf("restoring '%s': %w", n.Path, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != n.SHA256 {
+		return fmt.Errorf("restoring '%s': checksum mismatch", n.Path)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	// an existing file keeps its permissions on OpenFile
+	if err := os.Chmod(dest, n.Mode); err != nil {
+		return fmt.Errorf("setting file permissions: %w", err)
+	}
+	return nil
+}
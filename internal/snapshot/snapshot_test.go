@@ -0,0 +1,326 @@
+package snapshot
+
+import (
+	"context"
+	"filippo.io/age"
+	"github.com/eldius/mineserver-manager/internal/encryption"
+	"github.com/eldius/mineserver-manager/internal/retention"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestTree(t *testing.T, root string) {
+	t.Helper()
+	files := map[string][]byte{
+		"server.properties":      []byte("motd=A Minecraft Server\n"),
+		"world/level.dat":        randomData(3, 3000),
+		"world/region/r.0.0.mca": randomData(4, 3*DefaultMaxChunkSize+12345),
+		"world/region/r.0.1.mca": randomData(5, 200*1024),
+		"logs/latest.log":        []byte("some log\n"),
+		"empty.txt":              nil,
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.NoError(t, os.WriteFile(p, data, 0o644))
+	}
+	require.NoError(t, os.Chmod(filepath.Join(root, "server.properties"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "plugins", "empty"), os.ModePerm))
+	require.NoError(t, os.Symlink("world/level.dat", filepath.Join(root, "level.link")))
+}
+
+type treeEntry struct {
+	Mode    fs.FileMode
+	ModTime time.Time
+	Data    string
+	Target  string
+}
+
+func readTree(t *testing.T, root string) map[string]treeEntry {
+	t.Helper()
+	tree := make(map[string]treeEntry)
+	require.NoError(t, filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		e := treeEntry{Mode: info.Mode(), ModTime: info.ModTime()}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			e.ModTime = time.Time{}
+			e.Target, err = os.Readlink(path)
+		case info.Mode().IsRegular():
+			var b []byte
+			b, err = os.ReadFile(path)
+			e.Data = string(b)
+		}
+		tree[rel] = e
+		return err
+	}))
+	return tree
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("given an instance folder should restore the exact same tree", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+		snap, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		assert.Len(t, snap.ID, 64)
+		assert.Equal(t, snap.Size, snap.Added)
+
+		dest := filepath.Join(t.TempDir(), "restored")
+		restored, err := r.Restore(ctx, snap.ShortID(), dest)
+		require.NoError(t, err)
+		assert.Equal(t, snap.ID, restored.ID)
+		assert.Equal(t, readTree(t, src), readTree(t, dest))
+	})
+
+	t.Run("given unchanged files should not store anything again", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+
+		first, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		// a copy of the instance with a different name must reuse the stored chunks too
+		second, err := r.Save(ctx, src, SaveOpts{Name: "other-server"})
+		require.NoError(t, err)
+		assert.Zero(t, second.Added)
+
+		region := filepath.Join(src, "world", "region", "r.0.0.mca")
+		b, err := os.ReadFile(region)
+		require.NoError(t, err)
+		copy(b[DefaultMaxChunkSize+100:], "changed bytes")
+		require.NoError(t, os.WriteFile(region, b, 0o644))
+
+		third, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		assert.Greater(t, third.Added, int64(0))
+		assert.LessOrEqual(t, third.Added, int64(2*DefaultMaxChunkSize))
+
+		snaps, err := r.Snapshots()
+		require.NoError(t, err)
+		assert.Len(t, snaps, 3)
+
+		dest := t.TempDir()
+		_, err = r.Restore(ctx, first.ID, dest)
+		require.NoError(t, err)
+		restored, err := os.ReadFile(filepath.Join(dest, "world", "region", "r.0.0.mca"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(restored), "changed bytes")
+
+		dest = t.TempDir()
+		_, err = r.Restore(ctx, Latest, dest)
+		require.NoError(t, err)
+		assert.Equal(t, readTree(t, src), readTree(t, dest))
+	})
+
+	t.Run("given a skip function should leave files out", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+
+		snap, err := r.Save(ctx, src, SaveOpts{
+			Name: "my-server",
+			Skip: func(path string, info fs.FileInfo) bool {
+				return info.Name() == "logs" || strings.HasSuffix(path, ".mca")
+			},
+		})
+		require.NoError(t, err)
+		for _, n := range snap.Nodes {
+			assert.NotContains(t, n.Path, "logs")
+			assert.NotContains(t, n.Path, ".mca")
+		}
+	})
+
+	t.Run("given older snapshots should prune them and their chunks", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+
+		first, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(src, "world", "region", "r.0.1.mca"), randomData(6, 200*1024), 0o644))
+		_, err = r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		_, err = r.Save(ctx, src, SaveOpts{Name: "other-server"})
+		require.NoError(t, err)
+
+		result, err := r.Prune(ctx, retention.Policy{KeepLast: 1}, true)
+		require.NoError(t, err)
+		require.Len(t, result.Removed, 1)
+		assert.Zero(t, result.Chunks)
+		_, err = r.Find(first.ID)
+		require.NoError(t, err)
+
+		result, err = r.Prune(ctx, retention.Policy{KeepLast: 1}, false)
+		require.NoError(t, err)
+		require.Len(t, result.Removed, 1)
+		assert.Equal(t, first.ID, result.Removed[0].ID)
+		assert.Greater(t, result.Chunks, 0)
+		assert.Greater(t, result.Bytes, int64(0))
+
+		_, err = r.Find(first.ID)
+		assert.ErrorIs(t, err, ErrSnapshotNotFound)
+
+		check, err := r.Check(ctx, true)
+		require.NoError(t, err)
+		assert.True(t, check.OK(), check.Errors)
+		assert.Equal(t, 2, check.Snapshots)
+		assert.Zero(t, check.Unreferenced)
+
+		_, err = r.Prune(ctx, retention.Policy{MaxTotalSize: 1024}, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("given a damaged repository should report problems on check", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+		snap, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+
+		var chunks []string
+		for _, n := range snap.Nodes {
+			chunks = append(chunks, n.Chunks...)
+		}
+		require.GreaterOrEqual(t, len(chunks), 2)
+		require.NoError(t, os.Remove(r.chunkFile(chunks[0])))
+		require.NoError(t, os.WriteFile(r.chunkFile(chunks[1]), []byte("garbage"), 0o644))
+
+		check, err := r.Check(ctx, false)
+		require.NoError(t, err)
+		assert.Len(t, check.Errors, 1)
+
+		check, err = r.Check(ctx, true)
+		require.NoError(t, err)
+		assert.Len(t, check.Errors, 2)
+
+		_, err = r.Restore(ctx, snap.ID, t.TempDir())
+		assert.Error(t, err)
+	})
+
+	t.Run("given a locked repository should fail to save", func(t *testing.T) {
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+		unlock, err := r.lock()
+		require.NoError(t, err)
+		defer unlock()
+
+		_, err = r.Save(ctx, t.TempDir(), SaveOpts{Name: "my-server"})
+		assert.ErrorIs(t, err, ErrRepositoryLocked)
+	})
+
+	t.Run("given encryption keys should encrypt chunks and manifests", func(t *testing.T) {
+		src := t.TempDir()
+		writeTestTree(t, src)
+		id, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		keys, err := encryption.NewKeys(encryption.Config{Identities: []string{id.String()}, Recipients: []string{id.Recipient().String()}})
+		require.NoError(t, err)
+		publicKeys, err := encryption.NewKeys(encryption.Config{Recipients: []string{id.Recipient().String()}})
+		require.NoError(t, err)
+
+		path := filepath.Join(t.TempDir(), "repo")
+		r, err := Init(path, WithEncryption(keys))
+		require.NoError(t, err)
+		first, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+
+		for _, f := range []string{r.manifestFile(first.ID), r.chunkFile(first.Nodes[len(first.Nodes)-1].Chunks[0])} {
+			b, err := os.ReadFile(f)
+			require.NoError(t, err)
+			encrypted, _, err := encryption.IsEncrypted(strings.NewReader(string(b)))
+			require.NoError(t, err)
+			assert.True(t, encrypted, f)
+		}
+
+		data := []byte("data of a new file")
+		require.NoError(t, os.WriteFile(filepath.Join(src, "new.txt"), data, 0o644))
+
+		// public keys can't unlock the repository
+		r, err = Init(path, WithEncryption(publicKeys))
+		require.NoError(t, err)
+		_, err = r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		assert.ErrorIs(t, err, encryption.ErrNoDecryptionKeys)
+		_, err = r.Snapshots()
+		assert.ErrorIs(t, err, encryption.ErrNoDecryptionKeys)
+
+		r, err = Open(path, WithEncryption(keys))
+		require.NoError(t, err)
+		second, err := r.Save(ctx, src, SaveOpts{Name: "my-server"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), second.Added)
+		// chunk IDs don't tell which content is stored
+		assert.False(t, r.hasChunk(hashOf(data)))
+		check, err := r.Check(ctx, true)
+		require.NoError(t, err)
+		assert.True(t, check.OK(), check.Errors)
+		assert.Equal(t, 2, check.Snapshots)
+		dest := t.TempDir()
+		_, err = r.Restore(ctx, second.ID, dest)
+		require.NoError(t, err)
+		assert.Equal(t, readTree(t, src), readTree(t, dest))
+
+		other, err := age.GenerateX25519Identity()
+		require.NoError(t, err)
+		otherKeys, err := encryption.NewKeys(encryption.Config{Identities: []string{other.String()}})
+		require.NoError(t, err)
+		_, err = Open(path, WithEncryption(otherKeys))
+		assert.Error(t, err)
+	})
+
+	t.Run("given encryption keys and an unencrypted repository should fail to save", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "repo")
+		_, err := Init(path)
+		require.NoError(t, err)
+
+		keys, err := encryption.NewKeys(encryption.Config{Passphrase: "secret"})
+		require.NoError(t, err)
+		_, err = Init(path, WithEncryption(keys))
+		assert.ErrorIs(t, err, ErrNotEncrypted)
+	})
+
+	t.Run("given a snapshot with a path inside a symlink should fail to restore", func(t *testing.T) {
+		r, err := Init(filepath.Join(t.TempDir(), "repo"))
+		require.NoError(t, err)
+		outside := t.TempDir()
+		data := []byte("escaped")
+		id, _, err := r.storeChunk(data)
+		require.NoError(t, err)
+		snap := &Snapshot{
+			Name: "my-server",
+			Time: time.Now(),
+			Nodes: []Node{
+				{Path: "link", Type: NodeSymlink, Target: outside},
+				{Path: "link/x", Type: NodeFile, Mode: 0o644, Size: int64(len(data)), SHA256: hashOf(data), Chunks: []string{id}},
+			},
+		}
+		require.NoError(t, r.saveManifest(snap))
+
+		_, err = r.Restore(ctx, snap.ID, t.TempDir())
+		assert.ErrorContains(t, err, "inside symlink")
+		assert.NoFileExists(t, filepath.Join(outside, "x"))
+	})
+
+	t.Run("given a folder without repository should fail to open", func(t *testing.T) {
+		_, err := Open(t.TempDir())
+		assert.ErrorIs(t, err, ErrNotARepository)
+	})
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is a backup file format
+type ArchiveFormat string
+
+const (
+	FormatZip    ArchiveFormat = "zip"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarZst ArchiveFormat = "tar.zst"
+)
+
+var (
+	ArchiveFormats = []ArchiveFormat{FormatZip, FormatTarGz, FormatTarZst}
+
+	ErrUnknownArchiveFormat = errors.New("unknown backup file format")
+
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseArchiveFormat parses a backup file format name
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	f := ArchiveFormat(strings.TrimPrefix(strings.ToLower(s), "."))
+	if !slices.Contains(ArchiveFormats, f) {
+		return "", fmt.Errorf("%w: '%s' (use one of %v)", ErrUnknownArchiveFormat, s, ArchiveFormats)
+	}
+	return f, nil
+}
+
+// Extension returns the backup files extension for the format
+func (f ArchiveFormat) Extension() string {
+	return "." + string(f)
+}
+
+// DetectArchiveFormat detects a backup file format from its content
+func DetectArchiveFormat(file string) (ArchiveFormat, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading backup file: %w", err)
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, zipMagic):
+		return FormatZip, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return FormatTarZst, nil
+	default:
+		return "", ErrUnknownArchiveFormat
+	}
+}
+
+// archiveEntry is a file packed on a backup archive
+type archiveEntry struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// archiveWriter writes the entries of a backup archive
+type archiveWriter interface {
+	// WriteEntry writes an entry with the content read from r
+	WriteEntry(e archiveEntry, r io.Reader) error
+	Close() error
+}
+
+// newArchiveWriter creates a writer for format using the compression
+// level (0 means the format default level). Tar formats compress the
+// stream with up to workers goroutines.
+func newArchiveWriter(w io.Writer, format ArchiveFormat, level, workers int) (archiveWriter, error) {
+	switch format {
+	case FormatZip, "":
+		if level == 0 {
+			level = flate.DefaultCompression
+		} else if level < flate.BestSpeed || level > flate.BestCompression {
+			return nil, fmt.Errorf("invalid zip compression level %d (use 1 to 9)", level)
+		}
+		zw := zip.NewWriter(w)
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+		return &zipArchiveWriter{w: zw, level: level}, nil
+	case FormatTarGz:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return nil, fmt.Errorf("invalid gzip compression level %d (use 1 to 9)", level)
+		}
+		gw, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip compressor: %w", err)
+		}
+		if err := gw.SetConcurrency(1<<20, max(workers, 1)); err != nil {
+			return nil, fmt.Errorf("creating gzip compressor: %w", err)
+		}
+		return &tarArchiveWriter{w: tar.NewWriter(gw), compressor: gw}, nil
+	case FormatTarZst:
+		zstdLevel := zstd.SpeedDefault
+		if level != 0 {
+			if level < 1 || level > 22 {
+				return nil, fmt.Errorf("invalid zstd compression level %d (use 1 to 22)", level)
+			}
+			zstdLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel), zstd.WithEncoderConcurrency(max(workers, 1)))
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd compressor: %w", err)
+		}
+		return &tarArchiveWriter{w: tar.NewWriter(zw), compressor: zw}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownArchiveFormat, format)
+	}
+}
+
+type zipArchiveWriter struct {
+	w     *zip.Writer
+	level int
+}
+
+func (z *zipArchiveWriter) WriteEntry(e archiveEntry, r io.Reader) error {
+	out, err := z.w.CreateHeader(zipHeader(e))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	return err
+}
+
+// WriteCompressed writes an entry already compressed with Deflate
+func (z *zipArchiveWriter) WriteCompressed(e archiveEntry, crc uint32, compressedSize int64, r io.Reader) error {
+	hdr := zipHeader(e)
+	hdr.CRC32 = crc
+	hdr.CompressedSize64 = uint64(compressedSize)
+	hdr.UncompressedSize64 = uint64(e.Size)
+	out, err := z.w.CreateRaw(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	return err
+}
+
+func zipHeader(e archiveEntry) *zip.FileHeader {
+	hdr := &zip.FileHeader{
+		Name:     e.Name,
+		Method:   zip.Deflate,
+		Modified: e.ModTime,
+	}
+	if e.Mode != 0 {
+		hdr.SetMode(e.Mode)
+	}
+	return hdr
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.w.Close()
+}
+
+type tarArchiveWriter struct {
+	w          *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (t *tarArchiveWriter) WriteEntry(e archiveEntry, r io.Reader) error {
+	mode := e.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     e.Name,
+		Size:     e.Size,
+		Mode:     int64(mode.Perm()),
+		ModTime:  e.ModTime,
+		Format:   tar.FormatPAX,
+	}); err != nil {
+		return err
+	}
+	// the header size was written, a file that grew is cut
+	n, err := io.CopyN(t.w, r, e.Size)
+	if err != nil && !(errors.Is(err, io.EOF) && n == e.Size) {
+		return fmt.Errorf("copying %d of %d bytes: %w", n, e.Size, err)
+	}
+	return nil
+}
+
+func (t *tarArchiveWriter) Close() error {
+	return errors.Join(t.w.Close(), t.compressor.Close())
+}
+
+// readArchive calls fn for each backup archive entry, in the archive
+// order. open returns the entry content, it's only valid during fn.
+func readArchive(file string, fn func(e archiveEntry, open func() (io.ReadCloser, error)) error) error {
+	format, err := DetectArchiveFormat(file)
+	if err != nil {
+		return err
+	}
+	if format == FormatZip {
+		return readZipArchive(file, fn)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var in io.Reader
+	switch format {
+	case FormatTarGz:
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer func() {
+			_ = gr.Close()
+		}()
+		in = gr
+	case FormatTarZst:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("invalid zstd stream: %w", err)
+		}
+		defer zr.Close()
+		in = zr
+	}
+
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar entry: %w", err)
+		}
+		e := archiveEntry{Name: hdr.Name, Size: hdr.Size, Mode: hdr.FileInfo().Mode(), ModTime: hdr.ModTime}
+		if err := fn(e, func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func readZipArchive(file string, fn func(e archiveEntry, open func() (io.ReadCloser, error)) error) error {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return fmt.Errorf("invalid zip central directory: %w", err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	for _, f := range r.File {
+		e := archiveEntry{Name: f.Name, Size: int64(f.UncompressedSize64), Mode: f.Mode(), ModTime: f.Modified}
+		if err := fn(e, f.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	errStopReading = errors.New("stop reading archive")
+)
+
+// PackChecksums reads the SHA-256 hashes of the files packed on a
+// backup file (from its checksums file), by file name
+func PackChecksums(ctx context.Context, file string) (map[string]string, error) {
+	var checksums map[string]string
+	err := readPackEntry(ctx, file, PackChecksumsFileName, func(in io.Reader) error {
+		var err error
+		checksums, err = readPackChecksums(in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// ReadPackFile reads the content of a file packed on a backup file,
+// files not packed fail with os.ErrNotExist
+func ReadPackFile(ctx context.Context, file, name string) ([]byte, error) {
+	var content []byte
+	err := readPackEntry(ctx, file, name, func(in io.Reader) error {
+		var err error
+		content, err = io.ReadAll(in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// readPackEntry calls fn with the content of the backup file entry name
+func readPackEntry(ctx context.Context, file, name string, fn func(in io.Reader) error) error {
+	err := readArchive(file, func(e archiveEntry, open func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Name != name {
+			return nil
+		}
+		in, err := open()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+		if err := fn(in); err != nil {
+			return err
+		}
+		return errStopReading
+	})
+	switch {
+	case errors.Is(err, errStopReading):
+		return nil
+	case err != nil:
+		return fmt.Errorf("reading '%s' from backup file: %w", name, err)
+	default:
+		return fmt.Errorf("reading '%s' from backup file: %w", name, os.ErrNotExist)
+	}
+}
+
+// FileChecksums computes the SHA-256 hashes of the src files PackFiles
+// would pack (only the ignore rules option is used), by file name
+func FileChecksums(ctx context.Context, src string, opts ...PackOpt) (map[string]string, error) {
+	o := &packOptions{ignore: DefaultIgnoreRules()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	files, err := listPackFiles(ctx, src, o.ignore)
+	if err != nil {
+		return nil, fmt.Errorf("listing instance files: %w", err)
+	}
+	checksums := make(map[string]string, len(files))
+	for _, pf := range files {
+		in, err := os.Open(pf.path)
+		if err != nil {
+			return nil, fmt.Errorf("opening instance file: %w", err)
+		}
+		h, err := hashEntry(&ctxReader{ctx: ctx, r: in})
+		_ = in.Close()
+		if err != nil {
+			return nil, fmt.Errorf("hashing '%s': %w", pf.name, err)
+		}
+		checksums[pf.name] = h
+	}
+	return checksums, nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackChecksums(t *testing.T) {
+	ctx := context.Background()
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "world"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "server.properties"), []byte("motd=Hello\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "world", "level.dat"), []byte("level"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "server.log"), []byte("ignored"), 0o644))
+
+	for _, format := range ArchiveFormats {
+		t.Run("given a "+string(format)+" backup file should read the packed files checksums", func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "backup"+format.Extension())
+			require.NoError(t, PackFiles(ctx, src, dest, WithFormat(format)))
+
+			packed, err := PackChecksums(ctx, dest)
+			require.NoError(t, err)
+			live, err := FileChecksums(ctx, src)
+			require.NoError(t, err)
+			assert.Equal(t, live, packed)
+			assert.Len(t, packed, 2)
+
+			content, err := ReadPackFile(ctx, dest, "server.properties")
+			require.NoError(t, err)
+			assert.Equal(t, "motd=Hello\n", string(content))
+
+			_, err = ReadPackFile(ctx, dest, "missing.json")
+			assert.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+
+	t.Run("given a backup file without checksums should fail", func(t *testing.T) {
+		_, err := PackChecksums(ctx, writeTestZip(t, zipEntry{name: "server.properties", data: "motd=Hello\n"}))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// IgnoreFileName is the instance file listing the backup ignore patterns
+	IgnoreFileName = ".mineserverignore"
+)
+
+var (
+	// DefaultIgnorePatterns leave out of backups the logs, crash reports,
+	// the server pid file and everything that is restored by the installer
+	DefaultIgnorePatterns = []string{
+		"*.log",
+		"*.log.gz",
+		"server.pid",
+		"/java/",
+		"/libraries/",
+		"/versions/",
+		"/crash-reports/",
+	}
+)
+
+// IgnoreRules are gitignore-style patterns telling which instance
+// files are left out of backups. Patterns are evaluated in order and
+// the last matching one wins, patterns starting with '!' include the
+// matching files again. Patterns containing a '/' are relative to the
+// instance folder, the others match at any level, and patterns ending
+// with '/' only match folders. '*', '?', '[...]' and '**' wildcards
+// are supported.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// NewIgnoreRules parses the ignore patterns, blank lines and lines
+// starting with '#' are skipped
+func NewIgnoreRules(patterns ...string) (*IgnoreRules, error) {
+	r := &IgnoreRules{}
+	if err := r.Add(patterns...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// DefaultIgnoreRules returns the rules made of DefaultIgnorePatterns
+func DefaultIgnoreRules() *IgnoreRules {
+	return Must(NewIgnoreRules(DefaultIgnorePatterns...))
+}
+
+// WorldOnlyPatterns leave out everything but the world folders
+// (including the nether and the end folders used by Bukkit servers)
+func WorldOnlyPatterns(levelName string) []string {
+	return []string{
+		"/*",
+		"!/" + levelName + "/",
+		"!/" + levelName + "_nether/",
+		"!/" + levelName + "_the_end/",
+	}
+}
+
+// ReadIgnoreFile reads the patterns from an ignore file, a missing
+// file has no patterns
+func ReadIgnoreFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening ignore file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var patterns []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		patterns = append(patterns, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading ignore file: %w", err)
+	}
+	return patterns, nil
+}
+
+// Add appends patterns to the rules
+func (r *IgnoreRules) Add(patterns ...string) error {
+	for _, line := range patterns {
+		p, ok, err := parseIgnorePattern(line)
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	return nil
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool, error) {
+	var p ignorePattern
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false, nil
+	}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// escaped leading '!' or '#'
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return p, false, nil
+	}
+
+	p.segments = strings.Split(line, "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+	for _, s := range p.segments {
+		if _, err := path.Match(s, ""); err != nil {
+			return p, false, fmt.Errorf("invalid ignore pattern '%s': %w", line, err)
+		}
+	}
+	return p, true, nil
+}
+
+// Ignored tells if rel (a path relative to the instance folder) is
+// left out. Files inside ignored folders are ignored too.
+func (r *IgnoreRules) Ignored(rel string, isDir bool) bool {
+	if r == nil {
+		return false
+	}
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(rel)), "/")
+	for i := 1; i < len(segments); i++ {
+		if r.match(segments[:i], true) {
+			return true
+		}
+	}
+	return r.match(segments, isDir)
+}
+
+func (r *IgnoreRules) match(segments []string, isDir bool) bool {
+	ignored := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, segments) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// a trailing '**' matches everything inside, but not the folder itself
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
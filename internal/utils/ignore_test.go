@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	t.Run("given default rules should ignore logs and installer folders", func(t *testing.T) {
+		r := DefaultIgnoreRules()
+		assert.True(t, r.Ignored("logs/latest.log", false))
+		assert.True(t, r.Ignored("logs/2024-01-01-1.log.gz", false))
+		assert.True(t, r.Ignored("server.pid", false))
+		assert.True(t, r.Ignored("libraries/com/lib.jar", false))
+		assert.True(t, r.Ignored("java", true))
+		assert.False(t, r.Ignored("server.properties", false))
+		assert.False(t, r.Ignored("world/level.dat", false))
+		assert.False(t, r.Ignored("world/versions/data.dat", false))
+		assert.False(t, r.Ignored("versions", false))
+	})
+
+	t.Run("given negated patterns should include files again", func(t *testing.T) {
+		r, err := NewIgnoreRules("*.log", "!important.log", "# a comment", "")
+		require.NoError(t, err)
+		assert.True(t, r.Ignored("logs/latest.log", false))
+		assert.False(t, r.Ignored("logs/important.log", false))
+	})
+
+	t.Run("given wildcard patterns should match paths", func(t *testing.T) {
+		r, err := NewIgnoreRules("/world/region/r.*.mca", "**/dynmap/**", "cache?/")
+		require.NoError(t, err)
+		assert.True(t, r.Ignored("world/region/r.0.0.mca", false))
+		assert.False(t, r.Ignored("world/region/other.mca", false))
+		assert.False(t, r.Ignored("other/world/region/r.0.0.mca", false))
+		assert.True(t, r.Ignored("plugins/dynmap/web/index.html", false))
+		assert.False(t, r.Ignored("plugins/dynmap", true))
+		assert.True(t, r.Ignored("plugins/cache1", true))
+		assert.False(t, r.Ignored("plugins/cache1", false))
+	})
+
+	t.Run("given world only patterns should only keep world folders", func(t *testing.T) {
+		r, err := NewIgnoreRules(WorldOnlyPatterns("my_world")...)
+		require.NoError(t, err)
+		assert.False(t, r.Ignored("my_world/level.dat", false))
+		assert.False(t, r.Ignored("my_world_nether/DIM-1/region/r.0.0.mca", false))
+		assert.False(t, r.Ignored("my_world_the_end", true))
+		assert.True(t, r.Ignored("server.properties", false))
+		assert.True(t, r.Ignored("plugins/plugin.jar", false))
+		assert.True(t, r.Ignored("my_world", false))
+	})
+
+	t.Run("given an invalid pattern should fail", func(t *testing.T) {
+		_, err := NewIgnoreRules("world/[")
+		assert.Error(t, err)
+	})
+
+	t.Run("given an ignore file should read its patterns", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), IgnoreFileName)
+		require.NoError(t, os.WriteFile(f, []byte("# backups\n/dynmap/\n!*.conf\n"), 0o644))
+
+		patterns, err := ReadIgnoreFile(f)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"# backups", "/dynmap/", "!*.conf"}, patterns)
+
+		patterns, err = ReadIgnoreFile(filepath.Join(t.TempDir(), IgnoreFileName))
+		require.NoError(t, err)
+		assert.Empty(t, patterns)
+	})
+}
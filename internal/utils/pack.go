@@ -1,149 +1,490 @@
 package utils
 
 import (
-	"archive/zip"
 	"bytes"
+	"compress/flate"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/eldius/mineserver-manager/internal/logger"
+	"hash/crc32"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
-func PackFiles(ctx context.Context, src, dest string) error {
+const (
+	// spoolMemoryLimit is how much of a compressed entry is kept in
+	// memory before spilling it to a temporary file
+	spoolMemoryLimit = 1 << 20
+)
+
+// PackOpt configures how PackFiles packs files
+type PackOpt func(o *packOptions)
+
+type packOptions struct {
+	format   ArchiveFormat
+	level    int
+	ignore   *IgnoreRules
+	workers  int
+	progress func(PackProgress)
+}
+
+// PackProgress tells how far packing is
+type PackProgress struct {
+	Files      int
+	TotalFiles int
+	// Bytes are the packed bytes (before compression)
+	Bytes      int64
+	TotalBytes int64
+	Elapsed    time.Duration
+}
+
+// Throughput returns the packed bytes per second
+func (p PackProgress) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Bytes) / p.Elapsed.Seconds()
+}
+
+// WithFormat sets the backup file format (defaults to zip)
+func WithFormat(f ArchiveFormat) PackOpt {
+	return func(o *packOptions) {
+		o.format = f
+	}
+}
+
+// WithCompressionLevel sets the compression level (1 to 9 for zip
+// and tar.gz, 1 to 22 for tar.zst, 0 uses the format default)
+func WithCompressionLevel(l int) PackOpt {
+	return func(o *packOptions) {
+		o.level = l
+	}
+}
+
+// WithIgnoreRules sets the rules telling which files are left out
+// (defaults to DefaultIgnoreRules)
+func WithIgnoreRules(r *IgnoreRules) PackOpt {
+	return func(o *packOptions) {
+		o.ignore = r
+	}
+}
+
+// WithWorkers sets how many files are compressed at the same time
+// (defaults to GOMAXPROCS)
+func WithWorkers(n int) PackOpt {
+	return func(o *packOptions) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithProgress sets a function called after each file is packed
+func WithProgress(fn func(PackProgress)) PackOpt {
+	return func(o *packOptions) {
+		o.progress = fn
+	}
+}
+
+// PackFiles packs src files to the dest backup file, along with the
+// checksums file. Files are streamed through the compressor and the
+// hasher, zip entries are compressed in parallel (tar formats compress
+// the whole stream in parallel). The partial backup file is removed
+// when packing fails or ctx is done.
+func PackFiles(ctx context.Context, src, dest string, opts ...PackOpt) error {
+	o := &packOptions{
+		format:  FormatZip,
+		ignore:  DefaultIgnoreRules(),
+		workers: runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	log := logger.GetLogger().
 		With(
 			slog.String("action", "pack"),
 			slog.String("src", src),
 			slog.String("dest", dest),
+			slog.String("format", string(o.format)),
+			slog.Int("workers", o.workers),
 		)
 	log.Debug("Starting to pack files")
 
-	return pack(ctx, src, dest)
+	p, err := pack(ctx, src, dest, o)
+	if err != nil {
+		return err
+	}
+	log.With(
+		slog.Int("files", p.Files),
+		slog.Int64("bytes", p.Bytes),
+		slog.Duration("elapsed", p.Elapsed),
+		slog.String("throughput", fmt.Sprintf("%.1f MiB/s", p.Throughput()/(1<<20))),
+	).InfoContext(ctx, "Files packed")
+	return nil
 }
 
-func pack(_ context.Context, src, dest string) error {
-	log := logger.GetLogger().
-		With(
-			slog.String("action", "pack"),
-			slog.String("src", src),
-			slog.String("dest", dest))
+// packFile is an instance file to be packed
+type packFile struct {
+	path string
+	name string
+	info os.FileInfo
+}
+
+// packer writes the packed files to a backup archive, in order
+type packer struct {
+	w        archiveWriter
+	o        *packOptions
+	hashes   bytes.Buffer
+	progress PackProgress
+	start    time.Time
+}
+
+func pack(ctx context.Context, src, dest string, o *packOptions) (_ PackProgress, err error) {
+	files, err := listPackFiles(ctx, src, o.ignore)
+	if err != nil {
+		return PackProgress{}, fmt.Errorf("listing instance files: %w", err)
+	}
 
 	f, err := os.Create(dest)
 	if err != nil {
 		err = fmt.Errorf("opening file to backup (%s): %w", src, err)
-		return err
+		return PackProgress{}, err
 	}
-	w := zip.NewWriter(f)
-
 	defer func() {
-		_ = w.Flush()
-		_ = w.Close()
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(dest)
+		}
 	}()
+	w, err := newArchiveWriter(f, o.format, o.level, o.workers)
+	if err != nil {
+		return PackProgress{}, err
+	}
 
-	javaFolder := filepath.Join(src, "java")
+	p := &packer{w: w, o: o, start: time.Now()}
+	p.progress.TotalFiles = len(files)
+	for _, pf := range files {
+		p.progress.TotalBytes += pf.info.Size()
+	}
 
-	var hashes bytes.Buffer
-	if err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		log := log.With(
-			slog.String("path", path),
-			slog.String("name", info.Name()),
-			slog.Bool("is_dir", info.IsDir()),
-		)
+	if zw, ok := w.(*zipArchiveWriter); ok {
+		err = p.packParallel(ctx, zw, files)
+	} else {
+		err = p.packSequential(ctx, files)
+	}
+	if err != nil {
+		_ = w.Close()
+		return PackProgress{}, err
+	}
 
-		log.Debug("start processing file")
+	entry := archiveEntry{Name: PackChecksumsFileName, Size: int64(p.hashes.Len()), ModTime: time.Now()}
+	if err := w.WriteEntry(entry, &p.hashes); err != nil {
+		_ = w.Close()
+		return PackProgress{}, fmt.Errorf("writing file to backup (%s): %w", src, err)
+	}
+	if err := w.Close(); err != nil {
+		return PackProgress{}, fmt.Errorf("closing backup file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return PackProgress{}, fmt.Errorf("closing backup file: %w", err)
+	}
+	p.progress.Elapsed = time.Since(p.start)
+	return p.progress, nil
+}
 
+// listPackFiles lists the regular files inside src not ignored by rules
+func listPackFiles(ctx context.Context, src string, rules *IgnoreRules) ([]packFile, error) {
+	var files []packFile
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if path == src {
 			return nil
 		}
-		if info.IsDir() {
+		fileName := packedFileName(path, src)
+		if rules.Ignored(fileName, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-
-		if strings.HasSuffix(info.Name(), ".log") || strings.HasSuffix(info.Name(), ".log.gz") {
+		if info.IsDir() || !info.Mode().IsRegular() {
 			return nil
 		}
+		files = append(files, packFile{path: path, name: fileName, info: info})
+		return nil
+	})
+	return files, err
+}
 
-		if strings.HasPrefix(path, javaFolder) {
-			return nil
-		}
+// packed records a file written to the backup archive
+func (p *packer) packed(pf packFile, size int64, sum string) {
+	logger.GetLogger().Debug("File packed", slog.String("file", pf.name), slog.String("path", pf.path), slog.Int64("size", size))
 
-		if strings.HasPrefix(path, filepath.Join(src, "libraries")) {
-			return nil
-		}
+	p.hashes.WriteString(fmt.Sprintf("%s  %s\n", pf.name, sum))
+	p.progress.Files++
+	p.progress.Bytes += size
+	p.progress.Elapsed = time.Since(p.start)
+	if p.o.progress != nil {
+		p.o.progress(p.progress)
+	}
+}
 
-		if strings.HasPrefix(path, filepath.Join(src, "versions")) {
-			return nil
+// packSequential streams each file through the hasher to the archive
+// writer (its compressor may work in parallel)
+func (p *packer) packSequential(ctx context.Context, files []packFile) error {
+	for _, pf := range files {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-
-		if strings.HasPrefix(path, filepath.Join(src, "crash-reports")) {
-			return nil
+		in, err := os.Open(pf.path)
+		if err != nil {
+			return fmt.Errorf("opening file to backup (%s): %w", pf.path, err)
 		}
-
-		if strings.HasPrefix(path, javaFolder) {
-			return nil
+		h := sha256.New()
+		r := &countingReader{r: io.TeeReader(&ctxReader{ctx: ctx, r: in}, h)}
+		entry := archiveEntry{Name: pf.name, Size: pf.info.Size(), Mode: pf.info.Mode(), ModTime: pf.info.ModTime()}
+		err = p.w.WriteEntry(entry, r)
+		_ = in.Close()
+		if err != nil {
+			return fmt.Errorf("writing file to backup (%s): %w", pf.path, err)
 		}
+		p.packed(pf, r.n, hex.EncodeToString(h.Sum(nil)))
+	}
+	return nil
+}
 
-		if info.Name() == "server.pid" {
-			return nil
-		}
+// compressedFile is a file compressed by a packParallel worker
+type compressedFile struct {
+	file packFile
+	size int64
+	crc  uint32
+	sum  string
+	data *spool
+	err  error
+}
 
-		fmt.Printf("- file name: %s\n", info.Name())
-		fmt.Printf("  file path: %s\n", path)
+type compressJob struct {
+	file   packFile
+	result chan<- compressedFile
+}
 
-		fileName := packedFileName(path, src)
-		out, err := w.Create(fileName)
-		if err != nil {
-			err = fmt.Errorf("creating file to backup (%s): %w", path, err)
-			return err
+// packParallel compresses files with a bounded pool of workers, and
+// writes them to the zip archive in order. At most twice the number
+// of workers compressed files are pending to be written.
+func (p *packer) packParallel(ctx context.Context, w *zipArchiveWriter, files []packFile) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan compressJob)
+	pending := make(chan chan compressedFile, p.o.workers)
+	var wg sync.WaitGroup
+	for range p.o.workers {
+		wg.Go(func() {
+			for j := range jobs {
+				j.result <- compressFile(ctx, j.file, w.level)
+			}
+		})
+	}
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for _, pf := range files {
+			result := make(chan compressedFile, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- compressJob{file: pf, result: result}:
+			case <-ctx.Done():
+				return
+			}
 		}
+	}()
 
-		in, err := os.Open(path)
-		if err != nil {
-			err = fmt.Errorf("opening file to backup (%s): %w", path, err)
-			return err
+	err := p.writeCompressed(ctx, w, pending)
+	cancel()
+	wg.Wait()
+	// releases the files compressed after a failure
+	for result := range pending {
+		select {
+		case c := <-result:
+			if c.data != nil {
+				_ = c.data.Close()
+			}
+		default:
 		}
+	}
+	return err
+}
 
-		b, err := io.ReadAll(in)
+func (p *packer) writeCompressed(ctx context.Context, w *zipArchiveWriter, pending <-chan chan compressedFile) error {
+	for result := range pending {
+		var c compressedFile
+		select {
+		case c = <-result:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if c.err != nil {
+			return c.err
+		}
+		entry := archiveEntry{Name: c.file.name, Size: c.size, Mode: c.file.info.Mode(), ModTime: c.file.info.ModTime()}
+		err := w.WriteCompressed(entry, c.crc, c.data.Size(), c.data.Reader())
+		_ = c.data.Close()
 		if err != nil {
-			err = fmt.Errorf("reading file to backup (%s): %w", path, err)
-			return err
+			return fmt.Errorf("writing file to backup (%s): %w", c.file.path, err)
 		}
+		p.packed(c.file, c.size, c.sum)
+	}
+	return ctx.Err()
+}
 
-		log.Debug("copying file to zip")
-		if _, err := out.Write(b); err != nil {
-			err = fmt.Errorf("writing file to zip (%s): %w", path, err)
-			return err
+// compressFile streams a file through the Deflate compressor, the
+// SHA-256 hasher and the CRC-32 checksum at once
+func compressFile(ctx context.Context, pf packFile, level int) (c compressedFile) {
+	c.file = pf
+	if err := ctx.Err(); err != nil {
+		c.err = err
+		return c
+	}
+	in, err := os.Open(pf.path)
+	if err != nil {
+		c.err = fmt.Errorf("opening file to backup (%s): %w", pf.path, err)
+		return c
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	data := &spool{}
+	fw, err := flate.NewWriter(data, level)
+	if err != nil {
+		c.err = fmt.Errorf("creating compressor: %w", err)
+		return c
+	}
+	sha := sha256.New()
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(fw, sha, crc), &ctxReader{ctx: ctx, r: in})
+	if err == nil {
+		err = fw.Close()
+	}
+	if err != nil {
+		_ = data.Close()
+		c.err = fmt.Errorf("compressing file to backup (%s): %w", pf.path, err)
+		return c
+	}
+	c.size = n
+	c.crc = crc.Sum32()
+	c.sum = hex.EncodeToString(sha.Sum(nil))
+	c.data = data
+	return c
+}
+
+// spool buffers written data in memory, spilling it to a temporary
+// file when it grows past spoolMemoryLimit
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func (s *spool) Write(b []byte) (int, error) {
+	if s.file == nil && s.buf.Len()+len(b) > spoolMemoryLimit {
+		f, err := os.CreateTemp("", "mineserver-pack-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.buf.WriteTo(f); err != nil {
+			return 0, err
 		}
-		hashes.WriteString(fmt.Sprintf("%s  %s\n", fileName, shaHash(b)))
+	}
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(b)
+	} else {
+		n, err = s.buf.Write(b)
+	}
+	s.size += int64(n)
+	return n, err
+}
 
-		return nil
-	}); err != nil {
-		err = fmt.Errorf("listing instance files: %w", err)
-		return err
+// Size returns how many bytes were written
+func (s *spool) Size() int64 {
+	return s.size
+}
+
+// Reader returns a reader with the written data
+func (s *spool) Reader() io.Reader {
+	if s.file == nil {
+		return &s.buf
 	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return &errReader{err: err}
+	}
+	return s.file
+}
 
-	hf, err := w.Create("backup.sha256")
-	if err != nil {
-		err = fmt.Errorf("creating file to backup (%s): %w", src, err)
-		return err
+// Close removes the temporary file, if any
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
 	}
-	if _, err := hf.Write(hashes.Bytes()); err != nil {
-		err = fmt.Errorf("writing file to backup (%s): %w", src, err)
-		return err
+	return errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+// ctxReader stops reading when ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.r.Read(b)
+}
 
-	return nil
+// countingReader counts the read bytes
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	return n, err
 }
 
 func packedFileName(path, src string) string {
-	fmt.Printf("  file path: %s\n", path)
 	packedFileName := strings.TrimPrefix(path, src)
 	return strings.TrimPrefix(packedFileName, "/")
 }
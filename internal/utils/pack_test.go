@@ -1,7 +1,14 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,3 +26,125 @@ func TestPackedFileName(t *testing.T) {
 		packedFileName("/home/my-user/mine/sub-folder/server.properties", "/home/my-user"),
 	)
 }
+
+func TestPackFiles(t *testing.T) {
+	ctx := context.Background()
+
+	newInstance := func(t *testing.T) string {
+		src := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "world", "region"), os.ModePerm))
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "logs"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "server.properties"), []byte("motd=My Server\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "world", "level.dat"), []byte("level data"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "world", "region", "r.0.0.mca"), bytes.Repeat([]byte("region"), 1024), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "logs", "latest.log"), []byte("log"), 0o644))
+		return src
+	}
+
+	for _, format := range ArchiveFormats {
+		t.Run("given "+string(format)+" format should pack files that unpack back", func(t *testing.T) {
+			src := newInstance(t)
+			dest := filepath.Join(t.TempDir(), "backup"+format.Extension())
+			require.NoError(t, PackFiles(ctx, src, dest, WithFormat(format), WithCompressionLevel(9)))
+
+			detected, err := DetectArchiveFormat(dest)
+			require.NoError(t, err)
+			assert.Equal(t, format, detected)
+
+			report, err := VerifyPack(ctx, dest)
+			require.NoError(t, err)
+			assert.True(t, report.OK(), report)
+			assert.Equal(t, 3, report.Entries)
+
+			restored := t.TempDir()
+			require.NoError(t, Unpack(ctx, restored, dest))
+			b, err := os.ReadFile(filepath.Join(restored, "world", "region", "r.0.0.mca"))
+			require.NoError(t, err)
+			assert.Equal(t, bytes.Repeat([]byte("region"), 1024), b)
+			assert.NoFileExists(t, filepath.Join(restored, "logs", "latest.log"))
+		})
+	}
+
+	t.Run("given ignore rules should only pack the remaining files", func(t *testing.T) {
+		src := newInstance(t)
+		rules, err := NewIgnoreRules(WorldOnlyPatterns("world")...)
+		require.NoError(t, err)
+		dest := filepath.Join(t.TempDir(), "backup.zip")
+		require.NoError(t, PackFiles(ctx, src, dest, WithIgnoreRules(rules)))
+
+		restored := t.TempDir()
+		require.NoError(t, Unpack(ctx, restored, dest))
+		assert.FileExists(t, filepath.Join(restored, "world", "level.dat"))
+		assert.FileExists(t, filepath.Join(restored, "world", "region", "r.0.0.mca"))
+		assert.NoFileExists(t, filepath.Join(restored, "server.properties"))
+	})
+
+	t.Run("given an invalid compression level should fail", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "backup.tar.gz")
+		assert.Error(t, PackFiles(ctx, newInstance(t), dest, WithFormat(FormatTarGz), WithCompressionLevel(12)))
+	})
+}
+
+func TestParseArchiveFormat(t *testing.T) {
+	f, err := ParseArchiveFormat(".TAR.ZST")
+	require.NoError(t, err)
+	assert.Equal(t, FormatTarZst, f)
+
+	_, err = ParseArchiveFormat("rar")
+	assert.ErrorIs(t, err, ErrUnknownArchiveFormat)
+}
+
+func TestPackFiles_Streaming(t *testing.T) {
+	ctx := context.Background()
+
+	src := t.TempDir()
+	big := make([]byte, 3<<20)
+	_, err := rand.Read(big)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "world", "region"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "world", "region", "r.0.0.mca"), big, 0o644))
+	for i := range 50 {
+		require.NoError(t, os.WriteFile(filepath.Join(src, "world", fmt.Sprintf("file-%02d.dat", i)), bytes.Repeat([]byte{byte(i)}, i*100), 0o644))
+	}
+
+	for _, format := range ArchiveFormats {
+		t.Run("given many files and "+string(format)+" format should pack them with a worker pool", func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "backup"+format.Extension())
+			var last PackProgress
+			calls := 0
+			require.NoError(t, PackFiles(ctx, src, dest, WithFormat(format), WithWorkers(4), WithProgress(func(p PackProgress) {
+				calls++
+				last = p
+			})))
+
+			assert.Equal(t, 51, calls)
+			assert.Equal(t, 51, last.Files)
+			assert.Equal(t, last.TotalFiles, last.Files)
+			assert.Equal(t, last.TotalBytes, last.Bytes)
+			assert.Greater(t, last.Throughput(), 0.0)
+
+			report, err := VerifyPack(ctx, dest)
+			require.NoError(t, err)
+			assert.True(t, report.OK(), report)
+			assert.Equal(t, 51, report.Entries)
+
+			restored := t.TempDir()
+			require.NoError(t, Unpack(ctx, restored, dest))
+			b, err := os.ReadFile(filepath.Join(restored, "world", "region", "r.0.0.mca"))
+			require.NoError(t, err)
+			assert.Equal(t, big, b)
+		})
+	}
+
+	t.Run("given a cancelled context should stop packing and remove the backup file", func(t *testing.T) {
+		for _, format := range ArchiveFormats {
+			cancelled, cancel := context.WithCancel(ctx)
+			dest := filepath.Join(t.TempDir(), "backup"+format.Extension())
+			err := PackFiles(cancelled, src, dest, WithFormat(format), WithProgress(func(p PackProgress) {
+				cancel()
+			}))
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.NoFileExists(t, dest)
+		}
+	})
+}
@@ -1,52 +1,176 @@
 package utils
 
 import (
-	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-func Unpack(_ context.Context, instancePath, backupFile string) error {
-	r, err := zip.OpenReader(backupFile)
+var (
+	ErrUnsafePath = errors.New("unsafe backup entry path")
+)
+
+// UnpackOpt configures which backup entries are unpacked
+type UnpackOpt func(o *unpackOptions)
+
+type unpackOptions struct {
+	only []string
+}
+
+// WithOnly limits unpacking to these paths (files or folders, relative
+// to the instance folder)
+func WithOnly(paths ...string) UnpackOpt {
+	return func(o *unpackOptions) {
+		o.only = append(o.only, paths...)
+	}
+}
+
+func newUnpackOptions(opts ...UnpackOpt) (*unpackOptions, error) {
+	o := &unpackOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	for i, p := range o.only {
+		clean, err := CleanRelativePath(p)
+		if err != nil {
+			return nil, err
+		}
+		o.only[i] = clean
+	}
+	return o, nil
+}
+
+// selected tells if the entry name is inside the only paths
+func (o *unpackOptions) selected(name string) bool {
+	if len(o.only) == 0 {
+		return true
+	}
+	for _, p := range o.only {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanRelativePath cleans a path relative to the instance folder
+// (using '/' as separator), paths escaping the instance folder fail
+func CleanRelativePath(p string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(p))
+	if clean == "." || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("%w: '%s'", ErrUnsafePath, p)
+	}
+	return clean, nil
+}
+
+// ListPack lists the files Unpack would extract from a backup file
+func ListPack(ctx context.Context, backupFile string, opts ...UnpackOpt) ([]string, error) {
+	o, err := newUnpackOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = readArchive(backupFile, func(e archiveEntry, _ func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, err := unpacked(e, o)
+		if ok {
+			files = append(files, e.Name)
+		}
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("opening backup file: %w", err)
+		return nil, fmt.Errorf("listing backup file: %w", err)
 	}
-	defer func() {
-		_ = r.Close()
-	}()
+	return files, nil
+}
 
-	for _, f := range r.File {
-		outFile := filepath.Join(instancePath, f.Name)
+// unpacked tells if an entry is extracted, entries escaping the
+// instance folder fail
+func unpacked(e archiveEntry, o *unpackOptions) (bool, error) {
+	if !filepath.IsLocal(filepath.FromSlash(e.Name)) {
+		return false, fmt.Errorf("%w: '%s'", ErrUnsafePath, e.Name)
+	}
+	if !e.Mode.IsRegular() || e.Name == PackChecksumsFileName {
+		return false, nil
+	}
+	return o.selected(path.Clean(e.Name)), nil
+}
+
+// Unpack extracts a backup file (any of the ArchiveFormats, detected
+// from its content) into instancePath. Files keep their permissions
+// and modification times, the checksums file isn't extracted and
+// entries escaping instancePath fail the whole unpacking.
+func Unpack(ctx context.Context, instancePath, backupFile string, opts ...UnpackOpt) error {
+	o, err := newUnpackOptions(opts...)
+	if err != nil {
+		return err
+	}
+	err = readArchive(backupFile, func(e archiveEntry, open func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ok, err := unpacked(e, o); !ok || err != nil {
+			return err
+		}
+		outFile := filepath.Join(instancePath, filepath.FromSlash(e.Name))
 
 		slog.With(
 			slog.String("instance_path", instancePath),
-			slog.String("backup_file", f.Name),
-			slog.String("current_file", f.Name),
+			slog.String("backup_file", backupFile),
+			slog.String("current_file", e.Name),
 			slog.String("dest_file", outFile),
 		).Debug("UnpackingFile")
 
 		if err := os.MkdirAll(filepath.Dir(outFile), os.ModePerm); err != nil {
 			return fmt.Errorf("mkdirall: %w", err)
 		}
-
-		out, err := os.Create(outFile)
-		if err != nil {
-			return fmt.Errorf("creating output file: %w", err)
-		}
-
-		in, err := f.Open()
+		in, err := open()
 		if err != nil {
 			return fmt.Errorf("opening input file: %w", err)
 		}
+		defer func() {
+			_ = in.Close()
+		}()
+		return writeUnpackedFile(outFile, e, in)
+	})
+	if err != nil {
+		return fmt.Errorf("unpacking backup file: %w", err)
+	}
+	return nil
+}
 
-		if _, err := io.Copy(out, in); err != nil {
-			return fmt.Errorf("writing output file: %w", err)
+func writeUnpackedFile(outFile string, e archiveEntry, in io.Reader) error {
+	mode := e.Mode.Perm()
+	if mode == 0 {
+		mode = 0o644
+	}
+	out, err := os.OpenFile(outFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	// the umask may have dropped some permission bits
+	if err := os.Chmod(outFile, mode); err != nil {
+		return fmt.Errorf("setting output file mode: %w", err)
+	}
+	if !e.ModTime.IsZero() {
+		if err := os.Chtimes(outFile, e.ModTime, e.ModTime); err != nil {
+			return fmt.Errorf("setting output file times: %w", err)
 		}
 	}
-
 	return nil
 }
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnpack(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("given an entry escaping the instance folder should fail", func(t *testing.T) {
+		p := writeTestZip(t,
+			zipEntry{name: "server.properties", data: "motd=My Server\n"},
+			zipEntry{name: "../../evil.sh", data: "rm -rf ~"},
+		)
+		dest := filepath.Join(t.TempDir(), "instance")
+
+		err := Unpack(ctx, dest, p)
+		assert.ErrorIs(t, err, ErrUnsafePath)
+		assert.NoFileExists(t, filepath.Join(dest, "..", "..", "evil.sh"))
+
+		_, err = ListPack(ctx, p)
+		assert.ErrorIs(t, err, ErrUnsafePath)
+	})
+
+	t.Run("given a packed instance should keep file modes and skip the checksums file", func(t *testing.T) {
+		src := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(src, "start.sh"), []byte("#!/bin/sh\n"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "server.properties"), []byte("motd=My Server\n"), 0o600))
+
+		for _, format := range ArchiveFormats {
+			dest := filepath.Join(t.TempDir(), "backup"+format.Extension())
+			require.NoError(t, PackFiles(ctx, src, dest, WithFormat(format)))
+
+			restored := t.TempDir()
+			require.NoError(t, Unpack(ctx, restored, dest))
+			info, err := os.Stat(filepath.Join(restored, "start.sh"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o755), info.Mode().Perm(), format)
+			info, err = os.Stat(filepath.Join(restored, "server.properties"))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), format)
+			assert.NoFileExists(t, filepath.Join(restored, PackChecksumsFileName))
+		}
+	})
+
+	t.Run("given only paths should unpack just them", func(t *testing.T) {
+		p := writeTestZip(t,
+			zipEntry{name: "server.properties", data: "motd=My Server\n"},
+			zipEntry{name: "world/level.dat", data: "level data"},
+			zipEntry{name: "world_nether/level.dat", data: "nether data"},
+			zipEntry{name: "plugins/plugin.jar", data: "plugin"},
+		)
+
+		files, err := ListPack(ctx, p, WithOnly("world/", "./server.properties"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"server.properties", "world/level.dat"}, files)
+
+		restored := t.TempDir()
+		require.NoError(t, Unpack(ctx, restored, p, WithOnly("world/", "./server.properties")))
+		assert.FileExists(t, filepath.Join(restored, "server.properties"))
+		assert.FileExists(t, filepath.Join(restored, "world", "level.dat"))
+		assert.NoDirExists(t, filepath.Join(restored, "world_nether"))
+		assert.NoDirExists(t, filepath.Join(restored, "plugins"))
+
+		assert.ErrorIs(t, Unpack(ctx, restored, p, WithOnly("../other")), ErrUnsafePath)
+	})
+}
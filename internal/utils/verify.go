@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// PackChecksumsFileName is the file listing the packed files SHA-256 hashes
+	PackChecksumsFileName = "backup.sha256"
+)
+
+// PackReport is a backup file verification result
+type PackReport struct {
+	File string
+	// Entries is how many files were verified
+	Entries int
+	// Missing are files listed on checksums file but not packed
+	Missing []string
+	// Extra are files packed but not listed on checksums file
+	Extra []string
+	// Corrupt are files which content doesn't match (with the reason)
+	Corrupt []string
+	// Errors are backup file level problems
+	Errors []string
+}
+
+// OK tells if no problem was found
+func (r PackReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Corrupt) == 0 && len(r.Errors) == 0
+}
+
+// VerifyPack checks a backup file written by PackFiles: its structure
+// (the zip central directory or the compressed tar stream), every entry
+// CRC (zip only) and hash (against the checksums file). Problems are
+// reported, the error is only returned when ctx is done.
+func VerifyPack(ctx context.Context, file string) (*PackReport, error) {
+	report := &PackReport{File: file}
+
+	var expected map[string]string
+	hashes := make(map[string]string)
+	seen := make(map[string]bool)
+	err := readArchive(file, func(e archiveEntry, open func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if seen[e.Name] {
+			report.Errors = append(report.Errors, fmt.Sprintf("duplicated entry: '%s'", e.Name))
+			return nil
+		}
+		seen[e.Name] = true
+		if !filepath.IsLocal(filepath.FromSlash(e.Name)) {
+			report.Errors = append(report.Errors, fmt.Sprintf("unsafe entry path: '%s'", e.Name))
+			return nil
+		}
+		if e.Mode.IsDir() {
+			return nil
+		}
+
+		in, err := open()
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, fmt.Sprintf("%s: %v", e.Name, err))
+			hashes[e.Name] = ""
+			return nil
+		}
+		defer func() {
+			_ = in.Close()
+		}()
+
+		if e.Name == PackChecksumsFileName {
+			expected, err = readPackChecksums(in)
+			if err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			}
+			return nil
+		}
+
+		// reading until EOF also validates the entry CRC-32
+		h, err := hashEntry(in)
+		if err != nil {
+			report.Corrupt = append(report.Corrupt, fmt.Sprintf("%s: %v", e.Name, err))
+			hashes[e.Name] = ""
+			return nil
+		}
+		hashes[e.Name] = h
+		return nil
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+		return report, err
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+
+	if expected == nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("checksums file '%s' not found", PackChecksumsFileName))
+		return report, nil
+	}
+
+	for name, h := range hashes {
+		report.Entries++
+		want, ok := expected[name]
+		switch {
+		case !ok:
+			report.Extra = append(report.Extra, name)
+		case h != "" && h != want:
+			report.Corrupt = append(report.Corrupt, fmt.Sprintf("%s: checksum mismatch", name))
+		}
+	}
+	for name := range expected {
+		if _, ok := hashes[name]; !ok {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Corrupt)
+	return report, nil
+}
+
+func hashEntry(in io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readPackChecksums parses the checksums file ("<name>  <sha256>" lines)
+func readPackChecksums(in io.Reader) (map[string]string, error) {
+	result := make(map[string]string)
+	s := bufio.NewScanner(in)
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		idx := strings.LastIndex(line, "  ")
+		if idx < 0 {
+			return result, fmt.Errorf("invalid checksums file line: '%s'", line)
+		}
+		result[line[:idx]] = line[idx+2:]
+	}
+	if err := s.Err(); err != nil {
+		return result, fmt.Errorf("reading checksums file: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type zipEntry struct {
+	name string
+	data string
+}
+
+func writeTestZip(t *testing.T, entries ...zipEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "backup.zip")
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		out, err := w.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Store})
+		require.NoError(t, err)
+		_, err = out.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+	return p
+}
+
+func TestVerifyPack(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("given a packed instance should report no problems", func(t *testing.T) {
+		src := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "world"), os.ModePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "server.properties"), []byte("motd=My Server\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "world", "level.dat"), []byte("level data"), 0o644))
+		dest := filepath.Join(t.TempDir(), "backup.zip")
+		require.NoError(t, PackFiles(ctx, src, dest))
+
+		report, err := VerifyPack(ctx, dest)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), report)
+		assert.Equal(t, 2, report.Entries)
+	})
+
+	t.Run("given files not matching checksums should report them", func(t *testing.T) {
+		p := writeTestZip(t,
+			zipEntry{name: "server.properties", data: "motd=Changed\n"},
+			zipEntry{name: "extra.txt", data: "extra"},
+			zipEntry{name: PackChecksumsFileName, data: "server.properties  " + shaHash([]byte("motd=My Server\n")) + "\n" +
+				"world/level.dat  " + shaHash([]byte("level data")) + "\n"},
+		)
+
+		report, err := VerifyPack(ctx, p)
+		require.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Equal(t, []string{"world/level.dat"}, report.Missing)
+		assert.Equal(t, []string{"extra.txt"}, report.Extra)
+		assert.Equal(t, []string{"server.properties: checksum mismatch"}, report.Corrupt)
+	})
+
+	t.Run("given a damaged entry should report its crc error", func(t *testing.T) {
+		p := writeTestZip(t,
+			zipEntry{name: "server.properties", data: "motd=My Server\n"},
+			zipEntry{name: PackChecksumsFileName, data: "server.properties  " + shaHash([]byte("motd=My Server\n")) + "\n"},
+		)
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+		idx := bytes.Index(b, []byte("motd=My Server"))
+		require.Greater(t, idx, 0)
+		b[idx] = 'M'
+		require.NoError(t, os.WriteFile(p, b, 0o644))
+
+		report, err := VerifyPack(ctx, p)
+		require.NoError(t, err)
+		require.Len(t, report.Corrupt, 1)
+		assert.Contains(t, report.Corrupt[0], "checksum error")
+	})
+
+	t.Run("given a truncated file should report an invalid central directory", func(t *testing.T) {
+		p := writeTestZip(t, zipEntry{name: "server.properties", data: "motd=My Server\n"})
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(p, b[:len(b)/2], 0o644))
+
+		report, err := VerifyPack(ctx, p)
+		require.NoError(t, err)
+		require.Len(t, report.Errors, 1)
+		assert.Contains(t, report.Errors[0], "central directory")
+	})
+
+	t.Run("given a backup without checksums should report it", func(t *testing.T) {
+		report, err := VerifyPack(ctx, writeTestZip(t, zipEntry{name: "server.properties", data: "motd"}))
+		require.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Contains(t, report.Errors[0], PackChecksumsFileName)
+	})
+}